@@ -2,9 +2,11 @@ package automod
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"sort"
+	"time"
 
 	"github.com/jonas747/discordgo"
 	"github.com/jonas747/dstate"
@@ -16,6 +18,7 @@ import (
 	"github.com/jonas747/yagpdb/common"
 	"github.com/jonas747/yagpdb/common/scheduledevents2"
 	schEventsModels "github.com/jonas747/yagpdb/common/scheduledevents2/models"
+	"github.com/jonas747/yagpdb/moderation"
 	"github.com/volatiletech/null"
 	"github.com/volatiletech/sqlboiler/boil"
 	"github.com/volatiletech/sqlboiler/queries/qm"
@@ -30,6 +33,23 @@ func (p *Plugin) BotInit() {
 	eventsystem.AddHandlerAsyncLastLegacy(p, p.handleGuildMemberJoin, eventsystem.EventGuildMemberAdd)
 
 	scheduledevents2.RegisterHandler("amod2_reset_channel_ratelimit", ResetChannelRatelimitData{}, handleResetChannelRatelimit)
+
+	moderation.RegisterRecentViolationFunc(recentViolation)
+}
+
+// recentViolation returns the name of the most recent violation of the user within maxAge, used by the moderation commands
+func recentViolation(guildID, userID int64, maxAge time.Duration) (string, error) {
+	violation, err := models.AutomodViolations(
+		qm.Where("guild_id = ? AND user_id = ? AND created_at > ?", guildID, userID, time.Now().Add(-maxAge)),
+		qm.OrderBy("id desc")).OneG(context.Background())
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+		return "", err
+	}
+
+	return violation.Name, nil
 }
 
 type ResetChannelRatelimitData struct {
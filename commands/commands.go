@@ -30,6 +30,12 @@ var (
 // These functions are called on every message, and should return true if the message should be checked for commands, false otherwise
 var MessageFilterFuncs []MessageFilterFunc
 
+type MessageRewriteFunc func(msg *discordgo.Message) *discordgo.Message
+
+// These functions are called in order on messages that passed all the filters, and can return a modified copy
+// of the message to look for commands in instead, or nil to leave it as is
+var MessageRewriteFuncs []MessageRewriteFunc
+
 type Plugin struct{}
 
 func (p *Plugin) PluginInfo() *common.PluginInfo {
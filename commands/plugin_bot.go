@@ -276,7 +276,13 @@ func handleMsgCreate(evt *eventsystem.EventData) {
 		return
 	}
 
-	CommandSystem.HandleMessageCreate(common.BotSession, evt.MessageCreate())
+	for _, rewriteFunc := range MessageRewriteFuncs {
+		if rewritten := rewriteFunc(m.Message); rewritten != nil {
+			m = &discordgo.MessageCreate{Message: rewritten}
+		}
+	}
+
+	CommandSystem.HandleMessageCreate(common.BotSession, m)
 }
 
 func (p *Plugin) Prefix(data *dcmd.Data) string {
@@ -0,0 +1,109 @@
+package moderation
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/jonas747/discordgo"
+	"github.com/jonas747/yagpdb/common"
+	"github.com/jonas747/yagpdb/common/pubsub"
+	"github.com/mediocregopher/radix/v3"
+)
+
+// Every action is published as a "moderation_action" pubsub event for live views like the control panel,
+// the most recent ones are also kept in redis so a view has something to show when it's opened.
+
+// The number of recent actions kept per server
+const MaxRecentActions = 50
+
+// How long the recent actions of a server are kept after the last action
+const RecentActionsTTL = time.Hour * 24 * 7
+
+func RedisKeyRecentActions(guildID int64) string {
+	return "moderation_recent_actions:" + strconv.FormatInt(guildID, 10)
+}
+
+// ModActionEvent is the payload of the moderation_action pubsub event
+type ModActionEvent struct {
+	Time    time.Time `json:"time"`
+	Action  string    `json:"action"`
+	Details string    `json:"details,omitempty"`
+	Reason  string    `json:"reason"`
+
+	TargetID int64  `json:"target_id,string,omitempty"`
+	Target   string `json:"target,omitempty"`
+
+	ModeratorID int64  `json:"moderator_id,string,omitempty"`
+	Moderator   string `json:"moderator,omitempty"`
+}
+
+func newModActionEvent(author *discordgo.User, action ModlogAction, target *discordgo.User, reason string, t time.Time) *ModActionEvent {
+	evt := &ModActionEvent{
+		Time:    t,
+		Action:  strings.TrimSpace(action.Emoji + action.Prefix),
+		Details: action.Footer,
+		Reason:  reason,
+	}
+
+	if author != nil {
+		evt.ModeratorID = author.ID
+		evt.Moderator = author.Username + "#" + author.Discriminator
+	}
+
+	if target != nil {
+		evt.TargetID = target.ID
+		evt.Target = target.Username + "#" + target.Discriminator
+	}
+
+	return evt
+}
+
+// publishModAction publishes the action to the live views and adds it to the recent actions,
+// failing to do so doesn't stop the action so errors are only logged
+func publishModAction(guildID int64, author *discordgo.User, action ModlogAction, target *discordgo.User, reason string) {
+	evt := newModActionEvent(author, action, target, reason, time.Now())
+
+	err := pubsub.Publish("moderation_action", guildID, evt)
+	if err != nil {
+		logger.WithError(err).WithField("guild", guildID).Error("failed publishing moderation action")
+	}
+
+	encoded, err := json.Marshal(evt)
+	if err != nil {
+		logger.WithError(err).WithField("guild", guildID).Error("failed encoding moderation action")
+		return
+	}
+
+	key := RedisKeyRecentActions(guildID)
+	err = common.RedisPool.Do(radix.Pipeline(
+		radix.Cmd(nil, "LPUSH", key, string(encoded)),
+		radix.Cmd(nil, "LTRIM", key, "0", strconv.Itoa(MaxRecentActions-1)),
+		radix.FlatCmd(nil, "EXPIRE", key, int(RecentActionsTTL.Seconds())),
+	))
+	if err != nil {
+		logger.WithError(err).WithField("guild", guildID).Error("failed storing recent moderation action")
+	}
+}
+
+// RecentActions returns the most recent actions of the server, newest first
+func RecentActions(guildID int64) ([]*ModActionEvent, error) {
+	var raw []string
+	err := common.RedisPool.Do(radix.Cmd(&raw, "LRANGE", RedisKeyRecentActions(guildID), "0", "-1"))
+	if err != nil {
+		return nil, errors.WithStackIf(err)
+	}
+
+	result := make([]*ModActionEvent, 0, len(raw))
+	for _, v := range raw {
+		var evt ModActionEvent
+		if err := json.Unmarshal([]byte(v), &evt); err != nil {
+			continue
+		}
+		result = append(result, &evt)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,60 @@
+package moderation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jonas747/discordgo"
+	"github.com/jonas747/yagpdb/common"
+	"github.com/mediocregopher/radix/v3"
+)
+
+func TestNewModActionEvent(t *testing.T) {
+	author := &discordgo.User{ID: 3, Username: "mod", Discriminator: "0001"}
+	target := &discordgo.User{ID: 4, Username: "user", Discriminator: "0002"}
+
+	action := MAMute
+	action.Footer = "Duration: 1 hour"
+
+	evt := newModActionEvent(author, action, target, "spam", time.Now())
+	if evt.Action != "🔇Muted" || evt.Details != "Duration: 1 hour" || evt.Reason != "spam" {
+		t.Errorf("unexpected action details: %+v", evt)
+	}
+	if evt.ModeratorID != 3 || evt.Moderator != "mod#0001" || evt.TargetID != 4 || evt.Target != "user#0002" {
+		t.Errorf("unexpected moderator or target: %+v", evt)
+	}
+
+	// mass actions have no target, and the author of some entries isn't known yet
+	evt = newModActionEvent(nil, MAMute, nil, "", time.Now())
+	if evt.ModeratorID != 0 || evt.TargetID != 0 {
+		t.Errorf("expected no moderator or target: %+v", evt)
+	}
+}
+
+func TestRecentActions(t *testing.T) {
+	if common.RedisPool == nil {
+		t.Skip("redis not available, skipping.")
+		return
+	}
+
+	const guildID = 1
+	defer common.RedisPool.Do(radix.Cmd(nil, "DEL", RedisKeyRecentActions(guildID)))
+
+	target := &discordgo.User{ID: 4, Username: "user", Discriminator: "0002"}
+	for i := 0; i < MaxRecentActions+5; i++ {
+		publishModAction(guildID, nil, MAKick, target, "spam")
+	}
+	publishModAction(guildID, nil, MABanned, target, "more spam")
+
+	actions, err := RecentActions(guildID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(actions) != MaxRecentActions {
+		t.Errorf("expected %d recent actions, got %d", MaxRecentActions, len(actions))
+	}
+	if len(actions) > 0 && actions[0].Reason != "more spam" {
+		t.Errorf("expected the newest action first, got %+v", actions[0])
+	}
+}
@@ -0,0 +1,171 @@
+package moderation
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/jonas747/dcmd"
+	"github.com/jonas747/discordgo"
+	"github.com/jonas747/dstate"
+	"github.com/jonas747/yagpdb/bot"
+	"github.com/jonas747/yagpdb/commands"
+	"github.com/jonas747/yagpdb/common"
+)
+
+// The max number of linked accounts a single command acts on
+const MaxAltTargets = 10
+
+// AltLookupFunc returns the accounts known to belong to the same person as the user in the guild
+type AltLookupFunc func(guildID, userID int64) ([]int64, error)
+
+// AltLookupFuncs are consulted for the linked accounts of a user when using -alts, moderation doesn't track alts itself
+// so features that do (or store associations) add themselves here
+var AltLookupFuncs []AltLookupFunc
+
+// linkedAccounts returns the linked accounts of the user from all the lookups, without duplicates or the user themselves
+func linkedAccounts(guildID, userID int64) ([]int64, error) {
+	var result []int64
+	for _, f := range AltLookupFuncs {
+		alts, err := f(guildID, userID)
+		if err != nil {
+			return nil, errors.WithStackIf(err)
+		}
+
+		for _, v := range alts {
+			if v != userID && !common.ContainsInt64Slice(result, v) {
+				result = append(result, v)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// altScopeMessage asks the moderator to confirm acting on the user and all their linked accounts,
+// staff holds the names of the staff roles of the linked accounts that have one
+func altScopeMessage(verb string, target *discordgo.User, alts []int64, staff map[int64]string, skipped int) string {
+	ids := make([]string, len(alts))
+	for i, v := range alts {
+		ids[i] = fmt.Sprintf("`%d`", v)
+		if role, ok := staff[v]; ok {
+			ids[i] += fmt.Sprintf(" (⚠ staff role **%s**)", role)
+		}
+	}
+
+	resp := fmt.Sprintf("This will %s %s#%s and their %d linked account(s): %s.",
+		verb, target.Username, target.Discriminator, len(alts), strings.Join(ids, ", "))
+	if skipped > 0 {
+		resp += fmt.Sprintf(" %d linked account(s) you can't act on are skipped.", skipped)
+	}
+
+	return resp + " Run the command again with `-confirm` to proceed."
+}
+
+// filterAltTargets returns the linked accounts the author can act on. Like with mass actions, bots, the author,
+// members ranked the same or higher than them and staff are skipped. Staff are only kept if the server has staff bans
+// confirmed instead (ConfirmBanStaff), with the name of their staff role in staff so the moderator knows what they confirm.
+// Linked accounts that aren't in the server can't be checked and are kept.
+func filterAltTargets(config *Config, gs *dstate.GuildState, author *dstate.MemberState, alts []int64) (targets []int64, staff map[int64]string, skipped int) {
+	staff = make(map[int64]string)
+	for _, v := range alts {
+		member, _ := bot.GetMember(gs.ID, v)
+		if member == nil {
+			targets = append(targets, v)
+			continue
+		}
+
+		gs.RLock()
+		allowed := author != nil && massActionTarget(gs, author, member, nil)
+		gs.RUnlock()
+		if !allowed {
+			skipped++
+			continue
+		}
+
+		if role := banStaffRole(config, gs, member.Roles); role != "" {
+			if !config.ConfirmBanStaff {
+				skipped++
+				continue
+			}
+
+			staff[v] = role
+		}
+
+		targets = append(targets, v)
+	}
+
+	return targets, staff, skipped
+}
+
+// cmdAltTargets handles the -alts switch, returning the linked accounts to act on. If the moderator still has to
+// confirm the scope, or there's nothing to act on, resp is the response to give instead of acting.
+func cmdAltTargets(config *Config, parsed *dcmd.Data, target *discordgo.User, verb string) (alts []int64, resp string, err error) {
+	if parsed.Switches["alts"].Value == nil || !parsed.Switches["alts"].Value.(bool) {
+		return nil, "", nil
+	}
+
+	if !config.AltActionsEnabled {
+		return nil, "", commands.NewUserError("Acting on linked accounts isn't enabled on this server")
+	}
+
+	alts, err = linkedAccounts(parsed.GS.ID, target.ID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if len(alts) < 1 {
+		return nil, "No linked accounts are known for that user, leave out `-alts` to only " + verb + " them.", nil
+	}
+
+	alts, staff, skipped := filterAltTargets(config, parsed.GS, commands.ContextMS(parsed.Context()), alts)
+	if len(alts) < 1 {
+		return nil, fmt.Sprintf("You can't act on any of the %d linked account(s) of that user, leave out `-alts` to only %s them.", skipped, verb), nil
+	}
+
+	if len(alts) > MaxAltTargets {
+		return nil, fmt.Sprintf("That user has %d linked accounts, the max is %d.", len(alts), MaxAltTargets), nil
+	}
+
+	if parsed.Switches["confirm"].Value == nil || !parsed.Switches["confirm"].Value.(bool) {
+		return nil, altScopeMessage(verb, target, alts, staff, skipped), nil
+	}
+
+	return alts, "", nil
+}
+
+// altUser returns the user of the linked account, with placeholder details if they're not in the server
+func altUser(guildID, userID int64) *discordgo.User {
+	if member, _ := bot.GetMember(guildID, userID); member != nil {
+		return member.DGoUser()
+	}
+
+	return &discordgo.User{ID: userID, Username: "unknown", Discriminator: "????"}
+}
+
+// altReason is the reason the action on a linked account is done with
+func altReason(reason string, target *discordgo.User) string {
+	return strings.TrimSpace(fmt.Sprintf("%s (linked account of %s#%s)", reason, target.Username, target.Discriminator))
+}
+
+// actOnAlts runs the action on each of the linked accounts, each getting their own modlog entry, and returns a line for the response
+func actOnAlts(guildID int64, alts []int64, action ModlogAction, f func(alt *discordgo.User) error) string {
+	done := 0
+	failed := 0
+	for i, v := range alts {
+		if i > 0 {
+			time.Sleep(massActionDelay)
+		}
+
+		err := f(altUser(guildID, v))
+		if err != nil {
+			logger.WithError(err).WithField("guild", guildID).WithField("user", v).Error("failed acting on linked account")
+			failed++
+		} else {
+			done++
+		}
+	}
+
+	return fmt.Sprintf("\n%s Also %s %d linked account(s), %d failed.", action.Emoji, strings.ToLower(action.Prefix), done, failed)
+}
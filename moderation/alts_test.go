@@ -0,0 +1,47 @@
+package moderation
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jonas747/discordgo"
+)
+
+func TestLinkedAccounts(t *testing.T) {
+	old := AltLookupFuncs
+	defer func() { AltLookupFuncs = old }()
+
+	AltLookupFuncs = []AltLookupFunc{
+		func(guildID, userID int64) ([]int64, error) { return []int64{2, 3}, nil },
+		func(guildID, userID int64) ([]int64, error) { return []int64{3, 1, 4}, nil },
+	}
+
+	alts, err := linkedAccounts(10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []int64{2, 3, 4}; !reflect.DeepEqual(alts, want) {
+		t.Errorf("got %v, expected %v", alts, want)
+	}
+}
+
+func TestAltScopeMessage(t *testing.T) {
+	target := &discordgo.User{ID: 1, Username: "user", Discriminator: "0001"}
+
+	got := altScopeMessage("ban", target, []int64{2, 3}, nil, 0)
+	want := "This will ban user#0001 and their 2 linked account(s): `2`, `3`. Run the command again with `-confirm` to proceed."
+	if got != want {
+		t.Errorf("got %q, expected %q", got, want)
+	}
+
+	got = altScopeMessage("ban", target, []int64{2, 3}, map[int64]string{3: "Mods"}, 1)
+	want = "This will ban user#0001 and their 2 linked account(s): `2`, `3` (⚠ staff role **Mods**). 1 linked account(s) you can't act on are skipped. Run the command again with `-confirm` to proceed."
+	if got != want {
+		t.Errorf("got %q, expected %q", got, want)
+	}
+
+	if got := altReason("raiding", target); got != "raiding (linked account of user#0001)" {
+		t.Errorf("unexpected alt reason %q", got)
+	}
+}
@@ -0,0 +1,71 @@
+package moderation
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/jonas747/discordgo"
+	"github.com/jonas747/yagpdb/bot"
+)
+
+// Modlog entries can be mirrored to a webhook, usually in a central audit server for a network of servers.
+// Mirroring happens in the background after the entry was posted, so it can never hold up or break the modlog.
+
+var auditWebhookRegex = regexp.MustCompile(`^https://(?:canary\.|ptb\.)?discord(?:app)?\.com/api/(?:v\d+/)?webhooks/(\d+)/([\w-]+)/?$`)
+
+// parseAuditWebhook returns the id and token of the webhook url, false if it's not a valid discord webhook url
+func parseAuditWebhook(webhookURL string) (id int64, token string, ok bool) {
+	m := auditWebhookRegex.FindStringSubmatch(strings.TrimSpace(webhookURL))
+	if m == nil {
+		return 0, "", false
+	}
+
+	id, err := strconv.ParseInt(m[1], 10, 64)
+	if err != nil {
+		return 0, "", false
+	}
+
+	return id, m[2], true
+}
+
+// auditEmbed returns a copy of the modlog embed noting which server it came from
+func auditEmbed(guildID int64, guildName string, embed *discordgo.MessageEmbed) *discordgo.MessageEmbed {
+	cop := *embed
+
+	origin := fmt.Sprintf("%s (ID %d)", guildName, guildID)
+	if cop.Footer != nil && cop.Footer.Text != "" {
+		origin = cop.Footer.Text + " • " + origin
+	}
+	cop.Footer = &discordgo.MessageEmbedFooter{Text: origin}
+
+	return &cop
+}
+
+// mirrorModlogEmbed sends the modlog entry to the audit webhook in the background if one is set up, failures are only logged
+func mirrorModlogEmbed(config *Config, embed *discordgo.MessageEmbed) {
+	if config.AuditWebhook == "" {
+		return
+	}
+
+	id, token, ok := parseAuditWebhook(config.AuditWebhook)
+	if !ok {
+		return
+	}
+
+	// copied right away as the modlog embed can still be edited after this, e.g when the logs are ready
+	guildID := config.GetGuildID()
+	guildName := bot.GuildName(guildID)
+	params := &discordgo.WebhookParams{
+		Username: "Modlog: " + guildName,
+		Embeds:   []*discordgo.MessageEmbed{auditEmbed(guildID, guildName, embed)},
+	}
+
+	go func() {
+		err := session().WebhookExecute(id, token, false, params)
+		if err != nil {
+			logger.WithError(err).WithField("guild", guildID).Warn("failed mirroring modlog entry to the audit webhook")
+		}
+	}()
+}
@@ -0,0 +1,48 @@
+package moderation
+
+import (
+	"testing"
+
+	"github.com/jonas747/discordgo"
+)
+
+func TestParseAuditWebhook(t *testing.T) {
+	cases := []struct {
+		url   string
+		id    int64
+		token string
+		ok    bool
+	}{
+		{"https://discord.com/api/webhooks/123/abc-DEF_1", 123, "abc-DEF_1", true},
+		{"https://discordapp.com/api/v8/webhooks/5/tok/", 5, "tok", true},
+		{"https://ptb.discord.com/api/webhooks/5/tok", 5, "tok", true},
+		{"http://discord.com/api/webhooks/5/tok", 0, "", false},
+		{"https://example.com/api/webhooks/5/tok", 0, "", false},
+		{"https://discord.com/api/webhooks/abc/tok", 0, "", false},
+	}
+
+	for _, c := range cases {
+		id, token, ok := parseAuditWebhook(c.url)
+		if id != c.id || token != c.token || ok != c.ok {
+			t.Errorf("parseAuditWebhook(%q): got %d, %q, %t", c.url, id, token, ok)
+		}
+	}
+}
+
+func TestAuditEmbed(t *testing.T) {
+	embed := &discordgo.MessageEmbed{Description: "Banned someone", Footer: &discordgo.MessageEmbedFooter{Text: "Duration: 1 hour"}}
+
+	got := auditEmbed(1, "Some server", embed)
+	if got.Footer.Text != "Duration: 1 hour • Some server (ID 1)" {
+		t.Errorf("unexpected footer: %q", got.Footer.Text)
+	}
+
+	if embed.Footer.Text != "Duration: 1 hour" {
+		t.Error("the original embed was modified")
+	}
+
+	got = auditEmbed(1, "Some server", &discordgo.MessageEmbed{})
+	if got.Footer.Text != "Some server (ID 1)" {
+		t.Errorf("unexpected footer: %q", got.Footer.Text)
+	}
+}
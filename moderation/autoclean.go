@@ -0,0 +1,80 @@
+package moderation
+
+import (
+	"context"
+	"time"
+
+	"github.com/jonas747/discordgo"
+	"github.com/jonas747/yagpdb/common"
+	"github.com/jonas747/yagpdb/common/scheduledevents2"
+	seventsmodels "github.com/jonas747/yagpdb/common/scheduledevents2/models"
+	"github.com/volatiletech/sqlboiler/queries/qm"
+)
+
+// Auto clean periodically tidies up a channel, e.g a bot commands channel.
+// It's not a moderation action, so nothing is posted in the modlog.
+
+const (
+	// The max number of messages deleted in a single auto clean
+	AutoCleanMaxDelete = 100
+
+	// Discord can't bulk delete messages older than 2 weeks
+	autoCleanMaxAge = time.Hour*24*14 - time.Hour
+)
+
+func (c *Config) autoCleanEnabled() bool {
+	return c.IntAutoCleanChannel() != 0 && c.AutoCleanInterval > 0
+}
+
+func (c *Config) autoCleanInterval() time.Duration {
+	return time.Duration(c.AutoCleanInterval) * time.Minute
+}
+
+// autoCleanScheduleChanged returns true if the settings deciding when the next auto clean runs changed,
+// the rest are read when it runs
+func autoCleanScheduleChanged(old, updated *Config) bool {
+	return old.IntAutoCleanChannel() != updated.IntAutoCleanChannel() || old.AutoCleanInterval != updated.AutoCleanInterval
+}
+
+// rescheduleAutoClean removes the pending auto clean and schedules the next one if enabled,
+// called when the config is saved so that changes to the interval take effect right away
+func rescheduleAutoClean(config *Config, guildID int64) error {
+	_, err := seventsmodels.ScheduledEvents(qm.Where("event_name='moderation_auto_clean' AND guild_id = ? AND processed = false", guildID)).DeleteAll(context.Background(), common.PQ)
+	if err != nil || !config.autoCleanEnabled() {
+		return err
+	}
+
+	return scheduledevents2.ScheduleEvent("moderation_auto_clean", guildID, time.Now().Add(config.autoCleanInterval()), nil)
+}
+
+func handleScheduledAutoClean(evt *seventsmodels.ScheduledEvent, data interface{}) (retry bool, err error) {
+	config, err := GetConfig(evt.GuildID)
+	if err != nil {
+		return true, err
+	}
+
+	if !config.autoCleanEnabled() {
+		// turned off since this was scheduled
+		return false, nil
+	}
+
+	channelID := config.IntAutoCleanChannel()
+	minAge := time.Duration(config.AutoCleanMinAge) * time.Minute
+
+	deleted, err := AdvancedDeleteMessages(channelID, 0, "", autoCleanMaxAge, minAge, config.AutoCleanKeepPinned, AutoCleanMaxDelete, 1000)
+	if err != nil {
+		if common.IsDiscordErr(err, discordgo.ErrCodeUnknownChannel, discordgo.ErrCodeMissingAccess, discordgo.ErrCodeMissingPermissions) {
+			logger.WithError(err).WithField("guild", evt.GuildID).Info("disabling auto clean, channel is gone or missing permissions")
+			config.AutoCleanChannel = ""
+			return false, config.Save(evt.GuildID)
+		}
+
+		logger.WithError(err).WithField("guild", evt.GuildID).Error("failed auto cleaning channel")
+	} else {
+		logger.WithField("guild", evt.GuildID).WithField("channel", channelID).Infof("auto cleaned %d message(s)", deleted)
+	}
+
+	// this event is still marked as pending, so schedule directly
+	err = scheduledevents2.ScheduleEvent("moderation_auto_clean", evt.GuildID, time.Now().Add(config.autoCleanInterval()), nil)
+	return false, err
+}
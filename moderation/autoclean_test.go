@@ -0,0 +1,28 @@
+package moderation
+
+import (
+	"testing"
+)
+
+func TestAutoCleanScheduleChanged(t *testing.T) {
+	old := &Config{AutoCleanChannel: "10", AutoCleanInterval: 60, AutoCleanMinAge: 5}
+
+	updated := *old
+	updated.AutoCleanMinAge = 10
+	updated.AutoCleanKeepPinned = true
+	updated.MuteRole = "20"
+	if autoCleanScheduleChanged(old, &updated) {
+		t.Error("expected settings read when the clean runs not to reschedule it")
+	}
+
+	updated.AutoCleanInterval = 30
+	if !autoCleanScheduleChanged(old, &updated) {
+		t.Error("expected a changed interval to reschedule")
+	}
+
+	updated = *old
+	updated.AutoCleanChannel = ""
+	if !autoCleanScheduleChanged(old, &updated) {
+		t.Error("expected disabling auto clean to reschedule")
+	}
+}
@@ -0,0 +1,40 @@
+package moderation
+
+import (
+	"strings"
+	"time"
+)
+
+// How recent an automod violation has to be to be used as the reason of a mute
+const AutomodReasonMaxAge = time.Hour
+
+// RecentViolationFunc returns the name of the most recent automod violation of the user within maxAge, empty if there's none
+type RecentViolationFunc func(guildID, userID int64, maxAge time.Duration) (string, error)
+
+var recentViolationFunc RecentViolationFunc
+
+// RegisterRecentViolationFunc is called by automod to let the moderation commands look up violations,
+// moderation can't import automod directly as automod already depends on it
+func RegisterRecentViolationFunc(f RecentViolationFunc) {
+	recentViolationFunc = f
+}
+
+// automodReason returns a reason based on the user's most recent automod violation if enabled and there is one
+func automodReason(config *Config, guildID, userID int64) string {
+	if !config.MuteReasonFromAutomod || recentViolationFunc == nil {
+		return ""
+	}
+
+	name, err := recentViolationFunc(guildID, userID, AutomodReasonMaxAge)
+	if err != nil {
+		logger.WithError(err).WithField("guild", guildID).Error("failed looking up recent automod violation")
+		return ""
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return ""
+	}
+
+	return "auto: " + name
+}
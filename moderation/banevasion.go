@@ -0,0 +1,105 @@
+package moderation
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/jonas747/discordgo"
+	"github.com/jonas747/yagpdb/bot"
+	"github.com/jonas747/yagpdb/bot/eventsystem"
+	"github.com/jonas747/yagpdb/common"
+)
+
+// How far back we look for bans when checking new members for ban evasion
+const banEvasionWindow = time.Hour * 24 * 30
+
+// recordBan stores the identifying details of a banned user so that new members can be matched against it later,
+// it's also used to find the servers a user is banned in for the dm auto response
+func recordBan(config *Config, guildID int64, user *discordgo.User) {
+	if (!config.BanEvasionDetection && config.DMAutoResponse == "") || user.Discriminator == "????" {
+		return
+	}
+
+	err := common.GORM.Create(&BanModel{
+		GuildID:    guildID,
+		UserID:     user.ID,
+		Username:   user.Username,
+		AvatarHash: user.Avatar,
+	}).Error
+	if err != nil {
+		logger.WithError(err).WithField("guild", guildID).Error("failed recording ban")
+	}
+}
+
+// removeBanRecords removes the stored bans of the user after they've been unbanned
+func removeBanRecords(guildID, userID int64) {
+	err := common.GORM.Where("guild_id = ? AND user_id = ?", guildID, userID).Delete(BanModel{}).Error
+	if err != nil {
+		logger.WithError(err).WithField("guild", guildID).Error("failed removing ban records")
+	}
+}
+
+// banEvasionMatches returns the reasons the user looks like an alt of the banned user, if any
+func banEvasionMatches(ban *BanModel, user *discordgo.User) []string {
+	if ban.UserID == user.ID {
+		return nil
+	}
+
+	var reasons []string
+	if ban.AvatarHash != "" && ban.AvatarHash == user.Avatar {
+		reasons = append(reasons, "same avatar")
+	}
+
+	if ban.Username != "" && strings.EqualFold(strings.TrimSpace(ban.Username), strings.TrimSpace(user.Username)) {
+		reasons = append(reasons, "same username")
+	}
+
+	if len(reasons) > 0 && bot.SnowflakeToTime(user.ID).After(ban.CreatedAt) {
+		reasons = append(reasons, "account created after the ban")
+	}
+
+	return reasons
+}
+
+// HandleMemberJoinBanEvasion flags new members that look like alts of recently banned users in the report channel,
+// it never acts on them by itself
+func HandleMemberJoinBanEvasion(evt *eventsystem.EventData) (retry bool, err error) {
+	c := evt.GuildMemberAdd()
+
+	config, err := GetConfig(c.GuildID)
+	if err != nil {
+		return true, errors.WithStackIf(err)
+	}
+
+	if !config.BanEvasionDetection || config.IntReportChannel() == 0 || c.User.Bot {
+		return false, nil
+	}
+
+	var bans []*BanModel
+	err = common.GORM.Where("guild_id = ? AND created_at > ?", c.GuildID, time.Now().Add(-banEvasionWindow)).Order("id desc").Find(&bans).Error
+	if err != nil {
+		return true, errors.WithStackIf(err)
+	}
+
+	for _, ban := range bans {
+		reasons := banEvasionMatches(ban, c.User)
+		if len(reasons) < 1 {
+			continue
+		}
+
+		msg := fmt.Sprintf("⚠ Possible ban evasion: <@%d> (%s#%s, ID %d) who just joined matches %s (ID %d) banned %s ago: %s",
+			c.User.ID, c.User.Username, c.User.Discriminator, c.User.ID, ban.Username, ban.UserID,
+			common.HumanizeDuration(common.DurationPrecisionMinutes, time.Since(ban.CreatedAt)), strings.Join(reasons, ", "))
+
+		_, err = session().ChannelMessageSend(config.IntReportChannel(), msg)
+		if err != nil {
+			return bot.CheckDiscordErrRetry(err), errors.WithStackIf(err)
+		}
+
+		break
+	}
+
+	return false, nil
+}
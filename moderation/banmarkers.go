@@ -0,0 +1,80 @@
+package moderation
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jonas747/yagpdb/common"
+	"github.com/mediocregopher/radix/v3"
+)
+
+// The ban/unban markers are used to not log bans and unbans made by the bot twice, if one gets stuck
+// a manual ban or unban won't be logged. These let admins look at them and clear them.
+
+type banMarker struct {
+	Name string
+	Key  string
+}
+
+func banMarkers(guildID, userID int64) []banMarker {
+	return []banMarker{
+		{"Banned by the bot", RedisKeyBannedUser(guildID, userID)},
+		{"Unbanned by the bot", RedisKeyUnbannedUser(guildID, userID)},
+		{"Ban logged", RedisKeyBanLogged(guildID, userID)},
+		{"Ban undone", RedisKeyBanUndone(guildID, userID)},
+	}
+}
+
+// markerTTL returns the remaining time of the marker, -1 if it has no expiry and 0 if it's not set
+func markerTTL(key string) (time.Duration, error) {
+	var ms int64
+	err := common.RedisPool.Do(radix.Cmd(&ms, "PTTL", key))
+	if err != nil {
+		return 0, err
+	}
+
+	switch {
+	case ms == -2:
+		return 0, nil
+	case ms < 0:
+		return -1, nil
+	}
+
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+// describeBanMarkers lists the state of the ban/unban markers of the user
+func describeBanMarkers(guildID, userID int64) (string, error) {
+	var b strings.Builder
+	for _, m := range banMarkers(guildID, userID) {
+		ttl, err := markerTTL(m.Key)
+		if err != nil {
+			return "", err
+		}
+
+		switch {
+		case ttl == 0:
+			fmt.Fprintf(&b, "%s: not set\n", m.Name)
+		case ttl < 0:
+			fmt.Fprintf(&b, "%s: **set, never expires**\n", m.Name)
+		default:
+			fmt.Fprintf(&b, "%s: **set**, expires in %s\n", m.Name, common.HumanizeDuration(common.DurationPrecisionSeconds, ttl))
+		}
+	}
+
+	return b.String(), nil
+}
+
+// clearBanMarkers deletes the ban/unban markers of the user, returning the number that were set
+func clearBanMarkers(guildID, userID int64) (int, error) {
+	markers := banMarkers(guildID, userID)
+	keys := make([]string, 0, len(markers))
+	for _, m := range markers {
+		keys = append(keys, m.Key)
+	}
+
+	var deleted int
+	err := common.RedisPool.Do(radix.Cmd(&deleted, "DEL", keys...))
+	return deleted, err
+}
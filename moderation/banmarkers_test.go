@@ -0,0 +1,41 @@
+package moderation
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jonas747/yagpdb/common"
+)
+
+func TestClearBanMarkers(t *testing.T) {
+	if common.RedisPool == nil {
+		t.Skip("redis not available, skipping.")
+		return
+	}
+
+	setMarker(RedisKeyBannedUser(1, 5), time.Minute)
+	setMarker(RedisKeyUnbannedUser(1, 5), time.Minute)
+
+	desc, err := describeBanMarkers(1, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Count(desc, "**set**") != 2 || !strings.Contains(desc, "Ban logged: not set") {
+		t.Errorf("unexpected marker description:\n%s", desc)
+	}
+
+	n, err := clearBanMarkers(1, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 2 {
+		t.Errorf("expected 2 markers cleared, got %d", n)
+	}
+
+	if markerSet(RedisKeyBannedUser(1, 5)) || markerSet(RedisKeyUnbannedUser(1, 5)) {
+		t.Error("markers still set after clearing")
+	}
+}
@@ -0,0 +1,47 @@
+package moderation
+
+import (
+	"github.com/jonas747/dstate"
+)
+
+// Servers can have the ban command ask for confirmation before banning someone that looks like staff,
+// a lighter safety net than refusing to act on them.
+
+// banStaffRole returns the name of a role of the member that makes them count as staff, empty if they don't.
+// With ConfirmBanRole set that's any role at or above it, otherwise any of the moderation command roles.
+func banStaffRole(config *Config, gs *dstate.GuildState, memberRoles []int64) string {
+	gs.RLock()
+	defer gs.RUnlock()
+
+	threshold := -1
+	if thresholdRole := gs.RoleCopy(false, config.IntConfirmBanRole()); thresholdRole != nil {
+		threshold = thresholdRole.Position
+	}
+
+	var staffRoles []int64
+	if threshold == -1 {
+		staffRoles = append(staffRoles, config.BanCmdRoles...)
+		staffRoles = append(staffRoles, config.KickCmdRoles...)
+		staffRoles = append(staffRoles, config.MuteCmdRoles...)
+		staffRoles = append(staffRoles, config.WarnCmdRoles...)
+	}
+
+	for _, r := range memberRoles {
+		role := gs.RoleCopy(false, r)
+		if role == nil {
+			continue
+		}
+
+		if threshold != -1 && role.Position >= threshold {
+			return role.Name
+		}
+
+		for _, staffRole := range staffRoles {
+			if staffRole == r {
+				return role.Name
+			}
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,40 @@
+package moderation
+
+import (
+	"testing"
+
+	"github.com/jonas747/discordgo"
+	"github.com/jonas747/dstate"
+	"github.com/lib/pq"
+)
+
+func TestBanStaffRole(t *testing.T) {
+	gs := &dstate.GuildState{
+		Guild: &discordgo.Guild{
+			Roles: []*discordgo.Role{
+				{ID: 1, Name: "admin", Position: 10},
+				{ID: 2, Name: "helper", Position: 5},
+				{ID: 3, Name: "member", Position: 1},
+			},
+		},
+	}
+
+	cases := []struct {
+		name   string
+		config *Config
+		roles  []int64
+		want   string
+	}{
+		{"above the threshold", &Config{ConfirmBanRole: "2"}, []int64{3, 1}, "admin"},
+		{"at the threshold", &Config{ConfirmBanRole: "2"}, []int64{2}, "helper"},
+		{"below the threshold", &Config{ConfirmBanRole: "2"}, []int64{3}, ""},
+		{"command role without a threshold", &Config{WarnCmdRoles: pq.Int64Array{3}}, []int64{3}, "member"},
+		{"no staff roles", &Config{}, []int64{1, 2, 3}, ""},
+	}
+
+	for _, c := range cases {
+		if got := banStaffRole(c.config, gs, c.roles); got != c.want {
+			t.Errorf("%s: got %q, expected %q", c.name, got, c.want)
+		}
+	}
+}
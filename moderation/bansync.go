@@ -0,0 +1,188 @@
+package moderation
+
+import (
+	"strconv"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/jonas747/discordgo"
+	"github.com/jonas747/yagpdb/bot"
+	"github.com/jonas747/yagpdb/common"
+	"github.com/jonas747/yagpdb/common/pubsub"
+	"github.com/mediocregopher/radix/v3"
+	"github.com/sirupsen/logrus"
+)
+
+// Servers sharing the same ban sync group (a shared secret key) propagate bans done through the bot to each other,
+// servers only apply the bans of the group if they've opted into receiving them. Bans done by the bot itself
+// (synced bans, automod) aren't propagated further so a ban can't bounce around the group.
+
+const (
+	// The shortest ban sync group key allowed, it's the only thing keeping other servers out of the group
+	MinBanSyncGroupLength = 16
+
+	// The max number of synced bans a server applies per hour, the rest are dropped
+	MaxSyncedBansPerHour = 20
+)
+
+// BanSyncEvent is published to every other server in the group when a user is banned in one of them
+type BanSyncEvent struct {
+	Group         string        `json:"group"`
+	SourceGuildID int64         `json:"source_guild_id,string"`
+	SourceName    string        `json:"source_name"`
+	UserID        int64         `json:"user_id,string"`
+	Username      string        `json:"username"`
+	Discriminator string        `json:"discriminator"`
+	Reason        string        `json:"reason"`
+	Duration      time.Duration `json:"duration"`
+
+	// Set when the ban was undone in the source server, the synced bans are undone as well
+	Undo bool `json:"undo"`
+}
+
+func RedisKeySyncedBans(guildID int64) string {
+	return "moderation_synced_bans:" + discordgo.StrID(guildID)
+}
+
+// publishBanSync sends the ban to the other servers in the group, errors are only logged as the ban itself went through
+func publishBanSync(config *Config, guildID int64, user *discordgo.User, reason string, duration time.Duration) {
+	publishBanSyncEvent(config, guildID, &BanSyncEvent{
+		UserID:        user.ID,
+		Username:      user.Username,
+		Discriminator: user.Discriminator,
+		Reason:        reason,
+		Duration:      duration,
+	})
+}
+
+// publishBanSyncUndo has the other servers in the group undo the bans synced from the undone ban
+func publishBanSyncUndo(config *Config, guildID int64, ban *lastBan) {
+	publishBanSyncEvent(config, guildID, &BanSyncEvent{
+		UserID:        ban.UserID,
+		Username:      ban.Username,
+		Discriminator: ban.Discriminator,
+		Undo:          true,
+	})
+}
+
+func publishBanSyncEvent(config *Config, guildID int64, evt *BanSyncEvent) {
+	if config.BanSyncGroup == "" {
+		return
+	}
+
+	var guilds []int64
+	err := common.GORM.Model(&Config{}).Where("ban_sync_group = ? AND guild_id != ?", config.BanSyncGroup, guildID).Pluck("guild_id", &guilds).Error
+	if err != nil {
+		logger.WithError(err).WithField("guild", guildID).Error("failed retrieving ban sync group")
+		return
+	}
+
+	evt.Group = config.BanSyncGroup
+	evt.SourceGuildID = guildID
+	evt.SourceName = bot.GuildName(guildID)
+
+	for _, v := range guilds {
+		err = pubsub.Publish("moderation_ban_sync", v, evt)
+		if err != nil {
+			logger.WithError(err).WithField("guild", guildID).WithField("target_guild", v).Error("failed publishing synced ban")
+		}
+	}
+}
+
+// acceptsBanSync returns true if the server applies the synced ban
+func (c *Config) acceptsBanSync(evt *BanSyncEvent) bool {
+	return c.BanSyncReceive && c.BanSyncGroup != "" && c.BanSyncGroup == evt.Group
+}
+
+// syncedBanReason is the reason synced bans are applied with
+func syncedBanReason(evt *BanSyncEvent) string {
+	reason := "Synced ban from " + evt.SourceName
+	if evt.Reason != "" {
+		reason += ": " + evt.Reason
+	}
+
+	return reason
+}
+
+// syncedBanCapReached counts the synced ban and returns true if the server already applied the max this hour
+func syncedBanCapReached(guildID int64) (bool, error) {
+	key := RedisKeySyncedBans(guildID)
+
+	var count int
+	err := common.RedisPool.Do(radix.Cmd(&count, "INCR", key))
+	if err != nil {
+		return false, errors.WithStackIf(err)
+	}
+
+	if count == 1 {
+		common.RedisPool.Do(radix.Cmd(nil, "EXPIRE", key, strconv.Itoa(int(time.Hour.Seconds()))))
+	}
+
+	return count > MaxSyncedBansPerHour, nil
+}
+
+func handleBanSync(evt *pubsub.Event) {
+	data := evt.Data.(*BanSyncEvent)
+	guildID := evt.TargetGuildInt
+
+	config, err := GetConfig(guildID)
+	if err != nil || !config.acceptsBanSync(data) {
+		return
+	}
+
+	l := logger.WithField("guild", guildID).WithField("source_guild", data.SourceGuildID).WithField("user", data.UserID)
+
+	if data.Undo {
+		undoSyncedBan(config, data, l)
+		return
+	}
+
+	banned, err := userBanned(guildID, data.UserID)
+	if err != nil {
+		l.WithError(err).Error("failed checking ban of synced ban")
+		return
+	}
+	if banned {
+		return
+	}
+
+	capped, err := syncedBanCapReached(guildID)
+	if err != nil {
+		l.WithError(err).Error("failed checking synced ban cap")
+		return
+	}
+	if capped {
+		l.Warn("synced ban cap reached, dropping synced ban")
+		return
+	}
+
+	user := &discordgo.User{ID: data.UserID, Username: data.Username, Discriminator: data.Discriminator}
+	err = banUser(config, guildID, nil, nil, common.BotUser, syncedBanReason(data), user, data.Duration, 0, false, data.SourceGuildID)
+	if err != nil {
+		l.WithError(err).Error("failed applying synced ban")
+		return
+	}
+
+	l.Info("applied synced ban")
+}
+
+// undoSyncedBan undoes the ban synced from the source server, if it was applied here and is still undoable
+func undoSyncedBan(config *Config, data *BanSyncEvent, l *logrus.Entry) {
+	bans, err := takeUndoableBans(RedisKeySyncedBan(config.GetGuildID(), data.SourceGuildID, data.UserID))
+	if err != nil {
+		l.WithError(err).Error("failed retrieving synced ban to undo")
+		return
+	}
+
+	for _, v := range bans {
+		err = undoBan(config, v)
+		if err != nil {
+			l.WithError(err).Error("failed undoing synced ban")
+			return
+		}
+	}
+
+	if len(bans) > 0 {
+		l.Info("undid synced ban")
+	}
+}
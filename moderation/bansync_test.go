@@ -0,0 +1,29 @@
+package moderation
+
+import (
+	"testing"
+)
+
+func TestAcceptsBanSync(t *testing.T) {
+	evt := &BanSyncEvent{Group: "0123456789abcdef", SourceName: "Main server", Reason: "raiding"}
+
+	cases := []struct {
+		config *Config
+		want   bool
+	}{
+		{&Config{BanSyncGroup: "0123456789abcdef", BanSyncReceive: true}, true},
+		{&Config{BanSyncGroup: "0123456789abcdef"}, false},
+		{&Config{BanSyncGroup: "another group key", BanSyncReceive: true}, false},
+		{&Config{BanSyncReceive: true}, false},
+	}
+
+	for i, c := range cases {
+		if got := c.config.acceptsBanSync(evt); got != c.want {
+			t.Errorf("%d: got %t, expected %t", i, got, c.want)
+		}
+	}
+
+	if got := syncedBanReason(evt); got != "Synced ban from Main server: raiding" {
+		t.Errorf("unexpected synced ban reason %q", got)
+	}
+}
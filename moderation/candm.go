@@ -0,0 +1,69 @@
+package moderation
+
+import (
+	"emperror.dev/errors"
+	"github.com/jonas747/yagpdb/common"
+)
+
+// Discord error codes returned when probing a user's DMs
+const (
+	errCodeUnknownUser            = 10013
+	errCodeCannotSendEmptyMessage = 50006
+	errCodeCannotDMUser           = 50007
+)
+
+type dmStatus int
+
+const (
+	dmStatusOpen dmStatus = iota
+	dmStatusClosed
+	dmStatusUnknownUser
+	dmStatusInconclusive
+)
+
+func (d dmStatus) String() string {
+	switch d {
+	case dmStatusOpen:
+		return "DMs are open, they should receive the punishment DMs"
+	case dmStatusClosed:
+		return "Can't DM them, they either have DMs from server members disabled, blocked the bot or share no server with it. Consider posting a public notice instead"
+	case dmStatusUnknownUser:
+		return "Unknown user"
+	}
+
+	return "Couldn't tell if their DMs are open"
+}
+
+// probeDM checks if the user can be DM'd without sending them anything: sending an empty message fails with
+// "cannot send an empty message" if they'd otherwise receive it, and "cannot send messages to this user" if not
+func probeDM(userID int64) (dmStatus, error) {
+	channel, err := session().UserChannelCreate(userID)
+	if err != nil {
+		if common.IsDiscordErr(err, errCodeUnknownUser) {
+			return dmStatusUnknownUser, nil
+		}
+
+		if common.IsDiscordErr(err, errCodeCannotDMUser) {
+			return dmStatusClosed, nil
+		}
+
+		return dmStatusInconclusive, errors.WithStackIf(err)
+	}
+
+	_, err = session().ChannelMessageSend(channel.ID, "")
+	switch {
+	case common.IsDiscordErr(err, errCodeCannotSendEmptyMessage):
+		return dmStatusOpen, nil
+	case common.IsDiscordErr(err, errCodeCannotDMUser):
+		return dmStatusClosed, nil
+	case err != nil:
+		if code, _ := common.DiscordError(err); code != 0 {
+			// some other discord error, like being rate limited on opening dms
+			return dmStatusInconclusive, nil
+		}
+
+		return dmStatusInconclusive, errors.WithStackIf(err)
+	}
+
+	return dmStatusInconclusive, nil
+}
@@ -0,0 +1,49 @@
+package moderation
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jonas747/discordgo"
+)
+
+func discordErr(code int) error {
+	return &discordgo.RESTError{Message: &discordgo.APIErrorMessage{Code: code}}
+}
+
+func TestProbeDM(t *testing.T) {
+	cases := []struct {
+		name      string
+		createErr error
+		sendErr   error
+		expected  dmStatus
+		err       bool
+	}{
+		{name: "open", sendErr: discordErr(errCodeCannotSendEmptyMessage), expected: dmStatusOpen},
+		{name: "closed", sendErr: discordErr(errCodeCannotDMUser), expected: dmStatusClosed},
+		{name: "unknown user", createErr: discordErr(errCodeUnknownUser), expected: dmStatusUnknownUser},
+		{name: "other discord error", sendErr: discordErr(40003), expected: dmStatusInconclusive},
+		{name: "network error", createErr: errors.New("timeout"), expected: dmStatusInconclusive, err: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			m, restore := useMockSession()
+			defer restore()
+
+			m.dmCreateErr = c.createErr
+			m.dmSendErr = c.sendErr
+
+			status, err := probeDM(1)
+			if (err != nil) != c.err {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if status != c.expected {
+				t.Errorf("got status %d, expected %d", status, c.expected)
+			}
+			if len(m.sentMessages) > 0 {
+				t.Errorf("probing sent a message: %v", m.sentMessages)
+			}
+		})
+	}
+}
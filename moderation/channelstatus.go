@@ -0,0 +1,75 @@
+package moderation
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jonas747/discordgo"
+	"github.com/jonas747/yagpdb/common"
+)
+
+// channelStatus describes the moderation state of the channel: slowmode, whether @everyone can send messages
+// and whether the mute roles have their overrides, everyonePerms is the guild wide permissions of the @everyone role
+// and roleName is used to look up the names of the mute roles
+func channelStatus(config *Config, channel *discordgo.Channel, everyonePerms int, roleName func(int64) string) string {
+	var out strings.Builder
+	fmt.Fprintf(&out, "Status of <#%d>\n", channel.ID)
+
+	if channel.RateLimitPerUser > 0 {
+		fmt.Fprintf(&out, "🐌 **Slowmode:** %s\n", common.HumanizeDuration(common.DurationPrecisionSeconds, time.Duration(channel.RateLimitPerUser)*time.Second))
+	} else {
+		out.WriteString("🐌 **Slowmode:** off\n")
+	}
+
+	// the @everyone role has the same id as the guild
+	canSend := everyonePerms&discordgo.PermissionSendMessages != 0
+	for _, v := range channel.PermissionOverwrites {
+		if v.Type != "role" || v.ID != channel.GuildID {
+			continue
+		}
+
+		if v.Deny&discordgo.PermissionSendMessages != 0 {
+			canSend = false
+		} else if v.Allow&discordgo.PermissionSendMessages != 0 {
+			canSend = true
+		}
+	}
+
+	if canSend {
+		out.WriteString("🔓 **Lockdown:** no, @everyone can send messages\n")
+	} else {
+		out.WriteString("🔒 **Lockdown:** yes, @everyone can't send messages\n")
+	}
+
+	if config.MuteRole == "" {
+		out.WriteString("🔇 **Mute override:** no mute role set up")
+		return out.String()
+	}
+
+	if common.ContainsInt64Slice(config.MuteIgnoreChannels, channel.ID) {
+		out.WriteString("🔇 **Mute override:** channel is ignored by the mute role management")
+		return out.String()
+	}
+
+	for i, v := range config.managedMuteOverrides() {
+		state := "missing"
+		for _, ow := range channel.PermissionOverwrites {
+			if ow.Type == "role" && ow.ID == v.Role {
+				if ow.Deny&v.Denies == v.Denies {
+					state = "present"
+				} else {
+					state = "incomplete"
+				}
+				break
+			}
+		}
+
+		if i > 0 {
+			out.WriteString("\n")
+		}
+		fmt.Fprintf(&out, "🔇 **Mute override for %s:** %s", roleName(v.Role), state)
+	}
+
+	return out.String()
+}
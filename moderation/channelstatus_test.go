@@ -0,0 +1,39 @@
+package moderation
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jonas747/discordgo"
+)
+
+func TestChannelStatus(t *testing.T) {
+	config := testMuteConfig()
+	roleName := func(id int64) string { return "muted" }
+
+	channel := &discordgo.Channel{
+		ID:               2,
+		GuildID:          1,
+		RateLimitPerUser: 30,
+		PermissionOverwrites: []*discordgo.PermissionOverwrite{
+			{Type: "role", ID: 1, Deny: discordgo.PermissionSendMessages},
+			{Type: "role", ID: 10, Deny: MuteDeniedChannelPerms},
+		},
+	}
+
+	status := channelStatus(config, channel, discordgo.PermissionSendMessages, roleName)
+	for _, want := range []string{"Slowmode:** 30 seconds", "Lockdown:** yes", "muted:** present"} {
+		if !strings.Contains(status, want) {
+			t.Errorf("expected %q in status:\n%s", want, status)
+		}
+	}
+
+	channel.RateLimitPerUser = 0
+	channel.PermissionOverwrites = nil
+	status = channelStatus(config, channel, discordgo.PermissionSendMessages, roleName)
+	for _, want := range []string{"Slowmode:** off", "Lockdown:** no", "muted:** missing"} {
+		if !strings.Contains(status, want) {
+			t.Errorf("expected %q in status:\n%s", want, status)
+		}
+	}
+}
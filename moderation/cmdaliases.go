@@ -0,0 +1,159 @@
+package moderation
+
+import (
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/jonas747/discordgo"
+	"github.com/jonas747/yagpdb/commands"
+)
+
+// The max number of command aliases a server can configure
+const MaxCommandAliases = 25
+
+// parseCommandAliases parses comma separated alias=command pairs, returning the lowercased aliases mapped to the commands
+func parseCommandAliases(s string) (map[string]string, error) {
+	aliases := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		split := strings.SplitN(pair, "=", 2)
+		if len(split) != 2 {
+			return nil, errors.Errorf("`%s` is not in the format alias=command", pair)
+		}
+
+		alias := strings.ToLower(strings.TrimSpace(split[0]))
+		cmd := strings.ToLower(strings.TrimSpace(split[1]))
+		if alias == "" || cmd == "" || strings.ContainsAny(alias, " \t\n") {
+			return nil, errors.Errorf("`%s` is not in the format alias=command", pair)
+		}
+
+		if _, ok := aliases[alias]; ok {
+			return nil, errors.Errorf("duplicate alias `%s`", alias)
+		}
+
+		aliases[alias] = cmd
+	}
+
+	if len(aliases) > MaxCommandAliases {
+		return nil, errors.Errorf("too many aliases, the max is %d", MaxCommandAliases)
+	}
+
+	return aliases, nil
+}
+
+// moderationCommandName returns the name of the moderation command with the name or static alias, empty if there's none
+func moderationCommandName(name string) string {
+	for _, cmd := range ModerationCommands {
+		if strings.EqualFold(cmd.Name, name) {
+			return cmd.Name
+		}
+
+		for _, alias := range cmd.Aliases {
+			if strings.EqualFold(alias, name) {
+				return cmd.Name
+			}
+		}
+	}
+
+	return ""
+}
+
+// commandTriggerTaken returns true if a registered command (of any plugin) already uses the name
+func commandTriggerTaken(name string) bool {
+	if moderationCommandName(name) != "" {
+		return true
+	}
+
+	if commands.CommandSystem == nil || commands.CommandSystem.Root == nil {
+		return false
+	}
+
+	for _, cmd := range commands.CommandSystem.Root.Commands {
+		for _, trigger := range cmd.Trigger.Names {
+			if strings.EqualFold(trigger, name) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// validateCommandAliases checks that every alias points to a moderation command and doesn't shadow an existing command
+func validateCommandAliases(s string) error {
+	aliases, err := parseCommandAliases(s)
+	if err != nil {
+		return err
+	}
+
+	for alias, cmd := range aliases {
+		if moderationCommandName(cmd) == "" {
+			return errors.Errorf("`%s` is not a moderation command", cmd)
+		}
+
+		if commandTriggerTaken(alias) {
+			return errors.Errorf("`%s` is already the name of a command", alias)
+		}
+	}
+
+	return nil
+}
+
+// rewriteAliasedCommand replaces the alias at the start of the message with the name of the command it points to,
+// returning the alias that was replaced, empty if the message doesn't start with one
+func rewriteAliasedCommand(content, prefix string, aliases map[string]string) (string, string) {
+	if prefix == "" || !strings.HasPrefix(content, prefix) {
+		return content, ""
+	}
+
+	rest := content[len(prefix):]
+	word := rest
+	if i := strings.IndexAny(rest, " \t\n"); i != -1 {
+		word = rest[:i]
+	}
+
+	alias := strings.ToLower(word)
+	cmd, ok := aliases[alias]
+	if !ok {
+		return content, ""
+	}
+
+	return prefix + cmd + rest[len(word):], alias
+}
+
+// rewriteCommandAlias has commands look for the command behind a server's alias, as dcmd doesn't know about them.
+// It runs after the message filters so filtered messages don't run aliased commands either.
+// Aliases that have since been taken by a real command are ignored.
+func rewriteCommandAlias(msg *discordgo.Message) *discordgo.Message {
+	if msg.GuildID == 0 {
+		return nil
+	}
+
+	config, err := GetConfig(msg.GuildID)
+	if err != nil || config.CommandAliases == "" {
+		return nil
+	}
+
+	aliases, err := parseCommandAliases(config.CommandAliases)
+	if err != nil {
+		return nil
+	}
+
+	prefix, err := commands.GetCommandPrefix(msg.GuildID)
+	if err != nil {
+		return nil
+	}
+
+	content, alias := rewriteAliasedCommand(msg.Content, prefix, aliases)
+	if alias == "" || commandTriggerTaken(alias) {
+		return nil
+	}
+
+	aliased := *msg
+	aliased.Content = content
+	return &aliased
+}
@@ -0,0 +1,62 @@
+package moderation
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCommandAliases(t *testing.T) {
+	aliases, err := parseCommandAliases("b=ban, M = Mute,,")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := map[string]string{"b": "ban", "m": "mute"}; !reflect.DeepEqual(aliases, want) {
+		t.Errorf("got %v, expected %v", aliases, want)
+	}
+
+	for _, invalid := range []string{"b", "b=", "=ban", "a b=ban", "b=ban,B=kick"} {
+		if _, err := parseCommandAliases(invalid); err == nil {
+			t.Errorf("%q: expected an error", invalid)
+		}
+	}
+}
+
+func TestValidateCommandAliases(t *testing.T) {
+	if err := validateCommandAliases("b=ban, yeet=kick"); err != nil {
+		t.Errorf("expected valid aliases, got %v", err)
+	}
+
+	// not a moderation command
+	if err := validateCommandAliases("h=help"); err == nil {
+		t.Error("expected an error for an alias of a non moderation command")
+	}
+
+	// shadows a moderation command
+	if err := validateCommandAliases("kick=ban"); err == nil {
+		t.Error("expected an error for an alias colliding with a command")
+	}
+}
+
+func TestRewriteAliasedCommand(t *testing.T) {
+	aliases := map[string]string{"b": "ban"}
+
+	cases := []struct {
+		content string
+		want    string
+		alias   string
+	}{
+		{"-b @user spam", "-ban @user spam", "b"},
+		{"-B 123", "-ban 123", "b"},
+		{"-b", "-ban", "b"},
+		{"-ban @user", "-ban @user", ""},
+		{"b @user", "b @user", ""},
+	}
+
+	for _, c := range cases {
+		got, alias := rewriteAliasedCommand(c.content, "-", aliases)
+		if got != c.want || alias != c.alias {
+			t.Errorf("%q: got %q, %q, expected %q, %q", c.content, got, alias, c.want, c.alias)
+		}
+	}
+}
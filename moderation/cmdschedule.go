@@ -0,0 +1,41 @@
+package moderation
+
+import (
+	"strings"
+	"time"
+)
+
+// CmdScheduleActive returns false if the command is one of the scheduled commands and t is outside of the configured window,
+// commands that aren't scheduled are always active
+func (c *Config) CmdScheduleActive(cmdName string, t time.Time) bool {
+	if !c.cmdScheduled(cmdName) || c.CmdScheduleStart == c.CmdScheduleEnd {
+		return true
+	}
+
+	hour := t.In(c.cmdScheduleLocation()).Hour()
+	if c.CmdScheduleStart < c.CmdScheduleEnd {
+		return hour >= c.CmdScheduleStart && hour < c.CmdScheduleEnd
+	}
+
+	// the window wraps around midnight, e.g 22-06
+	return hour >= c.CmdScheduleStart || hour < c.CmdScheduleEnd
+}
+
+func (c *Config) cmdScheduled(cmdName string) bool {
+	for _, v := range strings.Split(c.ScheduledCmds, ",") {
+		if strings.EqualFold(strings.TrimSpace(v), cmdName) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *Config) cmdScheduleLocation() *time.Location {
+	loc, err := time.LoadLocation(c.CmdScheduleTimezone)
+	if err != nil {
+		return time.UTC
+	}
+
+	return loc
+}
@@ -0,0 +1,40 @@
+package moderation
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCmdScheduleActive(t *testing.T) {
+	at := func(hour int) time.Time {
+		return time.Date(2020, 1, 1, hour, 30, 0, 0, time.UTC)
+	}
+
+	cases := []struct {
+		name       string
+		cmds       string
+		start, end int
+		cmd        string
+		hour       int
+		want       bool
+	}{
+		{"not scheduled", "clean", 8, 20, "Report", 3, true},
+		{"no window", "report", 0, 0, "Report", 3, true},
+		{"inside", "report", 8, 20, "Report", 12, true},
+		{"before", "report", 8, 20, "Report", 7, false},
+		{"end is exclusive", "report", 8, 20, "Report", 20, false},
+		{"list with spaces", "clean, report", 8, 20, "Report", 3, false},
+		{"wrapping inside", "report", 22, 6, "Report", 23, true},
+		{"wrapping after midnight", "report", 22, 6, "Report", 2, true},
+		{"wrapping outside", "report", 22, 6, "Report", 12, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			config := &Config{ScheduledCmds: c.cmds, CmdScheduleStart: c.start, CmdScheduleEnd: c.end}
+			if got := config.CmdScheduleActive(c.cmd, at(c.hour)); got != c.want {
+				t.Errorf("got %t, expected %t", got, c.want)
+			}
+		})
+	}
+}
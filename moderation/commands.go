@@ -1,6 +1,7 @@
 package moderation
 
 import (
+	"encoding/json"
 	"fmt"
 	"regexp"
 	"strconv"
@@ -52,47 +53,155 @@ func MBaseCmd(cmdData *dcmd.Data, targetID int64) (config *Config, targetUser *d
 
 }
 
-func MBaseCmdSecond(cmdData *dcmd.Data, reason string, reasonArgOptional bool, neededPerm int, additionalPermRoles []int64, enabled bool) (oreason string, err error) {
+func MBaseCmdSecond(cmdData *dcmd.Data, reason string, reasonArgOptional bool, neededPerm int, additionalPermRoles []int64, requireAllRoles bool, enabled bool) (oreason string, err error) {
 	cmdName := cmdData.Cmd.Trigger.Names[0]
 	oreason = reason
 	if !enabled {
 		return oreason, commands.NewUserErrorf("The **%s** command is disabled on this server. Enable it in the control panel on the moderation page.", cmdName)
 	}
 
+	config, err := GetConfig(cmdData.GS.ID)
+	if err != nil {
+		return oreason, errors.WithMessage(err, "GetConfig")
+	}
+
+	if config.GlobalReasonRequired && cmdTakesReason(cmdData) {
+		reasonArgOptional = false
+	}
+
+	if config.RequireModlog && config.IntActionChannel() == 0 {
+		return oreason, commands.NewUserError("Moderation commands are disabled on this server until a modlog channel is set in the control panel.")
+	}
+
+	if !config.CmdScheduleActive(cmdName, time.Now()) {
+		return oreason, commands.NewUserErrorf("The **%s** command is only available between %02d:00 and %02d:00 (%s) on this server.", cmdName, config.CmdScheduleStart, config.CmdScheduleEnd, config.cmdScheduleLocation())
+	}
+
 	if strings.TrimSpace(reason) == "" {
-		if !reasonArgOptional {
-			return oreason, commands.NewUserError("A reason has been set to be required for this command by the server admins, see help for more info.")
+		omitted, err := reasonOmitted(cmdData)
+		if err != nil {
+			return oreason, err
 		}
 
-		oreason = "(No reason specified)"
+		if omitted != "" {
+			oreason = omitted
+		} else if !reasonArgOptional {
+			return oreason, commands.NewUserError("A reason has been set to be required for this command by the server admins, see help for more info.")
+		} else {
+			oreason = config.NoReasonText()
+		}
 	}
 
 	// check permissions or role setup for this command
-	permsMet := false
-	if len(additionalPermRoles) > 0 {
-		// Check if the user has one of the required roles
-		member := commands.ContextMS(cmdData.Context())
-		for _, r := range member.Roles {
-			if common.ContainsInt64Slice(additionalPermRoles, r) {
-				permsMet = true
-				break
-			}
+	permsMet, _, err := cmdPermsMet(cmdData.CS.ID, commands.ContextMS(cmdData.Context()), neededPerm, additionalPermRoles, requireAllRoles)
+	if err != nil || !permsMet {
+		return oreason, commands.NewUserErrorf("The **%s** command requires the **%s** permission in this channel or additional roles set up by admins, you don't have it. (if you do contact bot support)", cmdName, common.StringPerms[neededPerm])
+	}
+
+	go analytics.RecordActiveUnit(cmdData.GS.ID, &Plugin{}, "executed_cmd_"+cmdName)
+
+	return limitReasonLength(config, ExpandReasonRules(config, oreason))
+}
+
+// Switch that lets admins deliberately leave out the reason, even if one is required
+var noReasonSwitch = &dcmd.ArgDef{Switch: "noreason", Name: "Deliberately leave out the reason (admins only)"}
+
+// reasonOmitted returns the reason to record if the -noreason switch was used, making it clear who left it out on purpose
+// as opposed to just forgetting it, returns an empty string if the switch wasn't used
+func reasonOmitted(cmdData *dcmd.Data) (string, error) {
+	sw, ok := cmdData.Switches[noReasonSwitch.Switch]
+	if !ok || sw.Value == nil || !sw.Value.(bool) {
+		return "", nil
+	}
+
+	isAdmin, err := bot.AdminOrPermMS(cmdData.CS.ID, commands.ContextMS(cmdData.Context()), discordgo.PermissionManageServer)
+	if err != nil || !isAdmin {
+		return "", commands.NewUserError("Only members with the Manage Server permission can use -noreason")
+	}
+
+	author := cmdData.Msg.Author
+	return fmt.Sprintf("(Reason deliberately omitted by %s#%s)", author.Username, author.Discriminator), nil
+}
+
+// cmdTakesReason returns true if the command has a reason argument, commands without one never require a reason
+func cmdTakesReason(cmdData *dcmd.Data) bool {
+	cmd, ok := cmdData.Cmd.Command.(*commands.YAGCommand)
+	if !ok {
+		return false
+	}
+
+	for _, v := range cmd.Arguments {
+		if v.Name == "Reason" {
+			return true
 		}
 	}
 
-	if !permsMet && neededPerm != 0 {
-		// Fallback to legacy permissions
-		hasPerms, err := bot.AdminOrPermMS(cmdData.CS.ID, commands.ContextMS(cmdData.Context()), neededPerm)
-		if err != nil || !hasPerms {
-			return oreason, commands.NewUserErrorf("The **%s** command requires the **%s** permission in this channel or additional roles set up by admins, you don't have it. (if you do contact bot support)", cmdName, common.StringPerms[neededPerm])
+	return false
+}
+
+// cmdPermsMet returns true if the member can run a command requiring either the permission or the command roles,
+// viaRoles is true if access was given by the roles rather than the permission
+func cmdPermsMet(channelID int64, member *dstate.MemberState, neededPerm int, cmdRoles []int64, requireAll bool) (met bool, viaRoles bool, err error) {
+	// Check if the user has one (or all of, if set up like that) of the required roles
+	if len(cmdRoles) > 0 && hasCmdRoles(member.Roles, cmdRoles, requireAll) {
+		return true, true, nil
+	}
+
+	if neededPerm == 0 {
+		return true, false, nil
+	}
+
+	// Fallback to legacy permissions
+	met, err = bot.AdminOrPermMS(channelID, member, neededPerm)
+	return met, false, err
+}
+
+// hasCmdRoles returns true if the member has any of the command roles, or all of them if requireAll is set
+func hasCmdRoles(memberRoles []int64, cmdRoles []int64, requireAll bool) bool {
+	for _, r := range cmdRoles {
+		has := common.ContainsInt64Slice(memberRoles, r)
+		if has && !requireAll {
+			return true
 		}
 
-		permsMet = true
+		if !has && requireAll {
+			return false
+		}
 	}
 
-	go analytics.RecordActiveUnit(cmdData.GS.ID, &Plugin{}, "executed_cmd_"+cmdName)
+	return requireAll && len(cmdRoles) > 0
+}
+
+// cleanMaxAge returns the max age of messages to clean, the server's default is used if none was specified
+func cleanMaxAge(config *Config, switchValue interface{}) time.Duration {
+	if switchValue != nil {
+		return switchValue.(time.Duration)
+	}
+
+	return time.Duration(config.DefaultCleanMaxAge) * time.Minute
+}
+
+// cleanReasonGiven returns true if the clean was given a reason, only those are logged.
+// Checked before MBaseCmdSecond as that fills in the no reason text when it's left out.
+func cleanReasonGiven(parsed *dcmd.Data) bool {
+	return strings.TrimSpace(SafeArgString(parsed, 2)) != ""
+}
+
+// logClean posts the clean in the modlog, nothing is posted if no messages were deleted
+func logClean(config *Config, author *discordgo.User, channelName string, userFilter int64, numDeleted int, reason string) {
+	if numDeleted < 1 {
+		return
+	}
+
+	summary := fmt.Sprintf("Cleaned %d message(s) in #%s", numDeleted, channelName)
+	if userFilter != 0 {
+		summary += fmt.Sprintf(" from user %d", userFilter)
+	}
 
-	return oreason, nil
+	err := CreateMassModlogEmbed(config, author, MACleaned, summary, reason)
+	if err != nil {
+		logger.WithError(err).WithField("guild", config.GetGuildID()).Error("failed logging clean")
+	}
 }
 
 func SafeArgString(data *dcmd.Data, arg int) string {
@@ -103,6 +212,77 @@ func SafeArgString(data *dcmd.Data, arg int) string {
 	return data.Args[arg].Str()
 }
 
+// cmdTargetID returns the user given as the first argument, or the author of the message the command was a reply to if none was given.
+// A user that failed to parse ends up in the reason instead, that's rejected rather than acting on the replied to author.
+func cmdTargetID(data *dcmd.Data) (int64, error) {
+	if data.Args[0].Value != nil {
+		return data.Args[0].Int64(), nil
+	}
+
+	if word := firstPositionalWord(data); looksLikeUserArg(word) {
+		return 0, commands.NewUserErrorf("Invalid user `%s`, specify a valid user or leave it out when replying to one of their messages", word)
+	}
+
+	if userID := repliedAuthorID(data.Msg); userID != 0 {
+		return userID, nil
+	}
+
+	return 0, commands.NewUserError("No user given, specify one or reply to one of their messages")
+}
+
+// firstPositionalWord returns the first word of the text given after the command when the user was left out,
+// empty if it was parsed as something other than text (e.g the duration of a mute)
+func firstPositionalWord(data *dcmd.Data) string {
+	for _, v := range data.Args[1:] {
+		if v.Value == nil {
+			continue
+		}
+
+		str, ok := v.Value.(string)
+		if !ok {
+			return ""
+		}
+
+		if fields := strings.Fields(str); len(fields) > 0 {
+			return fields[0]
+		}
+	}
+
+	return ""
+}
+
+// looksLikeUserArg returns true if the word was likely meant as a user, i.e a mistyped id or mention
+func looksLikeUserArg(word string) bool {
+	if word == "" {
+		return false
+	}
+
+	return (word[0] >= '0' && word[0] <= '9') || strings.HasPrefix(word, "<@")
+}
+
+// repliedAuthorID returns the author of the message msg is a reply to, 0 if it's not a reply or the message is gone
+func repliedAuthorID(msg *discordgo.Message) int64 {
+	if msg == nil || msg.MessageReference == nil || msg.MessageReference.MessageID == 0 {
+		return 0
+	}
+
+	replied, err := session().ChannelMessage(msg.MessageReference.ChannelID, msg.MessageReference.MessageID)
+	if err != nil || replied.Author == nil {
+		return 0
+	}
+
+	return replied.Author.ID
+}
+
+// cmdConfirmation returns the terse 👌 confirmation, or the summary of what was done if the server has verbose confirmations enabled
+func cmdConfirmation(config *Config, summary string) string {
+	if config.VerboseConfirmations {
+		return "👌 " + summary
+	}
+
+	return "👌"
+}
+
 func GenericCmdResp(action ModlogAction, target *discordgo.User, duration time.Duration, zeroDurPermanent bool, noDur bool) string {
 	durStr := " indefinitely"
 	if duration > 0 || !zeroDurPermanent {
@@ -117,162 +297,1430 @@ func GenericCmdResp(action ModlogAction, target *discordgo.User, duration time.D
 		userStr = strconv.FormatInt(target.ID, 10)
 	}
 
-	return fmt.Sprintf("%s %s `%s`%s", action.Emoji, action.Prefix, userStr, durStr)
-}
+	return fmt.Sprintf("%s %s `%s`%s", action.Emoji, action.Prefix, userStr, durStr)
+}
+
+var ModerationCommands = []*commands.YAGCommand{
+	&commands.YAGCommand{
+		CustomEnabled:   true,
+		CmdCategory:     commands.CategoryModeration,
+		Name:            "Ban",
+		Aliases:         []string{"banid"},
+		Description:     "Bans a member, specify a duration with -d and specify number of days of messages to delete with -ddays (0 to 7)",
+		LongDescription: "With -logs the channel logs are created in the background and linked in the modlog entry once they're ready.\nLeave out the user when replying to one of their messages to ban the author.",
+		Arguments: []*dcmd.ArgDef{
+			&dcmd.ArgDef{Name: "User", Type: dcmd.UserID},
+			&dcmd.ArgDef{Name: "Reason", Type: dcmd.String},
+		},
+		ArgumentCombos: [][]int{[]int{0, 1}, []int{0}, []int{1}, []int{}},
+		ArgSwitches: []*dcmd.ArgDef{
+			&dcmd.ArgDef{Switch: "d", Default: time.Duration(0), Name: "Duration", Type: &commands.DurationArg{}},
+			&dcmd.ArgDef{Switch: "ddays", Default: 1, Name: "Days", Type: dcmd.Int},
+			&dcmd.ArgDef{Switch: "logs", Name: "Attach channel logs to the modlog entry"},
+			&dcmd.ArgDef{Switch: "confirm", Name: "Confirm banning a staff member or their linked accounts"},
+			&dcmd.ArgDef{Switch: "alts", Name: "Also ban their known linked accounts"},
+			noReasonSwitch,
+		},
+		RunFunc: func(parsed *dcmd.Data) (interface{}, error) {
+			targetID, err := cmdTargetID(parsed)
+			if err != nil {
+				return nil, err
+			}
+
+			config, target, err := MBaseCmd(parsed, targetID)
+			if err != nil {
+				return nil, err
+			}
+
+			reason := SafeArgString(parsed, 1)
+			if strings.TrimSpace(reason) == "" && config.ReasonOptional(config.BanReasonOptional) && parsed.Switches[noReasonSwitch.Switch].Value == nil {
+				reason = historyReason(config, parsed.GS.ID, target.ID)
+			}
+			reason, err = MBaseCmdSecond(parsed, reason, config.BanReasonOptional, discordgo.PermissionBanMembers, config.BanCmdRoles, config.BanRequireAllRoles, config.BanEnabled)
+			if err != nil {
+				return nil, err
+			}
+
+			alreadyBanned, err := userBanned(parsed.GS.ID, target.ID)
+			if err != nil {
+				return nil, err
+			}
+
+			if alreadyBanned && !config.BanUpdateExisting {
+				return "That user is already banned. To change the reason or duration by banning them again, enable updating existing bans in the control panel.", nil
+			}
+
+			if config.ConfirmBanStaff && (parsed.Switches["confirm"].Value == nil || !parsed.Switches["confirm"].Value.(bool)) {
+				if member, _ := bot.GetMember(parsed.GS.ID, target.ID); member != nil {
+					if role := banStaffRole(config, parsed.GS, member.Roles); role != "" {
+						return fmt.Sprintf("⚠ That user has the staff role **%s**, run the command again with `-confirm` if you really want to ban them.", role), nil
+					}
+				}
+			}
+
+			alts, altResp, err := cmdAltTargets(config, parsed, target, "ban")
+			if err != nil || altResp != "" {
+				return altResp, err
+			}
+
+			banFunc := BanUserWithDuration
+			if parsed.Switches["logs"].Value != nil && parsed.Switches["logs"].Value.(bool) {
+				banFunc = BanUserWithLogs
+			}
+
+			err = banFunc(config, parsed.GS.ID, parsed.CS, parsed.Msg, parsed.Msg.Author, reason, target, parsed.Switches["d"].Value.(time.Duration), parsed.Switches["ddays"].Int())
+			if err != nil {
+				return nil, err
+			}
+
+			resp := GenericCmdResp(MABanned, target, parsed.Switch("d").Value.(time.Duration), true, false)
+			if alreadyBanned {
+				resp += "\nThe user was already banned, their ban has been updated."
+			}
+
+			if len(alts) > 0 {
+				resp += actOnAlts(parsed.GS.ID, alts, MABanned, func(alt *discordgo.User) error {
+					return BanUserWithDuration(config, parsed.GS.ID, parsed.CS, parsed.Msg, parsed.Msg.Author, altReason(reason, target), alt, parsed.Switches["d"].Value.(time.Duration), parsed.Switches["ddays"].Int())
+				})
+			}
+
+			return resp, nil
+		},
+	},
+	&commands.YAGCommand{
+		CustomEnabled: true,
+		CmdCategory:   commands.CategoryModeration,
+		Name:          "UndoBan",
+		Description:   "Undoes your most recent ban, removing its modlog entry as well",
+		LongDescription: "Only works for a few minutes after the ban (set in the control panel), and only for the moderator that made it. Use it to take back a ban made by mistake, " +
+			"the user is unbanned without any trace of the ban left in the modlog. Linked accounts banned along with them using `-alts` and the bans synced to other servers are undone as well.",
+		RunFunc: func(parsed *dcmd.Data) (interface{}, error) {
+			config, _, err := MBaseCmd(parsed, 0)
+			if err != nil {
+				return nil, err
+			}
+
+			_, err = MBaseCmdSecond(parsed, "", true, discordgo.PermissionBanMembers, config.BanCmdRoles, config.BanRequireAllRoles, config.BanEnabled)
+			if err != nil {
+				return nil, err
+			}
+
+			window := config.BanUndoWindowDuration()
+			if window <= 0 {
+				return "Undoing bans is disabled on this server", nil
+			}
+
+			bans, err := takeLastBans(parsed.GS.ID, parsed.Msg.Author.ID)
+			if err != nil {
+				return nil, err
+			}
+
+			if len(bans) < 1 {
+				return fmt.Sprintf("You haven't banned anyone in the last %s, only your most recent ban can be undone",
+					common.HumanizeDuration(common.DurationPrecisionMinutes, window)), nil
+			}
+
+			undone := make([]*lastBan, 0, len(bans))
+			for _, v := range bans {
+				err = undoBan(config, v)
+				if err != nil {
+					logger.WithError(err).WithField("guild", parsed.GS.ID).WithField("user", v.UserID).Error("failed undoing ban")
+					continue
+				}
+
+				undone = append(undone, v)
+				go publishBanSyncUndo(config, parsed.GS.ID, v)
+			}
+
+			if len(undone) < 1 {
+				return nil, err
+			}
+
+			return undoBanResponse(undone, len(bans)-len(undone), time.Now()), nil
+		},
+	},
+	&commands.YAGCommand{
+		CustomEnabled:   true,
+		CmdCategory:     commands.CategoryModeration,
+		Name:            "Kick",
+		Description:     "Kicks a member",
+		LongDescription: "Leave out the user when replying to one of their messages to kick the author.",
+		Arguments: []*dcmd.ArgDef{
+			&dcmd.ArgDef{Name: "User", Type: dcmd.UserID},
+			&dcmd.ArgDef{Name: "Reason", Type: dcmd.String},
+		},
+		ArgSwitches: []*dcmd.ArgDef{
+			noReasonSwitch,
+		},
+		ArgumentCombos: [][]int{[]int{0, 1}, []int{0}, []int{1}, []int{}},
+		RunFunc: func(parsed *dcmd.Data) (interface{}, error) {
+			targetID, err := cmdTargetID(parsed)
+			if err != nil {
+				return nil, err
+			}
+
+			config, target, err := MBaseCmd(parsed, targetID)
+			if err != nil {
+				return nil, err
+			}
+
+			reason := SafeArgString(parsed, 1)
+			if strings.TrimSpace(reason) == "" && config.ReasonOptional(config.KickReasonOptional) && parsed.Switches[noReasonSwitch.Switch].Value == nil {
+				reason = historyReason(config, parsed.GS.ID, target.ID)
+			}
+			reason, err = MBaseCmdSecond(parsed, reason, config.KickReasonOptional, discordgo.PermissionKickMembers, config.KickCmdRoles, config.KickRequireAllRoles, config.KickEnabled)
+			if err != nil {
+				return nil, err
+			}
+
+			err = KickUser(config, parsed.GS.ID, parsed.CS, parsed.Msg, parsed.Msg.Author, reason, target)
+			if err != nil {
+				return nil, err
+			}
+
+			return GenericCmdResp(MAKick, target, 0, true, true), nil
+		},
+	},
+	&commands.YAGCommand{
+		CustomEnabled:   true,
+		CmdCategory:     commands.CategoryModeration,
+		Name:            "Mute",
+		Description:     "Mutes a member, use -role to pick one of the mute tiers set up in the control panel",
+		LongDescription: "Leave out the user when replying to one of their messages to mute the author.",
+		Arguments: []*dcmd.ArgDef{
+			&dcmd.ArgDef{Name: "User", Type: dcmd.UserID},
+			&dcmd.ArgDef{Name: "Duration", Type: &commands.DurationArg{}},
+			&dcmd.ArgDef{Name: "Reason", Type: dcmd.String},
+		},
+		ArgSwitches: []*dcmd.ArgDef{
+			&dcmd.ArgDef{Switch: "role", Name: "Mute tier", Type: dcmd.String},
+			&dcmd.ArgDef{Switch: "clean", Name: "Also delete this many of their recent messages in this channel", Type: dcmd.Int},
+			&dcmd.ArgDef{Switch: "alts", Name: "Also mute their known linked accounts"},
+			&dcmd.ArgDef{Switch: "confirm", Name: "Confirm muting their linked accounts"},
+			noReasonSwitch,
+		},
+		ArgumentCombos: [][]int{[]int{0, 1, 2}, []int{0, 2, 1}, []int{0, 1}, []int{0, 2}, []int{0}, []int{1, 2}, []int{2, 1}, []int{1}, []int{2}, []int{}},
+		RunFunc: func(parsed *dcmd.Data) (interface{}, error) {
+			targetID, err := cmdTargetID(parsed)
+			if err != nil {
+				return nil, err
+			}
+
+			config, target, err := MBaseCmd(parsed, targetID)
+			if err != nil {
+				return nil, err
+			}
+
+			if config.MuteRole == "" {
+				return "No mute role set up, assign a mute role in the control panel", nil
+			}
+
+			reason := parsed.Args[2].Str()
+			if strings.TrimSpace(reason) == "" && parsed.Switches[noReasonSwitch.Switch].Value == nil {
+				reason = automodReason(config, parsed.GS.ID, target.ID)
+			}
+			if strings.TrimSpace(reason) == "" && config.ReasonOptional(config.MuteReasonOptional) && parsed.Switches[noReasonSwitch.Switch].Value == nil {
+				reason = historyReason(config, parsed.GS.ID, target.ID)
+			}
+			reason, err = MBaseCmdSecond(parsed, reason, config.MuteReasonOptional, discordgo.PermissionKickMembers, config.MuteCmdRoles, config.MuteRequireAllRoles, config.MuteEnabled)
+			if err != nil {
+				return nil, err
+			}
+
+			d := time.Duration(config.DefaultMuteDuration.Int64) * time.Minute
+			if parsed.Args[1].Value != nil {
+				d = parsed.Args[1].Value.(time.Duration)
+			}
+			if d > 0 && d < time.Minute {
+				d = time.Minute
+			}
+
+			logger.Info(d.Seconds())
+
+			var muteRole int64
+			if parsed.Switches["role"].Value != nil {
+				tier := config.FindMuteTier(parsed.Switches["role"].Str())
+				if tier == nil {
+					if len(config.MuteTiers()) < 1 {
+						return "No mute tiers set up, only the default mute role can be used", nil
+					}
+
+					return "Unknown mute tier, available: " + config.MuteTierNamesList(), nil
+				}
+				muteRole = tier.Role
+			}
+
+			left, err := muteCooldownLeft(config, parsed.GS.ID, target.ID)
+			if err != nil {
+				return nil, err
+			}
+			if left > 0 {
+				return fmt.Sprintf("This user was muted recently, they can be muted again in `%s`", common.HumanizeDuration(common.DurationPrecisionSeconds, left)), nil
+			}
+
+			member, err := bot.GetMember(parsed.GS.ID, target.ID)
+			if err != nil || member == nil {
+				return "Member not found", err
+			}
+
+			alts, altResp, err := cmdAltTargets(config, parsed, target, "mute")
+			if err != nil || altResp != "" {
+				return altResp, err
+			}
+
+			cleaned := -1
+			if parsed.Switches["clean"].Value != nil {
+				hasPerms, err := bot.AdminOrPermMS(parsed.CS.ID, commands.ContextMS(parsed.Context()), discordgo.PermissionManageMessages)
+				if err != nil || !hasPerms {
+					return "You need the Manage Messages permission to use -clean", err
+				}
+
+				num := parsed.Switches["clean"].Int()
+				if num < 1 || num > 100 {
+					return "-clean has to be between 1 and 100", nil
+				}
+
+				cleaned, err = AdvancedDeleteMessages(parsed.CS.ID, target.ID, "", 0, 0, false, num, 1000)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			// escalation applies to each of the linked accounts separately
+			requested := int(d.Minutes())
+			result, err := muteUnmuteUser(config, true, parsed.GS.ID, parsed.CS, parsed.Msg, parsed.Msg.Author, reason, member, requested, muteRole)
+			if err != nil {
+				return nil, err
+			}
+
+			trackMute(config, parsed.GS.ID, parsed.Msg.Author, target)
+
+			d = time.Duration(result.Duration) * time.Minute
+			resp := GenericCmdResp(MAMute, target, d, true, false)
+			if cleaned >= 0 {
+				resp += fmt.Sprintf("\n🧹 Deleted %d of their message(s) in this channel.", cleaned)
+			}
+			if result.Escalated {
+				resp += fmt.Sprintf("\n⏫ This is mute #%d for this user, so the duration was escalated.", result.PreviousMutes+1)
+			}
+
+			if result.Kicked {
+				resp += "\n" + MAKick.Emoji + " The total mute duration exceeded the auto-kick threshold set by the server admins, so the user was also kicked."
+			}
+
+			if len(alts) > 0 {
+				resp += actOnAlts(parsed.GS.ID, alts, MAMute, func(alt *discordgo.User) error {
+					altMember, err := bot.GetMember(parsed.GS.ID, alt.ID)
+					if err != nil || altMember == nil {
+						return errors.New("not in the server")
+					}
+
+					_, err = muteUnmuteUser(config, true, parsed.GS.ID, parsed.CS, parsed.Msg, parsed.Msg.Author, altReason(reason, target), altMember, requested, muteRole)
+					return err
+				})
+			}
+
+			return resp, nil
+		},
+	},
+	&commands.YAGCommand{
+		CustomEnabled: true,
+		CmdCategory:   commands.CategoryModeration,
+		Name:          "MassMute",
+		Description:   fmt.Sprintf("Mutes all members with the specified role (max %d), staff and members ranked above you are skipped", MaxMassActionTargets),
+		RequiredArgs:  1,
+		Arguments: []*dcmd.ArgDef{
+			&dcmd.ArgDef{Name: "Role", Type: dcmd.String},
+			&dcmd.ArgDef{Name: "Duration", Type: &commands.DurationArg{}},
+			&dcmd.ArgDef{Name: "Reason", Type: dcmd.String},
+		},
+		ArgSwitches: []*dcmd.ArgDef{
+			&dcmd.ArgDef{Switch: "confirm", Name: "Confirm the mass mute"},
+		},
+		ArgumentCombos: [][]int{[]int{0, 1, 2}, []int{0, 2, 1}, []int{0, 1}, []int{0, 2}, []int{0}},
+		RunFunc: func(parsed *dcmd.Data) (interface{}, error) {
+			config, _, err := MBaseCmd(parsed, 0)
+			if err != nil {
+				return nil, err
+			}
+
+			if config.MuteRole == "" {
+				return "No mute role set up, assign a mute role in the control panel", nil
+			}
+
+			reason := SafeArgString(parsed, 2)
+			reason, err = MBaseCmdSecond(parsed, reason, config.MuteReasonOptional, discordgo.PermissionKickMembers, config.MuteCmdRoles, config.MuteRequireAllRoles, config.MuteEnabled)
+			if err != nil {
+				return nil, err
+			}
+
+			err = massActionPermsErr(config, parsed.CS.ID, commands.ContextMS(parsed.Context()))
+			if err != nil {
+				return nil, err
+			}
+
+			role := FindRole(parsed.GS, parsed.Args[0].Str())
+			if role == nil {
+				return "Couldn't find the specified role", nil
+			}
+
+			d := time.Duration(config.DefaultMuteDuration.Int64) * time.Minute
+			if parsed.Args[1].Value != nil {
+				d = parsed.Args[1].Value.(time.Duration)
+			}
+			if d > 0 && d < time.Minute {
+				d = time.Minute
+			}
+
+			staffRoles := make([]int64, 0, len(config.MuteCmdRoles)+len(config.KickCmdRoles)+len(config.BanCmdRoles))
+			staffRoles = append(staffRoles, config.MuteCmdRoles...)
+			staffRoles = append(staffRoles, config.KickCmdRoles...)
+			staffRoles = append(staffRoles, config.BanCmdRoles...)
+
+			targets, skipped := membersWithRole(parsed.GS, commands.ContextMS(parsed.Context()), role.ID, staffRoles)
+			if len(targets) < 1 {
+				return fmt.Sprintf("No members to mute with that role (skipped %d staff/higher ranked member(s))", skipped), nil
+			}
+
+			if len(targets) > MaxMassActionTargets {
+				return fmt.Sprintf("That role has %d members that would be muted, the max is %d", len(targets), MaxMassActionTargets), nil
+			}
+
+			if parsed.Switches["confirm"].Value == nil || !parsed.Switches["confirm"].Value.(bool) {
+				return fmt.Sprintf("This will mute **%d** member(s) with the role %s (skipping %d staff/higher ranked member(s)), run the command again with `-confirm` to proceed.", len(targets), role.Name, skipped), nil
+			}
+
+			progress, err := session().ChannelMessageSend(parsed.CS.ID, fmt.Sprintf("Muting members with the role %s... (0/%d)", role.Name, len(targets)))
+			if err != nil {
+				return nil, err
+			}
+
+			go runMassMute(config, parsed.GS, parsed.CS, parsed.Msg.Author, role, targets, d, reason, progress)
+			return nil, nil
+		},
+	},
+	&commands.YAGCommand{
+		CustomEnabled: true,
+		CmdCategory:   commands.CategoryModeration,
+		Name:          "VoiceAction",
+		Aliases:       []string{"massvoice"},
+		Description:   fmt.Sprintf("Mutes or kicks everyone in a voice channel (max %d), staff and members ranked above you are skipped", MaxMassActionTargets),
+		LongDescription: "Action is either `mute` or `kick`, the duration only applies to mutes." +
+			"\nUseful for dealing with a raided voice channel.",
+		RequiredArgs: 2,
+		Arguments: []*dcmd.ArgDef{
+			&dcmd.ArgDef{Name: "Action", Type: dcmd.String},
+			&dcmd.ArgDef{Name: "Channel", Type: dcmd.String},
+			&dcmd.ArgDef{Name: "Duration", Type: &commands.DurationArg{}},
+			&dcmd.ArgDef{Name: "Reason", Type: dcmd.String},
+		},
+		ArgSwitches: []*dcmd.ArgDef{
+			&dcmd.ArgDef{Switch: "confirm", Name: "Confirm the action"},
+		},
+		ArgumentCombos: [][]int{[]int{0, 1, 2, 3}, []int{0, 1, 3, 2}, []int{0, 1, 2}, []int{0, 1, 3}, []int{0, 1}},
+		RunFunc: func(parsed *dcmd.Data) (interface{}, error) {
+			config, _, err := MBaseCmd(parsed, 0)
+			if err != nil {
+				return nil, err
+			}
+
+			var kick bool
+			switch strings.ToLower(parsed.Args[0].Str()) {
+			case "mute":
+			case "kick":
+				kick = true
+			default:
+				return "Unknown action, use either `mute` or `kick`", nil
+			}
+
+			reason := SafeArgString(parsed, 3)
+			if kick {
+				reason, err = MBaseCmdSecond(parsed, reason, config.KickReasonOptional, discordgo.PermissionKickMembers, config.KickCmdRoles, config.KickRequireAllRoles, config.KickEnabled)
+			} else {
+				if config.MuteRole == "" {
+					return "No mute role set up, assign a mute role in the control panel", nil
+				}
+				reason, err = MBaseCmdSecond(parsed, reason, config.MuteReasonOptional, discordgo.PermissionKickMembers, config.MuteCmdRoles, config.MuteRequireAllRoles, config.MuteEnabled)
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			err = massActionPermsErr(config, parsed.CS.ID, commands.ContextMS(parsed.Context()))
+			if err != nil {
+				return nil, err
+			}
+
+			vc := findVoiceChannel(parsed.GS, parsed.Args[1].Str())
+			if vc == nil {
+				return "Couldn't find the specified voice channel", nil
+			}
+
+			d := time.Duration(config.DefaultMuteDuration.Int64) * time.Minute
+			if parsed.Args[2].Value != nil {
+				d = parsed.Args[2].Value.(time.Duration)
+			}
+			if d > 0 && d < time.Minute {
+				d = time.Minute
+			}
+
+			staffRoles := make([]int64, 0, len(config.MuteCmdRoles)+len(config.KickCmdRoles)+len(config.BanCmdRoles))
+			staffRoles = append(staffRoles, config.MuteCmdRoles...)
+			staffRoles = append(staffRoles, config.KickCmdRoles...)
+			staffRoles = append(staffRoles, config.BanCmdRoles...)
+
+			targets, skipped := membersInVoice(parsed.GS, commands.ContextMS(parsed.Context()), vc.ID, staffRoles)
+			if len(targets) < 1 {
+				return fmt.Sprintf("No members to act on in that voice channel (skipped %d staff/higher ranked member(s))", skipped), nil
+			}
+
+			if len(targets) > MaxMassActionTargets {
+				return fmt.Sprintf("That voice channel has %d members that would be affected, the max is %d", len(targets), MaxMassActionTargets), nil
+			}
+
+			verb := "mute"
+			if kick {
+				verb = "kick"
+			}
+
+			if parsed.Switches["confirm"].Value == nil || !parsed.Switches["confirm"].Value.(bool) {
+				return fmt.Sprintf("This will %s **%d** member(s) in %s (skipping %d staff/higher ranked member(s)), run the command again with `-confirm` to proceed.", verb, len(targets), vc.Name, skipped), nil
+			}
+
+			progress, err := session().ChannelMessageSend(parsed.CS.ID, fmt.Sprintf("Acting on members in %s... (0/%d)", vc.Name, len(targets)))
+			if err != nil {
+				return nil, err
+			}
+
+			go runVoiceAction(config, parsed.GS, parsed.CS, parsed.Msg.Author, vc, kick, targets, d, reason, progress)
+			return nil, nil
+		},
+	},
+	&commands.YAGCommand{
+		CustomEnabled: true,
+		CmdCategory:   commands.CategoryModeration,
+		Name:          "PatternAction",
+		Aliases:       []string{"massmatch"},
+		Description:   fmt.Sprintf("Bans or mutes everyone that posted messages matching a regex (max %d), staff and members ranked above you are skipped", MaxMassActionTargets),
+		LongDescription: fmt.Sprintf("Action is either `ban` or `mute`. Scans the last %d messages in this channel (or the one given with -c),", PatternScanChannelMessages) +
+			fmt.Sprintf(" or the last %d messages in up to %d channels with -server.", PatternScanGuildMessages, PatternScanGuildMaxChannels) +
+			"\nUseful for dealing with scam link raids, e.g `patternaction ban \"discord-gift\\.\" scam links -server`.",
+		RequiredArgs: 2,
+		Arguments: []*dcmd.ArgDef{
+			&dcmd.ArgDef{Name: "Action", Type: dcmd.String},
+			&dcmd.ArgDef{Name: "Pattern", Type: dcmd.String},
+			&dcmd.ArgDef{Name: "Reason", Type: dcmd.String},
+		},
+		ArgSwitches: []*dcmd.ArgDef{
+			&dcmd.ArgDef{Switch: "c", Name: "Channel to scan", Type: dcmd.Channel},
+			&dcmd.ArgDef{Switch: "server", Name: "Scan the whole server"},
+			&dcmd.ArgDef{Switch: "d", Default: time.Duration(0), Name: "Duration", Type: &commands.DurationArg{}},
+			&dcmd.ArgDef{Switch: "confirm", Name: "Confirm the action"},
+		},
+		RunFunc: func(parsed *dcmd.Data) (interface{}, error) {
+			config, _, err := MBaseCmd(parsed, 0)
+			if err != nil {
+				return nil, err
+			}
+
+			var ban bool
+			switch strings.ToLower(parsed.Args[0].Str()) {
+			case "mute":
+			case "ban":
+				ban = true
+			default:
+				return "Unknown action, use either `ban` or `mute`", nil
+			}
+
+			reason := SafeArgString(parsed, 2)
+			if ban {
+				reason, err = MBaseCmdSecond(parsed, reason, config.BanReasonOptional, discordgo.PermissionBanMembers, config.BanCmdRoles, config.BanRequireAllRoles, config.BanEnabled)
+			} else {
+				if config.MuteRole == "" {
+					return "No mute role set up, assign a mute role in the control panel", nil
+				}
+				reason, err = MBaseCmdSecond(parsed, reason, config.MuteReasonOptional, discordgo.PermissionKickMembers, config.MuteCmdRoles, config.MuteRequireAllRoles, config.MuteEnabled)
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			err = massActionPermsErr(config, parsed.CS.ID, commands.ContextMS(parsed.Context()))
+			if err != nil {
+				return nil, err
+			}
+
+			re, err := regexp.Compile(parsed.Args[1].Str())
+			if err != nil {
+				return nil, commands.NewUserErrorf("Invalid pattern: %s", err.Error())
+			}
+
+			d := parsed.Switches["d"].Value.(time.Duration)
+			if !ban && d == 0 {
+				d = time.Duration(config.DefaultMuteDuration.Int64) * time.Minute
+			}
+			if d > 0 && d < time.Minute {
+				d = time.Minute
+			}
+
+			channelID := parsed.CS.ID
+			if parsed.Switches["c"].Value != nil {
+				channelID = parsed.Switches["c"].Value.(*dstate.ChannelState).ID
+			}
+
+			guildWide := parsed.Switches["server"].Value != nil && parsed.Switches["server"].Value.(bool)
+			perChannel := PatternScanChannelMessages
+			if guildWide {
+				perChannel = PatternScanGuildMessages
+			}
+
+			matches, scanned, err := scanPatternAuthors(patternScanChannels(parsed.GS, channelID, guildWide), re, perChannel)
+			if err != nil {
+				return nil, err
+			}
+
+			staffRoles := make([]int64, 0, len(config.MuteCmdRoles)+len(config.KickCmdRoles)+len(config.BanCmdRoles))
+			staffRoles = append(staffRoles, config.MuteCmdRoles...)
+			staffRoles = append(staffRoles, config.KickCmdRoles...)
+			staffRoles = append(staffRoles, config.BanCmdRoles...)
+
+			targets, skipped := patternTargets(parsed.GS, commands.ContextMS(parsed.Context()), matches, staffRoles, ban)
+			if len(targets) < 1 {
+				return fmt.Sprintf("No authors to act on in the last %d messages (skipped %d staff/higher ranked/bot author(s))", scanned, skipped), nil
+			}
+
+			if len(targets) > MaxMassActionTargets {
+				return fmt.Sprintf("Found %d authors that would be affected, the max is %d. Try a more specific pattern.", len(targets), MaxMassActionTargets), nil
+			}
+
+			verb := "mute"
+			if ban {
+				verb = "ban"
+			}
+
+			if parsed.Switches["confirm"].Value == nil || !parsed.Switches["confirm"].Value.(bool) {
+				return patternConfirmation(verb, targets, skipped, scanned), nil
+			}
+
+			progress, err := session().ChannelMessageSend(parsed.CS.ID, fmt.Sprintf("Acting on matching authors... (0/%d)", len(targets)))
+			if err != nil {
+				return nil, err
+			}
+
+			go runPatternAction(config, parsed.GS, parsed.CS, parsed.Msg.Author, ban, targets, d, reason, progress)
+			return nil, nil
+		},
+	},
+	&commands.YAGCommand{
+		CustomEnabled: true,
+		CmdCategory:   commands.CategoryModeration,
+		Name:          "MigrateMuteRole",
+		Description:   "Changes the mute role and moves all currently muted members from the old role to the new one",
+		RequiredArgs:  2,
+		Arguments: []*dcmd.ArgDef{
+			&dcmd.ArgDef{Name: "OldRole", Type: dcmd.String},
+			&dcmd.ArgDef{Name: "NewRole", Type: dcmd.String},
+		},
+		RunFunc: func(parsed *dcmd.Data) (interface{}, error) {
+			config, _, err := MBaseCmd(parsed, 0)
+			if err != nil {
+				return nil, err
+			}
+
+			_, err = MBaseCmdSecond(parsed, "", true, discordgo.PermissionManageServer, nil, false, true)
+			if err != nil {
+				return nil, err
+			}
+
+			oldRole := FindRole(parsed.GS, parsed.Args[0].Str())
+			newRole := FindRole(parsed.GS, parsed.Args[1].Str())
+			if oldRole == nil || newRole == nil {
+				return "Couldn't find the specified role", nil
+			}
+
+			if oldRole.ID == newRole.ID {
+				return "The old and new role are the same", nil
+			}
+
+			// update the config first so the member update handlers and new mutes use the new role right away
+			config.MuteRole = discordgo.StrID(newRole.ID)
+			err = config.Save(parsed.GS.ID)
+			if err != nil {
+				return nil, err
+			}
+
+			progress, err := session().ChannelMessageSend(parsed.CS.ID, fmt.Sprintf("Migrating muted members from %s to %s...", oldRole.Name, newRole.Name))
+			if err != nil {
+				return nil, err
+			}
+
+			go runMigrateMuteRole(parsed.GS, oldRole, newRole, progress)
+			return nil, nil
+		},
+	},
+	&commands.YAGCommand{
+		CustomEnabled: true,
+		CmdCategory:   commands.CategoryModeration,
+		Name:          "RetryScheduled",
+		Description:   "Retries a timed unban or unmute that failed, running it right away",
+		RequiredArgs:  2,
+		Arguments: []*dcmd.ArgDef{
+			&dcmd.ArgDef{Name: "Action", Type: dcmd.String, Help: "unban or unmute"},
+			&dcmd.ArgDef{Name: "User", Type: dcmd.UserID},
+		},
+		RunFunc: func(parsed *dcmd.Data) (interface{}, error) {
+			_, _, err := MBaseCmd(parsed, 0)
+			if err != nil {
+				return nil, err
+			}
+
+			_, err = MBaseCmdSecond(parsed, "", true, discordgo.PermissionManageServer, nil, false, true)
+			if err != nil {
+				return nil, err
+			}
+
+			userID := parsed.Args[1].Int64()
+
+			var eventName string
+			switch strings.ToLower(parsed.Args[0].Str()) {
+			case "unban":
+				eventName = "moderation_unban"
+			case "unmute":
+				eventName = "moderation_unmute"
+
+				var mute MuteModel
+				err = common.GORM.Where(&MuteModel{UserID: userID, GuildID: parsed.GS.ID}).First(&mute).Error
+				if err == gorm.ErrRecordNotFound {
+					return "That user isn't muted", nil
+				} else if err != nil {
+					return nil, err
+				}
+			default:
+				return "Unknown action, use either `unban` or `unmute`", nil
+			}
+
+			done, retry, err := retryScheduledAction(parsed.GS.ID, userID, eventName)
+			if err != nil {
+				resp := fmt.Sprintf("Retrying the %s failed: %s", strings.ToLower(parsed.Args[0].Str()), err.Error())
+				if retry {
+					resp += "\nThis looks temporary, try again in a bit."
+				}
+				return resp, nil
+			}
+
+			if !done {
+				return "Nothing was done as there's no mute role set up, set one in the control panel and try again", nil
+			}
+
+			return fmt.Sprintf("Successfully retried the %s of <@%d>", strings.ToLower(parsed.Args[0].Str()), userID), nil
+		},
+	},
+	&commands.YAGCommand{
+		CustomEnabled: true,
+		CmdCategory:   commands.CategoryModeration,
+		Name:          "Unmute",
+		Description:   "Unmutes a member",
+		RequiredArgs:  1,
+		Arguments: []*dcmd.ArgDef{
+			&dcmd.ArgDef{Name: "User", Type: dcmd.UserID},
+			&dcmd.ArgDef{Name: "Reason", Type: dcmd.String},
+		},
+		ArgSwitches: []*dcmd.ArgDef{
+			noReasonSwitch,
+		},
+		RunFunc: func(parsed *dcmd.Data) (interface{}, error) {
+			config, target, err := MBaseCmd(parsed, parsed.Args[0].Int64())
+			if err != nil {
+				return nil, err
+			}
+
+			if config.MuteRole == "" {
+				return "No mute role set up, assign a mute role in the control panel", nil
+			}
+
+			reason := parsed.Args[1].Str()
+			reason, err = MBaseCmdSecond(parsed, reason, config.UnmuteReasonOptional, discordgo.PermissionKickMembers, config.MuteCmdRoles, config.MuteRequireAllRoles, config.MuteEnabled)
+			if err != nil {
+				return nil, err
+			}
+
+			member, err := bot.GetMember(parsed.GS.ID, target.ID)
+			if err != nil || member == nil {
+				// they left, but still clear the mute so they aren't muted again when rejoining
+				cleared, err := clearDepartedMute(config, parsed.GS.ID, parsed.Msg.Author, target, reason)
+				if err != nil {
+					return nil, err
+				}
+
+				if cleared {
+					return GenericCmdResp(MAUnmute, target, 0, false, true) + " (they're not in the server, their mute was cleared)", nil
+				}
+
+				return "Member not found", nil
+			}
+
+			result, err := muteUnmuteUser(config, false, parsed.GS.ID, parsed.CS, parsed.Msg, parsed.Msg.Author, reason, member, 0, 0)
+			if err != nil {
+				return nil, err
+			}
+
+			if result.WasMuted {
+				givePostMuteRole(config, parsed.GS.ID, target, true)
+			}
+
+			return GenericCmdResp(MAUnmute, target, 0, false, true), nil
+		},
+	},
+	&commands.YAGCommand{
+		CustomEnabled: true,
+		CmdCategory:   commands.CategoryModeration,
+		Name:          "UnmuteAll",
+		Description:   "Unmutes everyone that's currently muted, for amnesties or undoing a mass mute",
+		Arguments: []*dcmd.ArgDef{
+			&dcmd.ArgDef{Name: "Reason", Type: dcmd.String},
+		},
+		ArgSwitches: []*dcmd.ArgDef{
+			&dcmd.ArgDef{Switch: "confirm", Name: "Confirm unmuting everyone"},
+		},
+		RunFunc: func(parsed *dcmd.Data) (interface{}, error) {
+			config, _, err := MBaseCmd(parsed, 0)
+			if err != nil {
+				return nil, err
+			}
+
+			if config.MuteRole == "" {
+				return "No mute role set up, assign a mute role in the control panel", nil
+			}
+
+			reason := parsed.Args[0].Str()
+			reason, err = MBaseCmdSecond(parsed, reason, config.UnmuteReasonOptional, discordgo.PermissionManageServer, nil, false, config.MuteEnabled)
+			if err != nil {
+				return nil, err
+			}
+
+			err = massActionPermsErr(config, parsed.CS.ID, commands.ContextMS(parsed.Context()))
+			if err != nil {
+				return nil, err
+			}
+
+			var mutes []*MuteModel
+			err = common.GORM.Where("guild_id = ?", parsed.GS.ID).Find(&mutes).Error
+			if err != nil {
+				return nil, err
+			}
+
+			if len(mutes) < 1 {
+				return "Nobody is muted", nil
+			}
+
+			if parsed.Switches["confirm"].Value == nil || !parsed.Switches["confirm"].Value.(bool) {
+				return fmt.Sprintf("This will unmute **%d** member(s), run the command again with `-confirm` to proceed.", len(mutes)), nil
+			}
+
+			progress, err := session().ChannelMessageSend(parsed.CS.ID, fmt.Sprintf("Unmuting all muted members... (0/%d)", len(mutes)))
+			if err != nil {
+				return nil, err
+			}
+
+			go runUnmuteAll(config, parsed.GS, parsed.CS, parsed.Msg.Author, mutes, reason, progress)
+			return nil, nil
+		},
+	},
+	&commands.YAGCommand{
+		CustomEnabled: true,
+		CmdCategory:   commands.CategoryModeration,
+		Name:          "TimeLeft",
+		Description:   "Shows how much longer a user is muted or banned",
+		RequiredArgs:  1,
+		Arguments: []*dcmd.ArgDef{
+			&dcmd.ArgDef{Name: "User", Type: dcmd.UserID},
+		},
+		RunFunc: func(parsed *dcmd.Data) (interface{}, error) {
+			_, target, err := MBaseCmd(parsed, parsed.Args[0].Int64())
+			if err != nil {
+				return nil, err
+			}
+
+			_, err = MBaseCmdSecond(parsed, "", true, discordgo.PermissionKickMembers, nil, false, true)
+			if err != nil {
+				return nil, err
+			}
+
+			resp, err := timeLeftResponse(parsed.GS.ID, target.ID)
+			if err != nil {
+				return nil, err
+			}
+
+			return fmt.Sprintf("%s#%s (`%d`)\n%s", target.Username, target.Discriminator, target.ID, resp), nil
+		},
+	},
+	&commands.YAGCommand{
+		CustomEnabled:   true,
+		CmdCategory:     commands.CategoryModeration,
+		Name:            "ShadowMute",
+		Description:     "Mutes a member through channel overwrites instead of the mute role, without DMing them",
+		LongDescription: "The command message is deleted and you're confirmed through a DM instead of a reply. It's logged in the private audit channel, or the report channel if there's none, never in the modlog. Channels created while they're shadow muted are not covered, see the control panel for details.",
+		RequiredArgs:    1,
+		Arguments: []*dcmd.ArgDef{
+			&dcmd.ArgDef{Name: "User", Type: dcmd.UserID},
+			&dcmd.ArgDef{Name: "Duration", Type: &commands.DurationArg{}},
+			&dcmd.ArgDef{Name: "Reason", Type: dcmd.String},
+		},
+		ArgSwitches: []*dcmd.ArgDef{
+			noReasonSwitch,
+		},
+		ArgumentCombos: [][]int{[]int{0, 1, 2}, []int{0, 2, 1}, []int{0, 1}, []int{0, 2}, []int{0}},
+		RunFunc: func(parsed *dcmd.Data) (interface{}, error) {
+			config, target, err := MBaseCmd(parsed, parsed.Args[0].Int64())
+			if err != nil {
+				return nil, err
+			}
+
+			reason, err := MBaseCmdSecond(parsed, SafeArgString(parsed, 2), config.MuteReasonOptional, discordgo.PermissionKickMembers, config.MuteCmdRoles, config.MuteRequireAllRoles, config.ShadowMuteEnabled)
+			if err != nil {
+				return nil, err
+			}
+
+			var d time.Duration
+			if parsed.Args[1].Value != nil {
+				d = parsed.Args[1].Value.(time.Duration)
+			}
+			if d > 0 && d < time.Minute {
+				d = time.Minute
+			}
+
+			// don't leave the command lying around for them to see
+			if parsed.Source != 0 {
+				session().ChannelMessageDelete(parsed.Msg.ChannelID, parsed.Msg.ID)
+			}
+
+			err = ShadowMuteUser(config, parsed.GS.ID, parsed.Msg.Author, target, reason, d)
+			if err != nil {
+				return nil, err
+			}
+
+			go confirmShadowAction(parsed.GS.ID, parsed.Msg.Author, GenericCmdResp(MAShadowMute, target, d, true, false))
+			return nil, nil
+		},
+	},
+	&commands.YAGCommand{
+		CustomEnabled: true,
+		CmdCategory:   commands.CategoryModeration,
+		Name:          "ShadowUnmute",
+		Description:   "Lifts the shadow mute of a member",
+		RequiredArgs:  1,
+		Arguments: []*dcmd.ArgDef{
+			&dcmd.ArgDef{Name: "User", Type: dcmd.UserID},
+			&dcmd.ArgDef{Name: "Reason", Type: dcmd.String},
+		},
+		ArgSwitches: []*dcmd.ArgDef{
+			noReasonSwitch,
+		},
+		RunFunc: func(parsed *dcmd.Data) (interface{}, error) {
+			config, target, err := MBaseCmd(parsed, parsed.Args[0].Int64())
+			if err != nil {
+				return nil, err
+			}
+
+			reason, err := MBaseCmdSecond(parsed, SafeArgString(parsed, 1), config.UnmuteReasonOptional, discordgo.PermissionKickMembers, config.MuteCmdRoles, config.MuteRequireAllRoles, config.ShadowMuteEnabled)
+			if err != nil {
+				return nil, err
+			}
+
+			if parsed.Source != 0 {
+				session().ChannelMessageDelete(parsed.Msg.ChannelID, parsed.Msg.ID)
+			}
+
+			unmuted, err := ShadowUnmuteUser(config, parsed.GS.ID, parsed.Msg.Author, target, reason)
+			if err != nil {
+				return nil, err
+			}
+
+			resp := GenericCmdResp(MAShadowUnmute, target, 0, false, true)
+			if !unmuted {
+				resp = "That user isn't shadow muted"
+			}
+
+			go confirmShadowAction(parsed.GS.ID, parsed.Msg.Author, resp)
+			return nil, nil
+		},
+	},
+	&commands.YAGCommand{
+		CustomEnabled: true,
+		CmdCategory:   commands.CategoryModeration,
+		Name:          "Timeout",
+		Description:   "Puts a member in a native discord timeout, max duration is 28 days",
+		RequiredArgs:  2,
+		Arguments: []*dcmd.ArgDef{
+			&dcmd.ArgDef{Name: "User", Type: dcmd.UserID},
+			&dcmd.ArgDef{Name: "Duration", Type: &commands.DurationArg{}},
+			&dcmd.ArgDef{Name: "Reason", Type: dcmd.String},
+		},
+		ArgSwitches: []*dcmd.ArgDef{
+			noReasonSwitch,
+		},
+		RunFunc: func(parsed *dcmd.Data) (interface{}, error) {
+			config, target, err := MBaseCmd(parsed, parsed.Args[0].Int64())
+			if err != nil {
+				return nil, err
+			}
+
+			reason := SafeArgString(parsed, 2)
+			reason, err = MBaseCmdSecond(parsed, reason, config.TimeoutReasonOptional, discordgo.PermissionKickMembers, config.TimeoutCmdRoles, config.TimeoutRequireAllRoles, config.TimeoutEnabled)
+			if err != nil {
+				return nil, err
+			}
+
+			d := parsed.Args[1].Value.(time.Duration)
+			if d < time.Minute {
+				d = time.Minute
+			}
+			if d > MaxTimeoutDuration {
+				return "Timeouts can't be longer than 28 days", nil
+			}
+
+			err = TimeoutUser(config, parsed.GS.ID, parsed.CS, parsed.Msg, parsed.Msg.Author, reason, target, d)
+			if err != nil {
+				return nil, err
+			}
+
+			return GenericCmdResp(MATimeoutAdded, target, d, true, false), nil
+		},
+	},
+	&commands.YAGCommand{
+		CustomEnabled: true,
+		CmdCategory:   commands.CategoryModeration,
+		Name:          "Untimeout",
+		Aliases:       []string{"RemoveTimeout"},
+		Description:   "Removes a member's native discord timeout",
+		RequiredArgs:  1,
+		Arguments: []*dcmd.ArgDef{
+			&dcmd.ArgDef{Name: "User", Type: dcmd.UserID},
+			&dcmd.ArgDef{Name: "Reason", Type: dcmd.String},
+		},
+		RunFunc: func(parsed *dcmd.Data) (interface{}, error) {
+			config, target, err := MBaseCmd(parsed, parsed.Args[0].Int64())
+			if err != nil {
+				return nil, err
+			}
+
+			reason := SafeArgString(parsed, 1)
+			reason, err = MBaseCmdSecond(parsed, reason, true, discordgo.PermissionKickMembers, config.TimeoutCmdRoles, config.TimeoutRequireAllRoles, config.TimeoutEnabled)
+			if err != nil {
+				return nil, err
+			}
+
+			err = RemoveTimeout(config, parsed.GS.ID, parsed.Msg.Author, reason, target)
+			if err != nil {
+				return nil, err
+			}
+
+			return GenericCmdResp(MATimeoutRemoved, target, 0, false, true), nil
+		},
+	},
+	&commands.YAGCommand{
+		CustomEnabled: true,
+		CmdCategory:   commands.CategoryModeration,
+		Name:          "RefreshMuteOverride",
+		Description:   "Reapplies the mute role overrides in the current or specified channel",
+		Arguments: []*dcmd.ArgDef{
+			&dcmd.ArgDef{Name: "Channel", Type: dcmd.Channel},
+		},
+		RunFunc: func(parsed *dcmd.Data) (interface{}, error) {
+			config, _, err := MBaseCmd(parsed, 0)
+			if err != nil {
+				return nil, err
+			}
+
+			_, err = MBaseCmdSecond(parsed, "", true, discordgo.PermissionManageRoles, nil, false, true)
+			if err != nil {
+				return nil, err
+			}
+
+			if config.MuteRole == "" || !config.MuteManageRole {
+				return "The bot isn't set up to manage the mute role overrides, enable it in the control panel", nil
+			}
+
+			channelID := parsed.CS.ID
+			if parsed.Args[0].Value != nil {
+				channelID = parsed.Args[0].Value.(*dstate.ChannelState).ID
+			}
+
+			if common.ContainsInt64Slice(config.MuteIgnoreChannels, channelID) {
+				return "That channel is ignored by the mute role", nil
+			}
+
+			if !bot.BotProbablyHasPermission(parsed.GS.ID, channelID, discordgo.PermissionManageRoles) {
+				return "I need the Manage Roles permission in that channel to update the overrides", nil
+			}
+
+			// fetch it from the api in case the overrides were just edited
+			channel, err := session().Channel(channelID)
+			if err != nil {
+				return nil, err
+			}
+
+			updated, err := RefreshMuteOverrideForChannel(config, channel)
+			if err != nil {
+				return nil, err
+			}
+
+			if updated < 1 {
+				return "The mute overrides in <#" + discordgo.StrID(channelID) + "> were already up to date", nil
+			}
+
+			return fmt.Sprintf("Updated %d mute override(s) in <#%d>", updated, channelID), nil
+		},
+	},
+	&commands.YAGCommand{
+		CustomEnabled: true,
+		CmdCategory:   commands.CategoryModeration,
+		Name:          "MuteAudit",
+		Description:   "Lists the channels with missing or incorrect mute role overrides, without changing anything",
+		RunFunc: func(parsed *dcmd.Data) (interface{}, error) {
+			config, _, err := MBaseCmd(parsed, 0)
+			if err != nil {
+				return nil, err
+			}
+
+			_, err = MBaseCmdSecond(parsed, "", true, discordgo.PermissionManageRoles, nil, false, true)
+			if err != nil {
+				return nil, err
+			}
+
+			if config.MuteRole == "" || !config.MuteManageRole {
+				return "The bot isn't set up to manage the mute role overrides, enable it in the control panel", nil
+			}
+
+			return muteAudit(config, parsed.GS.ID)
+		},
+	},
+	&commands.YAGCommand{
+		CustomEnabled: true,
+		CmdCategory:   commands.CategoryModeration,
+		Name:          "ExportModlog",
+		Description:   "Exports the entries in the modlog channel to a json or csv file",
+		LongDescription: fmt.Sprintf("Looks through the last %d messages in the modlog channel, format is either `json` (default) or `csv`.",
+			MaxModlogExportMessages),
+		Arguments: []*dcmd.ArgDef{
+			&dcmd.ArgDef{Name: "Format", Type: dcmd.String},
+		},
+		RunFunc: func(parsed *dcmd.Data) (interface{}, error) {
+			config, _, err := MBaseCmd(parsed, 0)
+			if err != nil {
+				return nil, err
+			}
+
+			_, err = MBaseCmdSecond(parsed, "", true, discordgo.PermissionManageServer, nil, false, true)
+			if err != nil {
+				return nil, err
+			}
+
+			format := strings.ToLower(parsed.Args[0].Str())
+			if format == "" {
+				format = "json"
+			}
+			if format != "json" && format != "csv" {
+				return "Unknown format, use either `json` or `csv`", nil
+			}
+
+			channelID := config.IntActionChannel()
+			if channelID == 0 {
+				return "No modlog channel set", nil
+			}
+
+			entries, err := fetchModlogEntries(channelID, MaxModlogExportMessages)
+			if err != nil {
+				return nil, err
+			}
+
+			if len(entries) < 1 {
+				return "No modlog entries found", nil
+			}
+
+			buf, err := encodeModlogExport(entries, format)
+			if err != nil {
+				return nil, err
+			}
+
+			fname := fmt.Sprintf("modlog-%d.%s", parsed.GS.ID, format)
+			_, err = session().ChannelFileSendWithMessage(parsed.CS.ID, fmt.Sprintf("Exported %d modlog entries", len(entries)), fname, buf)
+			return nil, err
+		},
+	},
+	&commands.YAGCommand{
+		CustomEnabled: true,
+		CmdCategory:   commands.CategoryModeration,
+		Name:          "UserRecord",
+		Description:   "Exports a user's complete moderation record to a text or json file",
+		LongDescription: fmt.Sprintf("Includes their warnings, mutes, bans and manually logged actions, as well as their entries in the last %d messages of the modlog channel. "+
+			"Format is either `text` (default) or `json`.", MaxModlogExportMessages),
+		RequiredArgs: 1,
+		Arguments: []*dcmd.ArgDef{
+			&dcmd.ArgDef{Name: "User", Type: dcmd.UserID},
+			&dcmd.ArgDef{Name: "Format", Type: dcmd.String},
+		},
+		RunFunc: func(parsed *dcmd.Data) (interface{}, error) {
+			config, _, err := MBaseCmd(parsed, 0)
+			if err != nil {
+				return nil, err
+			}
+
+			_, err = MBaseCmdSecond(parsed, "", true, discordgo.PermissionManageServer, nil, false, true)
+			if err != nil {
+				return nil, err
+			}
+
+			format := strings.ToLower(parsed.Args[1].Str())
+			if format == "" || format == "txt" {
+				format = "text"
+			}
+			if format != "text" && format != "json" {
+				return "Unknown format, use either `text` or `json`", nil
+			}
+
+			var modlogEntries []*ModlogExportEntry
+			if channelID := config.IntActionChannel(); channelID != 0 {
+				modlogEntries, err = fetchModlogEntries(channelID, MaxModlogExportMessages)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			userID := parsed.Args[0].Int64()
+			record, err := fetchUserRecord(parsed.GS.ID, userID, modlogEntries)
+			if err != nil {
+				return nil, err
+			}
+
+			if len(record.Entries) < 1 {
+				return "No moderation record found for that user", nil
+			}
+
+			buf, err := encodeUserRecord(record, format)
+			if err != nil {
+				return nil, err
+			}
 
-var ModerationCommands = []*commands.YAGCommand{
+			ext := "txt"
+			if format == "json" {
+				ext = "json"
+			}
+
+			fname := fmt.Sprintf("record-%d-%d.%s", parsed.GS.ID, userID, ext)
+			_, err = session().ChannelFileSendWithMessage(parsed.CS.ID, fmt.Sprintf("Exported %d record entries for user `%d`", len(record.Entries), userID), fname, buf)
+			return nil, err
+		},
+	},
 	&commands.YAGCommand{
-		CustomEnabled: true,
-		CmdCategory:   commands.CategoryModeration,
-		Name:          "Ban",
-		Aliases:       []string{"banid"},
-		Description:   "Bans a member, specify a duration with -d and specify number of days of messages to delete with -ddays (0 to 7)",
-		RequiredArgs:  1,
+		CustomEnabled:   true,
+		CmdCategory:     commands.CategoryModeration,
+		Name:            "BanMarkers",
+		Description:     "Shows the markers used to not log bans and unbans made by the bot twice, use -clear to delete them",
+		LongDescription: "Useful if a ban or unban wasn't logged, which can happen if a marker got stuck.",
+		RequiredArgs:    1,
 		Arguments: []*dcmd.ArgDef{
 			&dcmd.ArgDef{Name: "User", Type: dcmd.UserID},
-			&dcmd.ArgDef{Name: "Reason", Type: dcmd.String},
 		},
 		ArgSwitches: []*dcmd.ArgDef{
-			&dcmd.ArgDef{Switch: "d", Default: time.Duration(0), Name: "Duration", Type: &commands.DurationArg{}},
-			&dcmd.ArgDef{Switch: "ddays", Default: 1, Name: "Days", Type: dcmd.Int},
+			&dcmd.ArgDef{Switch: "clear", Name: "Delete the markers"},
 		},
 		RunFunc: func(parsed *dcmd.Data) (interface{}, error) {
-			config, target, err := MBaseCmd(parsed, parsed.Args[0].Int64())
+			_, _, err := MBaseCmd(parsed, 0)
 			if err != nil {
 				return nil, err
 			}
 
-			reason := SafeArgString(parsed, 1)
-			reason, err = MBaseCmdSecond(parsed, reason, config.BanReasonOptional, discordgo.PermissionBanMembers, config.BanCmdRoles, config.BanEnabled)
+			_, err = MBaseCmdSecond(parsed, "", true, discordgo.PermissionManageServer, nil, false, true)
 			if err != nil {
 				return nil, err
 			}
 
-			err = BanUserWithDuration(config, parsed.GS.ID, parsed.CS, parsed.Msg, parsed.Msg.Author, reason, target, parsed.Switches["d"].Value.(time.Duration), parsed.Switches["ddays"].Int())
+			userID := parsed.Args[0].Int64()
+			desc, err := describeBanMarkers(parsed.GS.ID, userID)
 			if err != nil {
 				return nil, err
 			}
 
-			return GenericCmdResp(MABanned, target, parsed.Switch("d").Value.(time.Duration), true, false), nil
+			if parsed.Switches["clear"].Value != nil && parsed.Switches["clear"].Value.(bool) {
+				n, err := clearBanMarkers(parsed.GS.ID, userID)
+				if err != nil {
+					return nil, err
+				}
+
+				desc += fmt.Sprintf("\nCleared %d marker(s)", n)
+			}
+
+			return desc, nil
 		},
 	},
 	&commands.YAGCommand{
 		CustomEnabled: true,
 		CmdCategory:   commands.CategoryModeration,
-		Name:          "Kick",
-		Description:   "Kicks a member",
+		Name:          "ModPerms",
+		Description:   "Shows which moderation commands a user can run in this channel and why",
 		RequiredArgs:  1,
 		Arguments: []*dcmd.ArgDef{
 			&dcmd.ArgDef{Name: "User", Type: dcmd.UserID},
-			&dcmd.ArgDef{Name: "Reason", Type: dcmd.String},
 		},
 		RunFunc: func(parsed *dcmd.Data) (interface{}, error) {
-			config, target, err := MBaseCmd(parsed, parsed.Args[0].Int64())
+			config, _, err := MBaseCmd(parsed, 0)
 			if err != nil {
 				return nil, err
 			}
 
-			reason := SafeArgString(parsed, 1)
-			reason, err = MBaseCmdSecond(parsed, reason, config.KickReasonOptional, discordgo.PermissionKickMembers, config.KickCmdRoles, config.KickEnabled)
+			_, err = MBaseCmdSecond(parsed, "", true, discordgo.PermissionManageServer, nil, false, true)
 			if err != nil {
 				return nil, err
 			}
 
-			err = KickUser(config, parsed.GS.ID, parsed.CS, parsed.Msg, parsed.Msg.Author, reason, target)
+			member, err := bot.GetMember(parsed.GS.ID, parsed.Args[0].Int64())
+			if err != nil || member == nil {
+				return "Member not found", err
+			}
+
+			desc, err := describeModPerms(config, parsed.CS.ID, member)
 			if err != nil {
 				return nil, err
 			}
 
-			return GenericCmdResp(MAKick, target, 0, true, true), nil
+			return fmt.Sprintf("Moderation permissions of **%s#%s** in <#%d>:\n%s", member.Username, member.StrDiscriminator(), parsed.CS.ID, desc), nil
 		},
 	},
 	&commands.YAGCommand{
 		CustomEnabled: true,
 		CmdCategory:   commands.CategoryModeration,
-		Name:          "Mute",
-		Description:   "Mutes a member",
+		Name:          "ChannelStatus",
+		Description:   "Shows the slowmode, lockdown state and mute overrides of a channel",
 		Arguments: []*dcmd.ArgDef{
-			&dcmd.ArgDef{Name: "User", Type: dcmd.UserID},
-			&dcmd.ArgDef{Name: "Duration", Type: &commands.DurationArg{}},
-			&dcmd.ArgDef{Name: "Reason", Type: dcmd.String},
+			&dcmd.ArgDef{Name: "Channel", Type: dcmd.Channel},
 		},
-		ArgumentCombos: [][]int{[]int{0, 1, 2}, []int{0, 2, 1}, []int{0, 1}, []int{0, 2}, []int{0}},
 		RunFunc: func(parsed *dcmd.Data) (interface{}, error) {
-			config, target, err := MBaseCmd(parsed, parsed.Args[0].Int64())
+			config, _, err := MBaseCmd(parsed, 0)
 			if err != nil {
 				return nil, err
 			}
 
-			if config.MuteRole == "" {
-				return "No mute role set up, assign a mute role in the control panel", nil
+			_, err = MBaseCmdSecond(parsed, "", true, discordgo.PermissionManageMessages, nil, false, true)
+			if err != nil {
+				return nil, err
 			}
 
-			reason := parsed.Args[2].Str()
-			reason, err = MBaseCmdSecond(parsed, reason, config.MuteReasonOptional, discordgo.PermissionKickMembers, config.MuteCmdRoles, config.MuteEnabled)
+			channelID := parsed.CS.ID
+			if parsed.Args[0].Value != nil {
+				channelID = parsed.Args[0].Value.(*dstate.ChannelState).ID
+			}
+
+			// fetch it from the api to get the current slowmode
+			channel, err := session().Channel(channelID)
 			if err != nil {
 				return nil, err
 			}
 
-			d := time.Duration(config.DefaultMuteDuration.Int64) * time.Minute
-			if parsed.Args[1].Value != nil {
-				d = parsed.Args[1].Value.(time.Duration)
-			}
-			if d > 0 && d < time.Minute {
-				d = time.Minute
+			everyonePerms := 0
+			if everyone := parsed.GS.RoleCopy(true, parsed.GS.ID); everyone != nil {
+				everyonePerms = everyone.Permissions
 			}
 
-			logger.Info(d.Seconds())
+			roleName := func(id int64) string {
+				if r := parsed.GS.RoleCopy(true, id); r != nil {
+					return r.Name
+				}
 
-			member, err := bot.GetMember(parsed.GS.ID, target.ID)
-			if err != nil || member == nil {
-				return "Member not found", err
+				return fmt.Sprintf("deleted role (%d)", id)
 			}
 
-			err = MuteUnmuteUser(config, true, parsed.GS.ID, parsed.CS, parsed.Msg, parsed.Msg.Author, reason, member, int(d.Minutes()))
+			status := channelStatus(config, channel, everyonePerms, roleName)
+
+			snapshot, err := LockdownSnapshotInfo(channelID)
 			if err != nil {
 				return nil, err
 			}
+			if snapshot != nil {
+				status += fmt.Sprintf("\n📸 **Lockdown snapshot:** %d override(s), taken %s ago by <@%d>", len(snapshot.Overwrites),
+					common.HumanizeDuration(common.DurationPrecisionMinutes, time.Since(snapshot.CreatedAt)), snapshot.CreatedBy)
+			}
 
-			return GenericCmdResp(MAMute, target, d, true, false), nil
+			return status, nil
 		},
 	},
 	&commands.YAGCommand{
 		CustomEnabled: true,
 		CmdCategory:   commands.CategoryModeration,
-		Name:          "Unmute",
-		Description:   "Unmutes a member",
-		RequiredArgs:  1,
+		Name:          "Lockdown",
+		Description:   "Stops @everyone from sending messages in a channel, the permission overrides are restored exactly with Unlock",
 		Arguments: []*dcmd.ArgDef{
-			&dcmd.ArgDef{Name: "User", Type: dcmd.UserID},
-			&dcmd.ArgDef{Name: "Reason", Type: dcmd.String},
+			&dcmd.ArgDef{Name: "Channel", Type: dcmd.Channel},
 		},
 		RunFunc: func(parsed *dcmd.Data) (interface{}, error) {
-			config, target, err := MBaseCmd(parsed, parsed.Args[0].Int64())
+			_, _, err := MBaseCmd(parsed, 0)
 			if err != nil {
 				return nil, err
 			}
 
-			if config.MuteRole == "" {
-				return "No mute role set up, assign a mute role in the control panel", nil
+			_, err = MBaseCmdSecond(parsed, "", true, discordgo.PermissionManageChannels, nil, false, true)
+			if err != nil {
+				return nil, err
 			}
 
-			reason := parsed.Args[1].Str()
-			reason, err = MBaseCmdSecond(parsed, reason, config.UnmuteReasonOptional, discordgo.PermissionKickMembers, config.MuteCmdRoles, config.MuteEnabled)
+			channelID := parsed.CS.ID
+			if parsed.Args[0].Value != nil {
+				channelID = parsed.Args[0].Value.(*dstate.ChannelState).ID
+			}
+
+			// fetch it from the api to snapshot the current overrides
+			channel, err := session().Channel(channelID)
 			if err != nil {
 				return nil, err
 			}
 
-			member, err := bot.GetMember(parsed.GS.ID, target.ID)
-			if err != nil || member == nil {
-				return "Member not found", err
+			_, err = lockdownChannel(channel, parsed.Msg.Author.ID)
+			if err != nil {
+				return nil, err
 			}
 
-			err = MuteUnmuteUser(config, false, parsed.GS.ID, parsed.CS, parsed.Msg, parsed.Msg.Author, reason, member, 0)
+			return fmt.Sprintf("🔒 Locked down <#%d>", channelID), nil
+		},
+	},
+	&commands.YAGCommand{
+		CustomEnabled: true,
+		CmdCategory:   commands.CategoryModeration,
+		Name:          "Unlock",
+		Description:   "Restores the permission overrides a channel had before it was locked down",
+		Arguments: []*dcmd.ArgDef{
+			&dcmd.ArgDef{Name: "Channel", Type: dcmd.Channel},
+		},
+		RunFunc: func(parsed *dcmd.Data) (interface{}, error) {
+			_, _, err := MBaseCmd(parsed, 0)
 			if err != nil {
 				return nil, err
 			}
 
-			return GenericCmdResp(MAUnmute, target, 0, false, true), nil
+			_, err = MBaseCmdSecond(parsed, "", true, discordgo.PermissionManageChannels, nil, false, true)
+			if err != nil {
+				return nil, err
+			}
+
+			channelID := parsed.CS.ID
+			if parsed.Args[0].Value != nil {
+				channelID = parsed.Args[0].Value.(*dstate.ChannelState).ID
+			}
+
+			channel, err := session().Channel(channelID)
+			if err != nil {
+				return nil, err
+			}
+
+			restored, err := unlockChannel(channel)
+			if err != nil {
+				return nil, err
+			}
+
+			if !restored {
+				return fmt.Sprintf("<#%d> isn't locked down", channelID), nil
+			}
+
+			return fmt.Sprintf("🔓 Unlocked <#%d>", channelID), nil
 		},
 	},
 	&commands.YAGCommand{
@@ -292,7 +1740,7 @@ var ModerationCommands = []*commands.YAGCommand{
 				return nil, err
 			}
 
-			_, err = MBaseCmdSecond(parsed, "", true, 0, nil, config.ReportEnabled)
+			_, err = MBaseCmdSecond(parsed, "", true, 0, nil, false, config.ReportEnabled)
 			if err != nil {
 				return nil, err
 			}
@@ -308,11 +1756,25 @@ var ModerationCommands = []*commands.YAGCommand{
 
 			reportBody := fmt.Sprintf("<@%d> Reported <@%d> in <#%d> For `%s`\nLast 100 messages from channel: <%s>", parsed.Msg.Author.ID, target, parsed.Msg.ChannelID, parsed.Args[1].Str(), logLink)
 
-			_, err = common.BotSession.ChannelMessageSend(channelID, reportBody)
+			// Only allow the configured staff role to be pinged, not whatever was put in the reason
+			allowedMentions := discordgo.AllowedMentions{
+				Users: []int64{parsed.Msg.Author.ID, target},
+			}
+			if mentionRole := config.IntReportMentionRole(); mentionRole != 0 {
+				reportBody = fmt.Sprintf("<@&%d> ", mentionRole) + reportBody
+				allowedMentions.Roles = []int64{mentionRole}
+			}
+
+			m, err := session().ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+				Content:         reportBody,
+				AllowedMentions: allowedMentions,
+			})
 			if err != nil {
 				return nil, err
 			}
 
+			recordReport(config, parsed.GS.ID, m, parsed.Msg.Author.ID, target)
+
 			// don't bother sending confirmation if it's in the same channel
 			if channelID != parsed.Msg.ChannelID {
 				return "User reported to the proper authorities", nil
@@ -320,33 +1782,64 @@ var ModerationCommands = []*commands.YAGCommand{
 			return nil, nil
 		},
 	},
+	&commands.YAGCommand{
+		CustomEnabled:   true,
+		CmdCategory:     commands.CategoryModeration,
+		Name:            "SnoozeReport",
+		Description:     "Snoozes a report, hiding it until the duration is over (default 1h) and re-surfacing it if it wasn't resolved by then",
+		LongDescription: "Use the message id of the report in the report channel, max duration is 7 days.",
+		RequiredArgs:    1,
+		Arguments: []*dcmd.ArgDef{
+			&dcmd.ArgDef{Name: "Report", Help: "Message id of the report", Type: dcmd.Int},
+			&dcmd.ArgDef{Name: "Duration", Type: &commands.DurationArg{}},
+		},
+		RunFunc: func(parsed *dcmd.Data) (interface{}, error) {
+			return cmdSetReportState(parsed, ReportStateSnoozed)
+		},
+	},
+	&commands.YAGCommand{
+		CustomEnabled: true,
+		CmdCategory:   commands.CategoryModeration,
+		Name:          "ResolveReport",
+		Description:   "Marks a report as resolved",
+		RequiredArgs:  1,
+		Arguments: []*dcmd.ArgDef{
+			&dcmd.ArgDef{Name: "Report", Help: "Message id of the report", Type: dcmd.Int},
+		},
+		RunFunc: func(parsed *dcmd.Data) (interface{}, error) {
+			return cmdSetReportState(parsed, ReportStateResolved)
+		},
+	},
 	&commands.YAGCommand{
 		CustomEnabled:   true,
 		CmdCategory:     commands.CategoryModeration,
 		Name:            "Clean",
 		Description:     "Delete the last number of messages from chat, optionally filtering by user, max age and regex or ignoring pinned messages.",
-		LongDescription: "Specify a regex with \"-r regex_here\" and max age with \"-ma 1h10m\"\nNote: Will only look in the last 1k messages",
+		LongDescription: "Specify a regex with \"-r regex_here\" and max age with \"-ma 1h10m\" (\"-ma 0\" for no limit if the server has a default max age)\nCleans given a reason are logged in the modlog.\nNote: Will only look in the last 1k messages",
 		Aliases:         []string{"clear", "cl"},
 		RequiredArgs:    1,
 		Arguments: []*dcmd.ArgDef{
 			&dcmd.ArgDef{Name: "Num", Type: &dcmd.IntArg{Min: 1, Max: 100}},
 			&dcmd.ArgDef{Name: "User", Type: dcmd.UserID, Default: 0},
+			&dcmd.ArgDef{Name: "Reason", Type: dcmd.String},
 		},
 		ArgSwitches: []*dcmd.ArgDef{
 			&dcmd.ArgDef{Switch: "r", Name: "Regex", Type: dcmd.String},
-			&dcmd.ArgDef{Switch: "ma", Default: time.Duration(0), Name: "Max age", Type: &commands.DurationArg{}},
+			&dcmd.ArgDef{Switch: "ma", Name: "Max age", Type: &commands.DurationArg{}},
 			&dcmd.ArgDef{Switch: "minage", Default: time.Duration(0), Name: "Min age", Type: &commands.DurationArg{}},
 			&dcmd.ArgDef{Switch: "i", Name: "Regex case insensitive"},
 			&dcmd.ArgDef{Switch: "nopin", Name: "Ignore pinned messages"},
+			&dcmd.ArgDef{Switch: "threads", Name: "Also clean active threads under this channel"},
 		},
-		ArgumentCombos: [][]int{[]int{0}, []int{0, 1}, []int{1, 0}},
+		ArgumentCombos: [][]int{[]int{0}, []int{0, 1}, []int{1, 0}, []int{0, 1, 2}, []int{1, 0, 2}, []int{0, 2}},
 		RunFunc: func(parsed *dcmd.Data) (interface{}, error) {
 			config, _, err := MBaseCmd(parsed, 0)
 			if err != nil {
 				return nil, err
 			}
 
-			_, err = MBaseCmdSecond(parsed, "", true, discordgo.PermissionManageMessages, nil, config.CleanEnabled)
+			reasonGiven := cleanReasonGiven(parsed)
+			reason, err := MBaseCmdSecond(parsed, SafeArgString(parsed, 2), !config.CleanRequireReason, discordgo.PermissionManageMessages, nil, false, config.CleanEnabled)
 			if err != nil {
 				return nil, err
 			}
@@ -385,111 +1878,331 @@ var ModerationCommands = []*commands.YAGCommand{
 				}
 			}
 
-			// Check if we have a max age
-			ma := parsed.Switches["ma"].Value.(time.Duration)
-			if ma != 0 {
-				filtered = true
+			// Check if we have a max age
+			ma := cleanMaxAge(config, parsed.Switches["ma"].Value)
+			if ma != 0 {
+				filtered = true
+			}
+
+			// Check if we have a min age
+			minAge := parsed.Switches["minage"].Value.(time.Duration)
+			if minAge != 0 {
+				filtered = true
+			}
+
+			// Check if we should ignore pinned messages
+			pe := false
+			if parsed.Switches["nopin"].Value != nil && parsed.Switches["nopin"].Value.(bool) {
+				pe = true
+				filtered = true
+			}
+
+			limitFetch := num
+			if userFilter != 0 || filtered {
+				limitFetch = num * 50 // Maybe just change to full fetch?
+			}
+
+			if limitFetch > 1000 {
+				limitFetch = 1000
+			}
+
+			// Wait a bit so the client dosen't gltich out
+			if delay := config.CleanDelayDuration(); delay > 0 {
+				time.Sleep(delay)
+			}
+
+			numDeleted, numScanned, truncated, err := advancedDeleteMessages(parsed.Msg.ChannelID, userFilter, re, ma, minAge, pe, num, limitFetch)
+			if err == nil && parsed.Switches["threads"].Value != nil && parsed.Switches["threads"].Value.(bool) {
+				var numDeletedThreads int
+				numDeletedThreads, err = CleanActiveThreads(parsed.GS.ID, parsed.Msg.ChannelID, userFilter, re, ma, minAge, pe, num, limitFetch)
+				numDeleted += numDeletedThreads
+			}
+
+			if reasonGiven {
+				logClean(config, parsed.Msg.Author, parsed.CS.Name, userFilter, numDeleted, reason)
+			}
+
+			resp := fmt.Sprintf("Deleted %d message(s)! :')", numDeleted)
+			if truncated {
+				resp += fmt.Sprintf("\nNote: only scanned %d of the last %d messages as discord was ratelimiting the bot, you may want to run it again.", numScanned, limitFetch)
+			}
+
+			return dcmd.NewTemporaryResponse(time.Second*5, resp, true), err
+		},
+	},
+	&commands.YAGCommand{
+		CustomEnabled: true,
+		CmdCategory:   commands.CategoryModeration,
+		Name:          "Reason",
+		Description:   "Add/Edit a modlog reason",
+		RequiredArgs:  2,
+		Arguments: []*dcmd.ArgDef{
+			&dcmd.ArgDef{Name: "Message ID", Type: dcmd.Int},
+			&dcmd.ArgDef{Name: "Reason", Type: dcmd.String},
+		},
+		RunFunc: func(parsed *dcmd.Data) (interface{}, error) {
+			config, _, err := MBaseCmd(parsed, 0)
+			if err != nil {
+				return nil, err
+			}
+
+			_, err = MBaseCmdSecond(parsed, "", true, discordgo.PermissionKickMembers, nil, false, true)
+			if err != nil {
+				return nil, err
+			}
+
+			if config.ActionChannel == "" {
+				return "No mod log channel set up", nil
+			}
+
+			msg, err := session().ChannelMessage(config.IntActionChannel(), parsed.Args[0].Int64())
+			if err != nil {
+				return nil, err
+			}
+
+			if msg.Author.ID != common.BotUser.ID {
+				return "I didn't make that message", nil
+			}
+
+			if len(msg.Embeds) < 1 {
+				return "This entry is either too old or you're trying to mess with me...", nil
+			}
+
+			reason := parsed.Args[1].Str()
+			err = editModlogReason(config, parsed.Msg.Author, msg, reason, LinkReasonTickets(config, ResolveReasonMentions(parsed.GS.ID, reason)))
+			if err != nil {
+				return nil, err
+			}
+
+			return cmdConfirmation(config, fmt.Sprintf("Updated the reason of modlog entry `%d`", msg.ID)), nil
+		},
+	},
+	&commands.YAGCommand{
+		CustomEnabled:   true,
+		CmdCategory:     commands.CategoryModeration,
+		Name:            "LogAction",
+		Description:     "Creates a modlog entry for an action taken outside of the bot, without doing anything to the user",
+		LongDescription: "For example `LogAction @user \"verbal warning\" spamming in general`, quote the action if it's more than one word.",
+		RequiredArgs:    3,
+		Arguments: []*dcmd.ArgDef{
+			&dcmd.ArgDef{Name: "User", Type: dcmd.UserID},
+			&dcmd.ArgDef{Name: "Action", Type: dcmd.String},
+			&dcmd.ArgDef{Name: "Reason", Type: dcmd.String},
+		},
+		RunFunc: func(parsed *dcmd.Data) (interface{}, error) {
+			config, target, err := MBaseCmd(parsed, parsed.Args[0].Int64())
+			if err != nil {
+				return nil, err
+			}
+
+			reason, err := MBaseCmdSecond(parsed, parsed.Args[2].Str(), false, discordgo.PermissionKickMembers, nil, false, true)
+			if err != nil {
+				return nil, err
+			}
+
+			action := strings.TrimSpace(parsed.Args[1].Str())
+			if action == "" || len(action) > MaxLoggedActionLength {
+				return nil, commands.NewUserErrorf("The action has to be between 1 and %d characters long", MaxLoggedActionLength)
+			}
+
+			if config.ActionChannel == "" {
+				return nil, commands.NewUserError("No mod log channel set up")
+			}
+
+			err = logManualAction(config, parsed.Msg.Author, target, action, reason)
+			if err != nil {
+				return nil, err
+			}
+
+			return GenericCmdResp(loggedModlogAction(action), target, 0, true, true), nil
+		},
+	},
+	&commands.YAGCommand{
+		CustomEnabled: true,
+		CmdCategory:   commands.CategoryModeration,
+		Name:          "TestModDM",
+		Description:   "Renders the DM template of an action (ban, kick, mute, unmute, warn) with sample data and DMs it to you, use -here to show it in the channel instead",
+		RequiredArgs:  1,
+		Arguments: []*dcmd.ArgDef{
+			&dcmd.ArgDef{Name: "Action", Type: dcmd.String},
+		},
+		ArgSwitches: []*dcmd.ArgDef{
+			&dcmd.ArgDef{Switch: "here", Name: "Show it in this channel"},
+		},
+		RunFunc: func(parsed *dcmd.Data) (interface{}, error) {
+			config, _, err := MBaseCmd(parsed, 0)
+			if err != nil {
+				return nil, err
+			}
+
+			_, err = MBaseCmdSecond(parsed, "", true, discordgo.PermissionKickMembers, nil, false, true)
+			if err != nil {
+				return nil, err
 			}
 
-			// Check if we have a min age
-			minAge := parsed.Switches["minage"].Value.(time.Duration)
-			if minAge != 0 {
-				filtered = true
+			var tmpl string
+			var action ModlogAction
+			var duration time.Duration
+			var extraData map[string]interface{}
+			switch strings.ToLower(parsed.Args[0].Str()) {
+			case "ban":
+				tmpl, action, duration = config.BanMessage, MABanned, time.Hour
+			case "kick":
+				tmpl, action = config.KickMessage, MAKick
+			case "mute":
+				tmpl, action, duration = config.MuteMessage, MAMute, time.Hour
+			case "unmute":
+				tmpl, action = config.UnmuteMessage, MAUnmute
+			case "warn":
+				tmpl, action, duration = config.WarnMessage, MAWarned, -1
+				extraData = warnDMData(config, 1, "")
+			default:
+				return "Unknown action, available actions: ban, kick, mute, unmute, warn", nil
+			}
+
+			member := commands.ContextMS(parsed.Context())
+			executed, err := executePunishDM(tmpl, action, parsed.GS, parsed.CS, parsed.Msg, parsed.Msg.Author, member, duration, "This is a test reason", extraData)
+			if err != nil {
+				return "Failed executing the template: `" + err.Error() + "`", nil
 			}
 
-			// Check if we should ignore pinned messages
-			pe := false
-			if parsed.Switches["nopin"].Value != nil && parsed.Switches["nopin"].Value.(bool) {
-				pe = true
-				filtered = true
+			if strings.TrimSpace(executed) == "" {
+				return "The template rendered an empty message, no DM would be sent", nil
 			}
 
-			limitFetch := num
-			if userFilter != 0 || filtered {
-				limitFetch = num * 50 // Maybe just change to full fetch?
+			executed = "**" + bot.GuildName(parsed.GS.ID) + ":** " + executed
+			if parsed.Switches["here"].Value != nil && parsed.Switches["here"].Value.(bool) {
+				return executed, nil
 			}
 
-			if limitFetch > 1000 {
-				limitFetch = 1000
+			err = bot.SendDM(parsed.Msg.Author.ID, executed)
+			if err != nil {
+				return "Failed sending you a DM, are your DMs open?", nil
 			}
 
-			// Wait a second so the client dosen't gltich out
-			time.Sleep(time.Second)
-
-			numDeleted, err := AdvancedDeleteMessages(parsed.Msg.ChannelID, userFilter, re, ma, minAge, pe, num, limitFetch)
-
-			return dcmd.NewTemporaryResponse(time.Second*5, fmt.Sprintf("Deleted %d message(s)! :')", numDeleted), true), err
+			return "Sent you the rendered template in DM", nil
 		},
 	},
 	&commands.YAGCommand{
-		CustomEnabled: true,
-		CmdCategory:   commands.CategoryModeration,
-		Name:          "Reason",
-		Description:   "Add/Edit a modlog reason",
-		RequiredArgs:  2,
+		CustomEnabled:   true,
+		CmdCategory:     commands.CategoryModeration,
+		Name:            "CanDM",
+		Description:     "Checks if the bot can DM a user, useful to know if they will see the punishment DMs",
+		LongDescription: "No message is actually sent to the user.",
+		RequiredArgs:    1,
 		Arguments: []*dcmd.ArgDef{
-			&dcmd.ArgDef{Name: "Message ID", Type: dcmd.Int},
-			&dcmd.ArgDef{Name: "Reason", Type: dcmd.String},
+			&dcmd.ArgDef{Name: "User", Type: dcmd.UserID},
 		},
 		RunFunc: func(parsed *dcmd.Data) (interface{}, error) {
-			config, _, err := MBaseCmd(parsed, 0)
+			_, _, err := MBaseCmd(parsed, 0)
 			if err != nil {
 				return nil, err
 			}
 
-			_, err = MBaseCmdSecond(parsed, "", true, discordgo.PermissionKickMembers, nil, true)
+			_, err = MBaseCmdSecond(parsed, "", true, discordgo.PermissionKickMembers, nil, false, true)
 			if err != nil {
 				return nil, err
 			}
 
-			if config.ActionChannel == "" {
-				return "No mod log channel set up", nil
+			userID := parsed.Args[0].Int64()
+			if userID == common.BotUser.ID {
+				return "That's me", nil
 			}
 
-			msg, err := common.BotSession.ChannelMessage(config.IntActionChannel(), parsed.Args[0].Int64())
+			status, err := probeDM(userID)
 			if err != nil {
 				return nil, err
 			}
 
-			if msg.Author.ID != common.BotUser.ID {
-				return "I didn't make that message", nil
+			return status.String(), nil
+		},
+	},
+	&commands.YAGCommand{
+		CustomEnabled:   true,
+		CmdCategory:     commands.CategoryModeration,
+		Name:            "MassReason",
+		Description:     "Appends to (or replaces with -replace) the reasons of all warnings and active mutes made by a moderator",
+		LongDescription: "Limit it to recent entries with \"-ma 7d\". Useful for annotating the cases of a removed moderator, modlog messages are not edited.",
+		RequiredArgs:    2,
+		Arguments: []*dcmd.ArgDef{
+			&dcmd.ArgDef{Name: "Moderator", Type: dcmd.UserID},
+			&dcmd.ArgDef{Name: "Reason", Type: dcmd.String},
+		},
+		ArgSwitches: []*dcmd.ArgDef{
+			&dcmd.ArgDef{Switch: "ma", Default: time.Duration(0), Name: "Max age", Type: &commands.DurationArg{}},
+			&dcmd.ArgDef{Switch: "replace", Name: "Replace the reasons instead of appending"},
+		},
+		RunFunc: func(parsed *dcmd.Data) (interface{}, error) {
+			_, err := MBaseCmdSecond(parsed, "", true, discordgo.PermissionManageServer, nil, false, true)
+			if err != nil {
+				return nil, err
 			}
 
-			if len(msg.Embeds) < 1 {
-				return "This entry is either too old or you're trying to mess with me...", nil
+			var since time.Time
+			if ma := parsed.Switches["ma"].Value.(time.Duration); ma > 0 {
+				since = time.Now().Add(-ma)
 			}
 
-			embed := msg.Embeds[0]
-			updateEmbedReason(parsed.Msg.Author, parsed.Args[1].Str(), embed)
-			_, err = common.BotSession.ChannelMessageEditEmbed(config.IntActionChannel(), msg.ID, embed)
+			replace := parsed.Switches["replace"].Value != nil && parsed.Switches["replace"].Value.(bool)
+
+			warnings, mutes, err := MassEditReasons(parsed.GS.ID, parsed.Args[0].Int64(), since, parsed.Args[1].Str(), replace)
 			if err != nil {
 				return nil, err
 			}
 
-			return "👌", nil
+			return fmt.Sprintf("Updated %d warning(s) and %d active mute(s).", warnings, mutes), nil
 		},
 	},
 	&commands.YAGCommand{
-		CustomEnabled: true,
-		CmdCategory:   commands.CategoryModeration,
-		Name:          "Warn",
-		Description:   "Warns a user, warnings are saved using the bot. Use -warnings to view them.",
-		RequiredArgs:  2,
+		CustomEnabled:   true,
+		CmdCategory:     commands.CategoryModeration,
+		Name:            "Warn",
+		Description:     "Warns a user, warnings are saved using the bot. Use -warnings to view them.",
+		LongDescription: "Leave out the user when replying to one of their messages to warn the author.",
 		Arguments: []*dcmd.ArgDef{
 			&dcmd.ArgDef{Name: "User", Type: dcmd.UserID},
 			&dcmd.ArgDef{Name: "Reason", Type: dcmd.String},
 		},
+		ArgSwitches: []*dcmd.ArgDef{
+			noReasonSwitch,
+			&dcmd.ArgDef{Switch: "force", Name: "Warn even if an identical warning was given recently"},
+			&dcmd.ArgDef{Switch: "cat", Name: "Warning category", Type: dcmd.String},
+		},
+		ArgumentCombos: [][]int{[]int{0, 1}, []int{0}, []int{1}, []int{}},
 		RunFunc: func(parsed *dcmd.Data) (interface{}, error) {
-			config, target, err := MBaseCmd(parsed, parsed.Args[0].Int64())
+			targetID, err := cmdTargetID(parsed)
+			if err != nil {
+				return nil, err
+			}
+
+			config, target, err := MBaseCmd(parsed, targetID)
 			if err != nil {
 				return nil, err
 			}
-			_, err = MBaseCmdSecond(parsed, "", true, discordgo.PermissionManageMessages, config.WarnCmdRoles, config.WarnCommandsEnabled)
+			reason := SafeArgString(parsed, 1)
+			reason, err = MBaseCmdSecond(parsed, reason, false, discordgo.PermissionManageMessages, config.WarnCmdRoles, config.WarnRequireAllRoles, config.WarnCommandsEnabled)
 			if err != nil {
 				return nil, err
 			}
 
-			err = WarnUser(config, parsed.GS.ID, parsed.CS, parsed.Msg, parsed.Msg.Author, target, parsed.Args[1].Str())
+			if target.Bot && config.WarnDisallowBots {
+				return "You can't warn a bot", nil
+			}
+
+			category := ""
+			if parsed.Switches["cat"].Value != nil {
+				var ok bool
+				category, ok = config.matchWarnCategory(parsed.Switches["cat"].Str())
+				if !ok {
+					return config.warnCategoryErr(parsed.Switches["cat"].Str()), nil
+				}
+			}
+
+			force := parsed.Switches["force"].Value != nil && parsed.Switches["force"].Value.(bool)
+			err = warnUser(config, parsed.GS.ID, parsed.CS, parsed.Msg, parsed.Msg.Author, target, reason, repliedMessageLink(parsed.GS.ID, parsed.Msg), category, force)
+			if err == ErrDuplicateWarning {
+				return fmt.Sprintf("You already gave %s#%s an identical warning in the last %d seconds, run the command again with `-force` to warn anyway.", target.Username, target.Discriminator, config.WarnDedupSeconds), nil
+			}
 			if err != nil {
 				return nil, err
 			}
@@ -510,6 +2223,7 @@ var ModerationCommands = []*commands.YAGCommand{
 		},
 		ArgSwitches: []*dcmd.ArgDef{
 			&dcmd.ArgDef{Switch: "id", Name: "Warning ID", Type: dcmd.Int},
+			&dcmd.ArgDef{Switch: "cat", Name: "Only list warnings in this category", Type: dcmd.String},
 		},
 		RunFunc: func(parsed *dcmd.Data) (interface{}, error) {
 			var err error
@@ -518,7 +2232,7 @@ var ModerationCommands = []*commands.YAGCommand{
 				return nil, err
 			}
 
-			_, err = MBaseCmdSecond(parsed, "", true, discordgo.PermissionManageMessages, config.WarnCmdRoles, true)
+			_, err = MBaseCmdSecond(parsed, "", true, discordgo.PermissionManageMessages, config.WarnCmdRoles, config.WarnRequireAllRoles, true)
 			if err != nil {
 				return nil, err
 			}
@@ -533,12 +2247,26 @@ var ModerationCommands = []*commands.YAGCommand{
 					return fmt.Sprintf("Warning with given id : `%d` does not exist.", parsed.Switches["id"].Int()), nil
 				}
 
+				desc := fmt.Sprintf("`%20s` - **Reason** : %s", warn[0].CreatedAt.UTC().Format(time.RFC822), SanitizeReason(warn[0].Message))
+				if warn[0].MessageLink != "" {
+					desc += fmt.Sprintf("\n> message: [`link`](%s)", warn[0].MessageLink)
+				}
+				if warn[0].Category != "" {
+					desc += fmt.Sprintf("\n> category: `%s`", warn[0].Category)
+				}
+
 				return &discordgo.MessageEmbed{
 					Title:       fmt.Sprintf("Warning#%d - User : %s", warn[0].ID, warn[0].UserID),
-					Description: fmt.Sprintf("`%20s` - **Reason** : %s", warn[0].CreatedAt.UTC().Format(time.RFC822), warn[0].Message),
+					Description: desc,
 					Footer:      &discordgo.MessageEmbedFooter{Text: fmt.Sprintf("By: %s (%13s)", warn[0].AuthorUsernameDiscrim, warn[0].AuthorID)},
 				}, nil
 			}
+			if parsed.Switches["cat"].Value != nil {
+				if _, ok := config.matchWarnCategory(parsed.Switches["cat"].Str()); !ok {
+					return config.warnCategoryErr(parsed.Switches["cat"].Str()), nil
+				}
+			}
+
 			page := parsed.Args[1].Int()
 			if page < 1 {
 				page = 1
@@ -566,19 +2294,21 @@ var ModerationCommands = []*commands.YAGCommand{
 				return nil, err
 			}
 
-			_, err = MBaseCmdSecond(parsed, "", true, discordgo.PermissionManageMessages, config.WarnCmdRoles, config.WarnCommandsEnabled)
+			_, err = MBaseCmdSecond(parsed, "", true, discordgo.PermissionManageMessages, config.WarnCmdRoles, config.WarnRequireAllRoles, config.WarnCommandsEnabled)
 			if err != nil {
 				return nil, err
 			}
 
-			rows := common.GORM.Model(WarningModel{}).Where("guild_id = ? AND id = ?", parsed.GS.ID, parsed.Args[0].Int()).Update(
-				"message", fmt.Sprintf("%s (updated by %s#%s (%d))", parsed.Args[1].Str(), parsed.Msg.Author.Username, parsed.Msg.Author.Discriminator, parsed.Msg.Author.ID)).RowsAffected
+			found, err := editWarning(config, parsed.GS.ID, parsed.Msg.Author, parsed.Args[0].Int(), parsed.Args[1].Str())
+			if err != nil {
+				return nil, err
+			}
 
-			if rows < 1 {
+			if !found {
 				return "Failed updating, most likely couldn't find the warning", nil
 			}
 
-			return "👌", nil
+			return cmdConfirmation(config, fmt.Sprintf("Updated warning `#%d`", parsed.Args[0].Int())), nil
 		},
 	},
 	&commands.YAGCommand{
@@ -597,7 +2327,7 @@ var ModerationCommands = []*commands.YAGCommand{
 				return nil, err
 			}
 
-			_, err = MBaseCmdSecond(parsed, "", true, discordgo.PermissionManageMessages, config.WarnCmdRoles, config.WarnCommandsEnabled)
+			_, err = MBaseCmdSecond(parsed, "", true, discordgo.PermissionManageMessages, config.WarnCmdRoles, config.WarnRequireAllRoles, config.WarnCommandsEnabled)
 			if err != nil {
 				return nil, err
 			}
@@ -607,7 +2337,7 @@ var ModerationCommands = []*commands.YAGCommand{
 				return "Failed deleting, most likely couldn't find the warning", nil
 			}
 
-			return "👌", nil
+			return cmdConfirmation(config, fmt.Sprintf("Deleted warning `#%d`", parsed.Args[0].Int())), nil
 		},
 	},
 	&commands.YAGCommand{
@@ -627,7 +2357,7 @@ var ModerationCommands = []*commands.YAGCommand{
 				return nil, err
 			}
 
-			_, err = MBaseCmdSecond(parsed, "", true, discordgo.PermissionManageMessages, config.WarnCmdRoles, config.WarnCommandsEnabled)
+			_, err = MBaseCmdSecond(parsed, "", true, discordgo.PermissionManageMessages, config.WarnCmdRoles, config.WarnRequireAllRoles, config.WarnCommandsEnabled)
 			if err != nil {
 				return nil, err
 			}
@@ -638,16 +2368,149 @@ var ModerationCommands = []*commands.YAGCommand{
 			return fmt.Sprintf("Deleted %d warnings.", rows), nil
 		},
 	},
+	&commands.YAGCommand{
+		CustomEnabled: true,
+		CmdCategory:   commands.CategoryModeration,
+		Name:          "PruneWarnings",
+		Description:   "Deletes warnings older than the specified duration, of a single user or the whole server (requires Manage Server)",
+		RequiredArgs:  1,
+		Arguments: []*dcmd.ArgDef{
+			&dcmd.ArgDef{Name: "OlderThan", Type: &commands.DurationArg{}},
+			&dcmd.ArgDef{Name: "User", Type: dcmd.UserID},
+		},
+		RunFunc: func(parsed *dcmd.Data) (interface{}, error) {
+			config, _, err := MBaseCmd(parsed, 0)
+			if err != nil {
+				return nil, err
+			}
+
+			userID := parsed.Args[1].Int64()
+
+			// pruning the whole server is a lot more destructive
+			neededPerm := discordgo.PermissionManageServer
+			var cmdRoles []int64
+			if userID != 0 {
+				neededPerm = discordgo.PermissionManageMessages
+				cmdRoles = config.WarnCmdRoles
+			}
+
+			_, err = MBaseCmdSecond(parsed, "", true, neededPerm, cmdRoles, config.WarnRequireAllRoles, config.WarnCommandsEnabled)
+			if err != nil {
+				return nil, err
+			}
+
+			olderThan := parsed.Args[0].Value.(time.Duration)
+			if olderThan <= 0 {
+				return "The duration has to be above 0", nil
+			}
+
+			q := common.GORM.Where("guild_id = ? AND created_at < ?", parsed.GS.ID, time.Now().Add(-olderThan))
+			if userID != 0 {
+				q = q.Where("user_id = ?", discordgo.StrID(userID))
+			}
+
+			rows := q.Delete(WarningModel{}).RowsAffected
+			return fmt.Sprintf("Deleted %d warnings older than %s.", rows, common.HumanizeDuration(common.DurationPrecisionMinutes, olderThan)), nil
+		},
+	},
+	&commands.YAGCommand{
+		CustomEnabled: true,
+		CmdCategory:   commands.CategoryModeration,
+		Name:          "RecategorizeWarnings",
+		Aliases:       []string{"recatwarns"},
+		Description:   "Moves warnings matching the filters to another category, use `none` to remove the category",
+		LongDescription: "Filter with -user, -from (the current category, `none` for uncategorized), -contains (text in the reason) and -ma (max age)." +
+			"\nWithout a user filter this affects warnings across the whole server and requires Manage Server.",
+		RequiredArgs: 1,
+		Arguments: []*dcmd.ArgDef{
+			&dcmd.ArgDef{Name: "Category", Type: dcmd.String},
+		},
+		ArgSwitches: []*dcmd.ArgDef{
+			&dcmd.ArgDef{Switch: "user", Name: "Only warnings of this user", Type: dcmd.UserID},
+			&dcmd.ArgDef{Switch: "from", Name: "Only warnings in this category", Type: dcmd.String},
+			&dcmd.ArgDef{Switch: "contains", Name: "Only warnings with this text in the reason", Type: dcmd.String},
+			&dcmd.ArgDef{Switch: "ma", Name: "Max age", Type: &commands.DurationArg{}},
+		},
+		RunFunc: func(parsed *dcmd.Data) (interface{}, error) {
+			config, _, err := MBaseCmd(parsed, 0)
+			if err != nil {
+				return nil, err
+			}
+
+			var userID int64
+			if parsed.Switches["user"].Value != nil {
+				userID = parsed.Switches["user"].Int64()
+			}
+
+			// same as pruning, changing the whole server requires more permissions
+			neededPerm := discordgo.PermissionManageServer
+			var cmdRoles []int64
+			if userID != 0 {
+				neededPerm = discordgo.PermissionManageMessages
+				cmdRoles = config.WarnCmdRoles
+			}
+
+			_, err = MBaseCmdSecond(parsed, "", true, neededPerm, cmdRoles, config.WarnRequireAllRoles, config.WarnCommandsEnabled)
+			if err != nil {
+				return nil, err
+			}
+
+			newCategory := ""
+			if !strings.EqualFold(parsed.Args[0].Str(), "none") {
+				var ok bool
+				newCategory, ok = config.matchWarnCategory(parsed.Args[0].Str())
+				if !ok {
+					return config.warnCategoryErr(parsed.Args[0].Str()), nil
+				}
+			}
+
+			q := common.GORM.Model(WarningModel{}).Where("guild_id = ?", parsed.GS.ID)
+			if userID != 0 {
+				q = q.Where("user_id = ?", discordgo.StrID(userID))
+			}
+
+			if parsed.Switches["from"].Value != nil {
+				from := parsed.Switches["from"].Str()
+				if strings.EqualFold(from, "none") {
+					q = q.Where("category = ''")
+				} else {
+					q = q.Where("lower(category) = lower(?)", strings.TrimSpace(from))
+				}
+			}
+
+			if parsed.Switches["contains"].Value != nil {
+				q = q.Where("message ILIKE ?", "%"+likeEscaper.Replace(parsed.Switches["contains"].Str())+"%")
+			}
+
+			if parsed.Switches["ma"].Value != nil {
+				if ma := parsed.Switches["ma"].Value.(time.Duration); ma > 0 {
+					q = q.Where("created_at > ?", time.Now().Add(-ma))
+				}
+			}
+
+			result := q.Update("category", newCategory)
+			if result.Error != nil {
+				return nil, result.Error
+			}
+
+			if newCategory == "" {
+				return fmt.Sprintf("Removed the category of %d warning(s).", result.RowsAffected), nil
+			}
+
+			return fmt.Sprintf("Moved %d warning(s) to the category `%s`.", result.RowsAffected, newCategory), nil
+		},
+	},
 	&commands.YAGCommand{
 		CmdCategory: commands.CategoryModeration,
 		Name:        "TopWarnings",
-		Aliases:     []string{"topwarns"},
+		Aliases:     []string{"topwarns", "warnleaderboard"},
 		Description: "Shows ranked list of warnings on the server",
 		Arguments: []*dcmd.ArgDef{
 			{Name: "Page", Type: dcmd.Int, Default: 0},
 		},
 		ArgSwitches: []*dcmd.ArgDef{
 			&dcmd.ArgDef{Switch: "id", Name: "List userIDs"},
+			&dcmd.ArgDef{Switch: "min", Name: "Only show users with at least this many warnings (default 2)", Type: dcmd.Int, Default: 2},
 		},
 		RunFunc: paginatedmessages.PaginatedCommand(0, func(parsed *dcmd.Data, p *paginatedmessages.PaginatedMessage, page int) (*discordgo.MessageEmbed, error) {
 
@@ -657,7 +2520,7 @@ var ModerationCommands = []*commands.YAGCommand{
 				return nil, err
 			}
 
-			_, err = MBaseCmdSecond(parsed, "", true, discordgo.PermissionManageMessages, config.WarnCmdRoles, true)
+			_, err = MBaseCmdSecond(parsed, "", true, discordgo.PermissionManageMessages, config.WarnCmdRoles, config.WarnRequireAllRoles, true)
 			if err != nil {
 				return nil, err
 			}
@@ -667,7 +2530,7 @@ var ModerationCommands = []*commands.YAGCommand{
 			}
 
 			offset := (page - 1) * 15
-			entries, err := TopWarns(parsed.GS.ID, offset, 15)
+			entries, err := TopWarns(parsed.GS.ID, offset, 15, parsed.Switches["min"].Int())
 			if err != nil {
 				return nil, err
 			}
@@ -700,6 +2563,47 @@ var ModerationCommands = []*commands.YAGCommand{
 
 		}),
 	},
+	&commands.YAGCommand{
+		CustomEnabled: true,
+		CmdCategory:   commands.CategoryModeration,
+		Name:          "TrustLevel",
+		Aliases:       []string{"trust"},
+		Description:   "Shows or sets the trust level of a user, trusted users are treated more leniently by automated moderation",
+		LongDescription: fmt.Sprintf("Levels range from %d to %d, %d is the default and %d and above counts as trusted.",
+			TrustLevelMin, TrustLevelMax, TrustLevelDefault, TrustLevelTrusted),
+		RequiredArgs: 1,
+		Arguments: []*dcmd.ArgDef{
+			&dcmd.ArgDef{Name: "User", Type: dcmd.UserID},
+			&dcmd.ArgDef{Name: "Level", Type: &dcmd.IntArg{Min: TrustLevelMin, Max: TrustLevelMax}},
+		},
+		RunFunc: func(parsed *dcmd.Data) (interface{}, error) {
+			config, target, err := MBaseCmd(parsed, parsed.Args[0].Int64())
+			if err != nil {
+				return nil, err
+			}
+
+			_, err = MBaseCmdSecond(parsed, "", true, discordgo.PermissionManageServer, nil, false, true)
+			if err != nil {
+				return nil, err
+			}
+
+			if parsed.Args[1].Value == nil {
+				level, err := GetTrustLevel(parsed.GS.ID, target.ID)
+				if err != nil {
+					return nil, err
+				}
+
+				return fmt.Sprintf("Trust level of `%d`: **%d**", target.ID, level), nil
+			}
+
+			err = SetTrustLevel(parsed.GS.ID, target.ID, parsed.Msg.Author.ID, parsed.Args[1].Int())
+			if err != nil {
+				return nil, err
+			}
+
+			return cmdConfirmation(config, fmt.Sprintf("Set the trust level of %s#%s (ID %d) to **%d**", target.Username, target.Discriminator, target.ID, parsed.Args[1].Int())), nil
+		},
+	},
 	&commands.YAGCommand{
 		CustomEnabled: true,
 		CmdCategory:   commands.CategoryModeration,
@@ -721,7 +2625,7 @@ var ModerationCommands = []*commands.YAGCommand{
 				return nil, err
 			}
 
-			_, err = MBaseCmdSecond(parsed, "", true, discordgo.PermissionManageRoles, config.GiveRoleCmdRoles, config.GiveRoleCmdEnabled)
+			_, err = MBaseCmdSecond(parsed, "", true, discordgo.PermissionManageRoles, config.GiveRoleCmdRoles, config.GiveRoleRequireAllRoles, config.GiveRoleCmdEnabled)
 			if err != nil {
 				return nil, err
 			}
@@ -794,7 +2698,7 @@ var ModerationCommands = []*commands.YAGCommand{
 				return nil, err
 			}
 
-			_, err = MBaseCmdSecond(parsed, "", true, discordgo.PermissionManageRoles, config.GiveRoleCmdRoles, config.GiveRoleCmdEnabled)
+			_, err = MBaseCmdSecond(parsed, "", true, discordgo.PermissionManageRoles, config.GiveRoleCmdRoles, config.GiveRoleRequireAllRoles, config.GiveRoleCmdEnabled)
 			if err != nil {
 				return nil, err
 			}
@@ -837,6 +2741,136 @@ var ModerationCommands = []*commands.YAGCommand{
 }
 
 func AdvancedDeleteMessages(channelID int64, filterUser int64, regex string, maxAge time.Duration, minAge time.Duration, pinFilterEnable bool, deleteNum, fetchNum int) (int, error) {
+	deleted, _, _, err := advancedDeleteMessages(channelID, filterUser, regex, maxAge, minAge, pinFilterEnable, deleteNum, fetchNum)
+	return deleted, err
+}
+
+// advancedDeleteMessages is the same as AdvancedDeleteMessages but also returns the number of messages scanned,
+// and whether the scan was truncated because we kept getting ratelimited while fetching
+func advancedDeleteMessages(channelID int64, filterUser int64, regex string, maxAge time.Duration, minAge time.Duration, pinFilterEnable bool, deleteNum, fetchNum int) (deleted int, scanned int, truncated bool, err error) {
+	msgs, truncated, err := getMessagesWithRetry(channelID, fetchNum)
+	if err != nil {
+		return 0, 0, false, err
+	}
+
+	deleted, err = advancedDeleteFromMessages(channelID, msgs, filterUser, regex, maxAge, minAge, pinFilterEnable, deleteNum)
+	return deleted, len(msgs), truncated, err
+}
+
+func isRatelimitErr(err error) bool {
+	if cast, ok := errors.Cause(err).(*discordgo.RESTError); ok && cast.Response != nil {
+		return cast.Response.StatusCode == 429
+	}
+
+	return false
+}
+
+// getMessagesWithRetry retries bot.GetMessages with a backoff when ratelimited,
+// if it keeps getting ratelimited it falls back to fetching fewer messages
+func getMessagesWithRetry(channelID int64, limit int) (msgs []*dstate.MessageState, truncated bool, err error) {
+	backoff := time.Second
+	for {
+		msgs, err = bot.GetMessages(channelID, limit, false)
+		if err == nil || !isRatelimitErr(err) {
+			return msgs, truncated, err
+		}
+
+		if backoff > time.Second*2 {
+			if limit <= 100 {
+				return nil, truncated, err
+			}
+
+			// Give up on the full scan and try a smaller one instead
+			limit /= 2
+			truncated = true
+			backoff = time.Second
+			continue
+		}
+
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+type activeThread struct {
+	ID       int64 `json:"id,string"`
+	ParentID int64 `json:"parent_id,string"`
+}
+
+// CleanActiveThreads applies the same filters as AdvancedDeleteMessages to all active threads under the parent channel.
+// Threads are not tracked in the state, so the messages are always fetched from the api.
+func CleanActiveThreads(guildID, parentID int64, filterUser int64, regex string, maxAge time.Duration, minAge time.Duration, pinFilterEnable bool, deleteNum, fetchNum int) (int, error) {
+	endpoint := discordgo.EndpointGuild(guildID) + "/threads/active"
+	body, err := session().RequestWithBucketID("GET", endpoint, nil, endpoint)
+	if err != nil {
+		return 0, err
+	}
+
+	var resp struct {
+		Threads []*activeThread `json:"threads"`
+	}
+	err = json.Unmarshal(body, &resp)
+	if err != nil {
+		return 0, err
+	}
+
+	total := 0
+	for _, thread := range resp.Threads {
+		if thread.ParentID != parentID {
+			continue
+		}
+
+		msgs, err := fetchMessagesAPI(thread.ID, fetchNum)
+		if err != nil {
+			return total, err
+		}
+
+		n, err := advancedDeleteFromMessages(thread.ID, msgs, filterUser, regex, maxAge, minAge, pinFilterEnable, deleteNum)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+
+	return total, nil
+}
+
+// fetchMessagesAPI fetches up to limit messages from the api, oldest first like bot.GetMessages
+func fetchMessagesAPI(channelID int64, limit int) ([]*dstate.MessageState, error) {
+	result := make([]*dstate.MessageState, 0, limit)
+
+	var before int64
+	for len(result) < limit {
+		toFetch := limit - len(result)
+		if toFetch > 100 {
+			toFetch = 100
+		}
+
+		msgs, err := session().ChannelMessages(channelID, toFetch, before, 0, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, m := range msgs {
+			result = append(result, dstate.MessageStateFromMessage(m))
+		}
+
+		if len(msgs) < toFetch {
+			break
+		}
+
+		before = msgs[len(msgs)-1].ID
+	}
+
+	// The api returns the newest messages first
+	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+		result[i], result[j] = result[j], result[i]
+	}
+
+	return result, nil
+}
+
+func advancedDeleteFromMessages(channelID int64, msgs []*dstate.MessageState, filterUser int64, regex string, maxAge time.Duration, minAge time.Duration, pinFilterEnable bool, deleteNum int) (int, error) {
 	var compiledRegex *regexp.Regexp
 	if regex != "" {
 		// Start by compiling the regex
@@ -850,7 +2884,7 @@ func AdvancedDeleteMessages(channelID int64, filterUser int64, regex string, max
 	var pinnedMessages map[int64]struct{}
 	if pinFilterEnable {
 		//Fetch pinned messages from channel and make a map with ids as keys which will make it easy to verify if a message with a given ID is pinned message
-		messageSlice, err := common.BotSession.ChannelMessagesPinned(channelID)
+		messageSlice, err := session().ChannelMessagesPinned(channelID)
 		if err != nil {
 			return 0, err
 		}
@@ -860,11 +2894,6 @@ func AdvancedDeleteMessages(channelID int64, filterUser int64, regex string, max
 		}
 	}
 
-	msgs, err := bot.GetMessages(channelID, fetchNum, false)
-	if err != nil {
-		return 0, err
-	}
-
 	toDelete := make([]int64, 0)
 	now := time.Now()
 	for i := len(msgs) - 1; i >= 0; i-- {
@@ -912,12 +2941,13 @@ func AdvancedDeleteMessages(channelID int64, filterUser int64, regex string, max
 		return 0, nil
 	}
 
+	var err error
 	if len(toDelete) < 1 {
 		return 0, nil
 	} else if len(toDelete) == 1 {
-		err = common.BotSession.ChannelMessageDelete(channelID, toDelete[0])
+		err = session().ChannelMessageDelete(channelID, toDelete[0])
 	} else {
-		err = common.BotSession.ChannelMessagesBulkDelete(channelID, toDelete)
+		err = session().ChannelMessagesBulkDelete(channelID, toDelete)
 	}
 
 	return len(toDelete), err
@@ -959,15 +2989,23 @@ func PaginateWarnings(parsed *dcmd.Data) func(p *paginatedmessages.PaginatedMess
 		userID := parsed.Args[0].Int64()
 		limit := 6
 
+		q := common.GORM.Where("user_id = ? AND guild_id = ?", userID, parsed.GS.ID)
+		if sw, ok := parsed.Switches["cat"]; ok && sw.Value != nil {
+			q = q.Where("lower(category) = lower(?)", strings.TrimSpace(sw.Str()))
+		}
+
 		var result []*WarningModel
 		var count int
-		err = common.GORM.Table("moderation_warnings").Where("user_id = ? AND guild_id = ?", userID, parsed.GS.ID).Count(&count).Error
+		err = q.Table("moderation_warnings").Count(&count).Error
 		if err != nil && err != gorm.ErrRecordNotFound {
 			return nil, err
 		}
-		err = common.GORM.Where("user_id = ? AND guild_id = ?", userID, parsed.GS.ID).Order("id desc").Offset(skip).Limit(limit).Find(&result).Error
-		if err != nil && err != gorm.ErrRecordNotFound {
-			return nil, err
+		// don't bother scanning past the last warning
+		if skip < count {
+			err = q.Order("id desc").Offset(skip).Limit(limit).Find(&result).Error
+			if err != nil && err != gorm.ErrRecordNotFound {
+				return nil, err
+			}
 		}
 
 		if len(result) < 1 && p != nil && p.LastResponse != nil { //Dont send No Results error on first execution
@@ -985,7 +3023,12 @@ func PaginateWarnings(parsed *dcmd.Data) func(p *paginatedmessages.PaginatedMess
 
 			for _, entry := range result {
 
-				entry_formatted := fmt.Sprintf("#%d: `%20s` - By: **%s** (%13s) \n **Reason:** %s", entry.ID, entry.CreatedAt.UTC().Format(time.RFC822), entry.AuthorUsernameDiscrim, entry.AuthorID, entry.Message)
+				category := ""
+				if entry.Category != "" {
+					category = " [" + entry.Category + "]"
+				}
+
+				entry_formatted := fmt.Sprintf("#%d%s: `%20s` - By: **%s** (%13s) \n **Reason:** %s", entry.ID, category, entry.CreatedAt.UTC().Format(time.RFC822), entry.AuthorUsernameDiscrim, entry.AuthorID, SanitizeReason(entry.Message))
 				if len([]rune(entry_formatted)) > 900 {
 					entry_formatted = common.CutStringShort(entry_formatted, 900)
 				}
@@ -993,6 +3036,9 @@ func PaginateWarnings(parsed *dcmd.Data) func(p *paginatedmessages.PaginatedMess
 				if entry.LogsLink != "" {
 					entry_formatted += fmt.Sprintf("> logs: [`link`](%s)\n", entry.LogsLink)
 				}
+				if entry.MessageLink != "" {
+					entry_formatted += fmt.Sprintf("> message: [`link`](%s)\n", entry.MessageLink)
+				}
 
 				if len([]rune(currentField.Value+entry_formatted)) > 1023 {
 					currentField = &discordgo.MessageEmbedField{
@@ -0,0 +1,304 @@
+package moderation
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jonas747/dcmd"
+	"github.com/jonas747/discordgo"
+	"github.com/jonas747/dstate"
+	"github.com/jonas747/yagpdb/bot"
+	"github.com/jonas747/yagpdb/common"
+)
+
+func testMessage(id, author int64, content string, age time.Duration) *dstate.MessageState {
+	return &dstate.MessageState{
+		Message: &discordgo.Message{
+			ID:      id,
+			Content: content,
+			Author:  &discordgo.User{ID: author},
+		},
+		ParsedCreated: time.Now().Add(-age),
+	}
+}
+
+func TestAdvancedDeleteFromMessages(t *testing.T) {
+	msgs := []*dstate.MessageState{
+		testMessage(1, 1, "too old", time.Hour*24*15),
+		testMessage(2, 1, "hello", time.Hour),
+		testMessage(3, 2, "hello", time.Minute),
+		testMessage(4, 1, "bye", time.Minute),
+		testMessage(5, 1, "hello", time.Second),
+	}
+
+	cases := []struct {
+		name       string
+		user       int64
+		regex      string
+		maxAge     time.Duration
+		minAge     time.Duration
+		num        int
+		bulk       []int64
+		singleDels []int64
+	}{
+		{name: "all", num: 100, bulk: []int64{5, 4, 3, 2}},
+		{name: "limit", num: 2, bulk: []int64{5, 4}},
+		{name: "user", user: 1, num: 100, bulk: []int64{5, 4, 2}},
+		{name: "regex", user: 1, regex: "^hel", num: 100, bulk: []int64{5, 2}},
+		{name: "maxage", maxAge: time.Minute * 30, num: 100, bulk: []int64{5, 4, 3}},
+		{name: "minage", minAge: time.Second * 30, num: 100, bulk: []int64{4, 3, 2}},
+		{name: "single", user: 2, num: 100, singleDels: []int64{3}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			m, restore := useMockSession()
+			defer restore()
+
+			n, err := advancedDeleteFromMessages(1, msgs, c.user, c.regex, c.maxAge, c.minAge, false, c.num)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if n != len(c.bulk)+len(c.singleDels) {
+				t.Errorf("deleted %d, expected %d", n, len(c.bulk)+len(c.singleDels))
+			}
+
+			if !int64SlicesEqual(m.deleted, c.singleDels) {
+				t.Errorf("single deleted %v, expected %v", m.deleted, c.singleDels)
+			}
+
+			var bulk []int64
+			if len(m.bulkDeleted) > 0 {
+				bulk = m.bulkDeleted[0]
+			}
+			if !int64SlicesEqual(bulk, c.bulk) {
+				t.Errorf("bulk deleted %v, expected %v", bulk, c.bulk)
+			}
+		})
+	}
+}
+
+func int64SlicesEqual(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestHasCmdRoles(t *testing.T) {
+	cases := []struct {
+		member     []int64
+		cmd        []int64
+		requireAll bool
+		expected   bool
+	}{
+		{[]int64{1, 2}, []int64{2, 3}, false, true},
+		{[]int64{1}, []int64{2, 3}, false, false},
+		{[]int64{1, 2, 3}, []int64{2, 3}, true, true},
+		{[]int64{1, 2}, []int64{2, 3}, true, false},
+		{[]int64{1, 2}, nil, true, false},
+	}
+
+	for i, c := range cases {
+		if got := hasCmdRoles(c.member, c.cmd, c.requireAll); got != c.expected {
+			t.Errorf("case %d: got %t, expected %t", i, got, c.expected)
+		}
+	}
+}
+
+func TestCmdPermsMetViaRoles(t *testing.T) {
+	ms := &dstate.MemberState{ID: 1, Roles: []int64{2}}
+
+	met, viaRoles, err := cmdPermsMet(0, ms, discordgo.PermissionBanMembers, []int64{2}, false)
+	if err != nil || !met || !viaRoles {
+		t.Errorf("expected access through the command roles, got met: %t, viaRoles: %t, err: %v", met, viaRoles, err)
+	}
+
+	met, viaRoles, err = cmdPermsMet(0, ms, 0, []int64{3}, false)
+	if err != nil || !met || viaRoles {
+		t.Errorf("expected access without a required permission, got met: %t, viaRoles: %t, err: %v", met, viaRoles, err)
+	}
+}
+
+func TestCmdConfirmation(t *testing.T) {
+	if got := cmdConfirmation(&Config{}, "Deleted warning `#1`"); got != "👌" {
+		t.Errorf("unexpected terse confirmation: %q", got)
+	}
+
+	if got := cmdConfirmation(&Config{VerboseConfirmations: true}, "Deleted warning `#1`"); got != "👌 Deleted warning `#1`" {
+		t.Errorf("unexpected verbose confirmation: %q", got)
+	}
+}
+
+func TestCleanMaxAge(t *testing.T) {
+	config := &Config{DefaultCleanMaxAge: 60 * 24}
+
+	if got := cleanMaxAge(&Config{}, nil); got != 0 {
+		t.Errorf("expected no limit without a default, got %s", got)
+	}
+
+	if got := cleanMaxAge(config, nil); got != time.Hour*24 {
+		t.Errorf("expected the server default, got %s", got)
+	}
+
+	if got := cleanMaxAge(config, time.Hour); got != time.Hour {
+		t.Errorf("expected -ma to override the default, got %s", got)
+	}
+
+	if got := cleanMaxAge(config, time.Duration(0)); got != 0 {
+		t.Errorf("expected -ma 0 to lift the default, got %s", got)
+	}
+}
+
+func TestCleanDelayDuration(t *testing.T) {
+	if got := (&Config{}).CleanDelayDuration(); got != DefaultCleanDelay {
+		t.Errorf("expected the default delay for unsaved configs, got %s", got)
+	}
+
+	config := &Config{CleanDelay: sql.NullInt64{Valid: true}}
+	if got := config.CleanDelayDuration(); got != 0 {
+		t.Errorf("expected no delay, got %s", got)
+	}
+
+	config.CleanDelay.Int64 = 250
+	if got := config.CleanDelayDuration(); got != time.Millisecond*250 {
+		t.Errorf("expected 250ms, got %s", got)
+	}
+}
+
+func TestRepliedAuthorID(t *testing.T) {
+	m, restore := useMockSession()
+	defer restore()
+
+	m.messages = map[int64]*discordgo.Message{3: {ID: 3, ChannelID: 2, Author: &discordgo.User{ID: 5}}}
+
+	if got := repliedAuthorID(&discordgo.Message{}); got != 0 {
+		t.Errorf("expected no author without a reply, got %d", got)
+	}
+
+	if got := repliedAuthorID(&discordgo.Message{MessageReference: &discordgo.MessageReference{ChannelID: 2, MessageID: 3}}); got != 5 {
+		t.Errorf("expected the author of the replied to message, got %d", got)
+	}
+
+	if got := repliedAuthorID(&discordgo.Message{MessageReference: &discordgo.MessageReference{ChannelID: 2, MessageID: 4}}); got != 0 {
+		t.Errorf("expected no author when the message can't be found, got %d", got)
+	}
+}
+
+func TestReasonOptional(t *testing.T) {
+	if !(&Config{}).ReasonOptional(true) || (&Config{}).ReasonOptional(false) {
+		t.Error("expected the command setting to be used by default")
+	}
+
+	if (&Config{GlobalReasonRequired: true}).ReasonOptional(true) {
+		t.Error("expected the global setting to override the command setting")
+	}
+}
+
+func TestCmdTakesReason(t *testing.T) {
+	for _, cmd := range ModerationCommands {
+		data := &dcmd.Data{Cmd: &dcmd.RegisteredCommand{Command: cmd}}
+
+		switch cmd.Name {
+		case "Ban", "Kick", "Warn", "Clean":
+			if !cmdTakesReason(data) {
+				t.Errorf("expected %s to take a reason", cmd.Name)
+			}
+		case "ExportModlog", "CanDM", "ModPerms":
+			if cmdTakesReason(data) {
+				t.Errorf("expected %s not to take a reason", cmd.Name)
+			}
+		}
+	}
+}
+
+func TestCleanReasonGiven(t *testing.T) {
+	data := &dcmd.Data{Args: []*dcmd.ParsedArg{{Value: 10}, {Value: int64(0)}, {}}}
+	if cleanReasonGiven(data) {
+		t.Error("expected a clean without a reason not to be logged")
+	}
+
+	data.Args[2].Value = "  "
+	if cleanReasonGiven(data) {
+		t.Error("expected a blank reason not to count")
+	}
+
+	data.Args[2].Value = "spam"
+	if !cleanReasonGiven(data) {
+		t.Error("expected a clean with a reason to be logged")
+	}
+}
+
+func TestLogClean(t *testing.T) {
+	m, restore := useMockSession()
+	defer restore()
+
+	config := &Config{ActionChannel: "10"}
+	author := &discordgo.User{ID: 3, Username: "mod", Discriminator: "0001"}
+
+	logClean(config, author, "general", 0, 0, "spam")
+	if len(m.sentEmbeds) != 0 {
+		t.Errorf("expected nothing to be posted when nothing was deleted, got %d entries", len(m.sentEmbeds))
+	}
+
+	if common.RedisPool == nil || bot.State == nil {
+		t.Skip("redis or bot state not available, skipping.")
+		return
+	}
+
+	logClean(config, author, "general", 5, 10, "spam")
+	if len(m.sentEmbeds) != 1 || !strings.Contains(m.sentEmbeds[0].Description, "Cleaned 10 message(s) in #general from user 5") {
+		t.Errorf("expected the clean in the modlog, got %+v", m.sentEmbeds)
+	}
+}
+
+func TestCmdTargetID(t *testing.T) {
+	m, restore := useMockSession()
+	defer restore()
+
+	m.messages = map[int64]*discordgo.Message{3: {ID: 3, ChannelID: 2, Author: &discordgo.User{ID: 5}}}
+	reply := &discordgo.Message{MessageReference: &discordgo.MessageReference{ChannelID: 2, MessageID: 3}}
+
+	data := &dcmd.Data{Msg: reply, Args: []*dcmd.ParsedArg{{Value: int64(7)}, {Value: "spam"}}}
+	if got, err := cmdTargetID(data); err != nil || got != 7 {
+		t.Errorf("expected the given user, got %d, %v", got, err)
+	}
+
+	data.Args[0].Value = nil
+	if got, err := cmdTargetID(data); err != nil || got != 5 {
+		t.Errorf("expected the replied to author, got %d, %v", got, err)
+	}
+
+	// a mistyped id ends up in the reason, don't act on the replied to author
+	data.Args[1].Value = "12345x spam"
+	if got, err := cmdTargetID(data); err == nil {
+		t.Errorf("expected the mistyped user to be rejected, got %d", got)
+	}
+
+	data.Args[1].Value = "<@12345x> spam"
+	if got, err := cmdTargetID(data); err == nil {
+		t.Errorf("expected the mistyped mention to be rejected, got %d", got)
+	}
+
+	// the duration of a mute came first, the reason can start with anything
+	data.Args = []*dcmd.ParsedArg{{}, {Value: time.Minute}, {Value: "3 times in a row"}}
+	if got, err := cmdTargetID(data); err != nil || got != 5 {
+		t.Errorf("expected the replied to author after a duration, got %d, %v", got, err)
+	}
+
+	data.Msg = &discordgo.Message{}
+	data.Args = []*dcmd.ParsedArg{{}, {Value: "spam"}}
+	if _, err := cmdTargetID(data); err == nil {
+		t.Error("expected an error without a user or reply")
+	}
+}
@@ -0,0 +1,82 @@
+package moderation
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/jonas747/yagpdb/bot"
+	"github.com/jonas747/yagpdb/bot/eventsystem"
+	"github.com/jonas747/yagpdb/common"
+	"github.com/mediocregopher/radix/v3"
+)
+
+// Only reply to the same user once every hour
+const dmAutoResponseCooldownSeconds = 60 * 60
+
+func RedisKeyDMAutoResponseCooldown(userID int64) string {
+	return "moderation_dm_autoresponse:" + strconv.FormatInt(userID, 10)
+}
+
+// HandleDMAutoResponse replies to DMs from users that are muted or banned on a server that has an auto response set up
+func HandleDMAutoResponse(evt *eventsystem.EventData) (retry bool, err error) {
+	m := evt.MessageCreate()
+	if m.GuildID != 0 || m.Author == nil || m.Author.Bot || m.Author.ID == common.BotUser.ID {
+		return false, nil
+	}
+
+	guilds, err := punishedInGuilds(m.Author.ID)
+	if err != nil || len(guilds) < 1 {
+		return false, err
+	}
+
+	var responses []string
+	for _, guildID := range guilds {
+		config, err := GetConfig(guildID)
+		if err != nil {
+			return false, err
+		}
+
+		if strings.TrimSpace(config.DMAutoResponse) == "" {
+			continue
+		}
+
+		responses = append(responses, "**"+bot.GuildName(guildID)+":** "+config.DMAutoResponse)
+	}
+
+	if len(responses) < 1 {
+		return false, nil
+	}
+
+	var resp string
+	err = common.RedisPool.Do(radix.Cmd(&resp, "SET", RedisKeyDMAutoResponseCooldown(m.Author.ID), "1", "EX", strconv.Itoa(dmAutoResponseCooldownSeconds), "NX"))
+	if err != nil || resp != "OK" {
+		// on cooldown
+		return false, err
+	}
+
+	_, err = session().ChannelMessageSend(m.ChannelID, strings.Join(responses, "\n\n"))
+	return false, err
+}
+
+// punishedInGuilds returns the guilds the user is currently muted or banned in
+func punishedInGuilds(userID int64) ([]int64, error) {
+	var guilds []int64
+	err := common.GORM.Model(&MuteModel{}).Where("user_id = ?", userID).Pluck("DISTINCT guild_id", &guilds).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var banGuilds []int64
+	err = common.GORM.Model(&BanModel{}).Where("user_id = ?", userID).Pluck("DISTINCT guild_id", &banGuilds).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range banGuilds {
+		if !common.ContainsInt64Slice(guilds, v) {
+			guilds = append(guilds, v)
+		}
+	}
+
+	return guilds, nil
+}
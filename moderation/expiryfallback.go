@@ -0,0 +1,131 @@
+package moderation
+
+import (
+	"sync"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/jonas747/yagpdb/commands"
+	"github.com/jonas747/yagpdb/common"
+	"github.com/jonas747/yagpdb/common/backgroundworkers"
+	"github.com/jonas747/yagpdb/common/scheduledevents2"
+)
+
+// Timed mutes and bans rely on scheduled events to be reversed. If scheduling the reversal fails the expiry
+// is recorded in the database and the reconciler keeps trying to schedule it, so a temporary action can't
+// silently become permanent. Servers can instead opt into having the action undone on failure.
+
+// The max number of pending expiries handled per reconciler run
+const maxPendingExpiriesPerRun = 100
+
+var errExpiryNotScheduled = commands.NewUserError("Couldn't schedule the automatic reversal of this action, so it was undone. Try again in a bit.")
+
+// scheduleEventOverride is used instead of scheduledevents2.ScheduleEvent if set, only meant for tests
+var scheduleEventOverride func(evtName string, guildID int64, runAt time.Time, data interface{}) error
+
+func scheduleEvent(evtName string, guildID int64, runAt time.Time, data interface{}) error {
+	if scheduleEventOverride != nil {
+		return scheduleEventOverride(evtName, guildID, runAt, data)
+	}
+
+	return scheduledevents2.ScheduleEvent(evtName, guildID, runAt, data)
+}
+
+func expiryEventData(eventName string, userID int64) interface{} {
+	if eventName == "moderation_unban" {
+		return &ScheduledUnbanData{UserID: userID}
+	}
+
+	return &ScheduledUnmuteData{UserID: userID}
+}
+
+// scheduleExpiry schedules the unmute or unban of a timed action, if that fails the expiry is recorded for the reconciler.
+// revert is true if the server wants the action undone instead, the caller is responsible for that.
+func scheduleExpiry(config *Config, guildID, userID int64, eventName string, runAt time.Time) (revert bool, err error) {
+	err = scheduleEvent(eventName, guildID, runAt, expiryEventData(eventName, userID))
+	if err == nil {
+		return false, nil
+	}
+
+	l := logger.WithError(err).WithField("guild", guildID).WithField("user", userID).WithField("event", eventName)
+	if config.RevertUnscheduled {
+		l.Warn("failed scheduling expiry, reverting the action")
+		return true, errExpiryNotScheduled
+	}
+
+	l.Warn("failed scheduling expiry, recording it for the reconciler")
+
+	err = common.GORM.Create(&PendingExpiryModel{
+		GuildID:   guildID,
+		UserID:    userID,
+		EventName: eventName,
+		ExpiresAt: runAt,
+	}).Error
+	if err != nil {
+		return false, errors.WithMessage(err, "failed recording pending expiry")
+	}
+
+	return false, nil
+}
+
+// clearPendingExpiries removes the recorded expiries of the user, for when the action is replaced or reversed
+func clearPendingExpiries(guildID, userID int64, eventName string) {
+	err := common.GORM.Where("guild_id = ? AND user_id = ? AND event_name = ?", guildID, userID, eventName).Delete(PendingExpiryModel{}).Error
+	common.LogIgnoreError(err, "[moderation] failed clearing pending expiries", nil)
+}
+
+// reconcilePendingExpiries tries to schedule the recorded expiries again, returning the number scheduled
+func reconcilePendingExpiries() (int, error) {
+	var pending []*PendingExpiryModel
+	err := common.GORM.Order("expires_at asc").Limit(maxPendingExpiriesPerRun).Find(&pending).Error
+	if err != nil {
+		return 0, err
+	}
+
+	scheduled := 0
+	for _, p := range pending {
+		// expiries in the past are simply run right away by the scheduler
+		err = scheduleEvent(p.EventName, p.GuildID, p.ExpiresAt, expiryEventData(p.EventName, p.UserID))
+		if err != nil {
+			// the scheduler is still unavailable, try again next run
+			return scheduled, err
+		}
+
+		err = common.GORM.Delete(p).Error
+		if err != nil {
+			return scheduled, err
+		}
+
+		scheduled++
+	}
+
+	return scheduled, nil
+}
+
+var _ backgroundworkers.BackgroundWorkerPlugin = (*Plugin)(nil)
+
+// RunBackgroundWorker runs the expiry reconciler until the worker is stopped
+func (p *Plugin) RunBackgroundWorker() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			n, err := reconcilePendingExpiries()
+			if err != nil {
+				logger.WithError(err).Error("failed reconciling pending expiries")
+			}
+			if n > 0 {
+				logger.Infof("scheduled %d pending expiries", n)
+			}
+		case wg := <-p.stopWorkers:
+			wg.Done()
+			return
+		}
+	}
+}
+
+func (p *Plugin) StopBackgroundWorker(wg *sync.WaitGroup) {
+	p.stopWorkers <- wg
+}
@@ -0,0 +1,55 @@
+package moderation
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jonas747/yagpdb/common"
+)
+
+// failScheduling makes scheduling events fail, call the returned function to restore it
+func failScheduling() func() {
+	old := scheduleEventOverride
+	scheduleEventOverride = func(evtName string, guildID int64, runAt time.Time, data interface{}) error {
+		return errors.New("scheduler unavailable")
+	}
+	return func() { scheduleEventOverride = old }
+}
+
+func TestScheduleExpiryRevert(t *testing.T) {
+	defer failScheduling()()
+
+	revert, err := scheduleExpiry(&Config{RevertUnscheduled: true}, 1, 2, "moderation_unmute", time.Now().Add(time.Hour))
+	if !revert {
+		t.Error("expected the action to be reverted")
+	}
+	if err != errExpiryNotScheduled {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestScheduleExpiryRecordsPending(t *testing.T) {
+	if common.GORM == nil {
+		t.Skip("db not available, skipping.")
+		return
+	}
+
+	defer failScheduling()()
+	defer clearPendingExpiries(1, 2, "moderation_unban")
+
+	revert, err := scheduleExpiry(&Config{}, 1, 2, "moderation_unban", time.Now().Add(time.Hour))
+	if revert || err != nil {
+		t.Fatalf("expected the action to stay, got revert: %t, err: %v", revert, err)
+	}
+
+	var count int
+	err = common.GORM.Model(&PendingExpiryModel{}).Where("guild_id = 1 AND user_id = 2 AND event_name = 'moderation_unban'").Count(&count).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 1 {
+		t.Errorf("expected 1 pending expiry, got %d", count)
+	}
+}
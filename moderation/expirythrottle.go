@@ -0,0 +1,80 @@
+package moderation
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jonas747/yagpdb/common/config"
+	"github.com/jonas747/yagpdb/common/scheduledevents2"
+	seventsmodels "github.com/jonas747/yagpdb/common/scheduledevents2/models"
+)
+
+// Scheduled events each run in their own goroutine, so when lots of timed mutes or bans end at the same time
+// (e.g after a raid was mass muted) they would all hit the api at once. Instead they take turns through a
+// bounded number of slots, every one of them is still processed, just spread out.
+
+var confExpiryConcurrency = config.RegisterOption("yagpdb.moderation.expiry_concurrency", "Max number of scheduled unmutes and unbans processed at once", 5)
+
+// How long each expiry keeps its slot after finishing, to space out the api calls of a burst
+const expirySlotCooldown = time.Millisecond * 250
+
+type expiryThrottle struct {
+	slots   chan struct{}
+	waiting int32
+}
+
+var (
+	expiryThrottleOnce   sync.Once
+	globalExpiryThrottle *expiryThrottle
+)
+
+func newExpiryThrottle(concurrency int) *expiryThrottle {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	return &expiryThrottle{slots: make(chan struct{}, concurrency)}
+}
+
+// getExpiryThrottle returns the shared throttle, created on first use as the config isn't loaded when the handlers are registered
+func getExpiryThrottle() *expiryThrottle {
+	expiryThrottleOnce.Do(func() {
+		globalExpiryThrottle = newExpiryThrottle(confExpiryConcurrency.GetInt())
+	})
+
+	return globalExpiryThrottle
+}
+
+// run waits for a free slot and runs f in it, logging when a burst starts being throttled
+func (t *expiryThrottle) run(eventName string, guildID int64, f func()) {
+	select {
+	case t.slots <- struct{}{}:
+	default:
+		waiting := atomic.AddInt32(&t.waiting, 1)
+		if waiting == 1 {
+			logger.WithField("event", eventName).Info("throttling burst of scheduled expiries")
+		}
+		logger.WithField("event", eventName).WithField("guild", guildID).WithField("waiting", waiting).Debug("waiting for an expiry slot")
+
+		t.slots <- struct{}{}
+		atomic.AddInt32(&t.waiting, -1)
+	}
+
+	defer func() {
+		time.Sleep(expirySlotCooldown)
+		<-t.slots
+	}()
+
+	f()
+}
+
+// throttledExpiry wraps the handler of a scheduled unmute or unban so it runs through the expiry throttle
+func throttledExpiry(handler scheduledevents2.HandlerFunc) scheduledevents2.HandlerFunc {
+	return func(evt *seventsmodels.ScheduledEvent, data interface{}) (retry bool, err error) {
+		getExpiryThrottle().run(evt.EventName, evt.GuildID, func() {
+			retry, err = handler(evt, data)
+		})
+		return
+	}
+}
@@ -0,0 +1,43 @@
+package moderation
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestExpiryThrottle(t *testing.T) {
+	throttle := newExpiryThrottle(2)
+
+	var running, maxRunning, done int32
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			throttle.run("moderation_unmute", 1, func() {
+				n := atomic.AddInt32(&running, 1)
+				for {
+					max := atomic.LoadInt32(&maxRunning)
+					if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+						break
+					}
+				}
+
+				time.Sleep(time.Millisecond * 10)
+				atomic.AddInt32(&running, -1)
+				atomic.AddInt32(&done, 1)
+			})
+		}()
+	}
+	wg.Wait()
+
+	if done != 6 {
+		t.Errorf("expected all 6 expiries to be processed, got %d", done)
+	}
+
+	if maxRunning > 2 {
+		t.Errorf("expected at most 2 expiries at once, got %d", maxRunning)
+	}
+}
@@ -0,0 +1,129 @@
+package moderation
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jonas747/discordgo"
+	"github.com/jonas747/yagpdb/common"
+)
+
+// The max number of previous cases referenced in a suggested reason
+const MaxHistoryReasonCases = 5
+
+// historyCase is a previous warning, mute or ban of a user, referenced in a suggested reason
+type historyCase struct {
+	Time time.Time
+
+	// How the case is referenced, warnings by their id as used by the warning commands, mutes and bans by their date
+	Ref string
+}
+
+// historyReason returns a reason referencing the user's previous warnings, mutes and bans if enabled and they have any,
+// used when no reason is given
+func historyReason(config *Config, guildID, userID int64) string {
+	if !config.SuggestHistoryReason {
+		return ""
+	}
+
+	cases, err := userHistoryCases(guildID, userID)
+	if err != nil {
+		logger.WithError(err).WithField("guild", guildID).Error("failed looking up previous cases")
+		return ""
+	}
+
+	return formatHistoryReason(cases)
+}
+
+// userHistoryCases returns the user's previous warnings, mutes (current and archived) and bans, oldest first
+func userHistoryCases(guildID, userID int64) ([]*historyCase, error) {
+	var cases []*historyCase
+
+	var warnings []*WarningModel
+	err := common.GORM.Select("id, created_at").Where("guild_id = ? AND user_id = ?", guildID, discordgo.StrID(userID)).Find(&warnings).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range warnings {
+		cases = append(cases, &historyCase{Time: v.CreatedAt, Ref: "#" + strconv.FormatUint(uint64(v.ID), 10)})
+	}
+
+	var mutes []*MuteModel
+	err = common.GORM.Select("created_at").Where("guild_id = ? AND user_id = ?", guildID, userID).Find(&mutes).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range mutes {
+		cases = append(cases, &historyCase{Time: v.CreatedAt, Ref: "mute on " + v.CreatedAt.UTC().Format("2006-01-02")})
+	}
+
+	var mutesHistory []*MuteHistoryModel
+	err = common.GORM.Select("muted_at").Where("guild_id = ? AND user_id = ?", guildID, userID).Find(&mutesHistory).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range mutesHistory {
+		cases = append(cases, &historyCase{Time: v.MutedAt, Ref: "mute on " + v.MutedAt.UTC().Format("2006-01-02")})
+	}
+
+	var bans []*BanModel
+	err = common.GORM.Select("created_at").Where("guild_id = ? AND user_id = ?", guildID, userID).Find(&bans).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range bans {
+		cases = append(cases, &historyCase{Time: v.CreatedAt, Ref: "ban on " + v.CreatedAt.UTC().Format("2006-01-02")})
+	}
+
+	sort.SliceStable(cases, func(i, j int) bool {
+		return cases[i].Time.Before(cases[j].Time)
+	})
+
+	return cases, nil
+}
+
+// formatHistoryReason formats the suggested reason for a user with the previous cases (oldest first),
+// e.g "3rd offense - see #12, mute on 2021-03-04"
+func formatHistoryReason(cases []*historyCase) string {
+	if len(cases) < 1 {
+		return ""
+	}
+
+	refs := cases
+	if len(refs) > MaxHistoryReasonCases {
+		refs = refs[len(refs)-MaxHistoryReasonCases:]
+	}
+
+	strs := make([]string, len(refs))
+	for i, v := range refs {
+		strs[i] = v.Ref
+	}
+
+	return fmt.Sprintf("%s offense - see %s", ordinal(len(cases)+1), strings.Join(strs, ", "))
+}
+
+// ordinal returns n as an english ordinal, 1 -> 1st, 12 -> 12th, 22 -> 22nd
+func ordinal(n int) string {
+	suffix := "th"
+	switch n % 10 {
+	case 1:
+		suffix = "st"
+	case 2:
+		suffix = "nd"
+	case 3:
+		suffix = "rd"
+	}
+
+	if n%100 >= 11 && n%100 <= 13 {
+		suffix = "th"
+	}
+
+	return strconv.Itoa(n) + suffix
+}
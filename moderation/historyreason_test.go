@@ -0,0 +1,72 @@
+package moderation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jonas747/yagpdb/common"
+	"github.com/jonas747/yagpdb/common/configstore"
+)
+
+func testHistoryCases(refs ...string) []*historyCase {
+	cases := make([]*historyCase, len(refs))
+	for i, v := range refs {
+		cases[i] = &historyCase{Ref: v}
+	}
+	return cases
+}
+
+func TestFormatHistoryReason(t *testing.T) {
+	cases := []struct {
+		cases    []*historyCase
+		expected string
+	}{
+		{nil, ""},
+		{testHistoryCases("#12"), "2nd offense - see #12"},
+		{testHistoryCases("#12", "mute on 2021-03-04"), "3rd offense - see #12, mute on 2021-03-04"},
+		{testHistoryCases("#1", "#2", "#3", "#4", "#5", "#6", "#7", "#8", "#9", "#10"), "11th offense - see #6, #7, #8, #9, #10"},
+	}
+
+	for _, c := range cases {
+		if got := formatHistoryReason(c.cases); got != c.expected {
+			t.Errorf("formatHistoryReason(%d cases) = %q, expected %q", len(c.cases), got, c.expected)
+		}
+	}
+}
+
+func TestOrdinal(t *testing.T) {
+	for n, expected := range map[int]string{1: "1st", 2: "2nd", 3: "3rd", 4: "4th", 11: "11th", 12: "12th", 13: "13th", 21: "21st", 22: "22nd", 111: "111th"} {
+		if got := ordinal(n); got != expected {
+			t.Errorf("ordinal(%d) = %q, expected %q", n, got, expected)
+		}
+	}
+}
+
+func TestHistoryReasonWithoutWarnings(t *testing.T) {
+	if common.GORM == nil {
+		t.Skip("db not available, skipping.")
+		return
+	}
+
+	config := &Config{GuildConfigModel: configstore.GuildConfigModel{GuildID: 1}, SuggestHistoryReason: true}
+
+	mutedAt := time.Date(2021, 3, 4, 12, 0, 0, 0, time.UTC)
+	for _, v := range []time.Time{mutedAt, mutedAt.Add(time.Hour * 24)} {
+		err := common.GORM.Create(&MuteHistoryModel{GuildID: 1, UserID: 12, MutedAt: v, ExpiresAt: v.Add(time.Hour)}).Error
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer common.GORM.Where("guild_id = 1 AND user_id = 12").Delete(MuteHistoryModel{})
+
+	err := common.GORM.Create(&BanModel{GuildID: 1, UserID: 12}).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer common.GORM.Where("guild_id = 1 AND user_id = 12").Delete(BanModel{})
+
+	expected := "4th offense - see mute on 2021-03-04, mute on 2021-03-05, ban on " + time.Now().UTC().Format("2006-01-02")
+	if got := historyReason(config, 1, 12); got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
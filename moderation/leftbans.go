@@ -0,0 +1,60 @@
+package moderation
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/jonas747/discordgo"
+	"github.com/jonas747/yagpdb/common"
+	"github.com/mediocregopher/radix/v3"
+)
+
+// Users that leave and are banned shortly after (to stop them from coming back) make for confusing modlog entries,
+// so leaves are remembered for a bit and bans of users that already left can be annotated or not logged at all.
+
+// Values of Config.LeftBansModlog
+const (
+	LeftBansLog      = ""
+	LeftBansAnnotate = "annotate"
+	LeftBansSuppress = "suppress"
+)
+
+var leftBansModes = []string{LeftBansLog, LeftBansAnnotate, LeftBansSuppress}
+
+// How long leaves are remembered
+const RecentLeaveWindow = time.Minute * 10
+
+// The ban itself also removes the member, so the leave has to happen at least this long before the ban to count
+const leaveBeforeBanGrace = time.Second * 2
+
+func RedisKeyRecentLeave(guildID, userID int64) string {
+	return "moderation_recent_leave:" + discordgo.StrID(guildID) + ":" + discordgo.StrID(userID)
+}
+
+// recordLeave remembers when the user left
+func recordLeave(guildID, userID int64, t time.Time) {
+	err := common.RedisPool.Do(radix.FlatCmd(nil, "SET", RedisKeyRecentLeave(guildID, userID), t.UnixNano(), "PX", int64(RecentLeaveWindow/time.Millisecond)))
+	if err != nil {
+		logger.WithError(err).WithField("guild", guildID).Error("failed recording leave")
+	}
+}
+
+// leftBeforeBan returns true if the user left on their own shortly before being banned at bannedAt
+func leftBeforeBan(guildID, userID int64, bannedAt time.Time) bool {
+	var raw string
+	err := common.RedisPool.Do(radix.Cmd(&raw, "GET", RedisKeyRecentLeave(guildID, userID)))
+	if err != nil || raw == "" {
+		return false
+	}
+
+	nanos, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return false
+	}
+
+	return leftBefore(time.Unix(0, nanos), bannedAt)
+}
+
+func leftBefore(leftAt, bannedAt time.Time) bool {
+	return leftAt.Before(bannedAt.Add(-leaveBeforeBanGrace))
+}
@@ -0,0 +1,40 @@
+package moderation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jonas747/yagpdb/common"
+	"github.com/mediocregopher/radix/v3"
+)
+
+func TestLeftBefore(t *testing.T) {
+	now := time.Now()
+
+	if !leftBefore(now.Add(-time.Minute), now) {
+		t.Error("expected a leave a minute before the ban to count")
+	}
+
+	// the member remove event caused by the ban itself
+	if leftBefore(now.Add(-time.Millisecond*500), now) || leftBefore(now.Add(time.Second), now) {
+		t.Error("expected the removal caused by the ban not to count")
+	}
+}
+
+func TestLeftBeforeBan(t *testing.T) {
+	if common.RedisPool == nil {
+		t.Skip("redis not available, skipping.")
+		return
+	}
+
+	defer common.RedisPool.Do(radix.Cmd(nil, "DEL", RedisKeyRecentLeave(1, 2)))
+
+	if leftBeforeBan(1, 2, time.Now()) {
+		t.Error("expected no leave to be recorded")
+	}
+
+	recordLeave(1, 2, time.Now().Add(-time.Minute))
+	if !leftBeforeBan(1, 2, time.Now()) {
+		t.Error("expected the recorded leave to be found")
+	}
+}
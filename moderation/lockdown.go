@@ -0,0 +1,141 @@
+package moderation
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/jonas747/discordgo"
+	"github.com/jonas747/yagpdb/common"
+	"github.com/mediocregopher/radix/v3"
+)
+
+// Lockdowns snapshot the full set of permission overwrites of the channel before changing anything,
+// unlocking restores that snapshot exactly instead of just flipping the send messages bit back.
+
+// How long a lockdown snapshot is kept around if the channel is never unlocked
+const LockdownSnapshotTTL = time.Hour * 24 * 30
+
+func RedisKeyLockdownSnapshot(channelID int64) string {
+	return "moderation_lockdown_snapshot:" + strconv.FormatInt(channelID, 10)
+}
+
+// LockdownSnapshot is the state of the channel's permission overwrites from before it was locked down
+type LockdownSnapshot struct {
+	ChannelID  int64                            `json:"channel_id"`
+	CreatedAt  time.Time                        `json:"created_at"`
+	CreatedBy  int64                            `json:"created_by"`
+	Overwrites []*discordgo.PermissionOverwrite `json:"overwrites"`
+}
+
+// LockdownSnapshotInfo returns the stored snapshot of the channel, nil if it's not locked down
+func LockdownSnapshotInfo(channelID int64) (*LockdownSnapshot, error) {
+	var raw []byte
+	err := common.RedisPool.Do(radix.Cmd(&raw, "GET", RedisKeyLockdownSnapshot(channelID)))
+	if err != nil || len(raw) < 1 {
+		return nil, err
+	}
+
+	var snapshot *LockdownSnapshot
+	err = json.Unmarshal(raw, &snapshot)
+	return snapshot, errors.WithStackIf(err)
+}
+
+// snapshotChannelOverwrites stores the current overwrites of the channel, an existing snapshot is kept
+// so locking an already locked down channel doesn't overwrite the original state.
+// Returns false if there already was a snapshot.
+func snapshotChannelOverwrites(channel *discordgo.Channel, authorID int64) (bool, error) {
+	serialized, err := json.Marshal(&LockdownSnapshot{
+		ChannelID:  channel.ID,
+		CreatedAt:  time.Now(),
+		CreatedBy:  authorID,
+		Overwrites: channel.PermissionOverwrites,
+	})
+	if err != nil {
+		return false, errors.WithStackIf(err)
+	}
+
+	var set string
+	err = common.RedisPool.Do(radix.FlatCmd(&set, "SET", RedisKeyLockdownSnapshot(channel.ID), serialized, "EX", int64(LockdownSnapshotTTL/time.Second), "NX"))
+	return set == "OK", err
+}
+
+// lockdownChannel snapshots the overwrites of the channel and denies @everyone from sending messages in it.
+// Returns false if the channel was already locked down or it couldn't be locked down.
+func lockdownChannel(channel *discordgo.Channel, authorID int64) (bool, error) {
+	created, err := snapshotChannelOverwrites(channel, authorID)
+	if err != nil {
+		return false, errors.WithMessage(err, "snapshotChannelOverwrites")
+	}
+
+	// the @everyone role has the same id as the guild
+	allow, deny := 0, 0
+	for _, v := range channel.PermissionOverwrites {
+		if v.Type == "role" && v.ID == channel.GuildID {
+			allow, deny = v.Allow, v.Deny
+			break
+		}
+	}
+
+	allow &^= discordgo.PermissionSendMessages
+	deny |= discordgo.PermissionSendMessages
+	err = session().ChannelPermissionSet(channel.ID, channel.GuildID, "role", allow, deny)
+	if err != nil {
+		if created {
+			// it was never locked down, don't leave a snapshot for unlock to restore
+			delErr := common.RedisPool.Do(radix.Cmd(nil, "DEL", RedisKeyLockdownSnapshot(channel.ID)))
+			common.LogIgnoreError(delErr, "[moderation] failed removing lockdown snapshot", nil)
+		}
+		return false, err
+	}
+
+	return created, nil
+}
+
+// unlockChannel restores the overwrites of the channel from the lockdown snapshot, overwrites added since are removed
+// and changed ones are put back. Returns false if there was no snapshot to restore.
+func unlockChannel(channel *discordgo.Channel) (bool, error) {
+	snapshot, err := LockdownSnapshotInfo(channel.ID)
+	if err != nil || snapshot == nil {
+		return false, err
+	}
+
+	err = restoreOverwrites(channel, snapshot.Overwrites)
+	if err != nil {
+		return false, err
+	}
+
+	err = common.RedisPool.Do(radix.Cmd(nil, "DEL", RedisKeyLockdownSnapshot(channel.ID)))
+	return true, err
+}
+
+func restoreOverwrites(channel *discordgo.Channel, snapshot []*discordgo.PermissionOverwrite) error {
+	current := make(map[int64]*discordgo.PermissionOverwrite)
+	for _, v := range channel.PermissionOverwrites {
+		current[v.ID] = v
+	}
+
+	for _, v := range snapshot {
+		if c, ok := current[v.ID]; ok && c.Type == v.Type && c.Allow == v.Allow && c.Deny == v.Deny {
+			delete(current, v.ID)
+			continue
+		}
+
+		delete(current, v.ID)
+		err := session().ChannelPermissionSet(channel.ID, v.ID, v.Type, v.Allow, v.Deny)
+		if err != nil {
+			return errors.WithMessage(err, "ChannelPermissionSet")
+		}
+	}
+
+	// whatever is left wasn't there before the lockdown
+	for id := range current {
+		err := session().ChannelPermissionDelete(channel.ID, id)
+		if err != nil {
+			return errors.WithMessage(err, "ChannelPermissionDelete")
+		}
+	}
+
+	return nil
+}
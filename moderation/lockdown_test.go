@@ -0,0 +1,43 @@
+package moderation
+
+import (
+	"testing"
+
+	"github.com/jonas747/discordgo"
+)
+
+func TestRestoreOverwrites(t *testing.T) {
+	m, restore := useMockSession()
+	defer restore()
+
+	channel := &discordgo.Channel{
+		ID:      10,
+		GuildID: 1,
+		PermissionOverwrites: []*discordgo.PermissionOverwrite{
+			// @everyone, changed by the lockdown
+			{ID: 1, Type: "role", Deny: discordgo.PermissionSendMessages | discordgo.PermissionAddReactions},
+			// untouched
+			{ID: 2, Type: "role", Allow: discordgo.PermissionSendMessages},
+			// added during the lockdown
+			{ID: 3, Type: "member", Allow: discordgo.PermissionSendMessages},
+		},
+	}
+
+	snapshot := []*discordgo.PermissionOverwrite{
+		{ID: 1, Type: "role", Deny: discordgo.PermissionAddReactions},
+		{ID: 2, Type: "role", Allow: discordgo.PermissionSendMessages},
+	}
+
+	err := restoreOverwrites(channel, snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(m.permSets) != 1 || m.permSets[0].ID != 1 || m.permSets[0].Deny != discordgo.PermissionAddReactions || m.permSets[0].Allow != 0 {
+		t.Errorf("unexpected overrides set: %+v", m.permSets)
+	}
+
+	if len(m.permDeletes) != 1 || m.permDeletes[0] != 3 {
+		t.Errorf("unexpected overrides deleted: %v", m.permDeletes)
+	}
+}
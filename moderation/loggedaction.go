@@ -0,0 +1,78 @@
+package moderation
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"emperror.dev/errors"
+	"github.com/jonas747/discordgo"
+	"github.com/jonas747/yagpdb/common"
+)
+
+// Actions taken outside of the bot (through discord directly, or a verbal warning) can be logged manually
+// so they still end up in the modlog, nothing is done to the user.
+
+const MaxLoggedActionLength = 100
+
+// LoggedActionModel is an action that was logged manually with the LogAction command
+type LoggedActionModel struct {
+	common.SmallModel
+
+	GuildID  int64 `gorm:"index"`
+	UserID   int64 `gorm:"index"`
+	AuthorID int64
+
+	// Username and discrim for author incase they leave
+	AuthorUsernameDiscrim string
+
+	Action string
+	Reason string
+
+	// The modlog entry that was created for it, 0 if the modlog is disabled
+	ModlogMessageID int64
+}
+
+func (l *LoggedActionModel) TableName() string {
+	return "moderation_logged_actions"
+}
+
+// loggedModlogAction is the modlog action shown for a manually logged action
+func loggedModlogAction(action string) ModlogAction {
+	action = SanitizeReason(strings.TrimSpace(action))
+	if action != "" {
+		// "verbal warning" -> "Verbal warning:"
+		first, size := utf8.DecodeRuneInString(action)
+		action = string(unicode.ToUpper(first)) + action[size:] + ":"
+	}
+
+	return ModlogAction{Prefix: action, Emoji: "📝", Color: 0x99aab5, Footer: "Logged manually, no action was taken by the bot"}
+}
+
+// logManualAction creates the modlog entry and record for an action that was taken outside of the bot
+func logManualAction(config *Config, author *discordgo.User, target *discordgo.User, action, reason string) error {
+	entry, err := sendModlogEmbed(config, author, loggedModlogAction(action), target, reason, "")
+	if err != nil {
+		return err
+	}
+
+	record := &LoggedActionModel{
+		GuildID:               config.GetGuildID(),
+		UserID:                target.ID,
+		AuthorID:              author.ID,
+		AuthorUsernameDiscrim: author.Username + "#" + author.Discriminator,
+		Action:                strings.TrimSpace(action),
+		Reason:                reason,
+	}
+	if entry != nil && entry.Message != nil {
+		record.ModlogMessageID = entry.Message.ID
+	}
+
+	return errors.WithStackIf(common.GORM.Create(record).Error)
+}
+
+// updateRecordedReason updates the reason of the stored record behind a modlog entry, entries without one are ignored
+func updateRecordedReason(guildID, modlogMessageID int64, reason string) error {
+	err := common.GORM.Model(&LoggedActionModel{}).Where("guild_id = ? AND modlog_message_id = ?", guildID, modlogMessageID).Update("reason", reason).Error
+	return errors.WithStackIf(err)
+}
@@ -0,0 +1,91 @@
+package moderation
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jonas747/discordgo"
+	"github.com/jonas747/yagpdb/common"
+	"github.com/jonas747/yagpdb/common/configstore"
+)
+
+func TestLoggedModlogAction(t *testing.T) {
+	cases := map[string]string{
+		"verbal warning": "Verbal warning:",
+		" ébauche ":      "Ébauche:",
+		"@everyone":      "@\u200beveryone:",
+		"":               "",
+	}
+
+	for in, expected := range cases {
+		if got := loggedModlogAction(in).Prefix; got != expected {
+			t.Errorf("loggedModlogAction(%q): expected %q, got %q", in, expected, got)
+		}
+	}
+}
+
+func TestLogManualAction(t *testing.T) {
+	if common.GORM == nil {
+		t.Skip("db not available, skipping.")
+		return
+	}
+
+	author := &discordgo.User{ID: 3, Username: "mod", Discriminator: "0001"}
+	target := &discordgo.User{ID: 4, Username: "user", Discriminator: "0002"}
+	defer common.GORM.Where("guild_id = 1 AND user_id = 4").Delete(LoggedActionModel{})
+
+	err := logManualAction(&Config{GuildConfigModel: configstore.GuildConfigModel{GuildID: 1}}, author, target, "verbal warning", "spam")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var record LoggedActionModel
+	err = common.GORM.Where("guild_id = 1 AND user_id = 4").First(&record).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if record.Action != "verbal warning" || record.AuthorID != 3 || record.ModlogMessageID != 0 {
+		t.Errorf("unexpected record %+v", record)
+	}
+}
+
+func TestEditModlogReason(t *testing.T) {
+	if common.GORM == nil {
+		t.Skip("db not available, skipping.")
+		return
+	}
+
+	_, restore := useMockSession()
+	defer restore()
+
+	record := &LoggedActionModel{GuildID: 1, UserID: 5, AuthorID: 3, Action: "verbal warning", Reason: "spam", ModlogMessageID: 100}
+	err := common.GORM.Create(record).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer common.GORM.Where("guild_id = 1 AND user_id = 5").Delete(LoggedActionModel{})
+
+	embed := &discordgo.MessageEmbed{Description: "**📝Verbal warning: user**#0002 *(ID 5)*\n📄**Reason:** spam"}
+	msg := &discordgo.Message{ID: 100, ChannelID: 10, Embeds: []*discordgo.MessageEmbed{embed}}
+
+	author := &discordgo.User{ID: 3, Username: "mod", Discriminator: "0001"}
+	err = editModlogReason(&Config{GuildConfigModel: configstore.GuildConfigModel{GuildID: 1}}, author, msg, "spamming in <#10>", "spamming in #general")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasSuffix(embed.Description, "📄**Reason:** spamming in #general") {
+		t.Errorf("expected the embed reason to be updated, got %q", embed.Description)
+	}
+
+	var updated LoggedActionModel
+	err = common.GORM.Where("id = ?", record.ID).First(&updated).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if updated.Reason != "spamming in <#10>" {
+		t.Errorf("expected the stored reason to be updated, got %q", updated.Reason)
+	}
+}
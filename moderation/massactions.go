@@ -0,0 +1,363 @@
+package moderation
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jonas747/discordgo"
+	"github.com/jonas747/dstate"
+	"github.com/jonas747/yagpdb/bot"
+	"github.com/jonas747/yagpdb/commands"
+	"github.com/jonas747/yagpdb/common"
+)
+
+const (
+	// Max number of members a single mass action can target
+	MaxMassActionTargets = 100
+
+	// Delay between each member in a mass action, to not hammer the api
+	massActionDelay = time.Millisecond * 500
+)
+
+// massActionPermsMet checks the additional requirements for mass actions on top of the ones of the single user command,
+// having the roles or the permission (if both are configured) is enough
+func massActionPermsMet(config *Config, channelID int64, ms *dstate.MemberState) (bool, error) {
+	if len(config.MassActionCmdRoles) < 1 && !config.MassActionRequireManageServer {
+		return true, nil
+	}
+
+	if len(config.MassActionCmdRoles) > 0 && hasCmdRoles(ms.Roles, config.MassActionCmdRoles, config.MassActionRequireAllRoles) {
+		return true, nil
+	}
+
+	if config.MassActionRequireManageServer {
+		return bot.AdminOrPermMS(channelID, ms, discordgo.PermissionManageServer)
+	}
+
+	return false, nil
+}
+
+// massActionPermsErr checks the additional requirements of mass actions, returning a user error describing them if they're not met
+func massActionPermsErr(config *Config, channelID int64, ms *dstate.MemberState) error {
+	met, err := massActionPermsMet(config, channelID, ms)
+	if err != nil || met {
+		return err
+	}
+
+	var needed []string
+	if len(config.MassActionCmdRoles) > 0 {
+		if config.MassActionRequireAllRoles {
+			needed = append(needed, "all of the mass action roles")
+		} else {
+			needed = append(needed, "one of the mass action roles")
+		}
+	}
+	if config.MassActionRequireManageServer {
+		needed = append(needed, "the Manage Server permission")
+	}
+
+	return commands.NewUserErrorf("Mass actions on this server additionally require %s", strings.Join(needed, " or "))
+}
+
+// membersWithRole returns the members with the role that the author is allowed to act on,
+// skipping bots, the author and staff (members with any of the staff roles or ranked the same or higher than the author)
+func membersWithRole(gs *dstate.GuildState, author *dstate.MemberState, roleID int64, staffRoles []int64) (targets []int64, skipped int) {
+	gs.RLock()
+	defer gs.RUnlock()
+
+	for _, ms := range gs.Members {
+		if !ms.MemberSet || !common.ContainsInt64Slice(ms.Roles, roleID) {
+			continue
+		}
+
+		if !massActionTarget(gs, author, ms, staffRoles) {
+			skipped++
+			continue
+		}
+
+		targets = append(targets, ms.ID)
+	}
+
+	return
+}
+
+// membersInVoice returns the members in the voice channel that the author is allowed to act on, with the same rules as membersWithRole
+func membersInVoice(gs *dstate.GuildState, author *dstate.MemberState, channelID int64, staffRoles []int64) (targets []int64, skipped int) {
+	gs.RLock()
+	defer gs.RUnlock()
+
+	for _, vs := range gs.Guild.VoiceStates {
+		if vs.ChannelID != channelID {
+			continue
+		}
+
+		ms := gs.Member(false, vs.UserID)
+		if ms == nil || !ms.MemberSet || !massActionTarget(gs, author, ms, staffRoles) {
+			skipped++
+			continue
+		}
+
+		targets = append(targets, ms.ID)
+	}
+
+	return
+}
+
+// massActionTarget returns false for bots, the author and staff, gs has to be locked
+func massActionTarget(gs *dstate.GuildState, author *dstate.MemberState, ms *dstate.MemberState, staffRoles []int64) bool {
+	if ms.Bot || ms.ID == author.ID || !bot.IsMemberAbove(gs, author, ms) {
+		return false
+	}
+
+	for _, r := range staffRoles {
+		if common.ContainsInt64Slice(ms.Roles, r) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// findVoiceChannel finds a voice channel by id or name
+func findVoiceChannel(gs *dstate.GuildState, channelS string) *dstate.ChannelState {
+	parsedNumber, _ := strconv.ParseInt(channelS, 10, 64)
+
+	gs.RLock()
+	defer gs.RUnlock()
+
+	for _, c := range gs.Channels {
+		if c.Type != discordgo.ChannelTypeGuildVoice {
+			continue
+		}
+
+		if c.ID == parsedNumber || strings.EqualFold(strings.TrimSpace(c.Name), strings.TrimSpace(channelS)) {
+			return c
+		}
+	}
+
+	return nil
+}
+
+// runMassMute mutes all the targets one by one, editing the progress message as it goes and creating a single modlog entry at the end
+func runMassMute(config *Config, gs *dstate.GuildState, channel *dstate.ChannelState, author *discordgo.User, role *discordgo.Role, targets []int64, duration time.Duration, reason string, progress *discordgo.Message) {
+	// Don't create a modlog entry per member, we create a single one when done
+	muteConfig := *config
+	muteConfig.SuppressMemberEntries = true
+
+	muted := 0
+	failed := 0
+	for i, target := range targets {
+		member, err := bot.GetMember(gs.ID, target)
+		if err == nil && member != nil {
+			err = MuteUnmuteUser(&muteConfig, true, gs.ID, channel, nil, author, reason, member, int(duration.Minutes()))
+		}
+
+		if err != nil || member == nil {
+			logger.WithError(err).WithField("guild", gs.ID).WithField("user", target).Error("failed muting member in mass mute")
+			failed++
+		} else {
+			muted++
+		}
+
+		if progress != nil && (i+1)%10 == 0 {
+			session().ChannelMessageEdit(progress.ChannelID, progress.ID, fmt.Sprintf("Muting members with the role %s... (%d/%d)", role.Name, i+1, len(targets)))
+		}
+
+		time.Sleep(massActionDelay)
+	}
+
+	if progress != nil {
+		session().ChannelMessageEdit(progress.ChannelID, progress.ID, fmt.Sprintf("%s Muted %d member(s) with the role %s, %d failed.", MAMute.Emoji, muted, role.Name, failed))
+	}
+
+	action := MAMute
+	action.Footer = "Duration: "
+	if duration > 0 {
+		action.Footer += common.HumanizeDuration(common.DurationPrecisionMinutes, duration)
+	} else {
+		action.Footer += "permanent"
+	}
+
+	err := CreateMassModlogEmbed(config, author, action, fmt.Sprintf("Muted %d member(s) with the role %s", muted, role.Name), reason)
+	if err != nil {
+		logger.WithError(err).WithField("guild", gs.ID).Error("failed creating mass mute modlog entry")
+	}
+}
+
+// unmuteAllSummary describes the result of an UnmuteAll run
+func unmuteAllSummary(unmuted, departed, failed int) string {
+	summary := fmt.Sprintf("Unmuted %d member(s)", unmuted)
+	if departed > 0 {
+		summary += fmt.Sprintf(", cleared the mutes of %d that left", departed)
+	}
+	if failed > 0 {
+		summary += fmt.Sprintf(", %d failed", failed)
+	}
+
+	return summary
+}
+
+// runUnmuteAll unmutes everyone with an active mute one by one, members that left only have their mute cleared so
+// they aren't muted again when rejoining. Edits the progress message as it goes and creates a single modlog entry at the end
+func runUnmuteAll(config *Config, gs *dstate.GuildState, channel *dstate.ChannelState, author *discordgo.User, mutes []*MuteModel, reason string, progress *discordgo.Message) {
+	// Don't create a modlog entry per member, we create a single one when done
+	unmuteConfig := *config
+	unmuteConfig.SuppressMemberEntries = true
+
+	unmuted := 0
+	departed := 0
+	failed := 0
+	for i, mute := range mutes {
+		member, err := bot.GetMember(gs.ID, mute.UserID)
+		if err != nil || member == nil {
+			target := &discordgo.User{ID: mute.UserID, Username: "unknown", Discriminator: "????"}
+			_, err = clearDepartedMute(&unmuteConfig, gs.ID, author, target, reason)
+			if err == nil {
+				departed++
+			}
+		} else {
+			var result muteResult
+			result, err = muteUnmuteUser(&unmuteConfig, false, gs.ID, channel, nil, author, reason, member, 0, 0)
+			if err == nil {
+				unmuted++
+				if result.WasMuted {
+					givePostMuteRole(config, gs.ID, member.DGoUser(), true)
+				}
+			}
+		}
+
+		if err != nil {
+			logger.WithError(err).WithField("guild", gs.ID).WithField("user", mute.UserID).Error("failed unmuting member in unmute all")
+			failed++
+		}
+
+		if progress != nil && (i+1)%10 == 0 {
+			session().ChannelMessageEdit(progress.ChannelID, progress.ID, fmt.Sprintf("Unmuting all muted members... (%d/%d)", i+1, len(mutes)))
+		}
+
+		time.Sleep(massActionDelay)
+	}
+
+	summary := unmuteAllSummary(unmuted, departed, failed)
+	if progress != nil {
+		session().ChannelMessageEdit(progress.ChannelID, progress.ID, fmt.Sprintf("%s %s.", MAUnmute.Emoji, summary))
+	}
+
+	err := CreateMassModlogEmbed(config, author, MAUnmute, summary, reason)
+	if err != nil {
+		logger.WithError(err).WithField("guild", gs.ID).Error("failed creating unmute all modlog entry")
+	}
+}
+
+// runVoiceAction mutes or kicks all the targets that were in the voice channel one by one,
+// editing the progress message as it goes and creating a single modlog entry at the end
+func runVoiceAction(config *Config, gs *dstate.GuildState, channel *dstate.ChannelState, author *discordgo.User, vc *dstate.ChannelState, kick bool, targets []int64, duration time.Duration, reason string, progress *discordgo.Message) {
+	// Don't create a modlog entry per member, we create a single one when done
+	actionConfig := *config
+	actionConfig.SuppressMemberEntries = true
+
+	action := MAMute
+	verb := "Muting"
+	if kick {
+		action = MAKick
+		verb = "Kicking"
+	}
+
+	done := 0
+	failed := 0
+	for i, target := range targets {
+		member, err := bot.GetMember(gs.ID, target)
+		if err == nil && member != nil {
+			if kick {
+				err = KickUser(&actionConfig, gs.ID, channel, nil, author, reason, member.DGoUser())
+			} else {
+				err = MuteUnmuteUser(&actionConfig, true, gs.ID, channel, nil, author, reason, member, int(duration.Minutes()))
+			}
+		}
+
+		if err != nil || member == nil {
+			logger.WithError(err).WithField("guild", gs.ID).WithField("user", target).Error("failed acting on member in voice channel")
+			failed++
+		} else {
+			done++
+		}
+
+		if progress != nil && (i+1)%10 == 0 {
+			session().ChannelMessageEdit(progress.ChannelID, progress.ID, fmt.Sprintf("%s members in %s... (%d/%d)", verb, vc.Name, i+1, len(targets)))
+		}
+
+		time.Sleep(massActionDelay)
+	}
+
+	if progress != nil {
+		session().ChannelMessageEdit(progress.ChannelID, progress.ID, fmt.Sprintf("%s %s %d member(s) in %s, %d failed.", action.Emoji, action.Prefix, done, vc.Name, failed))
+	}
+
+	if !kick {
+		action.Footer = "Duration: "
+		if duration > 0 {
+			action.Footer += common.HumanizeDuration(common.DurationPrecisionMinutes, duration)
+		} else {
+			action.Footer += "permanent"
+		}
+	}
+
+	err := CreateMassModlogEmbed(config, author, action, fmt.Sprintf("%s %d member(s) in the voice channel %s", action.Prefix, done, vc.Name), reason)
+	if err != nil {
+		logger.WithError(err).WithField("guild", gs.ID).Error("failed creating voice action modlog entry")
+	}
+}
+
+// swapRole returns the roles with oldRole replaced by newRole, newRole is added even if the member didn't have oldRole
+func swapRole(roles []int64, oldRole, newRole int64) []string {
+	result := make([]string, 0, len(roles)+1)
+	for _, r := range roles {
+		if r == oldRole || r == newRole {
+			continue
+		}
+
+		result = append(result, strconv.FormatInt(r, 10))
+	}
+
+	return append(result, strconv.FormatInt(newRole, 10))
+}
+
+// runMigrateMuteRole gives every currently muted member the new mute role in place of the old one, the config should already be updated
+func runMigrateMuteRole(gs *dstate.GuildState, oldRole, newRole *discordgo.Role, progress *discordgo.Message) {
+	var mutes []*MuteModel
+	// mutes using one of the tier roles keep their role
+	err := common.GORM.Where("guild_id = ? AND mute_role = 0", gs.ID).Find(&mutes).Error
+	if err != nil {
+		logger.WithError(err).WithField("guild", gs.ID).Error("failed retrieving mutes for mute role migration")
+		session().ChannelMessageEdit(progress.ChannelID, progress.ID, "Failed retrieving the active mutes")
+		return
+	}
+
+	migrated := 0
+	failed := 0
+	for i, mute := range mutes {
+		member, err := bot.GetMember(gs.ID, mute.UserID)
+		if err != nil || member == nil {
+			// not on the server anymore, they get the new role when rejoining
+			continue
+		}
+
+		err = session().GuildMemberEdit(gs.ID, mute.UserID, swapRole(member.Roles, oldRole.ID, newRole.ID))
+		if err != nil {
+			logger.WithError(err).WithField("guild", gs.ID).WithField("user", mute.UserID).Error("failed migrating mute role")
+			failed++
+		} else {
+			migrated++
+		}
+
+		if (i+1)%10 == 0 {
+			session().ChannelMessageEdit(progress.ChannelID, progress.ID, fmt.Sprintf("Migrating muted members from %s to %s... (%d/%d)", oldRole.Name, newRole.Name, i+1, len(mutes)))
+		}
+
+		time.Sleep(massActionDelay)
+	}
+
+	session().ChannelMessageEdit(progress.ChannelID, progress.ID, fmt.Sprintf("Moved %d muted member(s) from %s to %s, %d failed.", migrated, oldRole.Name, newRole.Name, failed))
+}
@@ -0,0 +1,69 @@
+package moderation
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jonas747/dstate"
+	"github.com/lib/pq"
+)
+
+func TestSwapRole(t *testing.T) {
+	cases := []struct {
+		roles []int64
+		want  []string
+	}{
+		{[]int64{1, 2, 3}, []string{"1", "3", "10"}},
+		{[]int64{1, 3}, []string{"1", "3", "10"}},
+		{[]int64{2, 10}, []string{"10"}},
+	}
+
+	for _, c := range cases {
+		if got := swapRole(c.roles, 2, 10); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("swapRole(%v): got %v, expected %v", c.roles, got, c.want)
+		}
+	}
+}
+
+func TestMassActionPermsMet(t *testing.T) {
+	cases := []struct {
+		config *Config
+		roles  []int64
+		want   bool
+	}{
+		{&Config{}, nil, true},
+		{&Config{MassActionCmdRoles: pq.Int64Array{1, 2}}, []int64{2}, true},
+		{&Config{MassActionCmdRoles: pq.Int64Array{1, 2}}, []int64{3}, false},
+		{&Config{MassActionCmdRoles: pq.Int64Array{1, 2}, MassActionRequireAllRoles: true}, []int64{2}, false},
+		{&Config{MassActionCmdRoles: pq.Int64Array{1, 2}, MassActionRequireAllRoles: true}, []int64{1, 2}, true},
+	}
+
+	for i, c := range cases {
+		got, err := massActionPermsMet(c.config, 0, &dstate.MemberState{Roles: c.roles})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got != c.want {
+			t.Errorf("case %d: got %t, expected %t", i, got, c.want)
+		}
+	}
+}
+
+func TestUnmuteAllSummary(t *testing.T) {
+	cases := []struct {
+		unmuted, departed, failed int
+		want                      string
+	}{
+		{5, 0, 0, "Unmuted 5 member(s)"},
+		{5, 2, 0, "Unmuted 5 member(s), cleared the mutes of 2 that left"},
+		{0, 0, 3, "Unmuted 0 member(s), 3 failed"},
+		{4, 1, 1, "Unmuted 4 member(s), cleared the mutes of 1 that left, 1 failed"},
+	}
+
+	for _, c := range cases {
+		if got := unmuteAllSummary(c.unmuted, c.departed, c.failed); got != c.want {
+			t.Errorf("unmuteAllSummary(%d, %d, %d): got %q, expected %q", c.unmuted, c.departed, c.failed, got, c.want)
+		}
+	}
+}
@@ -3,37 +3,64 @@ package moderation
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"strconv"
+	"strings"
+	"text/template"
 	"time"
 
 	"github.com/jonas747/yagpdb/common"
 	"github.com/jonas747/yagpdb/common/configstore"
 	"github.com/jonas747/yagpdb/common/pubsub"
+	"github.com/jonas747/yagpdb/web"
 	"github.com/lib/pq"
 )
 
 type Config struct {
 	configstore.GuildConfigModel
 
+	// Set on the copy of the config used for the members of a mass action, which get a single modlog entry when done
+	// instead of one each. Never stored.
+	SuppressMemberEntries bool `gorm:"-" json:"-" schema:"-"`
+
+	// Set on the copy of the config used when a moderator retries a scheduled unmute, the user isn't sent the unmute DM.
+	// Never stored.
+	SuppressPunishDM bool `gorm:"-" json:"-" schema:"-"`
+
 	// Kick command
 	KickEnabled          bool
 	KickCmdRoles         pq.Int64Array `gorm:"type:bigint[]" valid:"role,true"`
+	KickRequireAllRoles  bool
 	DeleteMessagesOnKick bool
 	KickReasonOptional   bool
 	KickMessage          string `valid:"template,5000"`
 
 	// Ban
-	BanEnabled        bool
-	BanCmdRoles       pq.Int64Array `gorm:"type:bigint[]" valid:"role,true"`
-	BanReasonOptional bool
-	BanMessage        string `valid:"template,5000"`
+	BanEnabled         bool
+	BanCmdRoles        pq.Int64Array `gorm:"type:bigint[]" valid:"role,true"`
+	BanRequireAllRoles bool
+	BanReasonOptional  bool
+	BanMessage         string `valid:"template,5000"`
+	BanUpdateExisting  bool
+
+	// How long the moderator that made a ban can undo it with the UndoBan command, in minutes
+	BanUndoWindow sql.NullInt64 `gorm:"default:5"`
+
+	// Ask for confirmation before banning members with a role at or above ConfirmBanRole (or a moderation command role if not set)
+	ConfirmBanStaff bool
+	ConfirmBanRole  string `valid:"role,true"`
 
 	// Mute/unmute
 	MuteEnabled             bool
 	MuteCmdRoles            pq.Int64Array `gorm:"type:bigint[]" valid:"role,true"`
 	MuteRole                string        `valid:"role,true"`
+	MuteRequireAllRoles     bool
 	MuteDisallowReactionAdd bool
+	MuteDenyVoiceConnect    bool // keep muted users out of voice channels entirely, not just from speaking
+	MuteKeepOverrides       bool
+	ShadowMuteEnabled       bool // the shadowmute command, which mutes through member overwrites instead of the mute role
 	MuteReasonOptional      bool
+	MuteReasonFromAutomod   bool // use the most recent automod violation when no reason is given
 	UnmuteReasonOptional    bool
 	MuteManageRole          bool
 	MuteRemoveRoles         pq.Int64Array `gorm:"type:bigint[]" valid:"role,true"`
@@ -41,25 +68,170 @@ type Config struct {
 	MuteMessage             string        `valid:"template,5000"`
 	UnmuteMessage           string        `valid:"template,5000"`
 	DefaultMuteDuration     sql.NullInt64 `gorm:"default:10"`
+	MuteAutoKickEnabled     bool
+	MuteAutoKickThreshold   int `valid:"0,525600"` // in minutes
+	MuteNotifyModOnExpiry   bool
+	MuteCooldownMinutes     int `valid:"0,1440"`
+	MuteAlertThreshold      int `valid:"0,100"`
+	MuteAlertWindowMinutes  int `valid:"0,10080"`
+	MuteEscalationEnabled   bool
+	MuteEscalationSteps     string         `valid:",200"`
+	MuteEscalationWindow    int            `valid:"0,365"` // in days
+	MuteTierNames           pq.StringArray `gorm:"type:text[]"`
+	MuteTierRoles           pq.Int64Array  `gorm:"type:bigint[]"`
+	MuteTierKinds           pq.StringArray `gorm:"type:text[]"`
+
+	// Ended mutes left in muted_users (e.g the member left before the unmute) are archived after this many days, 0 to disable
+	MuteArchiveAfterDays   int `valid:"0,365"`
+	MuteArchiveKeepHistory bool
+
+	// Timeout
+	TimeoutEnabled         bool
+	TimeoutCmdRoles        pq.Int64Array `gorm:"type:bigint[]" valid:"role,true"`
+	TimeoutRequireAllRoles bool
+	TimeoutReasonOptional  bool
 
 	// Warn
 	WarnCommandsEnabled    bool
 	WarnCmdRoles           pq.Int64Array `gorm:"type:bigint[]" valid:"role,true"`
+	WarnRequireAllRoles    bool
 	WarnIncludeChannelLogs bool
 	WarnSendToModlog       bool
+	WarnDisallowBots       bool
 	WarnMessage            string `valid:"template,5000"`
+	WarnDedupSeconds       int    `valid:"0,3600"` // identical warnings within this window are skipped, 0 to disable
+	WarnCategories         string `valid:",1000"`  // comma separated
+	WarnReasonTemplate     string `valid:",1000"`  // see WarnReasonData
+
+	// Available as {{.AppealInstructions}} in the warning DM
+	WarnAppealInstructions string `valid:",1000"`
+	// Note in the modlog whether the warning DM was delivered
+	WarnDMStatusInModlog bool
+	// Post edits of warnings in the modlog, with the text before and after
+	WarnEditsToModlog bool
+
+	// Remind staff in the report channel to review the user's warnings this long after they reach the threshold
+	WarnReviewThreshold    int `valid:"0,100"`
+	WarnReviewDelayMinutes int `valid:"0,10080"`
 
 	// Misc
-	CleanEnabled  bool
-	ReportEnabled bool
-	ActionChannel string `valid:"channel,true"`
-	ReportChannel string `valid:"channel,true"`
-	LogUnbans     bool
-	LogBans       bool
+	CleanEnabled        bool
+	ReportEnabled       bool
+	ActionChannel       string `valid:"channel,true"`
+	ReportChannel       string `valid:"channel,true"`
+	ReportMentionRole   string `valid:"role,true"`
+	ReportRetentionDays int    `valid:"0,365"`
+	LogUnbans           bool
+	LogBans             bool
+
+	// How the moderator is shown in modlog entries, see ModlogAuthorFull and friends
+	ModlogAuthorDisplay string `valid:",20"`
+
+	// Full detail copies of modlog entries are posted here, the modlog then only gets a sanitized version
+	PrivateAuditChannel string `valid:"channel,true"`
+
+	// What to do with bans of users that left shortly before, see LeftBansAnnotate and friends
+	LeftBansModlog string `valid:",20"`
+
+	// Reply with a summary of what was done instead of just 👌
+	VerboseConfirmations bool
+
+	// Moderation commands can't be used until a modlog channel is set
+	RequireModlog bool
+
+	// Every action needs a reason, regardless of the per command reason optional settings
+	GlobalReasonRequired bool
+
+	// Server specific aliases for the moderation commands, comma separated alias=command pairs
+	CommandAliases string `valid:",1000"`
+
+	// Also log every action as a structured log line, for ops
+	StructuredActionLogs bool
+
+	// Raid mode locks down these channels when more than RaidModeJoinThreshold members join within RaidModeWindow seconds
+	RaidModeEnabled       bool
+	RaidModeJoinThreshold int           `valid:"0,1000"`
+	RaidModeWindow        int           `valid:"0,600"`
+	RaidModeChannels      pq.Int64Array `gorm:"type:bigint[]" valid:"channel,true"`
+	RaidModeCooldown      int           `valid:"0,1440"` // in minutes, 0 to only lift it manually
+
+	// Mass actions (MassMute, VoiceAction) additionally require one of these roles, or manage server if set
+	MassActionCmdRoles            pq.Int64Array `gorm:"type:bigint[]" valid:"role,true"`
+	MassActionRequireAllRoles     bool
+	MassActionRequireManageServer bool
+
+	// Undo timed mutes and bans if their expiry couldn't be scheduled, instead of retrying the scheduling later
+	RevertUnscheduled bool
+
+	// Bans also end the user's mute, and bans and unbans done outside of the bot cancel the scheduled unban
+	SupersedeScheduledActions bool
+
+	// Bans through the bot are shared with the servers using the same key, and theirs applied here if BanSyncReceive is on
+	BanSyncGroup   string `valid:",100"`
+	BanSyncReceive bool
+
+	// Ban and Mute can also act on the user's known linked accounts with -alts, see AltLookupFuncs
+	AltActionsEnabled bool
+
+	BanEvasionDetection bool
+
+	// Given when a mute or ban expires, e.g a probation role
+	PostMuteRole     string `valid:"role,true"`
+	PostBanRole      string `valid:"role,true"`
+	PostRoleOnManual bool   // also give them when a moderator removes the mute or ban
+
+	// Sent in reply to DMs from users that are muted or banned on this server
+	DMAutoResponse string `valid:",2000"`
+
+	// Used by the clean command when no max age is given, in minutes, 0 for no limit
+	DefaultCleanMaxAge int `valid:"0,20160"`
+
+	// Cleans have to be given a reason, cleans with a reason are logged in the modlog
+	CleanRequireReason bool
+
+	// How long the clean command waits before deleting, in milliseconds
+	CleanDelay sql.NullInt64 `gorm:"default:1000"`
+
+	// Periodically cleans a channel
+	AutoCleanChannel    string `valid:"channel,true"`
+	AutoCleanInterval   int    `valid:"0,10080"` // in minutes
+	AutoCleanMinAge     int    `valid:"0,10080"` // in minutes
+	AutoCleanKeepPinned bool
+
+	// Command schedule
+	ScheduledCmds       string
+	CmdScheduleStart    int `valid:"0,23"`
+	CmdScheduleEnd      int `valid:"0,23"`
+	CmdScheduleTimezone string
+
+	// Shown instead of the reason when it was left out
+	NoReasonPlaceholder string `valid:",100"`
+	FlagMissingReasons  bool
+
+	// Reasons longer than this are rejected, or cut off if TruncateLongReasons is set. 0 for no limit
+	MaxReasonLength     int `valid:"0,1500"`
+	TruncateLongReasons bool
+
+	// Use a reason referencing the user's previous warnings, mutes and bans when none is given, e.g "3rd offense - see #12, mute on 2021-03-04"
+	SuggestHistoryReason bool
+
+	// Link to a external ticket system, {{.TicketID}} is replaced with the id from ticket:id in reasons
+	TicketURLTemplate string `valid:",500"`
 
-	GiveRoleCmdEnabled bool
-	GiveRoleCmdModlog  bool
-	GiveRoleCmdRoles   pq.Int64Array `gorm:"type:bigint[]" valid:"role,true"`
+	// Replaces the built in layout of modlog entries with lines setting the title, description and fields,
+	// see parseModlogTemplate for the format and ModlogTemplateData for the available fields
+	ModlogTemplate string `valid:",2000"`
+
+	// Modlog entries are also sent to this webhook, e.g in a central audit server
+	AuditWebhook string `valid:",300"`
+
+	// Numbered server rules, one per line, rule:N in reasons is expanded to the text of rule N
+	Rules string `valid:",5000"`
+
+	GiveRoleCmdEnabled      bool
+	GiveRoleCmdModlog       bool
+	GiveRoleCmdRoles        pq.Int64Array `gorm:"type:bigint[]" valid:"role,true"`
+	GiveRoleRequireAllRoles bool
 }
 
 func (c *Config) IntMuteRole() (r int64) {
@@ -72,11 +244,221 @@ func (c *Config) IntActionChannel() (r int64) {
 	return
 }
 
+func (c *Config) IntPrivateAuditChannel() (r int64) {
+	r, _ = strconv.ParseInt(c.PrivateAuditChannel, 10, 64)
+	return
+}
+
 func (c *Config) IntReportChannel() (r int64) {
 	r, _ = strconv.ParseInt(c.ReportChannel, 10, 64)
 	return
 }
 
+func (c *Config) IntReportMentionRole() (r int64) {
+	r, _ = strconv.ParseInt(c.ReportMentionRole, 10, 64)
+	return
+}
+
+// MaxCleanDelay is the longest the clean command can be configured to wait, in milliseconds
+const MaxCleanDelay = 10000
+
+// DefaultCleanDelay is used for configs saved before the clean delay was configurable
+const DefaultCleanDelay = time.Second
+
+// CleanDelayDuration returns how long the clean command waits before it starts deleting messages.
+// The delay is there because the discord client tends to glitch out and keep showing the clean command,
+// or messages sent right before it, when they are deleted at the same moment they're posted.
+func (c *Config) CleanDelayDuration() time.Duration {
+	if !c.CleanDelay.Valid {
+		return DefaultCleanDelay
+	}
+
+	return time.Duration(c.CleanDelay.Int64) * time.Millisecond
+}
+
+// MaxBanUndoWindow is the longest bans can be configured to stay undoable, in minutes
+const MaxBanUndoWindow = 60
+
+// DefaultBanUndoWindow is used for configs saved before the undo window was configurable
+const DefaultBanUndoWindow = time.Minute * 5
+
+// BanUndoWindowDuration returns how long the moderator that made a ban can undo it, 0 if bans can't be undone
+func (c *Config) BanUndoWindowDuration() time.Duration {
+	if !c.BanUndoWindow.Valid {
+		return DefaultBanUndoWindow
+	}
+
+	return time.Duration(c.BanUndoWindow.Int64) * time.Minute
+}
+
+func (c *Config) IntAutoCleanChannel() (r int64) {
+	r, _ = strconv.ParseInt(c.AutoCleanChannel, 10, 64)
+	return
+}
+
+func (c *Config) IntConfirmBanRole() (r int64) {
+	r, _ = strconv.ParseInt(c.ConfirmBanRole, 10, 64)
+	return
+}
+
+func (c *Config) IntPostMuteRole() (r int64) {
+	r, _ = strconv.ParseInt(c.PostMuteRole, 10, 64)
+	return
+}
+
+func (c *Config) IntPostBanRole() (r int64) {
+	r, _ = strconv.ParseInt(c.PostBanRole, 10, 64)
+	return
+}
+
+// DefaultNoReasonPlaceholder is used when no placeholder for missing reasons is configured
+const DefaultNoReasonPlaceholder = "(No reason specified)"
+
+// NoReasonText returns the text shown in place of a reason that was left out
+func (c *Config) NoReasonText() string {
+	if strings.TrimSpace(c.NoReasonPlaceholder) == "" {
+		return DefaultNoReasonPlaceholder
+	}
+
+	return c.NoReasonPlaceholder
+}
+
+// ReasonOptional returns true if the reason can be left out of a command with the optional setting, the server
+// can require one for every action
+func (c *Config) ReasonOptional(cmdOptional bool) bool {
+	return cmdOptional && !c.GlobalReasonRequired
+}
+
+var _ web.CustomValidator = (*Config)(nil)
+
+func (c *Config) Validate(tmpl web.TemplateData) (ok bool) {
+	if _, err := time.LoadLocation(c.CmdScheduleTimezone); err != nil {
+		tmpl.AddAlerts(web.ErrorAlert("Unknown command schedule timezone, use a name like Europe/Oslo or America/New_York"))
+		return false
+	}
+
+	c.compactMuteTiers()
+	if len(c.MuteTierNames) > MaxMuteTiers {
+		tmpl.AddAlerts(web.ErrorAlert("Too many mute tiers, max ", MaxMuteTiers))
+		return false
+	}
+
+	for i, name := range c.MuteTierNames {
+		for _, other := range c.MuteTierNames[:i] {
+			if strings.EqualFold(name, other) {
+				tmpl.AddAlerts(web.ErrorAlert("Duplicate mute tier name: ", name))
+				return false
+			}
+		}
+	}
+
+	if c.MuteEscalationEnabled {
+		if _, err := c.MuteEscalationDurations(); err != nil {
+			tmpl.AddAlerts(web.ErrorAlert("Invalid mute escalation steps: ", err.Error()))
+			return false
+		}
+	}
+
+	if c.CleanDelay.Int64 < 0 || c.CleanDelay.Int64 > MaxCleanDelay {
+		tmpl.AddAlerts(web.ErrorAlert("The clean delay has to be between 0 and ", MaxCleanDelay, " milliseconds"))
+		return false
+	}
+
+	if c.BanUndoWindow.Int64 < 0 || c.BanUndoWindow.Int64 > MaxBanUndoWindow {
+		tmpl.AddAlerts(web.ErrorAlert("The time to undo a ban has to be between 0 and ", MaxBanUndoWindow, " minutes"))
+		return false
+	}
+
+	if c.AutoCleanInterval > 0 && c.AutoCleanInterval < 10 {
+		tmpl.AddAlerts(web.ErrorAlert("The auto clean interval has to be at least 10 minutes"))
+		return false
+	}
+
+	categories := c.WarnCategoryList()
+	if len(categories) > MaxWarnCategories {
+		tmpl.AddAlerts(web.ErrorAlert("Too many warning categories, max is ", MaxWarnCategories))
+		return false
+	}
+
+	for i, name := range categories {
+		for _, other := range categories[:i] {
+			if strings.EqualFold(name, other) {
+				tmpl.AddAlerts(web.ErrorAlert("Duplicate warning category: ", name))
+				return false
+			}
+		}
+	}
+
+	if c.RaidModeEnabled {
+		if c.RaidModeJoinThreshold < 2 || c.RaidModeWindow < 1 {
+			tmpl.AddAlerts(web.ErrorAlert("Raid mode needs a join threshold of at least 2 and a window of at least 1 second"))
+			return false
+		}
+
+		if len(c.RaidModeChannels) < 1 {
+			tmpl.AddAlerts(web.ErrorAlert("Raid mode needs at least one channel to lock down"))
+			return false
+		}
+	}
+
+	if !common.ContainsStringSlice(modlogAuthorDisplays, c.ModlogAuthorDisplay) {
+		tmpl.AddAlerts(web.ErrorAlert("Unknown modlog moderator display"))
+		return false
+	}
+
+	if !common.ContainsStringSlice(leftBansModes, c.LeftBansModlog) {
+		tmpl.AddAlerts(web.ErrorAlert("Unknown option for bans of users that left"))
+		return false
+	}
+
+	if c.RequireModlog && c.IntActionChannel() == 0 {
+		tmpl.AddAlerts(web.ErrorAlert("A modlog channel is required, either set one or turn off requiring it"))
+		return false
+	}
+
+	if c.WarnReasonTemplate != "" {
+		if err := validateWarnReasonTemplate(c.WarnReasonTemplate); err != nil {
+			tmpl.AddAlerts(web.ErrorAlert("Invalid warning reason template: ", err.Error()))
+			return false
+		}
+	}
+
+	if c.AuditWebhook != "" {
+		if _, _, ok := parseAuditWebhook(c.AuditWebhook); !ok {
+			tmpl.AddAlerts(web.ErrorAlert("Invalid audit webhook, it has to be a discord webhook url"))
+			return false
+		}
+	}
+
+	if c.BanSyncGroup != "" && len(c.BanSyncGroup) < MinBanSyncGroupLength {
+		tmpl.AddAlerts(web.ErrorAlert(fmt.Sprintf("The ban sync group key has to be at least %d characters, anyone knowing it can join the group", MinBanSyncGroupLength)))
+		return false
+	}
+
+	if c.CommandAliases != "" {
+		if err := validateCommandAliases(c.CommandAliases); err != nil {
+			tmpl.AddAlerts(web.ErrorAlert("Invalid command aliases: ", err.Error()))
+			return false
+		}
+	}
+
+	if c.TicketURLTemplate != "" {
+		if _, err := template.New("").Parse(c.TicketURLTemplate); err != nil {
+			tmpl.AddAlerts(web.ErrorAlert("Invalid ticket URL template: ", err.Error()))
+			return false
+		}
+	}
+
+	if c.ModlogTemplate != "" {
+		if err := validateModlogTemplate(c.ModlogTemplate); err != nil {
+			tmpl.AddAlerts(web.ErrorAlert("Invalid modlog template: ", err.Error()))
+			return false
+		}
+	}
+
+	return true
+}
+
 func (c *Config) GetName() string {
 	return "moderation"
 }
@@ -107,6 +489,12 @@ type WarningModel struct {
 
 	Message  string
 	LogsLink string
+
+	// Link to the message the warning was given in reply to, if any
+	MessageLink string
+
+	// One of the configured warning categories, empty if uncategorized
+	Category string `gorm:"index;default:''"`
 }
 
 func (w *WarningModel) TableName() string {
@@ -125,8 +513,41 @@ type MuteModel struct {
 	Reason   string
 
 	RemovedRoles pq.Int64Array `gorm:"type:bigint[]"`
+
+	// The mute tier role the user was muted with, 0 for the default mute role
+	MuteRole int64 `gorm:"default:0"`
 }
 
 func (m *MuteModel) TableName() string {
 	return "muted_users"
 }
+
+type BanModel struct {
+	common.SmallModel
+
+	GuildID int64 `gorm:"index"`
+	UserID  int64
+
+	Username   string
+	AvatarHash string
+}
+
+func (b *BanModel) TableName() string {
+	return "moderation_bans"
+}
+
+// PendingExpiryModel is the expiry of a timed mute or ban that couldn't be scheduled,
+// the reconciler keeps trying to schedule it until it succeeds
+type PendingExpiryModel struct {
+	common.SmallModel
+
+	GuildID   int64 `gorm:"index"`
+	UserID    int64
+	EventName string
+
+	ExpiresAt time.Time
+}
+
+func (p *PendingExpiryModel) TableName() string {
+	return "moderation_pending_expiries"
+}
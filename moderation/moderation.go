@@ -1,9 +1,13 @@
 package moderation
 
 import (
+	"sync"
+	"time"
+
 	"github.com/jonas747/discordgo"
 	"github.com/jonas747/yagpdb/common"
 	"github.com/jonas747/yagpdb/common/configstore"
+	"github.com/mediocregopher/radix/v3"
 	"golang.org/x/net/context"
 )
 
@@ -18,7 +22,9 @@ const (
 
 var logger = common.GetPluginLogger(&Plugin{})
 
-type Plugin struct{}
+type Plugin struct {
+	stopWorkers chan *sync.WaitGroup
+}
 
 func (p *Plugin) PluginInfo() *common.PluginInfo {
 	return &common.PluginInfo{
@@ -40,17 +46,84 @@ func RedisKeyUnbannedUser(guildID, userID int64) string {
 	return "moderation_unbanned_user:" + discordgo.StrID(guildID) + ":" + discordgo.StrID(userID)
 }
 
+// RedisKeyBanLogged marks that the bot created a modlog entry for banning the user recently
+func RedisKeyBanLogged(guildID, userID int64) string {
+	return "moderation_ban_logged:" + discordgo.StrID(guildID) + ":" + discordgo.StrID(userID)
+}
+
+// RedisKeyBanUndone marks that the user is being unbanned because their ban was undone, the unban isn't logged
+func RedisKeyBanUndone(guildID, userID int64) string {
+	return "moderation_ban_undone:" + discordgo.StrID(guildID) + ":" + discordgo.StrID(userID)
+}
+
+// RedisKeyLastBan holds the last ban the moderator made through the bot while it can still be undone
+func RedisKeyLastBan(guildID, moderatorID int64) string {
+	return "moderation_last_ban:" + discordgo.StrID(guildID) + ":" + discordgo.StrID(moderatorID)
+}
+
+// RedisKeySyncedBan holds a ban synced from the source server, so it can be undone when the original ban is
+func RedisKeySyncedBan(guildID, sourceGuildID, userID int64) string {
+	return "moderation_synced_ban:" + discordgo.StrID(guildID) + ":" + discordgo.StrID(sourceGuildID) + ":" + discordgo.StrID(userID)
+}
+
+// How long the ban/unban markers live for, they're normally consumed by the ban event handler well before that
+// but the TTL makes sure a marker left over from an event that never arrived can't suppress a later modlog entry
+const (
+	BanMarkerTTL   = time.Minute
+	UnbanMarkerTTL = time.Second * 30
+
+	// Unlike the ban marker this one isn't consumed, any ban event for the user within this window is treated as a duplicate
+	BanLoggedWindow = time.Minute * 5
+)
+
+// setMarker sets a marker key that expires after ttl
+func setMarker(key string, ttl time.Duration) {
+	err := common.RedisPool.Do(radix.FlatCmd(nil, "SET", key, 1, "PX", int64(ttl/time.Millisecond)))
+	if err != nil {
+		logger.WithError(err).WithField("key", key).Error("failed setting marker")
+	}
+}
+
+// consumeMarker returns true if the marker was set, deleting it in the process
+func consumeMarker(key string) bool {
+	var deleted int
+	err := common.RedisPool.Do(radix.Cmd(&deleted, "DEL", key))
+	if err != nil {
+		logger.WithError(err).WithField("key", key).Error("failed consuming marker")
+		return false
+	}
+
+	return deleted > 0
+}
+
+// markerSet returns true if the marker is set, without consuming it
+func markerSet(key string) bool {
+	var exists int
+	err := common.RedisPool.Do(radix.Cmd(&exists, "EXISTS", key))
+	if err != nil {
+		logger.WithError(err).WithField("key", key).Error("failed checking marker")
+		return false
+	}
+
+	return exists > 0
+}
+
 func RedisKeyLockedMute(guildID, userID int64) string {
 	return "moderation_updating_mute:" + discordgo.StrID(guildID) + ":" + discordgo.StrID(userID)
 }
 
 func RegisterPlugin() {
-	plugin := &Plugin{}
+	plugin := &Plugin{
+		stopWorkers: make(chan *sync.WaitGroup),
+	}
 
 	common.RegisterPlugin(plugin)
 
 	configstore.RegisterConfig(configstore.SQL, &Config{})
-	common.GORM.AutoMigrate(&Config{}, &WarningModel{}, &MuteModel{})
+	common.GORM.AutoMigrate(&Config{}, &WarningModel{}, &MuteModel{}, &BanModel{}, &TrustLevelModel{}, &ReportModel{}, &PendingExpiryModel{}, &LoggedActionModel{}, &MuteHistoryModel{}, &ShadowMuteModel{}, &TemplatedModlogEntryModel{})
+
+	// the warnings of a user are looked up and counted by guild and user together
+	common.GORM.Model(&WarningModel{}).AddIndex("idx_moderation_warnings_guild_user", "guild_id", "user_id")
 }
 
 func getConfigIfNotSet(guildID int64, config *Config) (*Config, error) {
@@ -0,0 +1,83 @@
+package moderation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jonas747/yagpdb/common"
+	"github.com/mediocregopher/radix/v3"
+)
+
+func TestMarkerExpires(t *testing.T) {
+	if common.RedisPool == nil {
+		t.Skip("redis not available, skipping.")
+		return
+	}
+
+	key := RedisKeyBannedUser(1, 2)
+	setMarker(key, time.Second)
+
+	var ttl int64
+	err := common.RedisPool.Do(radix.Cmd(&ttl, "PTTL", key))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ttl <= 0 || ttl > 1000 {
+		t.Errorf("unexpected marker ttl: %d", ttl)
+	}
+
+	time.Sleep(time.Millisecond * 1500)
+
+	if consumeMarker(key) {
+		t.Error("expired marker suppressed a later event")
+	}
+}
+
+func TestMarkerConsumedOnce(t *testing.T) {
+	if common.RedisPool == nil {
+		t.Skip("redis not available, skipping.")
+		return
+	}
+
+	key := RedisKeyUnbannedUser(1, 2)
+	setMarker(key, UnbanMarkerTTL)
+
+	if !consumeMarker(key) {
+		t.Error("marker was not consumed")
+	}
+
+	if consumeMarker(key) {
+		t.Error("marker was consumed twice")
+	}
+}
+
+func TestBanEventRaceSuppressed(t *testing.T) {
+	if common.RedisPool == nil {
+		t.Skip("redis not available, skipping.")
+		return
+	}
+
+	common.RedisPool.Do(radix.Cmd(nil, "DEL", RedisKeyBannedUser(1, 3), RedisKeyBanLogged(1, 3), RedisKeyBanLogged(1, 4)))
+
+	// what BanUserWithDuration does before issuing the ban
+	setMarker(RedisKeyBannedUser(1, 3), BanMarkerTTL)
+	setMarker(RedisKeyBanLogged(1, 3), BanLoggedWindow)
+
+	if !botBanned(1, 3) {
+		t.Error("first ban event was not suppressed")
+	}
+
+	// the ban marker is gone now, simulating it having expired or the event being delivered twice
+	if consumeMarker(RedisKeyBannedUser(1, 3)) {
+		t.Fatal("ban marker was not consumed")
+	}
+
+	if !botBanned(1, 3) {
+		t.Error("second ban event for the same user created a duplicate entry")
+	}
+
+	if botBanned(1, 4) {
+		t.Error("ban of a different user was suppressed")
+	}
+}
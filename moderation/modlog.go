@@ -1,12 +1,21 @@
 package moderation
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"net/url"
 	"regexp"
+	"strconv"
 	"strings"
+	"text/template"
 
 	"github.com/jonas747/discordgo"
+	"github.com/jonas747/yagpdb/bot"
+	"github.com/jonas747/yagpdb/commands"
 	"github.com/jonas747/yagpdb/common"
+	"github.com/jonas747/yagpdb/common/configstore"
+	"github.com/sirupsen/logrus"
 )
 
 type ModlogAction struct {
@@ -33,15 +42,30 @@ var (
 	MABanned     = ModlogAction{Prefix: "Banned", Emoji: "🔨", Color: 0xd64848}
 	MAUnbanned   = ModlogAction{Prefix: "Unbanned", Emoji: "🔓", Color: 0x62c65f}
 	MAWarned     = ModlogAction{Prefix: "Warned", Emoji: "⚠", Color: 0xfca253}
+	MACleaned    = ModlogAction{Prefix: "Cleaned", Emoji: "🧹", Color: 0x99aab5}
 	MAGiveRole   = ModlogAction{Prefix: "", Emoji: "➕", Color: 0x53fcf9}
 	MARemoveRole = ModlogAction{Prefix: "", Emoji: "➖", Color: 0x53fcf9}
+
+	MATimeoutAdded   = ModlogAction{Prefix: "Timed out", Emoji: "⏱", Color: 0x9b59b6}
+	MATimeoutRemoved = ModlogAction{Prefix: "Timeout removed from", Emoji: "⏱", Color: 0x62c65f}
+
+	MAWarningEdited = ModlogAction{Prefix: "Edited warning of", Emoji: "✏", Color: 0xfca253}
 )
 
 func CreateModlogEmbed(config *Config, author *discordgo.User, action ModlogAction, target *discordgo.User, reason, logLink string) error {
+	_, err := sendModlogEmbed(config, author, action, target, reason, logLink)
+	return err
+}
+
+// sendModlogEmbed is the same as CreateModlogEmbed but also returns the entry that was posted,
+// nil if both the modlog and the private audit channel are disabled
+func sendModlogEmbed(config *Config, author *discordgo.User, action ModlogAction, target *discordgo.User, reason, logLink string) (*modlogEntry, error) {
+	logModAction(config, author, action, target, reason, logLink)
+	publishModAction(config.GetGuildID(), author, action, target, reason)
+
 	channelID := config.IntActionChannel()
-	config.GetGuildID()
-	if channelID == 0 {
-		return nil
+	if config.SuppressMemberEntries || (channelID == 0 && config.IntPrivateAuditChannel() == 0) {
+		return nil, nil
 	}
 
 	emptyAuthor := false
@@ -54,26 +78,20 @@ func CreateModlogEmbed(config *Config, author *discordgo.User, action ModlogActi
 		}
 	}
 
-	if reason == "" {
-		reason = "(no reason specified)"
-	}
+	reason = config.modlogReason(reason)
+
+	reason = ResolveReasonMentions(config.GetGuildID(), reason)
+	reason = LinkReasonTickets(config, reason)
 
 	embed := &discordgo.MessageEmbed{
-		Author: &discordgo.MessageEmbedAuthor{
-			Name:    fmt.Sprintf("%s#%s (ID %d)", author.Username, author.Discriminator, author.ID),
-			IconURL: discordgo.EndpointUserAvatar(author.ID, author.Avatar),
-		},
 		Thumbnail: &discordgo.MessageEmbedThumbnail{
 			URL: discordgo.EndpointUserAvatar(target.ID, target.Avatar),
 		},
 		Color: action.Color,
-		Description: fmt.Sprintf("**%s%s %s**#%s *(ID %d)*\n📄**Reason:** %s",
-			action.Emoji, action.Prefix, target.Username, target.Discriminator, target.ID, reason),
 	}
 
-	if logLink != "" {
-		embed.Description += " ([Logs](" + logLink + "))"
-	}
+	templated := renderModlogEmbed(config, embed, author, action, target, reason, 0)
+	setModlogEmbedAuthor(config, author, embed)
 
 	if action.Footer != "" {
 		embed.Footer = &discordgo.MessageEmbedFooter{
@@ -81,30 +99,407 @@ func CreateModlogEmbed(config *Config, author *discordgo.User, action ModlogActi
 		}
 	}
 
-	m, err := common.BotSession.ChannelMessageSendEmbed(channelID, embed)
+	entry := &modlogEntry{}
+	if config.IntPrivateAuditChannel() != 0 {
+		audit := auditEmbedVariant(author, embed)
+		if templated {
+			renderModlogEmbed(fullAuthorConfig(config), audit, author, action, target, reason, 0)
+		}
+		if logLink != "" {
+			audit.Description += " ([Logs](" + logLink + "))"
+		}
+
+		m, err := sendPrivateAudit(config, audit)
+		if err != nil {
+			logger.WithError(err).WithField("guild", config.GetGuildID()).Error("failed posting in the private audit channel")
+		} else if m != nil {
+			entry.AuditMessage, entry.AuditEmbed = m, audit
+		}
+	}
+
+	// the logs are kept out of the modlog if they made it to the private audit channel
+	if logLink != "" && entry.AuditMessage == nil {
+		embed.Description += " ([Logs](" + logLink + "))"
+	}
+
+	if channelID == 0 {
+		if entry.AuditEmbed != nil {
+			mirrorModlogEmbed(config, entry.AuditEmbed)
+		}
+		return entry, nil
+	}
+
+	m, err := session().ChannelMessageSendEmbed(channelID, embed)
 	if err != nil {
 		if common.IsDiscordErr(err, discordgo.ErrCodeMissingAccess, discordgo.ErrCodeMissingPermissions, discordgo.ErrCodeUnknownChannel) {
-			// disable the modlog
-			config.ActionChannel = ""
-			config.Save(config.GetGuildID())
-			return nil
+			// don't lose the record, hand it to the moderator instead
+			if !emptyAuthor {
+				go modlogFallbackDM(config.GetGuildID(), author, embed)
+			}
+
+			go disableModlogChannel(config.GetGuildID(), channelID, func(c *Config) *string { return &c.ActionChannel })
+			return entry, nil
 		}
-		return err
+		return entry, err
+	}
+	entry.Message, entry.Embed = m, embed
+
+	if templated {
+		err = finishTemplatedModlogEntry(config, author, action, target, reason, logLink, emptyAuthor, entry)
+	} else if emptyAuthor {
+		updateEmbedReason(config, nil, emptyAuthorPlaceholder(m.ID), embed)
+		_, err = session().ChannelMessageEditEmbed(channelID, m.ID, embed)
+	}
+
+	if entry.AuditEmbed != nil {
+		mirrorModlogEmbed(config, entry.AuditEmbed)
+	} else {
+		mirrorModlogEmbed(config, embed)
+	}
+	return entry, err
+}
+
+// emptyAuthorPlaceholder is shown as the reason of entries for actions where the moderator couldn't be found
+func emptyAuthorPlaceholder(messageID int64) string {
+	return fmt.Sprintf("Asssign an author and reason to this using **'reason %d your-reason-here`**", messageID)
+}
+
+// modlogFallbackDM sends a modlog entry that couldn't be posted to the moderator that performed the action,
+// letting them know the modlog channel needs fixing
+func modlogFallbackDM(guildID int64, author *discordgo.User, embed *discordgo.MessageEmbed) {
+	if author.Bot {
+		return
+	}
+
+	msg := fmt.Sprintf("**%s:** I couldn't post the following in the modlog channel as I'm missing permissions there, or it was deleted. "+
+		"The modlog has been disabled, fix the permissions and set the modlog channel again in the control panel.", bot.GuildName(guildID))
+	err := bot.SendDM(author.ID, msg)
+	if err == nil {
+		err = bot.SendDMEmbed(author.ID, embed)
+	}
+
+	if err != nil {
+		logger.WithError(err).WithField("guild", guildID).Debug("failed sending modlog fallback dm")
+	}
+}
+
+// disableModlogChannel clears a modlog channel setting that isn't usable anymore. This is done on the stored config
+// as the one the entry was sent with can be a modified copy, and saving it would write everything else back as well.
+// Nothing is changed if the setting was pointed elsewhere in the meantime.
+func disableModlogChannel(guildID, channelID int64, setting func(c *Config) *string) {
+	var stored Config
+	err := configstore.SQL.GetGuildConfig(context.Background(), guildID, &stored)
+	if err != nil {
+		logger.WithError(err).WithField("guild", guildID).Error("failed loading config to disable a modlog channel")
+		return
+	}
+
+	field := setting(&stored)
+	if *field != discordgo.StrID(channelID) {
+		return
+	}
+
+	*field = ""
+	err = stored.Save(guildID)
+	if err != nil {
+		logger.WithError(err).WithField("guild", guildID).Error("failed disabling modlog channel")
 	}
+}
 
-	if emptyAuthor {
-		placeholder := fmt.Sprintf("Asssign an author and reason to this using **'reason %d your-reason-here`**", m.ID)
-		updateEmbedReason(nil, placeholder, embed)
-		_, err = common.BotSession.ChannelMessageEditEmbed(channelID, m.ID, embed)
+// logModAction emits a structured log line for the action if enabled, meant for servers that feed the logs into external systems
+func logModAction(config *Config, author *discordgo.User, action ModlogAction, target *discordgo.User, reason, logLink string) {
+	if !config.StructuredActionLogs {
+		return
+	}
+
+	fields := logrus.Fields{
+		"guild":  config.GetGuildID(),
+		"action": strings.TrimSpace(action.Emoji + action.Prefix),
+		"reason": reason,
+	}
+
+	if action.Footer != "" {
+		fields["details"] = action.Footer
+	}
+
+	if author != nil {
+		fields["author_id"] = author.ID
+		fields["author"] = author.Username + "#" + author.Discriminator
+	}
+
+	if target != nil {
+		fields["target_id"] = target.ID
+		fields["target"] = target.Username + "#" + target.Discriminator
+	}
+
+	if logLink != "" {
+		fields["logs"] = logLink
+	}
+
+	logger.WithFields(fields).Info("moderation action")
+}
+
+// attachModlogLogs waits for the log link and adds it to an already sent modlog entry
+func attachModlogLogs(entry *modlogEntry, logLinkC <-chan string) {
+	logLink := <-logLinkC
+	m, embed := entry.logsTarget()
+	if m == nil || embed == nil || logLink == "" {
+		return
+	}
+
+	embed.Description += " ([Logs](" + logLink + "))"
+	_, err := session().ChannelMessageEditEmbed(m.ChannelID, m.ID, embed)
+	if err != nil {
+		logger.WithError(err).WithField("channel", m.ChannelID).Error("failed adding logs to modlog entry")
+	}
+
+	// keep the logs when the templated entry is rendered again
+	if entry.Templated && m == entry.Message {
+		err = common.GORM.Model(&TemplatedModlogEntryModel{}).Where("message_id = ?", m.ID).Update("log_link", logLink).Error
+		common.LogIgnoreError(err, "[moderation] failed storing the logs of a templated modlog entry", nil)
+	}
+}
+
+// CreateMassModlogEmbed creates a single modlog entry for an action that was applied to many users at once
+func CreateMassModlogEmbed(config *Config, author *discordgo.User, action ModlogAction, summary string, reason string) error {
+	logged := action
+	logged.Footer = summary
+	logModAction(config, author, logged, nil, reason, "")
+	publishModAction(config.GetGuildID(), author, logged, nil, reason)
+
+	channelID := config.IntActionChannel()
+	if channelID == 0 && config.IntPrivateAuditChannel() == 0 {
+		return nil
+	}
+
+	reason = config.modlogReason(reason)
+
+	reason = ResolveReasonMentions(config.GetGuildID(), reason)
+	reason = LinkReasonTickets(config, reason)
+
+	embed := &discordgo.MessageEmbed{
+		Author: &discordgo.MessageEmbedAuthor{
+			Name:    fmt.Sprintf("%s#%s (ID %d)", author.Username, author.Discriminator, author.ID),
+			IconURL: discordgo.EndpointUserAvatar(author.ID, author.Avatar),
+		},
+		Color:       action.Color,
+		Description: fmt.Sprintf("**%s%s**\n📄**Reason:** %s", action.Emoji, summary, reason),
+	}
+
+	if action.Footer != "" {
+		embed.Footer = &discordgo.MessageEmbedFooter{
+			Text: action.Footer,
+		}
+	}
+
+	full := embed
+	if config.IntPrivateAuditChannel() != 0 {
+		m, err := sendPrivateAudit(config, full)
+		if err != nil {
+			logger.WithError(err).WithField("guild", config.GetGuildID()).Error("failed posting in the private audit channel")
+		}
+
+		if m != nil {
+			// the modlog shows the moderator as configured
+			public := *full
+			setModlogEmbedAuthor(config, author, &public)
+			embed = &public
+		}
+	}
+
+	if channelID == 0 {
+		mirrorModlogEmbed(config, full)
+		return nil
+	}
+
+	_, err := session().ChannelMessageSendEmbed(channelID, embed)
+	if err == nil {
+		mirrorModlogEmbed(config, full)
 	}
 	return err
 }
 
 var (
 	logsRegex = regexp.MustCompile(`\(\[Logs\]\(.*\)\)`)
+
+	reasonMentionRegex = regexp.MustCompile(`<(@!?|@&|#)(\d+)>`)
+
+	reasonTicketRegex = regexp.MustCompile(`\bticket:([\w-]+)`)
+
+	reasonRuleRegex = regexp.MustCompile(`\brule:(\d+)\b`)
 )
 
-func updateEmbedReason(author *discordgo.User, reason string, embed *discordgo.MessageEmbed) {
+// modlogReason returns the reason to show in the modlog, substituting the placeholder if it was left out
+// and marking it if missing reasons should be flagged
+func (c *Config) modlogReason(reason string) string {
+	if strings.TrimSpace(reason) == "" {
+		reason = c.NoReasonText()
+	}
+
+	if c.FlagMissingReasons && reason == c.NoReasonText() {
+		reason = "⚠ " + reason
+	}
+
+	return reason
+}
+
+// limitReasonLength enforces the server's max reason length, cutting the reason off or returning a user error depending on the config.
+// This is only to keep modlog entries readable, the audit log reason is limited separately.
+func limitReasonLength(config *Config, reason string) (string, error) {
+	if config.MaxReasonLength < 1 {
+		return reason, nil
+	}
+
+	runes := []rune(reason)
+	if len(runes) <= config.MaxReasonLength {
+		return reason, nil
+	}
+
+	if config.TruncateLongReasons {
+		return string(runes[:config.MaxReasonLength-1]) + "…", nil
+	}
+
+	return reason, commands.NewUserErrorf("The reason is too long (%d characters), the max on this server is %d.", len(runes), config.MaxReasonLength)
+}
+
+var reasonEscaper = strings.NewReplacer("@everyone", "@\u200beveryone", "@here", "@\u200bhere", "`", "\\`")
+
+// SanitizeReason escapes @everyone, @here and backticks in user provided reasons,
+// so they can't ping anyone or break the formatting of whatever they're shown in
+func SanitizeReason(reason string) string {
+	return reasonEscaper.Replace(reason)
+}
+
+// ResolveReasonMentions replaces user, role and channel mentions in the reason with their names,
+// so that it's readable for people without access to them. The reason is also sanitized with SanitizeReason.
+func ResolveReasonMentions(guildID int64, reason string) string {
+	reason = SanitizeReason(reason)
+
+	gs := bot.State.Guild(true, guildID)
+	if gs == nil {
+		return reason
+	}
+
+	return reasonMentionRegex.ReplaceAllStringFunc(reason, func(mention string) string {
+		submatches := reasonMentionRegex.FindStringSubmatch(mention)
+		id, _ := strconv.ParseInt(submatches[2], 10, 64)
+
+		switch submatches[1] {
+		case "@&":
+			if r := gs.RoleCopy(true, id); r != nil {
+				return "@\u200b" + r.Name
+			}
+		case "#":
+			if cs := gs.Channel(true, id); cs != nil {
+				return "#" + cs.Name
+			}
+		default:
+			if ms := gs.MemberCopy(true, id); ms != nil && ms.MemberSet {
+				return fmt.Sprintf("@\u200b%s#%04d", ms.Username, ms.Discriminator)
+			}
+		}
+
+		return mention
+	})
+}
+
+// RuleList returns the server rules, the first one being rule 1
+func (c *Config) RuleList() []string {
+	var rules []string
+	for _, line := range strings.Split(c.Rules, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" {
+			rules = append(rules, line)
+		}
+	}
+
+	return rules
+}
+
+// ExpandReasonRules replaces references like rule:5 in the reason with the text of the rule,
+// references to rules that don't exist are left in with a note
+func ExpandReasonRules(config *Config, reason string) string {
+	if !strings.Contains(reason, "rule:") {
+		return reason
+	}
+
+	rules := config.RuleList()
+	return reasonRuleRegex.ReplaceAllStringFunc(reason, func(ref string) string {
+		n, _ := strconv.Atoi(reasonRuleRegex.FindStringSubmatch(ref)[1])
+		if n < 1 || n > len(rules) {
+			return ref + " (unknown rule)"
+		}
+
+		return "Rule " + strconv.Itoa(n) + ": " + rules[n-1]
+	})
+}
+
+// LinkReasonTickets turns ticket:id references in the reason into links using the configured ticket url template,
+// references that don't produce a valid url are left as is
+func LinkReasonTickets(config *Config, reason string) string {
+	if config.TicketURLTemplate == "" {
+		return reason
+	}
+
+	tmpl, err := template.New("").Parse(config.TicketURLTemplate)
+	if err != nil {
+		return reason
+	}
+
+	return reasonTicketRegex.ReplaceAllStringFunc(reason, func(ref string) string {
+		ticketID := reasonTicketRegex.FindStringSubmatch(ref)[1]
+
+		var buf bytes.Buffer
+		err := tmpl.Execute(&buf, map[string]interface{}{"TicketID": url.PathEscape(ticketID)})
+		if err != nil {
+			return ref
+		}
+
+		link := strings.TrimSpace(buf.String())
+		parsed, err := url.Parse(link)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return ref
+		}
+
+		return "[" + ref + "](" + link + ")"
+	})
+}
+
+// editModlogReason updates the reason of the modlog entry, showing shownReason in it, and writes the reason through to the stored record behind it
+func editModlogReason(config *Config, author *discordgo.User, msg *discordgo.Message, reason, shownReason string) error {
+	templated, err := findTemplatedModlogEntry(msg.ID)
+	if err != nil {
+		return err
+	}
+
+	embed := msg.Embeds[0]
+	if templated != nil {
+		// the template can't be edited in place, render it again with the new reason
+		templated.Reason = shownReason
+		if author != nil {
+			templated.setAuthor(author)
+		}
+		templated.render(config, embed)
+
+		err = common.GORM.Save(templated).Error
+		if err != nil {
+			return common.ErrWithCaller(err)
+		}
+	} else if strings.Contains(embed.Description, "📄**Reason:**") {
+		updateEmbedReason(config, author, shownReason, embed)
+	} else {
+		return commands.NewUserError("That entry doesn't have a reason that can be edited")
+	}
+
+	_, err = session().ChannelMessageEditEmbed(msg.ChannelID, msg.ID, embed)
+	if err != nil {
+		return err
+	}
+
+	return updateRecordedReason(config.GetGuildID(), msg.ID, reason)
+}
+
+func updateEmbedReason(config *Config, author *discordgo.User, reason string, embed *discordgo.MessageEmbed) {
 	const checkStr = "📄**Reason:**"
 
 	index := strings.Index(embed.Description, checkStr)
@@ -118,9 +513,6 @@ func updateEmbedReason(author *discordgo.User, reason string, embed *discordgo.M
 	embed.Description = withoutReason + " " + reason + logsLink
 
 	if author != nil {
-		embed.Author = &discordgo.MessageEmbedAuthor{
-			Name:    fmt.Sprintf("%s#%s (ID %d)", author.Username, author.Discriminator, author.ID),
-			IconURL: discordgo.EndpointUserAvatar(author.ID, author.Avatar),
-		}
+		setModlogEmbedAuthor(config, author, embed)
 	}
 }
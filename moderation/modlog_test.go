@@ -0,0 +1,130 @@
+package moderation
+
+import "testing"
+
+func TestLinkReasonTickets(t *testing.T) {
+	config := &Config{TicketURLTemplate: "https://tickets.example.com/t/{{.TicketID}}"}
+
+	cases := []struct {
+		reason string
+		want   string
+	}{
+		{"spamming ticket:123", "spamming [ticket:123](https://tickets.example.com/t/123)"},
+		{"ticket:ab-1 and ticket:2", "[ticket:ab-1](https://tickets.example.com/t/ab-1) and [ticket:2](https://tickets.example.com/t/2)"},
+		{"malformed ticket: and ticket:", "malformed ticket: and ticket:"},
+		{"noticket:1", "noticket:1"},
+	}
+
+	for _, c := range cases {
+		if got := LinkReasonTickets(config, c.reason); got != c.want {
+			t.Errorf("LinkReasonTickets(%q): got %q, expected %q", c.reason, got, c.want)
+		}
+	}
+
+	if got := LinkReasonTickets(&Config{}, "ticket:1"); got != "ticket:1" {
+		t.Errorf("linked a ticket without a template: %q", got)
+	}
+
+	bad := &Config{TicketURLTemplate: "javascript:{{.TicketID}}"}
+	if got := LinkReasonTickets(bad, "ticket:1"); got != "ticket:1" {
+		t.Errorf("linked a ticket to a non http url: %q", got)
+	}
+}
+
+func TestExpandReasonRules(t *testing.T) {
+	config := &Config{Rules: "No spamming\n\n  Be nice  \nNo NSFW"}
+
+	cases := []struct {
+		reason string
+		want   string
+	}{
+		{"rule:1", "Rule 1: No spamming"},
+		{"broke rule:2 twice", "broke Rule 2: Be nice twice"},
+		{"rule:3 and rule:1", "Rule 3: No NSFW and Rule 1: No spamming"},
+		{"rule:4", "rule:4 (unknown rule)"},
+		{"rule:0", "rule:0 (unknown rule)"},
+		{"norule:1", "norule:1"},
+		{"rule:abc", "rule:abc"},
+	}
+
+	for _, c := range cases {
+		if got := ExpandReasonRules(config, c.reason); got != c.want {
+			t.Errorf("ExpandReasonRules(%q): got %q, expected %q", c.reason, got, c.want)
+		}
+	}
+}
+
+func TestModlogReason(t *testing.T) {
+	cases := []struct {
+		config *Config
+		reason string
+		want   string
+	}{
+		{&Config{}, "", "(No reason specified)"},
+		{&Config{}, "spam", "spam"},
+		{&Config{NoReasonPlaceholder: "n/a"}, " ", "n/a"},
+		{&Config{FlagMissingReasons: true}, "", "⚠ (No reason specified)"},
+		{&Config{FlagMissingReasons: true}, "(No reason specified)", "⚠ (No reason specified)"},
+		{&Config{FlagMissingReasons: true}, "spam", "spam"},
+	}
+
+	for _, c := range cases {
+		if got := c.config.modlogReason(c.reason); got != c.want {
+			t.Errorf("modlogReason(%q): got %q, expected %q", c.reason, got, c.want)
+		}
+	}
+}
+
+func TestSanitizeReason(t *testing.T) {
+	cases := []struct {
+		reason string
+		want   string
+	}{
+		{"plain reason", "plain reason"},
+		{"@everyone look", "@\u200beveryone look"},
+		{"@here @everyone", "@\u200bhere @\u200beveryone"},
+		{"```\nbreaks the list", "\\`\\`\\`\nbreaks the list"},
+		{"`code` @here", "\\`code\\` @\u200bhere"},
+	}
+
+	for _, c := range cases {
+		if got := SanitizeReason(c.reason); got != c.want {
+			t.Errorf("SanitizeReason(%q): got %q, expected %q", c.reason, got, c.want)
+		}
+	}
+}
+
+func TestFeaturesNeedingModlog(t *testing.T) {
+	config := &Config{LogBans: true, GiveRoleCmdModlog: true}
+
+	features := config.featuresNeedingModlog()
+	if len(features) != 1 || features[0] != "logging bans" {
+		t.Errorf("unexpected features: %q", features)
+	}
+
+	config.ActionChannel = "123"
+	if features := config.featuresNeedingModlog(); len(features) != 0 {
+		t.Errorf("expected no features with a modlog channel set, got %q", features)
+	}
+}
+
+func TestLimitReasonLength(t *testing.T) {
+	reason := "spamming ✉ in general"
+
+	if got, err := limitReasonLength(&Config{}, reason); got != reason || err != nil {
+		t.Errorf("expected no limit by default, got %q, %v", got, err)
+	}
+
+	if got, err := limitReasonLength(&Config{MaxReasonLength: 21}, reason); got != reason || err != nil {
+		t.Errorf("expected reason at the limit to pass, got %q, %v", got, err)
+	}
+
+	if _, err := limitReasonLength(&Config{MaxReasonLength: 10}, reason); err == nil {
+		t.Error("expected an error for a reason over the limit")
+	}
+
+	got, err := limitReasonLength(&Config{MaxReasonLength: 10, TruncateLongReasons: true}, reason)
+	if err != nil || got != "spamming …" {
+		t.Errorf("expected a truncated reason, got %q, %v", got, err)
+	}
+}
@@ -0,0 +1,64 @@
+package moderation
+
+import (
+	"fmt"
+
+	"github.com/jonas747/discordgo"
+	"github.com/jonas747/yagpdb/common"
+)
+
+// How the moderator is shown in modlog entries, servers with a public modlog may not want to show who did what
+const (
+	ModlogAuthorFull    = "" // name, discriminator and ID
+	ModlogAuthorName    = "name"
+	ModlogAuthorMention = "mention"
+	ModlogAuthorID      = "id"
+	ModlogAuthorHidden  = "hidden"
+)
+
+var modlogAuthorDisplays = []string{ModlogAuthorFull, ModlogAuthorName, ModlogAuthorMention, ModlogAuthorID, ModlogAuthorHidden}
+
+const modlogModeratorField = "Moderator"
+
+// setModlogEmbedAuthor shows the author on the modlog entry the way the server has configured.
+// Actions by the bot itself (e.g expired bans) and unknown authors are always shown in full since there's no one to hide.
+func setModlogEmbedAuthor(config *Config, author *discordgo.User, embed *discordgo.MessageEmbed) {
+	// remove a previous mention, the reason command sets the author again
+	fields := embed.Fields[:0]
+	for _, v := range embed.Fields {
+		if v.Name != modlogModeratorField {
+			fields = append(fields, v)
+		}
+	}
+	embed.Fields = fields
+
+	display := config.ModlogAuthorDisplay
+	if author.ID == 0 || (common.BotUser != nil && author.ID == common.BotUser.ID) {
+		display = ModlogAuthorFull
+	}
+
+	embed.Author = nil
+	switch display {
+	case ModlogAuthorName:
+		embed.Author = &discordgo.MessageEmbedAuthor{
+			Name:    fmt.Sprintf("%s#%s", author.Username, author.Discriminator),
+			IconURL: discordgo.EndpointUserAvatar(author.ID, author.Avatar),
+		}
+	case ModlogAuthorMention:
+		// mentions don't work in the author name
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  modlogModeratorField,
+			Value: fmt.Sprintf("<@%d>", author.ID),
+		})
+	case ModlogAuthorID:
+		embed.Author = &discordgo.MessageEmbedAuthor{
+			Name: fmt.Sprintf("Moderator (ID %d)", author.ID),
+		}
+	case ModlogAuthorHidden:
+	default:
+		embed.Author = &discordgo.MessageEmbedAuthor{
+			Name:    fmt.Sprintf("%s#%s (ID %d)", author.Username, author.Discriminator, author.ID),
+			IconURL: discordgo.EndpointUserAvatar(author.ID, author.Avatar),
+		}
+	}
+}
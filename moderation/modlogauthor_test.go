@@ -0,0 +1,50 @@
+package moderation
+
+import (
+	"testing"
+
+	"github.com/jonas747/discordgo"
+)
+
+func TestSetModlogEmbedAuthor(t *testing.T) {
+	author := &discordgo.User{ID: 3, Username: "mod", Discriminator: "0001"}
+
+	cases := []struct {
+		display    string
+		authorName string
+		mention    bool
+	}{
+		{ModlogAuthorFull, "mod#0001 (ID 3)", false},
+		{ModlogAuthorName, "mod#0001", false},
+		{ModlogAuthorID, "Moderator (ID 3)", false},
+		{ModlogAuthorMention, "", true},
+		{ModlogAuthorHidden, "", false},
+	}
+
+	for _, c := range cases {
+		// start from an entry that already has a moderator, like when the reason command updates it
+		embed := &discordgo.MessageEmbed{Fields: []*discordgo.MessageEmbedField{{Name: modlogModeratorField, Value: "<@1>"}}}
+		setModlogEmbedAuthor(&Config{ModlogAuthorDisplay: c.display}, author, embed)
+
+		name := ""
+		if embed.Author != nil {
+			name = embed.Author.Name
+		}
+		if name != c.authorName {
+			t.Errorf("%q: expected author %q, got %q", c.display, c.authorName, name)
+		}
+
+		if c.mention && (len(embed.Fields) != 1 || embed.Fields[0].Value != "<@3>") {
+			t.Errorf("%q: expected a single moderator mention, got %+v", c.display, embed.Fields)
+		} else if !c.mention && len(embed.Fields) != 0 {
+			t.Errorf("%q: expected the old moderator field to be removed, got %+v", c.display, embed.Fields)
+		}
+	}
+
+	// unknown authors are always shown in full
+	embed := &discordgo.MessageEmbed{}
+	setModlogEmbedAuthor(&Config{ModlogAuthorDisplay: ModlogAuthorHidden}, &discordgo.User{Username: "Unknown", Discriminator: "????"}, embed)
+	if embed.Author == nil || embed.Author.Name != "Unknown#???? (ID 0)" {
+		t.Errorf("expected the unknown author to be shown, got %+v", embed.Author)
+	}
+}
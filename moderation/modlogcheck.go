@@ -0,0 +1,24 @@
+package moderation
+
+// featuresNeedingModlog returns the enabled features that don't do anything useful without a modlog channel,
+// empty if the modlog channel is set
+func (c *Config) featuresNeedingModlog() []string {
+	if c.IntActionChannel() != 0 {
+		return nil
+	}
+
+	var result []string
+	add := func(enabled bool, name string) {
+		if enabled {
+			result = append(result, name)
+		}
+	}
+
+	add(c.LogBans, "logging bans")
+	add(c.LogUnbans, "logging unbans")
+	add(c.WarnSendToModlog, "sending warnings to the modlog")
+	add(c.GiveRoleCmdEnabled && c.GiveRoleCmdModlog, "logging the giverole/removerole commands")
+	add(c.AuditWebhook != "", "the audit webhook")
+
+	return result
+}
@@ -0,0 +1,150 @@
+package moderation
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jonas747/discordgo"
+	"github.com/jonas747/yagpdb/bot"
+	"github.com/jonas747/yagpdb/common"
+)
+
+// The max amount of messages we look through in the modlog channel when exporting
+const MaxModlogExportMessages = 5000
+
+// ModlogExportEntry is a single modlog entry parsed back from the embed the bot posted
+type ModlogExportEntry struct {
+	MessageID   int64     `json:"message_id,string"`
+	Time        time.Time `json:"time"`
+	Moderator   string    `json:"moderator"`
+	ModeratorID int64     `json:"moderator_id,string"`
+	Action      string    `json:"action"`
+	TargetID    int64     `json:"target_id,string"`
+	Reason      string    `json:"reason"`
+	Logs        string    `json:"logs,omitempty"`
+}
+
+var (
+	modlogAuthorRegex   = regexp.MustCompile(`^(.*) \(ID (\d+)\)$`)
+	modlogTargetIDRegex = regexp.MustCompile(`\(ID (\d+)\)`)
+	modlogLogsRegex     = regexp.MustCompile(` ?\(\[Logs\]\((.*)\)\)$`)
+)
+
+// parseModlogMessage parses a modlog entry, returns nil if the message isn't one
+func parseModlogMessage(m *discordgo.Message) *ModlogExportEntry {
+	if m.Author == nil || m.Author.ID != common.BotUser.ID || len(m.Embeds) < 1 {
+		return nil
+	}
+
+	embed := m.Embeds[0]
+	const reasonStr = "📄**Reason:**"
+	index := strings.Index(embed.Description, reasonStr)
+	if index == -1 {
+		return nil
+	}
+
+	entry := &ModlogExportEntry{
+		MessageID: m.ID,
+		Time:      bot.SnowflakeToTime(m.ID),
+	}
+
+	headline := strings.TrimSpace(embed.Description[:index])
+	entry.Action = strings.NewReplacer("**", "", "*", "").Replace(headline)
+	if matches := modlogTargetIDRegex.FindStringSubmatch(headline); matches != nil {
+		entry.TargetID, _ = strconv.ParseInt(matches[1], 10, 64)
+	}
+
+	reason := strings.TrimSpace(embed.Description[index+len(reasonStr):])
+	if matches := modlogLogsRegex.FindStringSubmatch(reason); matches != nil {
+		entry.Logs = matches[1]
+		reason = strings.TrimSpace(reason[:len(reason)-len(matches[0])])
+	}
+	entry.Reason = reason
+
+	parseModlogModerator(embed, entry)
+	return entry
+}
+
+// parseModlogModerator reads the moderator of the entry from the embed, shown either as the author or a field
+func parseModlogModerator(embed *discordgo.MessageEmbed, entry *ModlogExportEntry) {
+	if embed.Author != nil {
+		entry.Moderator = embed.Author.Name
+		if matches := modlogAuthorRegex.FindStringSubmatch(embed.Author.Name); matches != nil {
+			entry.Moderator = matches[1]
+			entry.ModeratorID, _ = strconv.ParseInt(matches[2], 10, 64)
+		}
+	}
+
+	// servers showing the moderator as a mention
+	for _, v := range embed.Fields {
+		if v.Name == modlogModeratorField {
+			entry.ModeratorID, _ = strconv.ParseInt(strings.Trim(v.Value, "<@!>"), 10, 64)
+		}
+	}
+}
+
+// fetchModlogEntries goes through up to limit messages in the modlog channel and returns the entries, oldest first
+func fetchModlogEntries(channelID int64, limit int) ([]*ModlogExportEntry, error) {
+	msgs, err := fetchMessagesAPI(channelID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	// entries rendered with the modlog template can't be parsed, they're read from what they were made from instead
+	var botMessages []int64
+	for _, v := range msgs {
+		if v.Author != nil && v.Author.ID == common.BotUser.ID && len(v.Embeds) > 0 {
+			botMessages = append(botMessages, v.ID)
+		}
+	}
+
+	templated, err := findTemplatedModlogEntries(botMessages)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]*ModlogExportEntry, 0, len(msgs))
+	for _, v := range msgs {
+		if stored, ok := templated[v.ID]; ok {
+			entries = append(entries, stored.exportEntry(v.Message))
+		} else if entry := parseModlogMessage(v.Message); entry != nil {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries, nil
+}
+
+// encodeModlogExport encodes the entries in the format, either json or csv
+func encodeModlogExport(entries []*ModlogExportEntry, format string) (*bytes.Buffer, error) {
+	var buf bytes.Buffer
+	if format == "json" {
+		enc := json.NewEncoder(&buf)
+		enc.SetIndent("", "  ")
+		err := enc.Encode(entries)
+		return &buf, err
+	}
+
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"message_id", "time", "moderator", "moderator_id", "action", "target_id", "reason", "logs"})
+	for _, v := range entries {
+		w.Write([]string{
+			discordgo.StrID(v.MessageID),
+			v.Time.UTC().Format(time.RFC3339),
+			v.Moderator,
+			discordgo.StrID(v.ModeratorID),
+			v.Action,
+			discordgo.StrID(v.TargetID),
+			v.Reason,
+			v.Logs,
+		})
+	}
+	w.Flush()
+
+	return &buf, w.Error()
+}
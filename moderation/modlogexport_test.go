@@ -0,0 +1,61 @@
+package moderation
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jonas747/discordgo"
+	"github.com/jonas747/yagpdb/common"
+)
+
+func TestParseModlogMessage(t *testing.T) {
+	oldBotUser := common.BotUser
+	common.BotUser = &discordgo.User{ID: 1}
+	defer func() { common.BotUser = oldBotUser }()
+
+	m := &discordgo.Message{
+		ID:     100,
+		Author: &discordgo.User{ID: 1},
+		Embeds: []*discordgo.MessageEmbed{{
+			Author:      &discordgo.MessageEmbedAuthor{Name: "mod#0001 (ID 5)"},
+			Description: "**🔨Banned someone**#1234 *(ID 10)*\n📄**Reason:** spamming ([Logs](https://example.com/logs/1))",
+		}},
+	}
+
+	entry := parseModlogMessage(m)
+	if entry == nil {
+		t.Fatal("expected an entry")
+	}
+
+	if entry.Action != "🔨Banned someone#1234 (ID 10)" {
+		t.Errorf("unexpected action: %q", entry.Action)
+	}
+	if entry.TargetID != 10 || entry.ModeratorID != 5 || entry.Moderator != "mod#0001" {
+		t.Errorf("unexpected ids or moderator: %+v", entry)
+	}
+	if entry.Reason != "spamming" || entry.Logs != "https://example.com/logs/1" {
+		t.Errorf("unexpected reason or logs: %q %q", entry.Reason, entry.Logs)
+	}
+
+	m.Author = &discordgo.User{ID: 2}
+	if parseModlogMessage(m) != nil {
+		t.Error("parsed a message not sent by the bot")
+	}
+}
+
+func TestEncodeModlogExportCSV(t *testing.T) {
+	entries := []*ModlogExportEntry{{MessageID: 1, Action: "Warned", TargetID: 2, Reason: "a, b"}}
+
+	buf, err := encodeModlogExport(entries, "csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected a header and 1 row, got %d lines", len(lines))
+	}
+	if !strings.Contains(lines[1], `"a, b"`) {
+		t.Errorf("reason wasn't quoted: %s", lines[1])
+	}
+}
@@ -0,0 +1,402 @@
+package moderation
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/jinzhu/gorm"
+	"github.com/jonas747/discordgo"
+	"github.com/jonas747/yagpdb/common"
+)
+
+// The modlog template replaces the built in layout of modlog entries. Each line sets a part of the embed:
+//
+//   title: {{.Action}} {{.Target}}
+//   description: {{.Reason}}
+//   field Case: {{.CaseID}}
+//
+// Parts that are left out are left empty, fields are added in the order they're given.
+// The result can't be parsed back, so what templated entries were made from is stored in TemplatedModlogEntryModel.
+
+// MaxModlogTemplateFields is the max amount of fields a modlog template can add
+const MaxModlogTemplateFields = 10
+
+// ModlogTemplateData is what the server's modlog template is executed with
+type ModlogTemplateData struct {
+	Action    string
+	Target    string
+	Moderator string
+	Reason    string
+
+	// The ID of the modlog message, what the reason command takes to refer to the entry.
+	// Empty until the entry is posted in the modlog.
+	CaseID string
+}
+
+var modlogTemplateSample = &ModlogTemplateData{
+	Action:    "🔨Banned",
+	Target:    "user#0001 (ID 1)",
+	Moderator: "moderator#0001 (ID 2)",
+	Reason:    "spam",
+	CaseID:    "123",
+}
+
+// modlogTemplateEscaper keeps the template, and the names put into it, from pinging anyone if the entry is shown outside an embed
+var modlogTemplateEscaper = strings.NewReplacer("@everyone", "@\u200beveryone", "@here", "@\u200bhere", "<@&", "<@&\u200b")
+
+type modlogTemplateField struct {
+	Name  string
+	Value *template.Template
+}
+
+type modlogTemplate struct {
+	Title       *template.Template
+	Description *template.Template
+	Fields      []*modlogTemplateField
+}
+
+// parseModlogTemplate parses the line based modlog template format
+func parseModlogTemplate(src string) (*modlogTemplate, error) {
+	result := &modlogTemplate{}
+
+	for i, line := range strings.Split(src, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		colon := strings.Index(line, ":")
+		if colon == -1 {
+			return nil, errors.Errorf("line %d: expected `title:`, `description:` or `field <name>:`", i+1)
+		}
+
+		part := strings.TrimSpace(line[:colon])
+		tmpl, err := template.New(part).Parse(strings.TrimSpace(line[colon+1:]))
+		if err != nil {
+			return nil, errors.Errorf("line %d: %s", i+1, err.Error())
+		}
+
+		switch {
+		case strings.EqualFold(part, "title"):
+			result.Title = tmpl
+		case strings.EqualFold(part, "description"):
+			result.Description = tmpl
+		case len(part) > len("field ") && strings.EqualFold(part[:len("field ")], "field "):
+			name := strings.TrimSpace(part[len("field "):])
+			if strings.EqualFold(name, modlogModeratorField) {
+				// setModlogEmbedAuthor manages the field with this name
+				return nil, errors.Errorf("line %d: the field name %s is reserved", i+1, modlogModeratorField)
+			}
+
+			result.Fields = append(result.Fields, &modlogTemplateField{Name: common.CutStringShort(name, 256), Value: tmpl})
+		default:
+			return nil, errors.Errorf("line %d: unknown part %q, expected `title:`, `description:` or `field <name>:`", i+1, part)
+		}
+	}
+
+	if len(result.Fields) > MaxModlogTemplateFields {
+		return nil, errors.Errorf("too many fields, max is %d", MaxModlogTemplateFields)
+	}
+
+	if result.Title == nil && result.Description == nil && len(result.Fields) < 1 {
+		return nil, errors.New("the template doesn't set any part of the entry")
+	}
+
+	return result, nil
+}
+
+// validateModlogTemplate makes sure the template parses and runs with the fields it's given
+func validateModlogTemplate(src string) error {
+	tmpl, err := parseModlogTemplate(src)
+	if err != nil {
+		return err
+	}
+
+	return tmpl.apply(&discordgo.MessageEmbed{}, modlogTemplateSample)
+}
+
+func executeModlogTemplatePart(tmpl *template.Template, data *ModlogTemplateData, maxLen int) (string, error) {
+	if tmpl == nil {
+		return "", nil
+	}
+
+	var buf bytes.Buffer
+	err := tmpl.Execute(&buf, data)
+	if err != nil {
+		return "", err
+	}
+
+	return common.CutStringShort(modlogTemplateEscaper.Replace(strings.TrimSpace(buf.String())), maxLen), nil
+}
+
+// apply renders the template into the title, description and fields of the embed, the embed is left alone on errors
+func (t *modlogTemplate) apply(embed *discordgo.MessageEmbed, data *ModlogTemplateData) error {
+	title, err := executeModlogTemplatePart(t.Title, data, 256)
+	if err != nil {
+		return err
+	}
+
+	description, err := executeModlogTemplatePart(t.Description, data, 2000)
+	if err != nil {
+		return err
+	}
+
+	var fields []*discordgo.MessageEmbedField
+	for _, v := range t.Fields {
+		value, err := executeModlogTemplatePart(v.Value, data, 1024)
+		if err != nil {
+			return err
+		}
+
+		// discord doesn't allow empty fields
+		if value != "" {
+			fields = append(fields, &discordgo.MessageEmbedField{Name: v.Name, Value: value})
+		}
+	}
+
+	if title == "" && description == "" && len(fields) < 1 {
+		return errors.New("the template rendered an empty entry")
+	}
+
+	embed.Title = title
+	embed.Description = description
+	embed.Fields = fields
+	return nil
+}
+
+// modlogTemplateModerator returns how the moderator is shown through the template, the same as in the embed author
+func modlogTemplateModerator(config *Config, author *discordgo.User) string {
+	display := config.ModlogAuthorDisplay
+	if author.ID == 0 || (common.BotUser != nil && author.ID == common.BotUser.ID) {
+		display = ModlogAuthorFull
+	}
+
+	switch display {
+	case ModlogAuthorName:
+		return author.Username + "#" + author.Discriminator
+	case ModlogAuthorMention:
+		return fmt.Sprintf("<@%d>", author.ID)
+	case ModlogAuthorID:
+		return fmt.Sprintf("Moderator (ID %d)", author.ID)
+	case ModlogAuthorHidden:
+		return "A moderator"
+	}
+
+	return fmt.Sprintf("%s#%s (ID %d)", author.Username, author.Discriminator, author.ID)
+}
+
+// defaultModlogDescription is the built in layout of modlog entries, the reason has to be resolved already
+func defaultModlogDescription(action ModlogAction, target *discordgo.User, reason string) string {
+	return fmt.Sprintf("**%s%s %s**#%s *(ID %d)*\n📄**Reason:** %s",
+		action.Emoji, action.Prefix, target.Username, target.Discriminator, target.ID, reason)
+}
+
+// renderModlogEmbed sets the contents of the modlog embed, using the server's modlog template if it has one and it renders.
+// Returns true if the template was used. The reason has to be resolved already, caseID is 0 if the entry hasn't been posted yet.
+// The author is set separately with setModlogEmbedAuthor.
+func renderModlogEmbed(config *Config, embed *discordgo.MessageEmbed, author *discordgo.User, action ModlogAction, target *discordgo.User, reason string, caseID int64) bool {
+	if config.ModlogTemplate != "" {
+		data := &ModlogTemplateData{
+			Action:    action.Emoji + action.Prefix,
+			Target:    SanitizeReason(fmt.Sprintf("%s#%s (ID %d)", target.Username, target.Discriminator, target.ID)),
+			Moderator: SanitizeReason(modlogTemplateModerator(config, author)),
+			Reason:    reason,
+		}
+		if caseID != 0 {
+			data.CaseID = strconv.FormatInt(caseID, 10)
+		}
+
+		tmpl, err := parseModlogTemplate(config.ModlogTemplate)
+		if err == nil {
+			err = tmpl.apply(embed, data)
+		}
+
+		if err == nil {
+			return true
+		}
+
+		logger.WithError(err).WithField("guild", config.GetGuildID()).Debug("modlog template failed, using the default layout")
+	}
+
+	embed.Title = ""
+	embed.Fields = nil
+	embed.Description = defaultModlogDescription(action, target, reason)
+	return false
+}
+
+// modlogTemplateUsesCaseID returns true if the entry has to be rendered again once the modlog message is posted
+func (c *Config) modlogTemplateUsesCaseID() bool {
+	return strings.Contains(c.ModlogTemplate, ".CaseID")
+}
+
+// fullAuthorConfig returns a copy of the config showing the moderator in full, for the private audit channel
+func fullAuthorConfig(config *Config) *Config {
+	full := *config
+	full.ModlogAuthorDisplay = ModlogAuthorFull
+	return &full
+}
+
+// TemplatedModlogEntryModel is what a modlog entry rendered with the server's template was made from.
+// The reason command renders the entry again from it, and the exports read it as the entry can't be parsed back.
+type TemplatedModlogEntryModel struct {
+	MessageID int64 `gorm:"primary_key;auto_increment:false"`
+	GuildID   int64 `gorm:"index"`
+	CreatedAt time.Time
+
+	ActionEmoji  string
+	ActionPrefix string
+
+	TargetID            int64
+	TargetUsername      string
+	TargetDiscriminator string
+
+	AuthorID            int64
+	AuthorUsername      string
+	AuthorDiscriminator string
+
+	// As shown in the entry
+	Reason string
+	// Only set if the logs are shown in the entry
+	LogLink string
+}
+
+func (t *TemplatedModlogEntryModel) TableName() string {
+	return "moderation_templated_modlog_entries"
+}
+
+func newTemplatedModlogEntry(guildID int64, author *discordgo.User, action ModlogAction, target *discordgo.User, reason, logLink string) *TemplatedModlogEntryModel {
+	return &TemplatedModlogEntryModel{
+		GuildID:             guildID,
+		ActionEmoji:         action.Emoji,
+		ActionPrefix:        action.Prefix,
+		TargetID:            target.ID,
+		TargetUsername:      target.Username,
+		TargetDiscriminator: target.Discriminator,
+		AuthorID:            author.ID,
+		AuthorUsername:      author.Username,
+		AuthorDiscriminator: author.Discriminator,
+		Reason:              reason,
+		LogLink:             logLink,
+	}
+}
+
+func (t *TemplatedModlogEntryModel) author() *discordgo.User {
+	return &discordgo.User{ID: t.AuthorID, Username: t.AuthorUsername, Discriminator: t.AuthorDiscriminator}
+}
+
+func (t *TemplatedModlogEntryModel) setAuthor(author *discordgo.User) {
+	t.AuthorID, t.AuthorUsername, t.AuthorDiscriminator = author.ID, author.Username, author.Discriminator
+}
+
+// render renders the entry into the embed again, with the moderator and the logs
+func (t *TemplatedModlogEntryModel) render(config *Config, embed *discordgo.MessageEmbed) {
+	target := &discordgo.User{ID: t.TargetID, Username: t.TargetUsername, Discriminator: t.TargetDiscriminator}
+	action := ModlogAction{Emoji: t.ActionEmoji, Prefix: t.ActionPrefix}
+
+	renderModlogEmbed(config, embed, t.author(), action, target, t.Reason, t.MessageID)
+	setModlogEmbedAuthor(config, t.author(), embed)
+	if t.LogLink != "" {
+		embed.Description = strings.TrimSpace(embed.Description + " ([Logs](" + t.LogLink + "))")
+	}
+}
+
+// exportEntry converts the stored entry to the export format, the moderator is read from the message like other entries
+func (t *TemplatedModlogEntryModel) exportEntry(m *discordgo.Message) *ModlogExportEntry {
+	entry := &ModlogExportEntry{
+		MessageID: m.ID,
+		Time:      t.CreatedAt,
+		Action:    fmt.Sprintf("%s%s %s#%s (ID %d)", t.ActionEmoji, t.ActionPrefix, t.TargetUsername, t.TargetDiscriminator, t.TargetID),
+		TargetID:  t.TargetID,
+		Reason:    t.Reason,
+		Logs:      t.LogLink,
+	}
+
+	if len(m.Embeds) > 0 {
+		parseModlogModerator(m.Embeds[0], entry)
+	}
+
+	return entry
+}
+
+// finishTemplatedModlogEntry stores what the templated entry was made from now that it's posted, rendering it again
+// if the template shows the case ID or the moderator couldn't be found
+func finishTemplatedModlogEntry(config *Config, author *discordgo.User, action ModlogAction, target *discordgo.User, reason, logLink string, emptyAuthor bool, entry *modlogEntry) error {
+	// the logs are only in the modlog if they didn't make it to the private audit channel
+	publicLogLink := logLink
+	if entry.AuditMessage != nil {
+		publicLogLink = ""
+	}
+
+	stored := newTemplatedModlogEntry(config.GetGuildID(), author, action, target, reason, publicLogLink)
+	stored.MessageID = entry.Message.ID
+	entry.Templated = true
+
+	if config.modlogTemplateUsesCaseID() && entry.AuditMessage != nil {
+		audit := *stored
+		audit.LogLink = logLink
+		audit.render(fullAuthorConfig(config), entry.AuditEmbed)
+
+		_, err := session().ChannelMessageEditEmbed(entry.AuditMessage.ChannelID, entry.AuditMessage.ID, entry.AuditEmbed)
+		if err != nil {
+			logger.WithError(err).WithField("guild", config.GetGuildID()).Error("failed updating the case id in the private audit channel")
+		}
+	}
+
+	if emptyAuthor {
+		stored.Reason = emptyAuthorPlaceholder(entry.Message.ID)
+	}
+
+	if emptyAuthor || config.modlogTemplateUsesCaseID() {
+		stored.render(config, entry.Embed)
+		_, err := session().ChannelMessageEditEmbed(entry.Message.ChannelID, entry.Message.ID, entry.Embed)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := common.GORM.Create(stored).Error
+	if err != nil {
+		return common.ErrWithCaller(err)
+	}
+
+	return nil
+}
+
+// findTemplatedModlogEntry returns the stored data of the templated modlog entry, nil if the entry isn't one
+func findTemplatedModlogEntry(messageID int64) (*TemplatedModlogEntryModel, error) {
+	var stored TemplatedModlogEntryModel
+	err := common.GORM.Where("message_id = ?", messageID).First(&stored).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, common.ErrWithCaller(err)
+	}
+
+	return &stored, nil
+}
+
+// findTemplatedModlogEntries returns the stored data of the templated entries among the messages
+func findTemplatedModlogEntries(messageIDs []int64) (map[int64]*TemplatedModlogEntryModel, error) {
+	result := make(map[int64]*TemplatedModlogEntryModel)
+	if len(messageIDs) < 1 {
+		return result, nil
+	}
+
+	var stored []*TemplatedModlogEntryModel
+	err := common.GORM.Where("message_id IN (?)", messageIDs).Find(&stored).Error
+	if err != nil {
+		return nil, common.ErrWithCaller(err)
+	}
+
+	for _, v := range stored {
+		result[v.MessageID] = v
+	}
+
+	return result, nil
+}
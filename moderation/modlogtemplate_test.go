@@ -0,0 +1,140 @@
+package moderation
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jonas747/discordgo"
+	"github.com/jonas747/yagpdb/common"
+	"github.com/jonas747/yagpdb/common/configstore"
+)
+
+func TestValidateModlogTemplate(t *testing.T) {
+	valid := "title: {{.Action}} {{.Target}}\ndescription: by {{.Moderator}}: {{.Reason}}\n\nfield Case: {{.CaseID}}"
+	if err := validateModlogTemplate(valid); err != nil {
+		t.Errorf("expected the template to be valid, got %v", err)
+	}
+
+	invalid := map[string]string{
+		"parse error":     "title: {{.Action",
+		"unknown field":   "title: {{.Points}}",
+		"unknown part":    "footer: {{.Reason}}",
+		"missing colon":   "{{.Reason}}",
+		"reserved field":  "field moderator: {{.Moderator}}",
+		"nothing set":     "\n  \n",
+		"too many fields": strings.Repeat("field A: {{.Reason}}\n", MaxModlogTemplateFields+1),
+	}
+
+	for name, src := range invalid {
+		if err := validateModlogTemplate(src); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestRenderModlogEmbed(t *testing.T) {
+	author := &discordgo.User{ID: 2, Username: "mod", Discriminator: "0001"}
+	target := &discordgo.User{ID: 1, Username: "@everyone", Discriminator: "0002"}
+
+	config := &Config{}
+	embed := &discordgo.MessageEmbed{}
+	if renderModlogEmbed(config, embed, author, MABanned, target, "spam", 0) {
+		t.Error("expected the default layout without a template")
+	}
+	if !strings.HasSuffix(embed.Description, "📄**Reason:** spam") {
+		t.Errorf("expected the default layout, got %q", embed.Description)
+	}
+
+	config.ModlogTemplate = "title: {{.Action}} {{.Target}}\ndescription: by {{.Moderator}} <@&5>: {{.Reason}}\nfield Case: #{{.CaseID}}\nfield Note: {{if .CaseID}}posted{{end}}"
+	config.ModlogAuthorDisplay = ModlogAuthorHidden
+	if !renderModlogEmbed(config, embed, author, MABanned, target, "spam", 10) {
+		t.Fatal("expected the template to be used")
+	}
+
+	if expected := "🔨Banned @\u200beveryone#0002 (ID 1)"; embed.Title != expected {
+		t.Errorf("expected the title %q, got %q", expected, embed.Title)
+	}
+	if expected := "by A moderator <@&\u200b5>: spam"; embed.Description != expected {
+		t.Errorf("expected the description %q, got %q", expected, embed.Description)
+	}
+	if len(embed.Fields) != 2 || embed.Fields[0].Name != "Case" || embed.Fields[0].Value != "#10" || embed.Fields[1].Value != "posted" {
+		t.Errorf("unexpected fields %+v", embed.Fields)
+	}
+
+	// empty fields are left out
+	renderModlogEmbed(config, embed, author, MABanned, target, "spam", 0)
+	if len(embed.Fields) != 1 || embed.Fields[0].Value != "#" {
+		t.Errorf("expected the empty field to be left out, got %+v", embed.Fields)
+	}
+
+	config.ModlogTemplate = "description: {{.Reason.Foo}}"
+	if renderModlogEmbed(config, embed, author, MABanned, target, "spam", 0) {
+		t.Error("expected the default layout when the template fails")
+	}
+	if embed.Title != "" || len(embed.Fields) != 0 || !strings.HasSuffix(embed.Description, "📄**Reason:** spam") {
+		t.Errorf("expected the default layout when the template fails, got %+v", embed)
+	}
+}
+
+func TestTemplatedModlogEntryExport(t *testing.T) {
+	author := &discordgo.User{ID: 2, Username: "mod", Discriminator: "0001"}
+	target := &discordgo.User{ID: 1, Username: "user", Discriminator: "0002"}
+
+	stored := newTemplatedModlogEntry(5, author, MABanned, target, "spam", "https://example.com/logs")
+	stored.MessageID = 10
+
+	config := &Config{ModlogTemplate: "title: {{.Target}}", ModlogAuthorDisplay: ModlogAuthorMention}
+	embed := &discordgo.MessageEmbed{}
+	stored.render(config, embed)
+
+	if embed.Description != "([Logs](https://example.com/logs))" {
+		t.Errorf("expected the logs in the description, got %q", embed.Description)
+	}
+
+	entry := stored.exportEntry(&discordgo.Message{ID: 10, Embeds: []*discordgo.MessageEmbed{embed}})
+	if entry.TargetID != 1 || entry.Reason != "spam" || entry.Logs != "https://example.com/logs" || entry.ModeratorID != 2 {
+		t.Errorf("unexpected export entry %+v", entry)
+	}
+	if entry.Action != "🔨Banned user#0002 (ID 1)" {
+		t.Errorf("unexpected action %q", entry.Action)
+	}
+}
+
+func TestEditTemplatedModlogReason(t *testing.T) {
+	if common.GORM == nil {
+		t.Skip("db not available, skipping.")
+		return
+	}
+
+	_, restore := useMockSession()
+	defer restore()
+
+	author := &discordgo.User{ID: 2, Username: "mod", Discriminator: "0001"}
+	target := &discordgo.User{ID: 1, Username: "user", Discriminator: "0002"}
+
+	stored := newTemplatedModlogEntry(5, author, MABanned, target, "spam", "")
+	stored.MessageID = 10
+	err := common.GORM.Create(stored).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer common.GORM.Where("message_id = 10").Delete(TemplatedModlogEntryModel{})
+
+	config := &Config{GuildConfigModel: configstore.GuildConfigModel{GuildID: 5}, ModlogTemplate: "description: {{.Action}} {{.Target}}: {{.Reason}}"}
+	msg := &discordgo.Message{ID: 10, ChannelID: 3, Embeds: []*discordgo.MessageEmbed{{}}}
+	stored.render(config, msg.Embeds[0])
+
+	err = editModlogReason(config, nil, msg, "raiding", "raiding")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := "🔨Banned user#0002 (ID 1): raiding"; msg.Embeds[0].Description != expected {
+		t.Errorf("expected the entry to be rendered again as %q, got %q", expected, msg.Embeds[0].Description)
+	}
+
+	updated, err := findTemplatedModlogEntry(10)
+	if err != nil || updated == nil || updated.Reason != "raiding" {
+		t.Errorf("expected the stored reason to be updated, got %+v, %v", updated, err)
+	}
+}
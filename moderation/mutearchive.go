@@ -0,0 +1,149 @@
+package moderation
+
+import (
+	"context"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/jonas747/yagpdb/common"
+	seventsmodels "github.com/jonas747/yagpdb/common/scheduledevents2/models"
+	"github.com/volatiletech/sqlboiler/queries/qm"
+)
+
+// Mutes are normally removed from muted_users when they end, but the row stays behind if the unmute couldn't happen,
+// e.g because the member left. Servers can have those moved to the mute history (or just pruned) after a while,
+// permanent and still running mutes are never touched.
+
+// MuteHistoryModel is a completed mute that was archived from muted_users
+type MuteHistoryModel struct {
+	common.SmallModel
+
+	GuildID int64 `gorm:"index"`
+	UserID  int64 `gorm:"index"`
+
+	AuthorID int64
+	Reason   string
+
+	MutedAt   time.Time
+	ExpiresAt time.Time
+}
+
+func (m *MuteHistoryModel) TableName() string {
+	return "moderation_mute_history"
+}
+
+func muteArchivePeriod(config *Config) time.Duration {
+	return time.Hour * 24 * time.Duration(config.MuteArchiveAfterDays)
+}
+
+// muteArchivable returns true if the mute ended more than period ago, permanent mutes never are
+func muteArchivable(mute *MuteModel, period time.Duration, now time.Time) bool {
+	if mute.ExpiresAt.IsZero() {
+		return false
+	}
+
+	return now.Sub(mute.ExpiresAt) > period
+}
+
+// scheduleMuteArchival schedules archiving the guild's completed mutes at t, unless it's already pending
+func scheduleMuteArchival(config *Config, guildID int64, t time.Time) {
+	if config.MuteArchiveAfterDays <= 0 {
+		return
+	}
+
+	exists, err := seventsmodels.ScheduledEvents(qm.Where("event_name='moderation_archive_mutes' AND guild_id = ? AND processed = false", guildID)).Exists(context.Background(), common.PQ)
+	if err == nil && !exists {
+		err = scheduleEvent("moderation_archive_mutes", guildID, t, nil)
+	}
+
+	if err != nil {
+		logger.WithError(err).WithField("guild", guildID).Error("failed scheduling mute archival")
+	}
+}
+
+// archiveMutes moves the completed mutes of the guild to the history, or deletes them if history isn't kept.
+// Returns the number of mutes archived.
+func archiveMutes(config *Config, guildID int64, now time.Time) (int, error) {
+	period := muteArchivePeriod(config)
+
+	var mutes []*MuteModel
+	err := common.GORM.Where("guild_id = ? AND expires_at < ?", guildID, now.Add(-period)).Find(&mutes).Error
+	if err != nil {
+		return 0, errors.WithStackIf(err)
+	}
+
+	archived := 0
+	for _, v := range mutes {
+		if !muteArchivable(v, period, now) {
+			continue
+		}
+
+		// don't race with something updating the mute
+		LockMute(v.UserID)
+		err = archiveMute(config, v)
+		UnlockMute(v.UserID)
+		if err != nil {
+			return archived, err
+		}
+
+		archived++
+	}
+
+	return archived, nil
+}
+
+func archiveMute(config *Config, mute *MuteModel) error {
+	tx := common.GORM.Begin()
+
+	// make sure it wasn't extended or replaced in the meantime
+	res := tx.Where("id = ? AND expires_at = ?", mute.ID, mute.ExpiresAt).Delete(MuteModel{})
+	if res.Error != nil || res.RowsAffected < 1 {
+		tx.Rollback()
+		return errors.WithStackIf(res.Error)
+	}
+
+	if config.MuteArchiveKeepHistory {
+		err := tx.Create(&MuteHistoryModel{
+			GuildID:   mute.GuildID,
+			UserID:    mute.UserID,
+			AuthorID:  mute.AuthorID,
+			Reason:    mute.Reason,
+			MutedAt:   mute.CreatedAt,
+			ExpiresAt: mute.ExpiresAt,
+		}).Error
+		if err != nil {
+			tx.Rollback()
+			return errors.WithStackIf(err)
+		}
+	}
+
+	return errors.WithStackIf(tx.Commit().Error)
+}
+
+func handleScheduledArchiveMutes(evt *seventsmodels.ScheduledEvent, data interface{}) (retry bool, err error) {
+	config, err := GetConfig(evt.GuildID)
+	if err != nil {
+		return true, err
+	}
+
+	if config.MuteArchiveAfterDays <= 0 {
+		// turned off since this was scheduled
+		return false, nil
+	}
+
+	_, err = archiveMutes(config, evt.GuildID, time.Now())
+	if err != nil {
+		return true, err
+	}
+
+	// schedule the next run for the timed mute that ends first
+	var next MuteModel
+	err = common.GORM.Where("guild_id = ? AND expires_at > ?", evt.GuildID, time.Time{}).Order("expires_at asc").First(&next).Error
+	if err == nil {
+		// this event is still marked as pending, so schedule directly
+		err = scheduleEvent("moderation_archive_mutes", evt.GuildID, next.ExpiresAt.Add(muteArchivePeriod(config)), nil)
+		return false, err
+	}
+
+	return false, nil
+}
@@ -0,0 +1,70 @@
+package moderation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jonas747/yagpdb/common"
+)
+
+func TestMuteArchivable(t *testing.T) {
+	now := time.Now()
+	period := time.Hour * 24
+
+	cases := []struct {
+		name      string
+		expiresAt time.Time
+		want      bool
+	}{
+		{"permanent", time.Time{}, false},
+		{"still running", now.Add(time.Hour), false},
+		{"just ended", now.Add(-time.Hour), false},
+		{"exactly at the boundary", now.Add(-period), false},
+		{"past the boundary", now.Add(-period - time.Second), true},
+	}
+
+	for _, c := range cases {
+		if got := muteArchivable(&MuteModel{ExpiresAt: c.expiresAt}, period, now); got != c.want {
+			t.Errorf("%s: got %t, expected %t", c.name, got, c.want)
+		}
+	}
+}
+
+func TestArchiveMutes(t *testing.T) {
+	if common.GORM == nil {
+		t.Skip("db not available, skipping.")
+		return
+	}
+
+	now := time.Now()
+	defer common.GORM.Where("guild_id = 7").Delete(MuteModel{})
+	defer common.GORM.Where("guild_id = 7").Delete(MuteHistoryModel{})
+
+	mutes := []*MuteModel{
+		{GuildID: 7, UserID: 1},
+		{GuildID: 7, UserID: 2, ExpiresAt: now.Add(time.Hour)},
+		{GuildID: 7, UserID: 3, ExpiresAt: now.Add(-time.Hour * 24 * 3)},
+	}
+	for _, v := range mutes {
+		if err := common.GORM.Create(v).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	config := &Config{MuteArchiveAfterDays: 2, MuteArchiveKeepHistory: true}
+	n, err := archiveMutes(config, 7, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 1 {
+		t.Errorf("expected 1 archived mute, got %d", n)
+	}
+
+	var remaining, history int
+	common.GORM.Model(&MuteModel{}).Where("guild_id = 7").Count(&remaining)
+	common.GORM.Model(&MuteHistoryModel{}).Where("guild_id = 7 AND user_id = 3").Count(&history)
+	if remaining != 2 || history != 1 {
+		t.Errorf("expected the permanent and running mutes to stay and the ended one in the history, got %d remaining, %d in history", remaining, history)
+	}
+}
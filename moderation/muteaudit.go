@@ -0,0 +1,140 @@
+package moderation
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jonas747/discordgo"
+	"github.com/jonas747/yagpdb/bot"
+	"github.com/jonas747/yagpdb/common"
+)
+
+// muteOverrideProblem is a mute role override that doesn't match what RefreshMuteOverrideForChannel would set
+type muteOverrideProblem struct {
+	RoleID        int64
+	Missing       bool
+	MissingDenies int
+	ExtraAllows   int
+}
+
+func (p muteOverrideProblem) describe(roleName string) string {
+	if p.Missing {
+		return fmt.Sprintf("`%s` override is missing", roleName)
+	}
+
+	var parts []string
+	if p.MissingDenies != 0 {
+		parts = append(parts, "doesn't deny "+strings.Join(common.HumanizePermissions(int64(p.MissingDenies)), ", "))
+	}
+	if p.ExtraAllows != 0 {
+		parts = append(parts, "allows "+strings.Join(common.HumanizePermissions(int64(p.ExtraAllows)), ", "))
+	}
+
+	return fmt.Sprintf("`%s` override %s", roleName, strings.Join(parts, " and "))
+}
+
+// channelMuteOverrideProblems compares the overrides in the channel with what refreshMuteRoleOverride would set, without changing anything
+func channelMuteOverrideProblems(config *Config, channel *discordgo.Channel, overrides []muteRoleOverride) []muteOverrideProblem {
+	if common.ContainsInt64Slice(config.MuteIgnoreChannels, channel.ID) {
+		return nil
+	}
+
+	var problems []muteOverrideProblem
+	for _, v := range overrides {
+		var override *discordgo.PermissionOverwrite
+		for _, o := range channel.PermissionOverwrites {
+			if o.Type == "role" && o.ID == v.Role {
+				override = o
+				break
+			}
+		}
+
+		if override == nil {
+			problems = append(problems, muteOverrideProblem{RoleID: v.Role, Missing: true})
+			continue
+		}
+
+		allows, denies, changed := muteOverridePerms(override.Allow, override.Deny, v.Denies, config.MuteKeepOverrides)
+		if !changed {
+			continue
+		}
+
+		problems = append(problems, muteOverrideProblem{
+			RoleID:        v.Role,
+			MissingDenies: denies &^ override.Deny,
+			ExtraAllows:   override.Allow &^ allows,
+		})
+	}
+
+	return problems
+}
+
+// muteAudit lists the channels in the guild with missing or incorrect mute role overrides
+func muteAudit(config *Config, guildID int64) (string, error) {
+	gs := bot.State.Guild(true, guildID)
+	if gs == nil {
+		return "", bot.ErrGuildNotFound
+	}
+
+	roleNames := make(map[int64]string)
+	var overrides []muteRoleOverride
+	for _, v := range config.managedMuteOverrides() {
+		role := gs.RoleCopy(true, v.Role)
+		if role == nil {
+			// tier role was deleted, the refresh skips these as well
+			continue
+		}
+
+		roleNames[v.Role] = role.Name
+		overrides = append(overrides, v)
+	}
+
+	if len(overrides) < 1 {
+		return "The mute role doesn't exist anymore", nil
+	}
+
+	gs.RLock()
+	channels := make([]*discordgo.Channel, 0, len(gs.Channels))
+	for _, v := range gs.Channels {
+		channels = append(channels, v.DGoCopy())
+	}
+	gs.RUnlock()
+
+	sort.Slice(channels, func(i, j int) bool {
+		if channels[i].Position != channels[j].Position {
+			return channels[i].Position < channels[j].Position
+		}
+		return channels[i].ID < channels[j].ID
+	})
+
+	var lines []string
+	for _, c := range channels {
+		problems := channelMuteOverrideProblems(config, c, overrides)
+		if len(problems) < 1 {
+			continue
+		}
+
+		descs := make([]string, 0, len(problems))
+		for _, p := range problems {
+			descs = append(descs, p.describe(roleNames[p.RoleID]))
+		}
+
+		line := fmt.Sprintf("<#%d>: %s", c.ID, strings.Join(descs, "; "))
+		if !bot.BotProbablyHasPermission(guildID, c.ID, discordgo.PermissionManageRoles) {
+			line += " *(I'm missing Manage Roles here, so I can't fix it)*"
+		}
+		lines = append(lines, line)
+	}
+
+	return muteAuditReport(lines), nil
+}
+
+func muteAuditReport(lines []string) string {
+	if len(lines) < 1 {
+		return "All the mute role overrides are up to date"
+	}
+
+	header := fmt.Sprintf("Found %d channel(s) with missing or incorrect mute role overrides:\n", len(lines))
+	return common.CutStringShort(header+strings.Join(lines, "\n"), 2000)
+}
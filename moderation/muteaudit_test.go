@@ -0,0 +1,59 @@
+package moderation
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jonas747/discordgo"
+)
+
+func TestChannelMuteOverrideProblems(t *testing.T) {
+	overrides := []muteRoleOverride{{Role: 1, Denies: discordgo.PermissionSendMessages | discordgo.PermissionAddReactions}}
+
+	channel := func(allow, deny int) *discordgo.Channel {
+		return &discordgo.Channel{ID: 10, PermissionOverwrites: []*discordgo.PermissionOverwrite{
+			{ID: 1, Type: "role", Allow: allow, Deny: deny},
+		}}
+	}
+
+	config := &Config{}
+	if problems := channelMuteOverrideProblems(config, channel(0, discordgo.PermissionSendMessages|discordgo.PermissionAddReactions), overrides); len(problems) != 0 {
+		t.Errorf("expected no problems, got %+v", problems)
+	}
+
+	problems := channelMuteOverrideProblems(config, &discordgo.Channel{ID: 10}, overrides)
+	if len(problems) != 1 || !problems[0].Missing {
+		t.Errorf("expected a missing override, got %+v", problems)
+	}
+
+	problems = channelMuteOverrideProblems(config, channel(discordgo.PermissionAddReactions, discordgo.PermissionSendMessages), overrides)
+	if len(problems) != 1 || problems[0].MissingDenies != discordgo.PermissionAddReactions || problems[0].ExtraAllows != discordgo.PermissionAddReactions {
+		t.Errorf("unexpected problems %+v", problems)
+	}
+
+	// explicit allows are left alone when keeping overrides
+	config.MuteKeepOverrides = true
+	if problems := channelMuteOverrideProblems(config, channel(discordgo.PermissionAddReactions, discordgo.PermissionSendMessages), overrides); len(problems) != 0 {
+		t.Errorf("expected no problems with kept overrides, got %+v", problems)
+	}
+
+	config.MuteIgnoreChannels = []int64{10}
+	if problems := channelMuteOverrideProblems(config, &discordgo.Channel{ID: 10}, overrides); len(problems) != 0 {
+		t.Errorf("expected ignored channels to be skipped, got %+v", problems)
+	}
+}
+
+func TestMuteOverrideProblemDescribe(t *testing.T) {
+	if got := (muteOverrideProblem{Missing: true}).describe("Muted"); got != "`Muted` override is missing" {
+		t.Errorf("unexpected description %q", got)
+	}
+
+	got := muteOverrideProblem{MissingDenies: discordgo.PermissionSendMessages, ExtraAllows: discordgo.PermissionEmbedLinks}.describe("Muted")
+	if got != "`Muted` override doesn't deny SendMessages and allows EmbedLinks" {
+		t.Errorf("unexpected description %q", got)
+	}
+
+	if got := muteAuditReport(nil); !strings.Contains(got, "up to date") {
+		t.Errorf("unexpected empty report %q", got)
+	}
+}
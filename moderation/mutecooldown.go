@@ -0,0 +1,98 @@
+package moderation
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jonas747/discordgo"
+	"github.com/jonas747/yagpdb/common"
+	"github.com/mediocregopher/radix/v3"
+)
+
+// The window mutes are counted in for the mute alert if none is configured
+const defaultMuteAlertWindow = time.Hour
+
+func RedisKeyMuteCooldown(guildID, userID int64) string {
+	return "moderation_mute_cooldown:" + strconv.FormatInt(guildID, 10) + ":" + strconv.FormatInt(userID, 10)
+}
+
+func RedisKeyMuteCount(guildID, userID int64) string {
+	return "moderation_mute_count:" + strconv.FormatInt(guildID, 10) + ":" + strconv.FormatInt(userID, 10)
+}
+
+// muteCooldownLeft returns how long is left until the user can be muted again, 0 if they can be muted now
+func muteCooldownLeft(config *Config, guildID, userID int64) (time.Duration, error) {
+	if config.MuteCooldownMinutes <= 0 {
+		return 0, nil
+	}
+
+	var ms int64
+	err := common.RedisPool.Do(radix.Cmd(&ms, "PTTL", RedisKeyMuteCooldown(guildID, userID)))
+	if err != nil || ms <= 0 {
+		return 0, err
+	}
+
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+func (c *Config) muteAlertWindow() time.Duration {
+	if c.MuteAlertWindowMinutes <= 0 {
+		return defaultMuteAlertWindow
+	}
+
+	return time.Duration(c.MuteAlertWindowMinutes) * time.Minute
+}
+
+// trackMute starts the mute cooldown for the user and alerts the report channel once they've been muted
+// MuteAlertThreshold times within the alert window
+func trackMute(config *Config, guildID int64, author, target *discordgo.User) {
+	if config.MuteCooldownMinutes > 0 {
+		setMarker(RedisKeyMuteCooldown(guildID, target.ID), time.Duration(config.MuteCooldownMinutes)*time.Minute)
+	}
+
+	if config.MuteAlertThreshold <= 0 {
+		return
+	}
+
+	key := RedisKeyMuteCount(guildID, target.ID)
+
+	var count int
+	err := common.RedisPool.Do(radix.Cmd(&count, "INCR", key))
+	if err != nil {
+		logger.WithError(err).WithField("guild", guildID).Error("failed tracking mute count")
+		return
+	}
+
+	if count == 1 {
+		common.RedisPool.Do(radix.FlatCmd(nil, "PEXPIRE", key, int64(config.muteAlertWindow()/time.Millisecond)))
+	}
+
+	// only alert once per window
+	if count != config.MuteAlertThreshold {
+		return
+	}
+
+	channelID := config.IntReportChannel()
+	if channelID == 0 {
+		return
+	}
+
+	msg := fmt.Sprintf("⚠ **%s#%s** (ID %d) has been muted %d times in the last %s, latest by **%s#%s** (ID %d)",
+		target.Username, target.Discriminator, target.ID, count, common.HumanizeDuration(common.DurationPrecisionMinutes, config.muteAlertWindow()),
+		author.Username, author.Discriminator, author.ID)
+
+	allowedMentions := discordgo.AllowedMentions{}
+	if mentionRole := config.IntReportMentionRole(); mentionRole != 0 {
+		msg = fmt.Sprintf("<@&%d> ", mentionRole) + msg
+		allowedMentions.Roles = []int64{mentionRole}
+	}
+
+	_, err = session().ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+		Content:         msg,
+		AllowedMentions: allowedMentions,
+	})
+	if err != nil {
+		logger.WithError(err).WithField("guild", guildID).Error("failed sending mute alert")
+	}
+}
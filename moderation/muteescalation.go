@@ -0,0 +1,172 @@
+package moderation
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/jonas747/yagpdb/common"
+	"github.com/mediocregopher/radix/v3"
+)
+
+// Used when mute escalation is enabled without any steps set up
+const DefaultMuteEscalationSteps = "10m, 1h, 1d"
+
+// Mutes older than this are forgotten if no window is configured
+const defaultMuteEscalationWindow = time.Hour * 24 * 30
+
+func RedisKeyMuteEscalation(guildID, userID int64) string {
+	return "moderation_mute_escalation:" + strconv.FormatInt(guildID, 10) + ":" + strconv.FormatInt(userID, 10)
+}
+
+// MuteEscalationDurations parses the escalation steps, the first one is used for the first mute, the second for the second and so on
+func (c *Config) MuteEscalationDurations() ([]time.Duration, error) {
+	steps := c.MuteEscalationSteps
+	if strings.TrimSpace(steps) == "" {
+		steps = DefaultMuteEscalationSteps
+	}
+
+	var result []time.Duration
+	for _, v := range strings.Split(steps, ",") {
+		d, err := common.ParseDuration(strings.TrimSpace(v))
+		if err != nil {
+			return nil, errors.WithMessage(err, v)
+		}
+
+		if d < time.Minute {
+			return nil, errors.New("mute escalation steps have to be at least 1 minute: " + v)
+		}
+
+		result = append(result, d)
+	}
+
+	return result, nil
+}
+
+func (c *Config) muteEscalationWindow() time.Duration {
+	if c.MuteEscalationWindow <= 0 {
+		return defaultMuteEscalationWindow
+	}
+
+	return time.Duration(c.MuteEscalationWindow) * time.Hour * 24
+}
+
+// escalatedMuteDuration returns the duration in minutes to use for a mute given the number of previous mutes,
+// the longer of the escalation step and the picked duration is used, permanent mutes stay permanent
+func escalatedMuteDuration(steps []time.Duration, previousMutes int, duration int) (newDuration int, escalated bool) {
+	if duration <= 0 || len(steps) < 1 {
+		return duration, false
+	}
+
+	step := previousMutes
+	if step >= len(steps) {
+		step = len(steps) - 1
+	}
+
+	stepDuration := int(steps[step].Minutes())
+	if stepDuration > duration {
+		return stepDuration, true
+	}
+
+	return duration, false
+}
+
+// previousMuteCount returns the number of times the user has been muted within the escalation window
+func previousMuteCount(guildID, userID int64) (int, error) {
+	var count int
+	err := common.RedisPool.Do(radix.Cmd(&radix.MaybeNil{Rcv: &count}, "GET", RedisKeyMuteEscalation(guildID, userID)))
+	return count, err
+}
+
+// recordMuteEscalation counts the mute towards escalation, the window restarts with every mute
+func recordMuteEscalation(config *Config, guildID, userID int64) {
+	key := RedisKeyMuteEscalation(guildID, userID)
+	err := common.RedisPool.Do(radix.Pipeline(
+		radix.Cmd(nil, "INCR", key),
+		radix.FlatCmd(nil, "PEXPIRE", key, int64(config.muteEscalationWindow()/time.Millisecond)),
+	))
+	if err != nil {
+		logger.WithError(err).WithField("guild", guildID).Error("failed recording mute escalation")
+	}
+}
+
+// The mutes of a user are also kept with their durations for the auto-kick threshold, which counts the time of all
+// the mutes within the escalation window. Like the escalation count they're forgotten once the user goes the window
+// without a mute. Each is stored as "<mute id>:<minutes>" scored by when it was given.
+
+func RedisKeyMuteDurations(guildID, userID int64) string {
+	return "moderation_mute_durations:" + strconv.FormatInt(guildID, 10) + ":" + strconv.FormatInt(userID, 10)
+}
+
+// recordMuteDuration remembers the new mute for the auto-kick threshold, the window restarts with every mute
+func recordMuteDuration(config *Config, guildID, userID int64, mute *MuteModel, duration int) {
+	key := RedisKeyMuteDurations(guildID, userID)
+	err := common.RedisPool.Do(radix.Pipeline(
+		radix.FlatCmd(nil, "ZADD", key, time.Now().Unix(), strconv.FormatInt(mute.ID, 10)+":"+strconv.Itoa(duration)),
+		radix.FlatCmd(nil, "PEXPIRE", key, int64(config.muteEscalationWindow()/time.Millisecond)),
+	))
+	if err != nil {
+		logger.WithError(err).WithField("guild", guildID).Error("failed recording mute duration")
+	}
+}
+
+// endMuteDuration replaces the duration the mute was given for with how long it actually lasted, once it's removed
+func endMuteDuration(guildID, userID int64, mute *MuteModel) {
+	key := RedisKeyMuteDurations(guildID, userID)
+	prefix := strconv.FormatInt(mute.ID, 10) + ":"
+
+	var members []string
+	err := common.RedisPool.Do(radix.Cmd(&members, "ZRANGE", key, "0", "-1", "WITHSCORES"))
+	if err != nil {
+		logger.WithError(err).WithField("guild", guildID).Error("failed retrieving mute durations")
+		return
+	}
+
+	for i := 0; i+1 < len(members); i += 2 {
+		if !strings.HasPrefix(members[i], prefix) {
+			continue
+		}
+
+		lasted := int(time.Since(mute.CreatedAt).Minutes())
+		err = common.RedisPool.Do(radix.Pipeline(
+			radix.Cmd(nil, "ZREM", key, members[i]),
+			radix.Cmd(nil, "ZADD", key, members[i+1], prefix+strconv.Itoa(lasted)),
+		))
+		common.LogIgnoreError(err, "[moderation] failed updating mute duration", nil)
+		return
+	}
+}
+
+// previousMutesDuration returns the total time of the user's mutes within the escalation window, other than the
+// mute with the id. Permanent mutes that were removed count for as long as they lasted.
+func previousMutesDuration(guildID, userID, excludeMuteID int64) (time.Duration, error) {
+	var members []string
+	err := common.RedisPool.Do(radix.Cmd(&members, "ZRANGE", RedisKeyMuteDurations(guildID, userID), "0", "-1"))
+	if err != nil {
+		return 0, err
+	}
+
+	return sumMuteDurations(members, excludeMuteID), nil
+}
+
+// sumMuteDurations adds up the stored "<mute id>:<minutes>" durations, leaving out the mute with the id
+func sumMuteDurations(members []string, excludeMuteID int64) time.Duration {
+	var total time.Duration
+	for _, v := range members {
+		split := strings.SplitN(v, ":", 2)
+		if len(split) != 2 {
+			continue
+		}
+
+		id, _ := strconv.ParseInt(split[0], 10, 64)
+		minutes, _ := strconv.Atoi(split[1])
+		if id == excludeMuteID {
+			continue
+		}
+
+		total += time.Duration(minutes) * time.Minute
+	}
+
+	return total
+}
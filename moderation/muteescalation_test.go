@@ -0,0 +1,71 @@
+package moderation
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEscalatedMuteDuration(t *testing.T) {
+	steps := []time.Duration{10 * time.Minute, time.Hour, 24 * time.Hour}
+
+	cases := []struct {
+		previous  int
+		duration  int
+		want      int
+		escalated bool
+	}{
+		{0, 5, 10, true},
+		{0, 30, 30, false},
+		{1, 10, 60, true},
+		{2, 10, 1440, true},
+		{5, 10, 1440, true},
+		{3, 0, 0, false},
+	}
+
+	for _, c := range cases {
+		got, escalated := escalatedMuteDuration(steps, c.previous, c.duration)
+		if got != c.want || escalated != c.escalated {
+			t.Errorf("escalatedMuteDuration(%d, %d): got %d %t, expected %d %t", c.previous, c.duration, got, escalated, c.want, c.escalated)
+		}
+	}
+}
+
+func TestMuteEscalationDurations(t *testing.T) {
+	steps, err := (&Config{}).MuteEscalationDurations()
+	if err != nil || len(steps) != 3 || steps[2] != 24*time.Hour {
+		t.Errorf("unexpected default steps: %v %v", steps, err)
+	}
+
+	if _, err := (&Config{MuteEscalationSteps: "10m, 30s"}).MuteEscalationDurations(); err == nil {
+		t.Error("expected an error for a step under a minute")
+	}
+}
+
+func TestSumMuteDurations(t *testing.T) {
+	got := sumMuteDurations([]string{"1:30", "2:60", "3:15", "invalid"}, 2)
+	if got != 45*time.Minute {
+		t.Errorf("expected 45 minutes, got %s", got)
+	}
+}
+
+func TestMutePastAutoKickThreshold(t *testing.T) {
+	config := &Config{MuteAutoKickEnabled: true, MuteAutoKickThreshold: 60}
+	mute := &MuteModel{}
+
+	if mutePastAutoKickThreshold(config, mute, false, 30, 0) {
+		t.Error("expected a single short mute to stay under the threshold")
+	}
+
+	if !mutePastAutoKickThreshold(config, mute, false, 30, 45*time.Minute) {
+		t.Error("expected the earlier mutes to count towards the threshold")
+	}
+
+	if !mutePastAutoKickThreshold(config, mute, false, 0, 0) {
+		t.Error("expected permanent mutes to exceed the threshold")
+	}
+
+	config.MuteAutoKickEnabled = false
+	if mutePastAutoKickThreshold(config, mute, false, 30, 45*time.Minute) {
+		t.Error("expected nothing when auto-kick is disabled")
+	}
+}
@@ -0,0 +1,134 @@
+package moderation
+
+import (
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/jinzhu/gorm"
+	"github.com/jonas747/yagpdb/bot"
+	"github.com/jonas747/yagpdb/bot/eventsystem"
+	"github.com/jonas747/yagpdb/common"
+)
+
+// The mute role can be stripped in ways that don't show up as a single member update we handle in time, e.g. bulk role
+// syncs from other bots or role changes while the bot was disconnected. Members are checked again when their roles
+// are synced through member chunks, always against the mute row so manual unmutes aren't fought.
+
+// Mutes this close to expiring are left alone
+const muteExpiryGrace = time.Second * 5
+
+// muteStillActive returns true if the mute isn't up or about to be
+func muteStillActive(mute *MuteModel, now time.Time) bool {
+	return mute.ExpiresAt.IsZero() || mute.ExpiresAt.Sub(now) >= muteExpiryGrace
+}
+
+// muteRoleStripped returns true if the member is still actively muted but doesn't have the mute role they were muted with
+func muteRoleStripped(config *Config, mute *MuteModel, roles []int64, now time.Time) bool {
+	if !muteStillActive(mute, now) {
+		return false
+	}
+
+	return !common.ContainsInt64Slice(roles, config.muteRoleFor(mute))
+}
+
+// recordRemovedRoles appends roles removed while muted to the mute, so they're given back when it ends
+func recordRemovedRoles(guildID, userID int64, removedRoles []int64) error {
+	tx, err := common.PQ.Begin()
+	if err != nil {
+		return errors.WithStackIf(err)
+	}
+
+	// Append the removed roles to the removed_roles array column, if they don't already exist in it
+	const queryStr = "UPDATE muted_users SET removed_roles = array_append(removed_roles, $3 ) WHERE user_id=$2 AND guild_id=$1 AND NOT ($3 = ANY(removed_roles));"
+	for _, v := range removedRoles {
+		_, err := tx.Exec(queryStr, guildID, userID, v)
+		if err != nil {
+			tx.Rollback()
+			return errors.WithStackIf(err)
+		}
+	}
+
+	return errors.WithStackIf(tx.Commit())
+}
+
+// reapplyMuteRole gives the member their mute role back if they're still muted, the mute is checked again
+// under the mute lock since a moderator could have unmuted them in the meantime
+func reapplyMuteRole(config *Config, guildID, userID int64) error {
+	LockMute(userID)
+	defer UnlockMute(userID)
+
+	var mute MuteModel
+	err := common.GORM.Where(MuteModel{UserID: userID, GuildID: guildID}).First(&mute).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil
+		}
+		return errors.WithStackIf(err)
+	}
+
+	ms, err := bot.GetMember(guildID, userID)
+	if err != nil || ms == nil {
+		// left, they get the role back when rejoining
+		return nil
+	}
+
+	if !muteRoleStripped(config, &mute, ms.Roles, time.Now()) {
+		return nil
+	}
+
+	logger.WithField("guild", guildID).WithField("user", userID).Info("reapplying stripped mute role")
+
+	removedRoles, err := addMemberMuteRole(config, userID, ms.Roles, config.muteRoleFor(&mute), 0)
+	if err != nil {
+		return err
+	}
+
+	if len(removedRoles) < 1 {
+		return nil
+	}
+
+	return recordRemovedRoles(guildID, userID, removedRoles)
+}
+
+// HandleMembersChunkReapplyMute checks the members in the chunk for stripped mute roles
+func HandleMembersChunkReapplyMute(evt *eventsystem.EventData) (retry bool, err error) {
+	chunk := evt.GuildMembersChunk()
+
+	config, err := GetConfig(chunk.GuildID)
+	if err != nil {
+		return true, errors.WithStackIf(err)
+	}
+	if config.MuteRole == "" {
+		return false, nil
+	}
+
+	var mutes []*MuteModel
+	err = common.GORM.Where("guild_id = ?", chunk.GuildID).Find(&mutes).Error
+	if err != nil {
+		return true, errors.WithStackIf(err)
+	}
+
+	if len(mutes) < 1 {
+		return false, nil
+	}
+
+	byUser := make(map[int64]*MuteModel, len(mutes))
+	for _, v := range mutes {
+		byUser[v.UserID] = v
+	}
+
+	now := time.Now()
+	for _, m := range chunk.Members {
+		mute, ok := byUser[m.User.ID]
+		if !ok || !muteRoleStripped(config, mute, m.Roles, now) {
+			continue
+		}
+
+		err = reapplyMuteRole(config, chunk.GuildID, m.User.ID)
+		if err != nil {
+			logger.WithError(err).WithField("guild", chunk.GuildID).WithField("user", m.User.ID).Error("failed reapplying mute role")
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,33 @@
+package moderation
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMuteRoleStripped(t *testing.T) {
+	config := testMuteConfig()
+	now := time.Now()
+
+	cases := []struct {
+		name  string
+		mute  *MuteModel
+		roles []int64
+		want  bool
+	}{
+		{"still has the mute role", &MuteModel{}, []int64{10, 30}, false},
+		{"bulk role sync stripped everything", &MuteModel{}, nil, true},
+		{"only the mute role stripped", &MuteModel{}, []int64{30}, true},
+		{"timed mute still running", &MuteModel{ExpiresAt: now.Add(time.Hour)}, []int64{30}, true},
+		{"mute about to expire", &MuteModel{ExpiresAt: now.Add(time.Second)}, []int64{30}, false},
+		{"mute already expired", &MuteModel{ExpiresAt: now.Add(-time.Minute)}, []int64{30}, false},
+		{"tier role stripped", &MuteModel{MuteRole: 11}, []int64{10}, true},
+		{"still has the tier role", &MuteModel{MuteRole: 11}, []int64{11}, false},
+	}
+
+	for _, c := range cases {
+		if got := muteRoleStripped(config, c.mute, c.roles, now); got != c.want {
+			t.Errorf("%s: got %t, expected %t", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package moderation
+
+import (
+	"strings"
+
+	"github.com/jonas747/discordgo"
+	"github.com/jonas747/yagpdb/common"
+)
+
+// Mute tiers are additional named mute roles that can be picked with the -role switch on the mute command,
+// for example a "media" mute that only takes away the ability to post attachments and embeds.
+// They're stored as parallel arrays in the config, MuteTiers zips them together.
+
+const (
+	MaxMuteTiers = 5
+
+	MuteTierKindFull  = "full"
+	MuteTierKindMedia = "media"
+)
+
+// Permissions denied in the channel overrides of media mute tiers
+const MuteTierMediaDeniedPerms = discordgo.PermissionAttachFiles | discordgo.PermissionEmbedLinks
+
+type MuteTier struct {
+	Name string
+	Role int64
+	Kind string
+}
+
+// MuteTiers returns the configured mute tiers, skipping incomplete ones
+func (c *Config) MuteTiers() []*MuteTier {
+	result := make([]*MuteTier, 0, len(c.MuteTierNames))
+	for i, name := range c.MuteTierNames {
+		name = strings.TrimSpace(name)
+		if name == "" || i >= len(c.MuteTierRoles) || c.MuteTierRoles[i] == 0 {
+			continue
+		}
+
+		kind := MuteTierKindFull
+		if i < len(c.MuteTierKinds) && c.MuteTierKinds[i] == MuteTierKindMedia {
+			kind = MuteTierKindMedia
+		}
+
+		result = append(result, &MuteTier{Name: name, Role: c.MuteTierRoles[i], Kind: kind})
+	}
+
+	return result
+}
+
+// FindMuteTier returns the mute tier with the name, or nil if there's none
+func (c *Config) FindMuteTier(name string) *MuteTier {
+	for _, v := range c.MuteTiers() {
+		if strings.EqualFold(v.Name, strings.TrimSpace(name)) {
+			return v
+		}
+	}
+
+	return nil
+}
+
+// MuteTierNamesList returns the names of the mute tiers, for showing to users
+func (c *Config) MuteTierNamesList() string {
+	tiers := c.MuteTiers()
+	names := make([]string, 0, len(tiers))
+	for _, v := range tiers {
+		names = append(names, "`"+v.Name+"`")
+	}
+
+	return strings.Join(names, ", ")
+}
+
+// MuteTierRows returns the mute tiers padded with empty ones up to MaxMuteTiers, used for the control panel form
+func (c *Config) MuteTierRows() []*MuteTier {
+	rows := c.MuteTiers()
+	for len(rows) < MaxMuteTiers {
+		rows = append(rows, &MuteTier{Kind: MuteTierKindFull})
+	}
+
+	return rows
+}
+
+// compactMuteTiers removes incomplete tiers so that the arrays stay aligned
+func (c *Config) compactMuteTiers() {
+	tiers := c.MuteTiers()
+
+	c.MuteTierNames = make([]string, 0, len(tiers))
+	c.MuteTierRoles = make([]int64, 0, len(tiers))
+	c.MuteTierKinds = make([]string, 0, len(tiers))
+	for _, v := range tiers {
+		c.MuteTierNames = append(c.MuteTierNames, v.Name)
+		c.MuteTierRoles = append(c.MuteTierRoles, v.Role)
+		c.MuteTierKinds = append(c.MuteTierKinds, v.Kind)
+	}
+}
+
+// muteRoleFor returns the mute role a mute uses, mutes without a tier use the default mute role
+func (c *Config) muteRoleFor(mute *MuteModel) int64 {
+	if mute != nil && mute.MuteRole != 0 {
+		return mute.MuteRole
+	}
+
+	return c.IntMuteRole()
+}
+
+// userMuteRole returns the mute role the user is currently muted with
+func userMuteRole(config *Config, guildID, userID int64) int64 {
+	var mute MuteModel
+	err := common.GORM.Where(&MuteModel{UserID: userID, GuildID: guildID}).First(&mute).Error
+	if err != nil {
+		return config.IntMuteRole()
+	}
+
+	return config.muteRoleFor(&mute)
+}
+
+type muteRoleOverride struct {
+	Role   int64
+	Denies int
+}
+
+// managedMuteOverrides returns the mute roles and the permissions they should have denied in every channel
+func (c *Config) managedMuteOverrides() []muteRoleOverride {
+	fullDenies := MuteDeniedChannelPerms
+	if c.MuteDisallowReactionAdd {
+		fullDenies |= discordgo.PermissionAddReactions
+	}
+	if c.MuteDenyVoiceConnect {
+		fullDenies |= discordgo.PermissionVoiceConnect
+	}
+
+	result := []muteRoleOverride{{Role: c.IntMuteRole(), Denies: fullDenies}}
+	for _, v := range c.MuteTiers() {
+		denies := fullDenies
+		if v.Kind == MuteTierKindMedia {
+			denies = MuteTierMediaDeniedPerms
+		}
+
+		result = append(result, muteRoleOverride{Role: v.Role, Denies: denies})
+	}
+
+	return result
+}
@@ -0,0 +1,61 @@
+package moderation
+
+import (
+	"testing"
+
+	"github.com/jonas747/discordgo"
+	"github.com/lib/pq"
+)
+
+func TestMuteTiers(t *testing.T) {
+	config := &Config{
+		MuteTierNames: pq.StringArray{"media", "", "full", "norole"},
+		MuteTierRoles: pq.Int64Array{30, 31, 32, 0},
+		MuteTierKinds: pq.StringArray{"media", "full", "bogus", "full"},
+	}
+
+	tiers := config.MuteTiers()
+	if len(tiers) != 2 {
+		t.Fatalf("expected 2 tiers, got %d", len(tiers))
+	}
+
+	if tiers[0].Name != "media" || tiers[0].Role != 30 || tiers[0].Kind != MuteTierKindMedia {
+		t.Errorf("unexpected first tier: %#v", tiers[0])
+	}
+
+	if tiers[1].Kind != MuteTierKindFull {
+		t.Errorf("unknown kind should fall back to full, got %q", tiers[1].Kind)
+	}
+
+	if tier := config.FindMuteTier("MEDIA"); tier == nil || tier.Role != 30 {
+		t.Errorf("FindMuteTier didn't find the tier case insensitively: %#v", tier)
+	}
+
+	config.compactMuteTiers()
+	if len(config.MuteTierNames) != 2 || len(config.MuteTierRoles) != 2 || len(config.MuteTierKinds) != 2 || config.MuteTierRoles[1] != 32 {
+		t.Errorf("arrays not compacted properly: %v %v %v", config.MuteTierNames, config.MuteTierRoles, config.MuteTierKinds)
+	}
+}
+
+func TestMuteRoleFor(t *testing.T) {
+	config := testMuteConfig()
+	if r := config.muteRoleFor(&MuteModel{}); r != 10 {
+		t.Errorf("mute without a tier should use the default role, got %d", r)
+	}
+
+	if r := config.muteRoleFor(&MuteModel{MuteRole: 30}); r != 30 {
+		t.Errorf("expected the tier role, got %d", r)
+	}
+}
+
+func TestManagedMuteOverridesVoiceConnect(t *testing.T) {
+	config := &Config{MuteRole: "10"}
+	if denies := config.managedMuteOverrides()[0].Denies; denies&discordgo.PermissionVoiceConnect != 0 {
+		t.Errorf("voice connect denied without the option: %d", denies)
+	}
+
+	config.MuteDenyVoiceConnect = true
+	if denies := config.managedMuteOverrides()[0].Denies; denies != MuteDeniedChannelPerms|discordgo.PermissionVoiceConnect {
+		t.Errorf("expected voice connect to be denied as well, got %d", denies)
+	}
+}
@@ -0,0 +1,202 @@
+package moderation
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/jonas747/discordgo"
+	"github.com/jonas747/dstate"
+	"github.com/jonas747/yagpdb/bot"
+	"github.com/jonas747/yagpdb/common"
+)
+
+// During scam link raids staff can ban or mute everyone that posted messages matching a pattern,
+// the messages are scanned the same way the clean command does.
+
+const (
+	// Messages scanned when only scanning a single channel
+	PatternScanChannelMessages = 1000
+	// Messages scanned per channel when scanning the whole server, and the max number of channels scanned
+	PatternScanGuildMessages    = 100
+	PatternScanGuildMaxChannels = 25
+
+	// Number of matching messages shown when asking for confirmation
+	patternSampleSize = 3
+)
+
+// patternMatch is an author that posted messages matching the pattern
+type patternMatch struct {
+	Author *discordgo.User
+	Sample string
+}
+
+// matchingAuthors returns the authors of the messages matching the regex, in the order of their most recent match
+func matchingAuthors(msgs []*dstate.MessageState, re *regexp.Regexp, seen map[int64]bool) []*patternMatch {
+	var result []*patternMatch
+	for _, msg := range msgs {
+		if msg.Author == nil || seen[msg.Author.ID] || !re.MatchString(msg.Content) {
+			continue
+		}
+
+		seen[msg.Author.ID] = true
+		result = append(result, &patternMatch{Author: msg.Author, Sample: msg.Content})
+	}
+
+	return result
+}
+
+// patternScanChannels returns the channels to scan, either just the one or the text channels of the server
+func patternScanChannels(gs *dstate.GuildState, channelID int64, guildWide bool) []int64 {
+	if !guildWide {
+		return []int64{channelID}
+	}
+
+	gs.RLock()
+	defer gs.RUnlock()
+
+	channels := make([]*dstate.ChannelState, 0, len(gs.Channels))
+	for _, c := range gs.Channels {
+		if c.Type == discordgo.ChannelTypeGuildText || c.Type == discordgo.ChannelTypeGuildNews {
+			channels = append(channels, c)
+		}
+	}
+
+	// the ones with the most recent activity are the most likely to have been hit
+	sort.Slice(channels, func(i, j int) bool {
+		return channels[i].LastMessageID > channels[j].LastMessageID
+	})
+
+	result := make([]int64, 0, PatternScanGuildMaxChannels)
+	for i := 0; i < len(channels) && i < PatternScanGuildMaxChannels; i++ {
+		result = append(result, channels[i].ID)
+	}
+
+	return result
+}
+
+// scanPatternAuthors scans the channels for messages matching the regex, returning the matching authors and the number of messages scanned
+func scanPatternAuthors(channels []int64, re *regexp.Regexp, perChannel int) (matches []*patternMatch, scanned int, err error) {
+	seen := make(map[int64]bool)
+	for i, channelID := range channels {
+		msgs, _, err := getMessagesWithRetry(channelID, perChannel)
+		if err != nil {
+			if len(channels) > 1 && common.IsDiscordErr(err, discordgo.ErrCodeMissingAccess, discordgo.ErrCodeMissingPermissions) {
+				continue
+			}
+			return nil, scanned, err
+		}
+
+		scanned += len(msgs)
+		matches = append(matches, matchingAuthors(msgs, re, seen)...)
+
+		if i < len(channels)-1 {
+			time.Sleep(massActionDelay)
+		}
+	}
+
+	return matches, scanned, nil
+}
+
+// patternTargets filters out the authors that can't be acted on, authors no longer in the server can only be banned
+func patternTargets(gs *dstate.GuildState, author *dstate.MemberState, matches []*patternMatch, staffRoles []int64, ban bool) (targets []*patternMatch, skipped int) {
+	gs.RLock()
+	defer gs.RUnlock()
+
+	for _, m := range matches {
+		if m.Author.Bot || m.Author.ID == author.ID {
+			skipped++
+			continue
+		}
+
+		ms := gs.Member(false, m.Author.ID)
+		if ms == nil || !ms.MemberSet {
+			if ban {
+				targets = append(targets, m)
+			} else {
+				skipped++
+			}
+			continue
+		}
+
+		if !massActionTarget(gs, author, ms, staffRoles) {
+			skipped++
+			continue
+		}
+
+		targets = append(targets, m)
+	}
+
+	return
+}
+
+// patternConfirmation describes what's about to happen, with a few of the matching messages
+func patternConfirmation(verb string, targets []*patternMatch, skipped, scanned int) string {
+	msg := fmt.Sprintf("Found **%d** author(s) in the last %d messages, this will %s them (skipping %d staff/higher ranked/bot author(s)). Some of the matching messages:\n", len(targets), scanned, verb, skipped)
+	for i := 0; i < len(targets) && i < patternSampleSize; i++ {
+		sample := common.CutStringShort(targets[i].Sample, 100)
+		msg += fmt.Sprintf("`%s#%s`: `%s`\n", targets[i].Author.Username, targets[i].Author.Discriminator, SanitizeReason(sample))
+	}
+
+	return msg + "Run the command again with `-confirm` to proceed."
+}
+
+// runPatternAction bans or mutes the targets one by one, editing the progress message as it goes and creating a single modlog entry at the end
+func runPatternAction(config *Config, gs *dstate.GuildState, channel *dstate.ChannelState, author *discordgo.User, ban bool, targets []*patternMatch, duration time.Duration, reason string, progress *discordgo.Message) {
+	// Don't create a modlog entry per member, we create a single one when done
+	actionConfig := *config
+	actionConfig.SuppressMemberEntries = true
+
+	action := MAMute
+	verb := "Muting"
+	if ban {
+		action = MABanned
+		verb = "Banning"
+	}
+
+	done := 0
+	failed := 0
+	for i, target := range targets {
+		member, err := bot.GetMember(gs.ID, target.Author.ID)
+		if ban {
+			if member == nil {
+				// not in the server anymore
+				err = BanUserWithDuration(&actionConfig, gs.ID, channel, nil, author, reason, target.Author, duration, 1)
+			} else {
+				err = BanUserWithDuration(&actionConfig, gs.ID, channel, nil, author, reason, member.DGoUser(), duration, 1)
+			}
+		} else if err == nil && member != nil {
+			err = MuteUnmuteUser(&actionConfig, true, gs.ID, channel, nil, author, reason, member, int(duration.Minutes()))
+		}
+
+		if err != nil || (!ban && member == nil) {
+			logger.WithError(err).WithField("guild", gs.ID).WithField("user", target.Author.ID).Error("failed acting on pattern match")
+			failed++
+		} else {
+			done++
+		}
+
+		if progress != nil && (i+1)%10 == 0 {
+			session().ChannelMessageEdit(progress.ChannelID, progress.ID, fmt.Sprintf("%s matching authors... (%d/%d)", verb, i+1, len(targets)))
+		}
+
+		time.Sleep(massActionDelay)
+	}
+
+	if progress != nil {
+		session().ChannelMessageEdit(progress.ChannelID, progress.ID, fmt.Sprintf("%s %s %d matching author(s), %d failed.", action.Emoji, action.Prefix, done, failed))
+	}
+
+	action.Footer = "Duration: "
+	if duration > 0 {
+		action.Footer += common.HumanizeDuration(common.DurationPrecisionMinutes, duration)
+	} else {
+		action.Footer += "permanent"
+	}
+
+	err := CreateMassModlogEmbed(config, author, action, fmt.Sprintf("%s %d author(s) of messages matching a pattern", action.Prefix, done), reason)
+	if err != nil {
+		logger.WithError(err).WithField("guild", gs.ID).Error("failed creating pattern action modlog entry")
+	}
+}
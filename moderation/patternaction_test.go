@@ -0,0 +1,34 @@
+package moderation
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/jonas747/dstate"
+)
+
+func TestMatchingAuthors(t *testing.T) {
+	re := regexp.MustCompile(`discord-gift\.`)
+	msgs := []*dstate.MessageState{
+		testMessage(4, 1, "free nitro discord-gift.example", time.Minute),
+		testMessage(3, 2, "hello", time.Minute),
+		testMessage(2, 1, "discord-gift.example again", time.Minute),
+		testMessage(1, 3, "https://discord-gift.example", time.Minute),
+	}
+
+	seen := make(map[int64]bool)
+	matches := matchingAuthors(msgs, re, seen)
+	if len(matches) != 2 || matches[0].Author.ID != 1 || matches[1].Author.ID != 3 {
+		t.Fatalf("unexpected matches: %+v", matches)
+	}
+
+	if matches[0].Sample != "free nitro discord-gift.example" {
+		t.Errorf("expected the most recent match as the sample, got %q", matches[0].Sample)
+	}
+
+	// authors already matched in another channel aren't returned again
+	if again := matchingAuthors(msgs, re, seen); len(again) != 0 {
+		t.Errorf("expected no new matches, got %d", len(again))
+	}
+}
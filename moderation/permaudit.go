@@ -0,0 +1,65 @@
+package moderation
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jonas747/discordgo"
+	"github.com/jonas747/dstate"
+	"github.com/jonas747/yagpdb/common"
+)
+
+// modCmdAccess is how access to a moderation command is checked, mirrors what the command passes to MBaseCmdSecond
+type modCmdAccess struct {
+	Name       string
+	Enabled    bool
+	Perm       int
+	Roles      []int64
+	RequireAll bool
+}
+
+func modCmdAccessList(config *Config) []*modCmdAccess {
+	return []*modCmdAccess{
+		{"Ban", config.BanEnabled, discordgo.PermissionBanMembers, config.BanCmdRoles, config.BanRequireAllRoles},
+		{"Kick", config.KickEnabled, discordgo.PermissionKickMembers, config.KickCmdRoles, config.KickRequireAllRoles},
+		{"Mute/Unmute", config.MuteEnabled, discordgo.PermissionKickMembers, config.MuteCmdRoles, config.MuteRequireAllRoles},
+		{"Timeout", config.TimeoutEnabled, discordgo.PermissionKickMembers, config.TimeoutCmdRoles, config.TimeoutRequireAllRoles},
+		{"Warn", config.WarnCommandsEnabled, discordgo.PermissionManageMessages, config.WarnCmdRoles, config.WarnRequireAllRoles},
+		{"Clean", config.CleanEnabled, discordgo.PermissionManageMessages, nil, false},
+		{"Report", config.ReportEnabled, 0, nil, false},
+		{"GiveRole/RemoveRole", config.GiveRoleCmdEnabled, discordgo.PermissionManageRoles, config.GiveRoleCmdRoles, config.GiveRoleRequireAllRoles},
+		{"Reason/LogAction", true, discordgo.PermissionKickMembers, nil, false},
+		{"Server settings (ExportModlog, TrustLevel...)", true, discordgo.PermissionManageServer, nil, false},
+	}
+}
+
+// describeModPerms lists which moderation commands the member can run in the channel and why
+func describeModPerms(config *Config, channelID int64, ms *dstate.MemberState) (string, error) {
+	var b strings.Builder
+	for _, cmd := range modCmdAccessList(config) {
+		if !cmd.Enabled {
+			fmt.Fprintf(&b, "❌ %s: disabled on this server\n", cmd.Name)
+			continue
+		}
+
+		met, viaRoles, err := cmdPermsMet(channelID, ms, cmd.Perm, cmd.Roles, cmd.RequireAll)
+		if err != nil {
+			return "", err
+		}
+
+		switch {
+		case met && viaRoles:
+			fmt.Fprintf(&b, "✅ %s: has the command roles\n", cmd.Name)
+		case met && cmd.Perm == 0:
+			fmt.Fprintf(&b, "✅ %s: available to everyone\n", cmd.Name)
+		case met:
+			fmt.Fprintf(&b, "✅ %s: has the %s permission\n", cmd.Name, common.StringPerms[cmd.Perm])
+		case len(cmd.Roles) > 0:
+			fmt.Fprintf(&b, "❌ %s: missing the %s permission and the command roles\n", cmd.Name, common.StringPerms[cmd.Perm])
+		default:
+			fmt.Fprintf(&b, "❌ %s: missing the %s permission\n", cmd.Name, common.StringPerms[cmd.Perm])
+		}
+	}
+
+	return b.String(), nil
+}
@@ -1,6 +1,7 @@
 package moderation
 
 import (
+	"fmt"
 	"strconv"
 	"strings"
 	"time"
@@ -17,7 +18,6 @@ import (
 	"github.com/jonas747/yagpdb/common/pubsub"
 	"github.com/jonas747/yagpdb/common/scheduledevents2"
 	seventsmodels "github.com/jonas747/yagpdb/common/scheduledevents2/models"
-	"github.com/mediocregopher/radix/v3"
 )
 
 var (
@@ -43,20 +43,34 @@ func (p *Plugin) AddCommands() {
 func (p *Plugin) BotInit() {
 	// scheduledevents.RegisterEventHandler("unmute", handleUnMuteLegacy)
 	// scheduledevents.RegisterEventHandler("mod_unban", handleUnbanLegacy)
-	scheduledevents2.RegisterHandler("moderation_unmute", ScheduledUnmuteData{}, handleScheduledUnmute)
-	scheduledevents2.RegisterHandler("moderation_unban", ScheduledUnbanData{}, handleScheduledUnban)
+	scheduledevents2.RegisterHandler("moderation_unmute", ScheduledUnmuteData{}, throttledExpiry(handleScheduledUnmute))
+	scheduledevents2.RegisterHandler("moderation_unban", ScheduledUnbanData{}, throttledExpiry(handleScheduledUnban))
+	scheduledevents2.RegisterHandler("moderation_purge_reports", nil, handleScheduledPurgeReports)
+	scheduledevents2.RegisterHandler("moderation_auto_clean", nil, handleScheduledAutoClean)
+	scheduledevents2.RegisterHandler("moderation_unsnooze_report", ScheduledUnsnoozeReportData{}, handleScheduledUnsnoozeReport)
+	scheduledevents2.RegisterHandler("moderation_raid_mode_end", ScheduledRaidModeEndData{}, handleScheduledRaidModeEnd)
+	scheduledevents2.RegisterHandler("moderation_archive_mutes", nil, handleScheduledArchiveMutes)
+	scheduledevents2.RegisterHandler("moderation_warn_review", ScheduledWarnReviewData{}, handleScheduledWarnReview)
+	scheduledevents2.RegisterHandler("moderation_shadow_unmute", ScheduledUnmuteData{}, throttledExpiry(handleScheduledShadowUnmute))
 	scheduledevents2.RegisterLegacyMigrater("unmute", handleMigrateScheduledUnmute)
 	scheduledevents2.RegisterLegacyMigrater("mod_unban", handleMigrateScheduledUnban)
 
 	eventsystem.AddHandlerAsyncLastLegacy(p, bot.ConcurrentEventHandler(HandleGuildBanAddRemove), eventsystem.EventGuildBanAdd, eventsystem.EventGuildBanRemove)
 	eventsystem.AddHandlerAsyncLast(p, HandleGuildMemberRemove, eventsystem.EventGuildMemberRemove)
 	eventsystem.AddHandlerAsyncLast(p, LockMemberMuteMW(HandleMemberJoin), eventsystem.EventGuildMemberAdd)
+	eventsystem.AddHandlerAsyncLast(p, HandleMemberJoinBanEvasion, eventsystem.EventGuildMemberAdd)
+	eventsystem.AddHandlerAsyncLast(p, HandleMemberJoinPostBanRole, eventsystem.EventGuildMemberAdd)
+	eventsystem.AddHandlerAsyncLast(p, HandleMemberJoinRaidMode, eventsystem.EventGuildMemberAdd)
 	eventsystem.AddHandlerAsyncLast(p, LockMemberMuteMW(HandleGuildMemberUpdate), eventsystem.EventGuildMemberUpdate)
+	eventsystem.AddHandlerAsyncLast(p, HandleMembersChunkReapplyMute, eventsystem.EventGuildMembersChunk)
 
 	eventsystem.AddHandlerAsyncLastLegacy(p, bot.ConcurrentEventHandler(HandleGuildCreate), eventsystem.EventGuildCreate)
 	eventsystem.AddHandlerAsyncLast(p, HandleChannelCreateUpdate, eventsystem.EventChannelCreate, eventsystem.EventChannelUpdate)
+	eventsystem.AddHandlerAsyncLast(p, HandleDMAutoResponse, eventsystem.EventMessageCreate)
+	commands.MessageRewriteFuncs = append(commands.MessageRewriteFuncs, rewriteCommandAlias)
 
 	pubsub.AddHandler("mod_refresh_mute_override", HandleRefreshMuteOverrides, nil)
+	pubsub.AddHandler("moderation_ban_sync", handleBanSync, BanSyncEvent{})
 }
 
 type ScheduledUnmuteData struct {
@@ -142,58 +156,97 @@ func HandleChannelCreateUpdate(evt *eventsystem.EventData) (retry bool, err erro
 	return false, nil
 }
 
-func RefreshMuteOverrideForChannel(config *Config, channel *discordgo.Channel) {
+// RefreshMuteOverrideForChannel makes sure the mute roles have the permissions denied in the channel,
+// returns the number of overrides that had to be updated
+func RefreshMuteOverrideForChannel(config *Config, channel *discordgo.Channel) (updated int, err error) {
 	// Ignore the channel
 	if common.ContainsInt64Slice(config.MuteIgnoreChannels, channel.ID) {
-		return
+		return 0, nil
 	}
 
 	if !bot.BotProbablyHasPermission(channel.GuildID, channel.ID, discordgo.PermissionManageRoles) {
-		return
+		return 0, nil
 	}
 
+	gs := bot.State.Guild(true, channel.GuildID)
+	for _, v := range config.managedMuteOverrides() {
+		if gs != nil && gs.RoleCopy(true, v.Role) == nil {
+			// tier role was deleted
+			continue
+		}
+
+		changed, err := refreshMuteRoleOverride(config, channel, v.Role, v.Denies)
+		if err != nil {
+			return updated, err
+		}
+
+		if changed {
+			updated++
+		}
+	}
+
+	return updated, nil
+}
+
+// refreshMuteRoleOverride makes sure the mute role has the permissions denied in the channel
+func refreshMuteRoleOverride(config *Config, channel *discordgo.Channel, roleID int64, mutePerms int) (changed bool, err error) {
 	var override *discordgo.PermissionOverwrite
 
 	// Check for existing override
 	for _, v := range channel.PermissionOverwrites {
-		if v.Type == "role" && v.ID == config.IntMuteRole() {
+		if v.Type == "role" && v.ID == roleID {
 			override = v
 			break
 		}
 	}
 
-	MuteDeniedChannelPermsFinal := MuteDeniedChannelPerms
-	if config.MuteDisallowReactionAdd {
-		MuteDeniedChannelPermsFinal = MuteDeniedChannelPermsFinal | discordgo.PermissionAddReactions
-	}
 	allows := 0
-	denies := MuteDeniedChannelPermsFinal
-	changed := true
+	denies := mutePerms
+	changed = true
 
 	if override != nil {
-		allows = override.Allow
-		denies = override.Deny
-		changed = false
-
-		if (allows & MuteDeniedChannelPermsFinal) != 0 {
-			// One of the mute permissions was in the allows, remove it
-			allows &= ^MuteDeniedChannelPermsFinal
-			changed = true
-		}
-
-		if (denies & MuteDeniedChannelPermsFinal) != MuteDeniedChannelPermsFinal {
-			// Missing one of the mute permissions
-			denies |= MuteDeniedChannelPermsFinal
-			changed = true
-		}
+		allows, denies, changed = muteOverridePerms(override.Allow, override.Deny, mutePerms, config.MuteKeepOverrides)
 	}
 
 	if changed {
-		common.BotSession.ChannelPermissionSet(channel.ID, config.IntMuteRole(), "role", allows, denies)
+		err = session().ChannelPermissionSet(channel.ID, roleID, "role", allows, denies)
 	}
+
+	return changed, err
+}
+
+// muteOverridePerms returns the allows and denies needed on an existing mute role override so that the mute permissions are denied.
+// If keepAllows is set, permissions explicitly allowed on the override are left alone and only the missing denies are added.
+func muteOverridePerms(allows, denies, mutePerms int, keepAllows bool) (newAllows, newDenies int, changed bool) {
+	toDeny := mutePerms
+	if keepAllows {
+		toDeny &= ^allows
+	} else if (allows & mutePerms) != 0 {
+		// One of the mute permissions was in the allows, remove it
+		allows &= ^mutePerms
+		changed = true
+	}
+
+	if (denies & toDeny) != toDeny {
+		// Missing one of the mute permissions
+		denies |= toDeny
+		changed = true
+	}
+
+	return allows, denies, changed
+}
+
+// botBanned returns true if the ban was issued through the bot, in which case it already has a modlog entry.
+// The ban marker is consumed by the first event, but if it has expired or the event is duplicated
+// we still catch it through the longer lived logged marker.
+func botBanned(guildID, userID int64) bool {
+	consumed := consumeMarker(RedisKeyBannedUser(guildID, userID))
+	return consumed || markerSet(RedisKeyBanLogged(guildID, userID))
 }
 
 func HandleGuildBanAddRemove(evt *eventsystem.EventData) {
+	receivedAt := time.Now()
+
 	var user *discordgo.User
 	var guildID = evt.GS.ID
 	var action ModlogAction
@@ -206,11 +259,8 @@ func HandleGuildBanAddRemove(evt *eventsystem.EventData) {
 		user = evt.GuildBanAdd().User
 		action = MABanned
 
-		var i int
-		common.RedisPool.Do(radix.Cmd(&i, "GET", RedisKeyBannedUser(guildID, user.ID)))
-		if i > 0 {
+		if botBanned(guildID, user.ID) {
 			// The bot banned the user earlier, don't make duplicate entries in the modlog
-			common.RedisPool.Do(radix.Cmd(nil, "DEL", RedisKeyBannedUser(guildID, user.ID)))
 			return
 		}
 
@@ -219,11 +269,13 @@ func HandleGuildBanAddRemove(evt *eventsystem.EventData) {
 		action = MAUnbanned
 		user = evt.GuildBanRemove().User
 
-		var i int
-		common.RedisPool.Do(radix.Cmd(&i, "GET", RedisKeyUnbannedUser(guildID, user.ID)))
-		if i > 0 {
+		if consumeMarker(RedisKeyBanUndone(guildID, user.ID)) {
+			// the ban was undone and taken out of the modlog, there's nothing to log
+			return
+		}
+
+		if consumeMarker(RedisKeyUnbannedUser(guildID, user.ID)) {
 			// The bot was the one that performed the unban
-			common.RedisPool.Do(radix.Cmd(nil, "DEL", RedisKeyUnbannedUser(guildID, user.ID)))
 			botPerformed = true
 		}
 
@@ -237,6 +289,29 @@ func HandleGuildBanAddRemove(evt *eventsystem.EventData) {
 		return
 	}
 
+	if action == MABanned {
+		recordBan(config, guildID, user)
+	} else {
+		removeBanRecords(guildID, user.ID)
+
+		if !botPerformed {
+			// unbanned by a moderator, expired bans mark the role when they expire
+			markPostBanRole(config, guildID, user.ID, true)
+		}
+	}
+
+	// bans and unbans done outside of the bot replace what the bot had scheduled for the user
+	if config.SupersedeScheduledActions && !botPerformed {
+		conflicts := []string{"moderation_unban"}
+		if action == MABanned {
+			conflicts = banConflicts(config)
+		}
+
+		note := supersededNotes(guildID, user.ID, conflicts)
+		cancelConflicts(guildID, user.ID, conflicts)
+		addFooterNote(&action, note)
+	}
+
 	if config.IntActionChannel() == 0 {
 		return
 	}
@@ -265,6 +340,19 @@ func HandleGuildBanAddRemove(evt *eventsystem.EventData) {
 		return
 	}
 
+	if action == MABanned && markerSet(RedisKeyBanLogged(guildID, user.ID)) {
+		// The ban was issued through the bot while we were waiting on the audit log
+		return
+	}
+
+	if action == MABanned && config.LeftBansModlog != LeftBansLog && leftBeforeBan(guildID, user.ID, receivedAt) {
+		if config.LeftBansModlog == LeftBansSuppress {
+			return
+		}
+
+		addFooterNote(&action, "user had already left")
+	}
+
 	// The bot only unbans people in the case of timed bans
 	if botPerformed {
 		author = common.BotUser
@@ -289,6 +377,10 @@ func HandleGuildMemberRemove(evt *eventsystem.EventData) (retry bool, err error)
 		return false, nil
 	}
 
+	if config.LeftBansModlog != LeftBansLog {
+		recordLeave(data.GuildID, data.User.ID, time.Now())
+	}
+
 	go checkAuditLogMemberRemoved(config, data)
 	return false, nil
 }
@@ -343,7 +435,7 @@ func LockMemberMuteMW(next eventsystem.HandlerFunc) eventsystem.HandlerFunc {
 		}
 
 		// Don't bother doing anythign if this mute is almost up
-		if !currentMute.ExpiresAt.IsZero() && currentMute.ExpiresAt.Sub(time.Now()) < 5*time.Second {
+		if !muteStillActive(&currentMute, time.Now()) {
 			return false, nil
 		}
 
@@ -363,7 +455,7 @@ func HandleMemberJoin(evt *eventsystem.EventData) (retry bool, err error) {
 		return false, nil
 	}
 
-	err = common.BotSession.GuildMemberRoleAdd(c.GuildID, c.User.ID, config.IntMuteRole())
+	err = session().GuildMemberRoleAdd(c.GuildID, c.User.ID, userMuteRole(config, c.GuildID, c.User.ID))
 	if err != nil {
 		return bot.CheckDiscordErrRetry(err), errors.WithStackIf(err)
 	}
@@ -384,12 +476,13 @@ func HandleGuildMemberUpdate(evt *eventsystem.EventData) (retry bool, err error)
 
 	guild := evt.GS
 
-	role := guild.RoleCopy(true, config.IntMuteRole())
+	muteRole := userMuteRole(config, c.GuildID, c.Member.User.ID)
+	role := guild.RoleCopy(true, muteRole)
 	if role == nil {
 		return false, nil // Probably deleted the mute role, do nothing then
 	}
 
-	removedRoles, err := AddMemberMuteRole(config, c.Member.User.ID, c.Member.Roles)
+	removedRoles, err := addMemberMuteRole(config, c.Member.User.ID, c.Member.Roles, muteRole, 0)
 	if err != nil {
 		return bot.CheckDiscordErrRetry(err), errors.WithStackIf(err)
 	}
@@ -398,31 +491,16 @@ func HandleGuildMemberUpdate(evt *eventsystem.EventData) (retry bool, err error)
 		return false, nil
 	}
 
-	tx, err := common.PQ.Begin()
+	err = recordRemovedRoles(c.GuildID, c.Member.User.ID, removedRoles)
 	if err != nil {
-		return true, errors.WithStackIf(err)
-	}
-
-	// Append the removed roles to the removed_roles array column, if they don't already exist in it
-	const queryStr = "UPDATE muted_users SET removed_roles = array_append(removed_roles, $3 ) WHERE user_id=$2 AND guild_id=$1 AND NOT ($3 = ANY(removed_roles));"
-	for _, v := range removedRoles {
-		_, err := tx.Exec(queryStr, c.GuildID, c.Member.User.ID, v)
-		if err != nil {
-			tx.Rollback()
-			return true, errors.WithStackIf(err)
-		}
-	}
-
-	err = tx.Commit()
-	if err != nil {
-		return true, errors.WithStackIf(err)
+		return true, err
 	}
 
 	return false, nil
 }
 
 func FindAuditLogEntry(guildID int64, typ int, targetUser int64, within time.Duration) (author *discordgo.User, entry *discordgo.AuditLogEntry) {
-	auditlog, err := common.BotSession.GuildAuditLog(guildID, 0, 0, typ, 10)
+	auditlog, err := session().GuildAuditLog(guildID, 0, 0, typ, 10)
 	if err != nil {
 		return nil, nil
 	}
@@ -484,17 +562,81 @@ func handleMigrateScheduledUnban(t time.Time, data string) error {
 func handleScheduledUnmute(evt *seventsmodels.ScheduledEvent, data interface{}) (retry bool, err error) {
 	unmuteData := data.(*ScheduledUnmuteData)
 
-	member, err := bot.GetMember(evt.GuildID, unmuteData.UserID)
+	_, retry, err = scheduledUnmute(evt.GuildID, unmuteData.UserID, false)
+	return retry, err
+}
+
+// scheduledUnmute removes the expired mute of the user. manual is true if a moderator retried it with the RetryScheduled
+// command, the user and the moderator that issued the mute aren't told it expired then.
+// done is false if the bot couldn't do anything, as there's no mute role.
+func scheduledUnmute(guildID, userID int64, manual bool) (done bool, retry bool, err error) {
+	member, err := bot.GetMember(guildID, userID)
 	if err != nil {
-		return scheduledevents2.CheckDiscordErrRetry(err), err
+		return false, scheduledevents2.CheckDiscordErrRetry(err), err
 	}
 
-	err = MuteUnmuteUser(nil, false, evt.GuildID, nil, nil, common.BotUser, "Mute Duration Expired", member, 0)
-	if errors.Cause(err) != ErrNoMuteRole {
-		return scheduledevents2.CheckDiscordErrRetry(err), err
+	config, err := GetConfig(guildID)
+	if err != nil {
+		return false, true, err
 	}
 
-	return false, nil
+	if manual {
+		quiet := *config
+		quiet.SuppressPunishDM = true
+		config = &quiet
+	}
+
+	// grab the mute before it's removed so we know who issued it
+	var mute MuteModel
+	mErr := common.GORM.Where(&MuteModel{UserID: member.ID, GuildID: guildID}).First(&mute).Error
+
+	err = MuteUnmuteUser(config, false, guildID, nil, nil, common.BotUser, "Mute Duration Expired", member, 0)
+	if errors.Cause(err) == ErrNoMuteRole {
+		return false, false, nil
+	}
+
+	if err != nil {
+		return false, scheduledevents2.CheckDiscordErrRetry(err), err
+	}
+
+	if mErr == nil {
+		if !manual {
+			go notifyMuteExpired(guildID, &mute, member)
+		}
+
+		givePostMuteRole(config, guildID, member.DGoUser(), false)
+	}
+
+	return true, false, nil
+}
+
+// notifyMuteExpired lets the moderator that issued the mute know that it has expired, if enabled
+func notifyMuteExpired(guildID int64, mute *MuteModel, member *dstate.MemberState) {
+	if mute.AuthorID == 0 || mute.AuthorID == common.BotUser.ID {
+		return
+	}
+
+	config, err := GetConfig(guildID)
+	if err != nil || !config.MuteNotifyModOnExpiry {
+		return
+	}
+
+	// don't bother moderators that have since left the server
+	moderator, err := bot.GetMember(guildID, mute.AuthorID)
+	if err != nil || moderator == nil {
+		return
+	}
+
+	reason := mute.Reason
+	if reason == "" {
+		reason = config.NoReasonText()
+	}
+
+	msg := fmt.Sprintf("**%s:** Your mute on %s#%s (ID %d) has expired.\n📄**Reason:** %s", bot.GuildName(guildID), member.Username, member.StrDiscriminator(), member.ID, reason)
+	err = bot.SendDM(moderator.ID, msg)
+	if err != nil {
+		logger.WithError(err).WithField("guild", guildID).Debug("failed notifying moderator of expired mute")
+	}
 }
 
 func handleScheduledUnban(evt *seventsmodels.ScheduledEvent, data interface{}) (retry bool, err error) {
@@ -509,13 +651,40 @@ func handleScheduledUnban(evt *seventsmodels.ScheduledEvent, data interface{}) (
 		return false, nil
 	}
 
-	common.RedisPool.Do(radix.FlatCmd(nil, "SETEX", RedisKeyUnbannedUser(guildID, userID), 30, 1))
+	setMarker(RedisKeyUnbannedUser(guildID, userID), UnbanMarkerTTL)
 
-	err = common.BotSession.GuildBanDelete(guildID, userID)
+	err = session().GuildBanDelete(guildID, userID)
 	if err != nil {
 		logger.WithField("guild", guildID).WithError(err).Error("failed unbanning user")
 		return scheduledevents2.CheckDiscordErrRetry(err), err
 	}
 
+	if config, err := GetConfig(guildID); err == nil {
+		markPostBanRole(config, guildID, userID, false)
+	}
+
 	return false, nil
 }
+
+// retryScheduledAction runs the handler of a scheduled unban or unmute for the user right away,
+// used to recover actions that got stuck because the scheduled event failed. done is false if there was nothing
+// the bot could do, retry is true if it failed with an error that may go away when tried again later.
+func retryScheduledAction(guildID, userID int64, eventName string) (done bool, retry bool, err error) {
+	switch eventName {
+	case "moderation_unmute":
+		done, retry, err = scheduledUnmute(guildID, userID, true)
+	case "moderation_unban":
+		evt := &seventsmodels.ScheduledEvent{GuildID: guildID, EventName: eventName}
+		retry, err = handleScheduledUnban(evt, &ScheduledUnbanData{UserID: userID})
+		done = err == nil
+	default:
+		return false, false, errors.New("unknown scheduled action: " + eventName)
+	}
+
+	if err != nil || !done {
+		return done, retry, err
+	}
+
+	// it went through, so make sure it doesn't run again later
+	return true, false, cancelScheduledAction(guildID, userID, eventName)
+}
@@ -0,0 +1,35 @@
+package moderation
+
+import (
+	"testing"
+
+	"github.com/jonas747/discordgo"
+)
+
+func TestMuteOverridePerms(t *testing.T) {
+	mutePerms := discordgo.PermissionSendMessages | discordgo.PermissionAddReactions
+	custom := discordgo.PermissionReadMessageHistory
+
+	cases := []struct {
+		name                   string
+		allows, denies         int
+		keepAllows             bool
+		wantAllows, wantDenies int
+		wantChanged            bool
+	}{
+		{"already denied", custom, mutePerms, false, custom, mutePerms, false},
+		{"missing deny", 0, discordgo.PermissionSendMessages, false, 0, mutePerms, true},
+		{"allowed mute perm", custom | discordgo.PermissionAddReactions, 0, false, custom, mutePerms, true},
+		{"keep allowed mute perm", custom | discordgo.PermissionAddReactions, 0, true, custom | discordgo.PermissionAddReactions, discordgo.PermissionSendMessages, true},
+		{"keep with nothing missing", discordgo.PermissionAddReactions, discordgo.PermissionSendMessages, true, discordgo.PermissionAddReactions, discordgo.PermissionSendMessages, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			allows, denies, changed := muteOverridePerms(c.allows, c.denies, mutePerms, c.keepAllows)
+			if allows != c.wantAllows || denies != c.wantDenies || changed != c.wantChanged {
+				t.Errorf("got (%d, %d, %t), expected (%d, %d, %t)", allows, denies, changed, c.wantAllows, c.wantDenies, c.wantChanged)
+			}
+		})
+	}
+}
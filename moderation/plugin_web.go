@@ -4,9 +4,11 @@ import (
 	"fmt"
 	"html/template"
 	"net/http"
+	"strings"
 
 	"github.com/jonas747/discordgo"
 	"github.com/jonas747/yagpdb/common"
+	"github.com/jonas747/yagpdb/common/configstore"
 	"github.com/jonas747/yagpdb/web"
 	"goji.io"
 	"goji.io/pat"
@@ -55,9 +57,21 @@ func HandleModeration(w http.ResponseWriter, r *http.Request) (web.TemplateData,
 		templateData["ModConfig"] = config
 	}
 
+	addModlogWarning(templateData, templateData["ModConfig"].(*Config))
+
 	return templateData, nil
 }
 
+// addModlogWarning warns about features that are enabled but can't work because there's no modlog channel
+func addModlogWarning(templateData web.TemplateData, config *Config) {
+	features := config.featuresNeedingModlog()
+	if len(features) < 1 {
+		return
+	}
+
+	templateData.AddAlerts(web.WarningAlert("No modlog channel is set, so the following won't do anything: ", strings.Join(features, ", ")))
+}
+
 // Update the settings
 func HandlePostModeration(w http.ResponseWriter, r *http.Request) (web.TemplateData, error) {
 	ctx := r.Context()
@@ -66,9 +80,21 @@ func HandlePostModeration(w http.ResponseWriter, r *http.Request) (web.TemplateD
 
 	newConfig := ctx.Value(common.ContextKeyParsedForm).(*Config)
 	newConfig.DefaultMuteDuration.Valid = true
+	newConfig.CleanDelay.Valid = true
+	newConfig.BanUndoWindow.Valid = true
 	templateData["ModConfig"] = newConfig
 
-	err := newConfig.Save(activeGuild.ID)
+	// fresh from the db, the cached one could be from before the last save
+	var oldConfig Config
+	err := configstore.SQL.GetGuildConfig(ctx, activeGuild.ID, &oldConfig)
+	if err != nil && err != configstore.ErrNotFound {
+		return templateData, err
+	}
+
+	err = newConfig.Save(activeGuild.ID)
+	if err == nil && autoCleanScheduleChanged(&oldConfig, newConfig) {
+		err = rescheduleAutoClean(newConfig, activeGuild.ID)
+	}
 
 	templateData["DefaultDMMessage"] = DefaultDMMessage
 
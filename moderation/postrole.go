@@ -0,0 +1,85 @@
+package moderation
+
+import (
+	"time"
+
+	"github.com/jonas747/discordgo"
+	"github.com/jonas747/yagpdb/bot"
+	"github.com/jonas747/yagpdb/bot/eventsystem"
+	"github.com/jonas747/yagpdb/common"
+)
+
+// Servers can give a role, e.g a probation role, once a mute or ban is over.
+// Users aren't in the server when their ban expires, so the post ban role is given when they rejoin.
+
+// How long after the ban expired the post ban role is still given if they rejoin
+const PostBanRoleWindow = time.Hour * 24 * 30
+
+func RedisKeyPendingPostBanRole(guildID, userID int64) string {
+	return "moderation_pending_post_ban_role:" + discordgo.StrID(guildID) + ":" + discordgo.StrID(userID)
+}
+
+// givePostMuteRole gives the member the post mute role if set up, manual is true if the mute was removed by a moderator
+func givePostMuteRole(config *Config, guildID int64, target *discordgo.User, manual bool) {
+	roleID := config.IntPostMuteRole()
+	if roleID == 0 || (manual && !config.PostRoleOnManual) {
+		return
+	}
+
+	givePostRole(config, guildID, target, roleID, "Mute expired")
+}
+
+// markPostBanRole marks the user to be given the post ban role when they rejoin, manual is true if the ban was removed by a moderator
+func markPostBanRole(config *Config, guildID, userID int64, manual bool) {
+	if config.IntPostBanRole() == 0 || (manual && !config.PostRoleOnManual) {
+		return
+	}
+
+	setMarker(RedisKeyPendingPostBanRole(guildID, userID), PostBanRoleWindow)
+}
+
+// HandleMemberJoinPostBanRole gives users that rejoin after their ban expired the post ban role
+func HandleMemberJoinPostBanRole(evt *eventsystem.EventData) (retry bool, err error) {
+	c := evt.GuildMemberAdd()
+	if !markerSet(RedisKeyPendingPostBanRole(c.GuildID, c.User.ID)) {
+		return false, nil
+	}
+
+	config, err := GetConfig(c.GuildID)
+	if err != nil {
+		return true, err
+	}
+
+	consumeMarker(RedisKeyPendingPostBanRole(c.GuildID, c.User.ID))
+
+	roleID := config.IntPostBanRole()
+	if roleID == 0 {
+		// turned off since the ban expired
+		return false, nil
+	}
+
+	givePostRole(config, c.GuildID, c.User, roleID, "Ban expired")
+	return false, nil
+}
+
+func givePostRole(config *Config, guildID int64, target *discordgo.User, roleID int64, reason string) {
+	err := session().GuildMemberRoleAdd(guildID, target.ID, roleID)
+	if err != nil {
+		logger.WithError(err).WithField("guild", guildID).WithField("user", target.ID).Error("failed giving post punishment role")
+		return
+	}
+
+	roleName := discordgo.StrID(roleID)
+	if gs := bot.State.Guild(true, guildID); gs != nil {
+		if r := gs.RoleCopy(true, roleID); r != nil {
+			roleName = r.Name
+		}
+	}
+
+	action := MAGiveRole
+	action.Prefix = "Gave the role " + roleName + " to "
+	err = CreateModlogEmbed(config, common.BotUser, action, target, reason, "")
+	if err != nil {
+		logger.WithError(err).WithField("guild", guildID).Error("failed creating post punishment role modlog entry")
+	}
+}
@@ -0,0 +1,56 @@
+package moderation
+
+import (
+	"github.com/jonas747/discordgo"
+	"github.com/jonas747/yagpdb/common"
+)
+
+// With a private audit channel set, modlog entries are posted in two variants: a sanitized one in the (public) modlog,
+// showing the moderator as set by ModlogAuthorDisplay and without the message logs, and one with every detail in the
+// private channel, always showing the moderator in full along with the logs.
+
+// modlogEntry is a posted modlog entry, either part is nil if it wasn't posted
+type modlogEntry struct {
+	Message *discordgo.Message
+	Embed   *discordgo.MessageEmbed
+
+	// The full detail copy in the private audit channel
+	AuditMessage *discordgo.Message
+	AuditEmbed   *discordgo.MessageEmbed
+
+	// Rendered with the server's modlog template, see TemplatedModlogEntryModel
+	Templated bool
+}
+
+// logsTarget returns the message and embed the message logs belong on, the private copy if there is one
+func (e *modlogEntry) logsTarget() (*discordgo.Message, *discordgo.MessageEmbed) {
+	if e == nil {
+		return nil, nil
+	}
+
+	if e.AuditMessage != nil {
+		return e.AuditMessage, e.AuditEmbed
+	}
+
+	return e.Message, e.Embed
+}
+
+// auditEmbedVariant returns a copy of the modlog embed with the moderator shown in full, for the private audit channel
+func auditEmbedVariant(author *discordgo.User, embed *discordgo.MessageEmbed) *discordgo.MessageEmbed {
+	audit := *embed
+	audit.Fields = append([]*discordgo.MessageEmbedField(nil), embed.Fields...)
+	setModlogEmbedAuthor(&Config{ModlogAuthorDisplay: ModlogAuthorFull}, author, &audit)
+	return &audit
+}
+
+// sendPrivateAudit posts the full detail embed in the private audit channel, disabling it if it's not usable anymore
+func sendPrivateAudit(config *Config, embed *discordgo.MessageEmbed) (*discordgo.Message, error) {
+	m, err := session().ChannelMessageSendEmbed(config.IntPrivateAuditChannel(), embed)
+	if err != nil && common.IsDiscordErr(err, discordgo.ErrCodeMissingAccess, discordgo.ErrCodeMissingPermissions, discordgo.ErrCodeUnknownChannel) {
+		logger.WithError(err).WithField("guild", config.GetGuildID()).Warn("private audit channel not usable, disabling it")
+		go disableModlogChannel(config.GetGuildID(), config.IntPrivateAuditChannel(), func(c *Config) *string { return &c.PrivateAuditChannel })
+		return nil, nil
+	}
+
+	return m, err
+}
@@ -0,0 +1,62 @@
+package moderation
+
+import (
+	"testing"
+
+	"github.com/jonas747/discordgo"
+)
+
+func TestAuditEmbedVariant(t *testing.T) {
+	author := &discordgo.User{ID: 3, Username: "mod", Discriminator: "0001"}
+
+	embed := &discordgo.MessageEmbed{Description: "**🔨Banned user**#0002 *(ID 4)*\n📄**Reason:** spam"}
+	setModlogEmbedAuthor(&Config{ModlogAuthorDisplay: ModlogAuthorMention}, author, embed)
+
+	audit := auditEmbedVariant(author, embed)
+	if audit.Author == nil || audit.Author.Name != "mod#0001 (ID 3)" {
+		t.Errorf("expected the moderator in full on the audit copy, got %+v", audit.Author)
+	}
+	if len(audit.Fields) != 0 {
+		t.Errorf("expected the mention field to be removed from the audit copy, got %+v", audit.Fields)
+	}
+
+	// the modlog version is left alone
+	if embed.Author != nil || len(embed.Fields) != 1 {
+		t.Errorf("expected the modlog embed to be unchanged, got author %+v and fields %+v", embed.Author, embed.Fields)
+	}
+}
+
+func TestModlogEntryLogsTarget(t *testing.T) {
+	var entry *modlogEntry
+	if m, _ := entry.logsTarget(); m != nil {
+		t.Errorf("expected no target for a nil entry, got %+v", m)
+	}
+
+	entry = &modlogEntry{Message: &discordgo.Message{ID: 1}, Embed: &discordgo.MessageEmbed{}}
+	if m, _ := entry.logsTarget(); m == nil || m.ID != 1 {
+		t.Errorf("expected the modlog entry without a private copy, got %+v", m)
+	}
+
+	entry.AuditMessage = &discordgo.Message{ID: 2}
+	entry.AuditEmbed = &discordgo.MessageEmbed{}
+	if m, _ := entry.logsTarget(); m == nil || m.ID != 2 {
+		t.Errorf("expected the private copy, got %+v", m)
+	}
+}
+
+func TestSuppressMemberEntries(t *testing.T) {
+	m, restore := useMockSession()
+	defer restore()
+
+	config := &Config{ActionChannel: "10", PrivateAuditChannel: "11", SuppressMemberEntries: true}
+	target := &discordgo.User{ID: 4, Username: "user", Discriminator: "0002"}
+
+	entry, err := sendModlogEmbed(config, &discordgo.User{ID: 3}, MAMute, target, "spam", "")
+	if err != nil || entry != nil {
+		t.Errorf("expected nothing to be posted, got %+v, %v", entry, err)
+	}
+
+	if len(m.sentEmbeds) != 0 {
+		t.Errorf("expected no modlog or private audit entries for a mass action member, got %d", len(m.sentEmbeds))
+	}
+}
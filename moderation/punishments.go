@@ -12,12 +12,9 @@ import (
 	"github.com/jonas747/dstate"
 	"github.com/jonas747/yagpdb/bot"
 	"github.com/jonas747/yagpdb/common"
-	"github.com/jonas747/yagpdb/common/scheduledevents2"
-	seventsmodels "github.com/jonas747/yagpdb/common/scheduledevents2/models"
 	"github.com/jonas747/yagpdb/common/templates"
 	"github.com/jonas747/yagpdb/logs"
 	"github.com/mediocregopher/radix/v3"
-	"github.com/volatiletech/sqlboiler/queries/qm"
 )
 
 type Punishment int
@@ -55,11 +52,14 @@ func getMemberWithFallback(gs *dstate.GuildState, user *discordgo.User) (ms *dst
 }
 
 // Kick or bans someone, uploading a hasebin log, and sending the report message in the action channel
-func punish(config *Config, p Punishment, guildID int64, channel *dstate.ChannelState, message *discordgo.Message, author *discordgo.User, reason string, user *discordgo.User, duration time.Duration, variadicBanDeleteDays ...int) error {
+// If asyncLogs is set the channel logs are created in the background and added to the modlog entry once they're ready
+// instead of holding up the punishment. note is added to the footer of the modlog entry.
+// Returns the modlog entry, nil if none was posted.
+func punish(config *Config, p Punishment, guildID int64, channel *dstate.ChannelState, message *discordgo.Message, author *discordgo.User, reason string, user *discordgo.User, duration time.Duration, asyncLogs bool, note string, variadicBanDeleteDays ...int) (*modlogEntry, error) {
 
 	config, err := getConfigIfNotSet(guildID, config)
 	if err != nil {
-		return common.ErrWithCaller(err)
+		return nil, common.ErrWithCaller(err)
 	}
 
 	var action ModlogAction
@@ -71,6 +71,7 @@ func punish(config *Config, p Punishment, guildID int64, channel *dstate.Channel
 			action.Footer = "Expires after: " + common.HumanizeDuration(common.DurationPrecisionMinutes, duration)
 		}
 	}
+	addFooterNote(&action, note)
 
 	var channelID int64
 	if channel != nil {
@@ -89,8 +90,22 @@ func punish(config *Config, p Punishment, guildID int64, channel *dstate.Channel
 	}
 
 	logLink := ""
+	var logLinkC chan string
 	if channelID != 0 {
-		logLink = CreateLogs(guildID, channelID, author)
+		if asyncLogs {
+			// start the snapshot before the ban goes through so it (hopefully) captures the messages before they're deleted
+			logLinkC = make(chan string, 1)
+			go func() {
+				logLinkC <- CreateLogs(guildID, channelID, author)
+			}()
+		} else {
+			logLink = CreateLogs(guildID, channelID, author)
+		}
+	}
+
+	// banned through the bot after leaving, always logged as a moderator chose to do it
+	if p == PunishmentBan && config.LeftBansModlog != LeftBansLog && leftBeforeBan(guildID, user.ID, time.Now()) {
+		addFooterNote(&action, "user had already left")
 	}
 
 	fullReason := reason
@@ -100,53 +115,99 @@ func punish(config *Config, p Punishment, guildID int64, channel *dstate.Channel
 
 	switch p {
 	case PunishmentKick:
-		err = common.BotSession.GuildMemberDeleteWithReason(guildID, user.ID, fullReason)
+		err = session().GuildMemberDeleteWithReason(guildID, user.ID, fullReason)
 	case PunishmentBan:
 		banDeleteDays := 1
 		if len(variadicBanDeleteDays) > 0 {
 			banDeleteDays = variadicBanDeleteDays[0]
 		}
-		err = common.BotSession.GuildBanCreateWithReason(guildID, user.ID, fullReason, banDeleteDays)
+
+		// Set a key in redis that marks that this user has appeared in the modlog already,
+		// before the ban as the ban event can arrive before the request returns
+		setMarker(RedisKeyBannedUser(guildID, user.ID), BanMarkerTTL)
+		setMarker(RedisKeyBanLogged(guildID, user.ID), BanLoggedWindow)
+
+		err = session().GuildBanCreateWithReason(guildID, user.ID, fullReason, banDeleteDays)
+		if err != nil {
+			// the user wasn't banned, a later ban from elsewhere still needs its modlog entry
+			delErr := common.RedisPool.Do(radix.Cmd(nil, "DEL", RedisKeyBannedUser(guildID, user.ID), RedisKeyBanLogged(guildID, user.ID)))
+			common.LogIgnoreError(delErr, "[moderation] failed clearing ban markers after a failed ban", nil)
+		}
 	}
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	logger.Infof("MODERATION: %s %s %s cause %q", author.Username, action.Prefix, user.Username, reason)
 
 	if memberNotFound {
-		// Wait a tiny bit to make sure the audit log is updated
-		time.Sleep(time.Second * 3)
+		user = resolvePunishedUser(guildID, p, user)
+	}
 
-		auditLogType := discordgo.AuditLogActionMemberBanAdd
-		if p == PunishmentKick {
-			auditLogType = discordgo.AuditLogActionMemberKick
-		}
-
-		// Pull user details from audit log if we can
-		auditLog, err := common.BotSession.GuildAuditLog(gs.ID, common.BotUser.ID, 0, auditLogType, 10)
-		if err == nil {
-			for _, v := range auditLog.Users {
-				if v.ID == user.ID {
-					user = &discordgo.User{
-						ID:            v.ID,
-						Username:      v.Username,
-						Discriminator: v.Discriminator,
-						Bot:           v.Bot,
-						Avatar:        v.Avatar,
-					}
-					break
-				}
+	entry, err := sendModlogEmbed(config, author, action, user, reason, logLink)
+	if logLinkC != nil {
+		go attachModlogLogs(entry, logLinkC)
+	}
+
+	return entry, err
+}
+
+// How long to wait for the audit log to update after a punishment before looking up the user in it
+var auditLogDelay = time.Second * 3
+
+// resolvePunishedUser returns the details of a punished user that wasn't on the server when the punishment went through.
+// If we already know who they are, for example if they left between the command being run and the ban, the known details are used,
+// otherwise they're pulled from the audit log if possible
+func resolvePunishedUser(guildID int64, p Punishment, user *discordgo.User) *discordgo.User {
+	if user.Discriminator != "????" {
+		return user
+	}
+
+	// Wait a tiny bit to make sure the audit log is updated
+	time.Sleep(auditLogDelay)
+
+	auditLogType := discordgo.AuditLogActionMemberBanAdd
+	if p == PunishmentKick {
+		auditLogType = discordgo.AuditLogActionMemberKick
+	}
+
+	// Pull user details from audit log if we can
+	auditLog, err := session().GuildAuditLog(guildID, common.BotUser.ID, 0, auditLogType, 10)
+	if err != nil {
+		return user
+	}
+
+	for _, v := range auditLog.Users {
+		if v.ID == user.ID {
+			return &discordgo.User{
+				ID:            v.ID,
+				Username:      v.Username,
+				Discriminator: v.Discriminator,
+				Bot:           v.Bot,
+				Avatar:        v.Avatar,
 			}
 		}
 	}
 
-	err = CreateModlogEmbed(config, author, action, user, reason, logLink)
-	return err
+	return user
 }
 
 func sendPunishDM(config *Config, dmMsg string, action ModlogAction, gs *dstate.GuildState, channel *dstate.ChannelState, message *discordgo.Message, author *discordgo.User, member *dstate.MemberState, duration time.Duration, reason string) {
+	executed, err := executePunishDM(dmMsg, action, gs, channel, message, author, member, duration, reason, nil)
+	if err != nil {
+		logger.WithError(err).WithField("guild", gs.ID).Warn("Failed executing pusnishment DM")
+		executed = "Failed executing template."
+	}
+
+	if strings.TrimSpace(executed) != "" {
+		go bot.SendDM(member.ID, "**"+bot.GuildName(gs.ID)+":** "+executed)
+	}
+}
+
+// executePunishDM executes the punishment DM template, using the default one if dmMsg is empty,
+// extraData is added to the template data on top of the data every punishment has
+func executePunishDM(dmMsg string, action ModlogAction, gs *dstate.GuildState, channel *dstate.ChannelState, message *discordgo.Message, author *discordgo.User, member *dstate.MemberState, duration time.Duration, reason string, extraData map[string]interface{}) (string, error) {
 	if dmMsg == "" {
 		dmMsg = DefaultDMMessage
 	}
@@ -169,15 +230,11 @@ func sendPunishDM(config *Config, dmMsg string, action ModlogAction, gs *dstate.
 		ctx.Data["HumanDuration"] = "permanently"
 	}
 
-	executed, err := ctx.Execute(dmMsg)
-	if err != nil {
-		logger.WithError(err).WithField("guild", gs.ID).Warn("Failed executing pusnishment DM")
-		executed = "Failed executing template."
+	for k, v := range extraData {
+		ctx.Data[k] = v
 	}
 
-	if strings.TrimSpace(executed) != "" {
-		go bot.SendDM(member.ID, "**"+bot.GuildName(gs.ID)+":** "+executed)
-	}
+	return ctx.Execute(dmMsg)
 }
 
 func KickUser(config *Config, guildID int64, channel *dstate.ChannelState, message *discordgo.Message, author *discordgo.User, reason string, user *discordgo.User) error {
@@ -186,7 +243,7 @@ func KickUser(config *Config, guildID int64, channel *dstate.ChannelState, messa
 		return common.ErrWithCaller(err)
 	}
 
-	err = punish(config, PunishmentKick, guildID, channel, message, author, reason, user, 0)
+	_, err = punish(config, PunishmentKick, guildID, channel, message, author, reason, user, 0, false, "")
 	if err != nil {
 		return err
 	}
@@ -232,17 +289,31 @@ func DeleteMessages(channelID int64, filterUser int64, deleteNum, fetchNum int)
 	if len(toDelete) < 1 {
 		return 0, nil
 	} else if len(toDelete) == 1 {
-		err = common.BotSession.ChannelMessageDelete(channelID, toDelete[0])
+		err = session().ChannelMessageDelete(channelID, toDelete[0])
 	} else {
-		err = common.BotSession.ChannelMessagesBulkDelete(channelID, toDelete)
+		err = session().ChannelMessagesBulkDelete(channelID, toDelete)
 	}
 
 	return len(toDelete), err
 }
 
 func BanUserWithDuration(config *Config, guildID int64, channel *dstate.ChannelState, message *discordgo.Message, author *discordgo.User, reason string, user *discordgo.User, duration time.Duration, deleteMessageDays int) error {
-	// Set a key in redis that marks that this user has appeared in the modlog already
-	common.RedisPool.Do(radix.Cmd(nil, "SETEX", RedisKeyBannedUser(guildID, user.ID), "60", "1"))
+	return banUser(config, guildID, channel, message, author, reason, user, duration, deleteMessageDays, false, 0)
+}
+
+// BanUserWithLogs is the same as BanUserWithDuration, except the channel logs are created in the background
+// and linked in the modlog entry once ready
+func BanUserWithLogs(config *Config, guildID int64, channel *dstate.ChannelState, message *discordgo.Message, author *discordgo.User, reason string, user *discordgo.User, duration time.Duration, deleteMessageDays int) error {
+	return banUser(config, guildID, channel, message, author, reason, user, duration, deleteMessageDays, true, 0)
+}
+
+// banUser bans the user, syncedFrom is the server the ban was synced from, 0 if it wasn't
+func banUser(config *Config, guildID int64, channel *dstate.ChannelState, message *discordgo.Message, author *discordgo.User, reason string, user *discordgo.User, duration time.Duration, deleteMessageDays int, asyncLogs bool, syncedFrom int64) error {
+	config, err := getConfigIfNotSet(guildID, config)
+	if err != nil {
+		return common.ErrWithCaller(err)
+	}
+
 	if deleteMessageDays > 7 {
 		deleteMessageDays = 7
 	}
@@ -250,26 +321,60 @@ func BanUserWithDuration(config *Config, guildID int64, channel *dstate.ChannelS
 		deleteMessageDays = 0
 	}
 
-	err := punish(config, PunishmentBan, guildID, channel, message, author, reason, user, duration, deleteMessageDays)
+	conflicts := banConflicts(config)
+	note := supersededNotes(guildID, user.ID, conflicts)
+	superseded := supersededMute(guildID, user.ID, conflicts)
+
+	entry, err := punish(config, PunishmentBan, guildID, channel, message, author, reason, user, duration, asyncLogs, note, deleteMessageDays)
 	if err != nil {
 		return err
 	}
 
-	_, err = seventsmodels.ScheduledEvents(qm.Where("event_name='moderation_unban' AND  guild_id = ? AND (data->>'user_id')::bigint = ?", guildID, user.ID)).DeleteAll(context.Background(), common.PQ)
-	common.LogIgnoreError(err, "[moderation] failed clearing unban events", nil)
+	recordBan(config, guildID, user)
+	cancelConflicts(guildID, user.ID, conflicts)
+
+	// bans by the bot itself include the synced ones, don't send them around again
+	if author.ID != common.BotUser.ID {
+		go publishBanSync(config, guildID, user, reason, duration)
+	}
 
 	if duration > 0 {
-		err = scheduledevents2.ScheduleEvent("moderation_unban", guildID, time.Now().Add(duration), &ScheduledUnbanData{
-			UserID: user.ID,
-		})
+		revert, err := scheduleExpiry(config, guildID, user.ID, "moderation_unban", time.Now().Add(duration))
+		if revert {
+			setMarker(RedisKeyUnbannedUser(guildID, user.ID), UnbanMarkerTTL)
+			if unbanErr := session().GuildBanDelete(guildID, user.ID); unbanErr != nil {
+				return errors.WithMessage(unbanErr, "punish,revert_ban")
+			}
+		}
 		if err != nil {
-			return errors.WithMessage(err, "punish,sched_unban")
+			return err
 		}
 	}
 
+	undoable := newLastBan(user, message, entry, superseded)
+	if syncedFrom != 0 {
+		rememberSyncedBan(guildID, syncedFrom, undoable)
+	} else {
+		rememberLastBan(config, author, undoable)
+	}
+
 	return nil
 }
 
+// userBanned returns true if the user is currently banned from the guild
+func userBanned(guildID, userID int64) (bool, error) {
+	_, err := session().RequestWithBucketID("GET", discordgo.EndpointGuildBan(guildID, userID), nil, discordgo.EndpointGuildBans(guildID))
+	if err == nil {
+		return true, nil
+	}
+
+	if cast, ok := errors.Cause(err).(*discordgo.RESTError); ok && cast.Response != nil && cast.Response.StatusCode == 404 {
+		return false, nil
+	}
+
+	return false, err
+}
+
 func BanUser(config *Config, guildID int64, channel *dstate.ChannelState, message *discordgo.Message, author *discordgo.User, reason string, user *discordgo.User) error {
 	return BanUserWithDuration(config, guildID, channel, message, author, reason, user, 0, 1)
 }
@@ -281,13 +386,36 @@ const (
 // Unmut or mute a user, ignore duration if unmuting
 // TODO: i don't think we need to track mutes in its own database anymore now with the new scheduled event system
 func MuteUnmuteUser(config *Config, mute bool, guildID int64, channel *dstate.ChannelState, message *discordgo.Message, author *discordgo.User, reason string, member *dstate.MemberState, duration int) error {
-	config, err := getConfigIfNotSet(guildID, config)
+	_, err := muteUnmuteUser(config, mute, guildID, channel, message, author, reason, member, duration, 0)
+	return err
+}
+
+// muteResult describes what happened in muteUnmuteUser
+type muteResult struct {
+	// The mute went over the auto-kick threshold and the user was kicked
+	Kicked bool
+
+	// The final duration of the mute in minutes, 0 if permanent
+	Duration int
+
+	// Duration was increased because of mute escalation, PreviousMutes is the number of mutes within the escalation window before this one
+	Escalated     bool
+	PreviousMutes int
+
+	// The user had a mute entry before this, i.e they were already muted
+	WasMuted bool
+}
+
+// muteUnmuteUser is the same as MuteUnmuteUser, but also reports whether the mute was escalated to a kick or a longer duration
+// muteRole is the mute tier role to mute with, 0 for the default mute role (or to keep the tier of an existing mute)
+func muteUnmuteUser(config *Config, mute bool, guildID int64, channel *dstate.ChannelState, message *discordgo.Message, author *discordgo.User, reason string, member *dstate.MemberState, duration int, muteRole int64) (result muteResult, err error) {
+	config, err = getConfigIfNotSet(guildID, config)
 	if err != nil {
-		return common.ErrWithCaller(err)
+		return result, common.ErrWithCaller(err)
 	}
 
 	if config.MuteRole == "" {
-		return ErrNoMuteRole
+		return result, ErrNoMuteRole
 	}
 
 	var channelID int64
@@ -304,8 +432,15 @@ func MuteUnmuteUser(config *Config, mute bool, guildID int64, channel *dstate.Ch
 	err = common.GORM.Where(&MuteModel{UserID: member.ID, GuildID: guildID}).First(&currentMute).Error
 	alreadyMuted := err != gorm.ErrRecordNotFound
 	if err != nil && err != gorm.ErrRecordNotFound {
-		return common.ErrWithCaller(err)
+		return result, common.ErrWithCaller(err)
 	}
+	result.WasMuted = alreadyMuted
+
+	// Repeat offenders get longer mutes, extending a existing mute doesn't count as a new one
+	if mute && !alreadyMuted && config.MuteEscalationEnabled {
+		duration = escalateMute(config, guildID, member.ID, duration, &result)
+	}
+	result.Duration = duration
 
 	// Insert/update the mute entry in the database
 	if !alreadyMuted {
@@ -325,14 +460,27 @@ func MuteUnmuteUser(config *Config, mute bool, guildID int64, channel *dstate.Ch
 	}
 
 	// no matter what, if were unmuting or muting, we wanna make sure we dont have duplicated unmute events
-	_, err = seventsmodels.ScheduledEvents(qm.Where("event_name='moderation_unmute' AND  guild_id = ? AND (data->>'user_id')::bigint = ?", guildID, member.ID)).DeleteAll(context.Background(), common.PQ)
-	common.LogIgnoreError(err, "[moderation] failed clearing unban events", nil)
+	// (expired mutes are unmuted by the bot, that's not replacing anything)
+	var note string
+	if mute || author == nil || author.ID != common.BotUser.ID {
+		note = supersededNotes(guildID, member.ID, []string{"moderation_unmute"})
+	}
+	err = cancelScheduledAction(guildID, member.ID, "moderation_unmute")
+	common.LogIgnoreError(err, "[moderation] failed clearing unmute events", nil)
 
 	if mute {
-		// Apply the roles to the user
-		removedRoles, err := AddMemberMuteRole(config, member.ID, member.Roles)
+		// Apply the roles to the user, replacing the role of the previous tier if it changed
+		var replaceRole int64
+		if alreadyMuted && muteRole != 0 {
+			replaceRole = config.muteRoleFor(&currentMute)
+			currentMute.MuteRole = muteRole
+		} else if !alreadyMuted {
+			currentMute.MuteRole = muteRole
+		}
+
+		removedRoles, err := addMemberMuteRole(config, member.ID, member.Roles, config.muteRoleFor(&currentMute), replaceRole)
 		if err != nil {
-			return errors.WithMessage(err, "AddMemberMuteRole")
+			return result, errors.WithMessage(err, "AddMemberMuteRole")
 		}
 
 		if alreadyMuted {
@@ -355,27 +503,47 @@ func MuteUnmuteUser(config *Config, mute bool, guildID int64, channel *dstate.Ch
 
 		err = common.GORM.Save(&currentMute).Error
 		if err != nil {
-			return errors.WithMessage(err, "failed inserting/updating mute")
+			return result, errors.WithMessage(err, "failed inserting/updating mute")
+		}
+
+		if !alreadyMuted && config.MuteEscalationEnabled {
+			recordMuteEscalation(config, guildID, member.ID)
+		}
+
+		// the mute role only stops them from connecting again, kick them out of the channel they're in
+		if config.MuteDenyVoiceConnect && inVoice(guildID, member.ID) {
+			err = disconnectFromVoice(guildID, member.ID)
+			if err != nil {
+				logger.WithError(err).WithField("guild", guildID).WithField("user", member.ID).Error("failed disconnecting muted member from voice")
+			}
 		}
 
 		if duration > 0 {
-			err = scheduledevents2.ScheduleEvent("moderation_unmute", guildID, time.Now().Add(time.Minute*time.Duration(duration)), &ScheduledUnmuteData{
-				UserID: member.ID,
-			})
+			revert, err := scheduleExpiry(config, guildID, member.ID, "moderation_unmute", time.Now().Add(time.Minute*time.Duration(duration)))
+			if revert {
+				revertMute(config, guildID, member, currentMute)
+			}
 			if err != nil {
-				return errors.WithMessage(err, "failed scheduling unmute")
+				return result, err
 			}
+
+			// in case the unmute doesn't go through
+			scheduleMuteArchival(config, guildID, currentMute.ExpiresAt.Add(muteArchivePeriod(config)))
 		}
 	} else {
 		// Remove the mute role, and give back the role the bot took
 		err = RemoveMemberMuteRole(config, member.ID, member.Roles, currentMute)
 		if err != nil {
-			return errors.WithMessage(err, "failed removing mute role")
+			return result, errors.WithMessage(err, "failed removing mute role")
 		}
 
 		if alreadyMuted {
 			common.GORM.Delete(&currentMute)
 			common.RedisPool.Do(radix.Cmd(nil, "DEL", RedisKeyMutedUser(guildID, member.ID)))
+
+			if config.MuteAutoKickEnabled {
+				endMuteDuration(guildID, member.ID, &currentMute)
+			}
 		}
 	}
 
@@ -395,30 +563,153 @@ func MuteUnmuteUser(config *Config, mute bool, guildID int64, channel *dstate.Ch
 		} else {
 			action.Footer += "permanent"
 		}
+		if result.Escalated {
+			action.Footer += " (escalated, mute #" + strconv.Itoa(result.PreviousMutes+1) + ")"
+		}
 		dmMsg = config.MuteMessage
 	}
 
 	gs := bot.State.Guild(true, guildID)
-	if gs != nil {
+	if gs != nil && !config.SuppressPunishDM {
 		sendPunishDM(config, dmMsg, action, gs, channel, message, author, member, time.Duration(duration)*time.Minute, reason)
 	}
 
 	// Create the modlog entry
-	return CreateModlogEmbed(config, author, action, member.DGoUser(), reason, logLink)
+	addFooterNote(&action, note)
+	err = CreateModlogEmbed(config, author, action, member.DGoUser(), reason, logLink)
+	if err != nil {
+		return result, err
+	}
+
+	if !mute || !config.MuteAutoKickEnabled {
+		return result, nil
+	}
+
+	previous, err := previousMutesDuration(guildID, member.ID, currentMute.ID)
+	if err != nil {
+		logger.WithError(err).WithField("guild", guildID).Error("failed retrieving previous mutes for the auto-kick threshold")
+	}
+
+	if !alreadyMuted {
+		recordMuteDuration(config, guildID, member.ID, &currentMute, duration)
+	}
+
+	if !mutePastAutoKickThreshold(config, &currentMute, alreadyMuted, duration, previous) {
+		return result, nil
+	}
+
+	logger.WithField("guild", guildID).WithField("user", member.ID).Info("Mute exceeded auto-kick threshold, escalating to kick")
+
+	kickReason := "Mute exceeded the auto-kick threshold of " + common.HumanizeDuration(common.DurationPrecisionMinutes, time.Duration(config.MuteAutoKickThreshold)*time.Minute)
+	if author != nil && author.ID != common.BotUser.ID {
+		kickReason += " (muted by " + author.Username + "#" + author.Discriminator + ")"
+	}
+
+	err = KickUser(config, guildID, channel, message, common.BotUser, kickReason, member.DGoUser())
+	if err != nil {
+		return result, errors.WithMessage(err, "auto-kick")
+	}
+
+	result.Kicked = true
+	return result, nil
+}
+
+// escalateMute returns the duration to use for a new mute based on the users previous mutes, filling in the escalation details of the result
+func escalateMute(config *Config, guildID, userID int64, duration int, result *muteResult) int {
+	steps, err := config.MuteEscalationDurations()
+	if err != nil {
+		logger.WithError(err).WithField("guild", guildID).Error("invalid mute escalation steps")
+		return duration
+	}
+
+	previous, err := previousMuteCount(guildID, userID)
+	if err != nil {
+		logger.WithError(err).WithField("guild", guildID).Error("failed retrieving previous mute count")
+		return duration
+	}
+
+	result.PreviousMutes = previous
+	duration, result.Escalated = escalatedMuteDuration(steps, previous, duration)
+	return duration
+}
+
+// clearDepartedMute removes the mute of a user that isn't in the server anymore,
+// so that they aren't muted again when they rejoin. Returns false if the user wasn't muted.
+func clearDepartedMute(config *Config, guildID int64, author *discordgo.User, target *discordgo.User, reason string) (cleared bool, err error) {
+	LockMute(target.ID)
+	defer UnlockMute(target.ID)
+
+	var mute MuteModel
+	err = common.GORM.Where(&MuteModel{UserID: target.ID, GuildID: guildID}).First(&mute).Error
+	if err == gorm.ErrRecordNotFound {
+		return false, nil
+	} else if err != nil {
+		return false, common.ErrWithCaller(err)
+	}
+
+	err = cancelScheduledAction(guildID, target.ID, "moderation_unmute")
+	if err != nil {
+		return false, errors.WithMessage(err, "failed clearing unmute events")
+	}
+
+	err = common.GORM.Delete(&mute).Error
+	if err != nil {
+		return false, errors.WithMessage(err, "failed removing mute")
+	}
+	common.RedisPool.Do(radix.Cmd(nil, "DEL", RedisKeyMutedUser(guildID, target.ID)))
+
+	if config.MuteAutoKickEnabled {
+		endMuteDuration(guildID, target.ID, &mute)
+	}
+
+	action := MAUnmute
+	action.Footer = "Not in the server"
+	err = CreateModlogEmbed(config, author, action, target, reason, "")
+	return true, err
+}
+
+// mutePastAutoKickThreshold returns true if the total time the user will have been muted exceeds the auto-kick threshold,
+// previous being the time of their earlier mutes within the escalation window. Permanent mutes always exceed it.
+func mutePastAutoKickThreshold(config *Config, mute *MuteModel, extended bool, duration int, previous time.Duration) bool {
+	if !config.MuteAutoKickEnabled || config.MuteAutoKickThreshold < 1 {
+		return false
+	}
+
+	if duration < 1 {
+		return true
+	}
+
+	total := previous + time.Duration(duration)*time.Minute
+	if extended {
+		total += time.Since(mute.CreatedAt)
+	}
+
+	return total > time.Duration(config.MuteAutoKickThreshold)*time.Minute
 }
 
 func AddMemberMuteRole(config *Config, id int64, currentRoles []int64) (removedRoles []int64, err error) {
+	return addMemberMuteRole(config, id, currentRoles, config.IntMuteRole(), 0)
+}
+
+// addMemberMuteRole gives the member muteRole, removing replaceRole (the role of a previous mute tier) if set
+func addMemberMuteRole(config *Config, id int64, currentRoles []int64, muteRole, replaceRole int64) (removedRoles []int64, err error) {
 	removedRoles = make([]int64, 0, len(config.MuteRemoveRoles))
 	newMemberRoles := make([]string, 0, len(currentRoles))
-	newMemberRoles = append(newMemberRoles, config.MuteRole)
+	newMemberRoles = append(newMemberRoles, strconv.FormatInt(muteRole, 10))
 
 	hadMuteRole := false
+	replaced := false
 	for _, r := range currentRoles {
-		if config.IntMuteRole() == r {
+		if muteRole == r {
 			hadMuteRole = true
 			continue
 		}
 
+		if replaceRole != 0 && replaceRole == r {
+			replaced = true
+			continue
+		}
+
 		if common.ContainsInt64Slice(config.MuteRemoveRoles, r) {
 			removedRoles = append(removedRoles, r)
 		} else {
@@ -426,44 +717,217 @@ func AddMemberMuteRole(config *Config, id int64, currentRoles []int64) (removedR
 		}
 	}
 
-	if hadMuteRole && len(removedRoles) < 1 {
+	if hadMuteRole && !replaced && len(removedRoles) < 1 {
 		// No changes needs to be made
 		return
 	}
 
-	err = common.BotSession.GuildMemberEdit(config.GuildID, id, newMemberRoles)
+	err = session().GuildMemberEdit(config.GuildID, id, newMemberRoles)
 	return
 }
 
+// revertMute undoes a mute that was just applied, giving the member back the roles they had before
+func revertMute(config *Config, guildID int64, member *dstate.MemberState, mute MuteModel) {
+	err := RemoveMemberMuteRole(config, member.ID, member.Roles, mute)
+	if err != nil {
+		logger.WithError(err).WithField("guild", guildID).WithField("user", member.ID).Error("failed removing mute role when reverting mute")
+	}
+
+	common.GORM.Delete(&mute)
+	common.RedisPool.Do(radix.Cmd(nil, "DEL", RedisKeyMutedUser(guildID, member.ID)))
+}
+
 func RemoveMemberMuteRole(config *Config, id int64, currentRoles []int64, mute MuteModel) (err error) {
 
 	newMemberRoles := make([]string, 0, len(currentRoles)+len(config.MuteRemoveRoles))
 
+	muteRole := config.muteRoleFor(&mute)
 	for _, v := range currentRoles {
-		if v != config.IntMuteRole() {
+		if v != muteRole {
 			newMemberRoles = append(newMemberRoles, strconv.FormatInt(v, 10))
 		}
 	}
 
+	var gs *dstate.GuildState
+	if bot.State != nil {
+		gs = bot.State.Guild(true, config.GuildID)
+	}
+
 	for _, v := range mute.RemovedRoles {
-		if !common.ContainsInt64Slice(currentRoles, v) {
-			newMemberRoles = append(newMemberRoles, strconv.FormatInt(v, 10))
+		if common.ContainsInt64Slice(currentRoles, v) {
+			continue
 		}
+
+		if gs != nil && gs.RoleCopy(true, v) == nil {
+			// the role was deleted while they were muted, giving it back would fail the whole edit
+			continue
+		}
+
+		newMemberRoles = append(newMemberRoles, strconv.FormatInt(v, 10))
 	}
 
-	err = common.BotSession.GuildMemberEdit(config.GuildID, id, newMemberRoles)
+	err = session().GuildMemberEdit(config.GuildID, id, newMemberRoles)
 
 	return
 }
 
+// Discord doesn't allow timeouts longer than this
+const MaxTimeoutDuration = time.Hour * 24 * 28
+
+// TimeoutUser puts the user in a native discord timeout, duration has to be between 1 minute and 28 days
+func TimeoutUser(config *Config, guildID int64, channel *dstate.ChannelState, message *discordgo.Message, author *discordgo.User, reason string, user *discordgo.User, duration time.Duration) error {
+	config, err := getConfigIfNotSet(guildID, config)
+	if err != nil {
+		return common.ErrWithCaller(err)
+	}
+
+	until := time.Now().Add(duration)
+	err = setMemberTimeout(guildID, user.ID, &until)
+	if err != nil {
+		return err
+	}
+
+	action := MATimeoutAdded
+	action.Footer = "Duration: " + common.HumanizeDuration(common.DurationPrecisionMinutes, duration)
+
+	gs := bot.State.Guild(true, guildID)
+	if gs != nil {
+		member, notFound := getMemberWithFallback(gs, user)
+		if !notFound {
+			sendPunishDM(config, "", action, gs, channel, message, author, member, duration, reason)
+		}
+	}
+
+	logLink := ""
+	if channel != nil {
+		logLink = CreateLogs(guildID, channel.ID, author)
+	}
+
+	return CreateModlogEmbed(config, author, action, user, reason, logLink)
+}
+
+// RemoveTimeout clears a native discord timeout from the user
+func RemoveTimeout(config *Config, guildID int64, author *discordgo.User, reason string, user *discordgo.User) error {
+	config, err := getConfigIfNotSet(guildID, config)
+	if err != nil {
+		return common.ErrWithCaller(err)
+	}
+
+	err = setMemberTimeout(guildID, user.ID, nil)
+	if err != nil {
+		return err
+	}
+
+	return CreateModlogEmbed(config, author, MATimeoutRemoved, user, reason, "")
+}
+
+// setMemberTimeout sets communication_disabled_until on the member, clearing it if until is nil
+func setMemberTimeout(guildID, userID int64, until *time.Time) error {
+	data := map[string]interface{}{
+		"communication_disabled_until": nil,
+	}
+	if until != nil {
+		data["communication_disabled_until"] = until.UTC().Format(time.RFC3339)
+	}
+
+	_, err := session().RequestWithBucketID("PATCH", discordgo.EndpointGuildMember(guildID, userID), data, discordgo.EndpointGuildMember(guildID, 0))
+	return err
+}
+
+// disconnectFromVoice disconnects the member from the voice channel they're in
+func disconnectFromVoice(guildID, userID int64) error {
+	data := map[string]interface{}{
+		"channel_id": nil,
+	}
+
+	_, err := session().RequestWithBucketID("PATCH", discordgo.EndpointGuildMember(guildID, userID), data, discordgo.EndpointGuildMember(guildID, 0))
+	return err
+}
+
+// inVoice returns true if the member is connected to a voice channel
+func inVoice(guildID, userID int64) bool {
+	gs := bot.State.Guild(true, guildID)
+	if gs == nil {
+		return false
+	}
+
+	vs := gs.VoiceState(true, userID)
+	return vs != nil && vs.ChannelID != 0
+}
+
+// isDuplicateWarning returns true if the warning was given by the author for the same reason within the window
+func isDuplicateWarning(w *WarningModel, authorID int64, message string, window time.Duration, now time.Time) bool {
+	return w.AuthorID == discordgo.StrID(authorID) && strings.TrimSpace(w.Message) == strings.TrimSpace(message) && now.Sub(w.CreatedAt) <= window
+}
+
+// recentDuplicateWarning returns true if the author gave the target an identical warning within the window
+func recentDuplicateWarning(guildID, authorID, targetID int64, message string, window time.Duration) (bool, error) {
+	now := time.Now()
+
+	var recent []*WarningModel
+	err := common.GORM.Where("guild_id = ? AND user_id = ? AND created_at > ?", guildID, discordgo.StrID(targetID), now.Add(-window)).Find(&recent).Error
+	if err != nil {
+		return false, err
+	}
+
+	for _, w := range recent {
+		if isDuplicateWarning(w, authorID, message, window, now) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// ErrDuplicateWarning is returned to the Warn command when an identical warning was given recently, see Config.WarnDedupSeconds
+var ErrDuplicateWarning = errors.New("identical warning given recently")
+
+// WarnUser warns the user, used by other plugins like automod. Duplicate warnings are only suppressed for the Warn command,
+// repeated automatic warnings are intended.
 func WarnUser(config *Config, guildID int64, channel *dstate.ChannelState, msg *discordgo.Message, author *discordgo.User, target *discordgo.User, message string) error {
+	return warnUser(config, guildID, channel, msg, author, target, message, "", "", true)
+}
+
+// warnUser is the same as WarnUser, but also stores a link to the offending message as evidence and the category of the warning,
+// allowDuplicate skips the check for identical recent warnings
+func warnUser(config *Config, guildID int64, channel *dstate.ChannelState, msg *discordgo.Message, author *discordgo.User, target *discordgo.User, message string, messageLink string, category string, allowDuplicate bool) error {
+	config, err := getConfigIfNotSet(guildID, config)
+	if err != nil {
+		return common.ErrWithCaller(err)
+	}
+
+	dedupMessage := message
+	if config.WarnReasonTemplate != "" {
+		var previous int
+		err = common.GORM.Model(&WarningModel{}).Where("guild_id = ? AND user_id = ?", guildID, discordgo.StrID(target.ID)).Count(&previous).Error
+		if err != nil {
+			return common.ErrWithCaller(err)
+		}
+
+		// an identical warning given right before this one was rendered with the count at that time
+		dedupMessage = renderWarnReason(config, author, target, message, previous)
+		message = renderWarnReason(config, author, target, message, previous+1)
+	}
+
+	if !allowDuplicate && config.WarnDedupSeconds > 0 {
+		dupe, err := recentDuplicateWarning(guildID, author.ID, target.ID, dedupMessage, time.Duration(config.WarnDedupSeconds)*time.Second)
+		if err != nil {
+			return common.ErrWithCaller(err)
+		}
+		if dupe {
+			return ErrDuplicateWarning
+		}
+	}
+
 	warning := &WarningModel{
 		GuildID:               guildID,
 		UserID:                discordgo.StrID(target.ID),
 		AuthorID:              discordgo.StrID(author.ID),
 		AuthorUsernameDiscrim: author.Username + "#" + author.Discriminator,
 
-		Message: message,
+		Message:     message,
+		MessageLink: messageLink,
+		Category:    category,
 	}
 
 	var channelID int64
@@ -471,11 +935,6 @@ func WarnUser(config *Config, guildID int64, channel *dstate.ChannelState, msg *
 		channelID = channel.ID
 	}
 
-	config, err := getConfigIfNotSet(guildID, config)
-	if err != nil {
-		return common.ErrWithCaller(err)
-	}
-
 	if config.WarnIncludeChannelLogs && channelID != 0 {
 		warning.LogsLink = CreateLogs(guildID, channelID, author)
 	}
@@ -486,24 +945,88 @@ func WarnUser(config *Config, guildID int64, channel *dstate.ChannelState, msg *
 		return common.ErrWithCaller(err)
 	}
 
+	var count int
+	err = common.GORM.Model(&WarningModel{}).Where("guild_id = ? AND user_id = ?", guildID, discordgo.StrID(target.ID)).Count(&count).Error
+	if err != nil {
+		logger.WithError(err).WithField("guild", guildID).Error("failed counting warnings")
+	}
+
+	dmResult := "not DM'd, they're not in the server"
 	gs := bot.State.Guild(true, guildID)
 	ms, _ := bot.GetMember(guildID, target.ID)
 	if gs != nil && ms != nil {
-		sendPunishDM(config, config.WarnMessage, MAWarned, gs, channel, msg, author, ms, -1, message)
+		dmResult = sendWarnDM(config, gs, channel, msg, author, ms, message, category, count)
 	}
 
+	scheduleWarnReview(config, guildID, target.ID, count)
+
 	// go bot.SendDM(target.ID, fmt.Sprintf("**%s**: You have been warned for: %s", bot.GuildName(guildID), message))
 
+	action := MAWarned
+	if config.WarnDMStatusInModlog {
+		addFooterNote(&action, dmResult)
+	}
+
 	if config.WarnSendToModlog && config.ActionChannel != "" {
-		err = CreateModlogEmbed(config, author, MAWarned, target, message, warning.LogsLink)
+		err = CreateModlogEmbed(config, author, action, target, message, warning.LogsLink)
 		if err != nil {
 			return common.ErrWithCaller(err)
 		}
+	} else {
+		logModAction(config, author, MAWarned, target, message, warning.LogsLink)
+		publishModAction(guildID, author, MAWarned, target, message)
 	}
 
 	return nil
 }
 
+// repliedMessageLink returns a link to the message msg is a reply to, or an empty string if it isn't a reply
+func repliedMessageLink(guildID int64, msg *discordgo.Message) string {
+	if msg == nil || msg.MessageReference == nil || msg.MessageReference.MessageID == 0 {
+		return ""
+	}
+
+	return "https://discord.com/channels/" + discordgo.StrID(guildID) + "/" + discordgo.StrID(msg.MessageReference.ChannelID) + "/" + discordgo.StrID(msg.MessageReference.MessageID)
+}
+
+// MassEditReasons appends to or replaces the reasons of all warnings and active mutes made by the moderator,
+// optionally only the ones created after since. Returns the number of updated warnings and mutes.
+func MassEditReasons(guildID, authorID int64, since time.Time, reason string, replace bool) (warnings int64, mutes int64, err error) {
+	warnQuery := "UPDATE moderation_warnings SET message = message || ' ' || $4 WHERE guild_id = $1 AND author_id = $2 AND created_at > $3"
+	muteQuery := "UPDATE muted_users SET reason = reason || ' ' || $4 WHERE guild_id = $1 AND author_id = $2 AND created_at > $3"
+	if replace {
+		warnQuery = "UPDATE moderation_warnings SET message = $4 WHERE guild_id = $1 AND author_id = $2 AND created_at > $3"
+		muteQuery = "UPDATE muted_users SET reason = $4 WHERE guild_id = $1 AND author_id = $2 AND created_at > $3"
+	}
+
+	tx, err := common.PQ.Begin()
+	if err != nil {
+		return 0, 0, errors.WithStackIf(err)
+	}
+
+	// warnings store the author id as a string
+	res, err := tx.Exec(warnQuery, guildID, discordgo.StrID(authorID), since, reason)
+	if err != nil {
+		tx.Rollback()
+		return 0, 0, errors.WithStackIf(err)
+	}
+	warnings, _ = res.RowsAffected()
+
+	res, err = tx.Exec(muteQuery, guildID, authorID, since, reason)
+	if err != nil {
+		tx.Rollback()
+		return 0, 0, errors.WithStackIf(err)
+	}
+	mutes, _ = res.RowsAffected()
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, 0, errors.WithStackIf(err)
+	}
+
+	return warnings, mutes, nil
+}
+
 func CreateLogs(guildID, channelID int64, user *discordgo.User) string {
 	lgs, err := logs.CreateChannelLog(context.TODO(), nil, guildID, channelID, user.Username, user.ID, 100)
 	if err != nil {
@@ -0,0 +1,316 @@
+package moderation
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jonas747/discordgo"
+	"github.com/jonas747/dstate"
+	"github.com/jonas747/yagpdb/bot"
+	"github.com/jonas747/yagpdb/common"
+	"github.com/jonas747/yagpdb/common/configstore"
+	"github.com/lib/pq"
+	"github.com/mediocregopher/radix/v3"
+)
+
+func testMuteConfig() *Config {
+	return &Config{
+		GuildConfigModel: configstore.GuildConfigModel{GuildID: 1},
+		MuteRole:         "10",
+		MuteRemoveRoles:  pq.Int64Array{20, 21},
+	}
+}
+
+func TestAddMemberMuteRole(t *testing.T) {
+	m, restore := useMockSession()
+	defer restore()
+
+	removed, err := AddMemberMuteRole(testMuteConfig(), 100, []int64{20, 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(removed) != 1 || removed[0] != 20 {
+		t.Errorf("unexpected removed roles: %v", removed)
+	}
+
+	roles := m.memberEdits[100]
+	if len(roles) != 2 || roles[0] != "10" || roles[1] != "30" {
+		t.Errorf("unexpected new roles: %v", roles)
+	}
+}
+
+func TestAddMemberMuteRoleNoChanges(t *testing.T) {
+	m, restore := useMockSession()
+	defer restore()
+
+	_, err := AddMemberMuteRole(testMuteConfig(), 100, []int64{10, 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := m.memberEdits[100]; ok {
+		t.Error("edited member that already had the mute role")
+	}
+}
+
+func TestAddMemberMuteRoleReplacesTier(t *testing.T) {
+	m, restore := useMockSession()
+	defer restore()
+
+	// muted with tier role 30 before, now switching to 31
+	_, err := addMemberMuteRole(testMuteConfig(), 100, []int64{30, 40}, 31, 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roles := m.memberEdits[100]
+	if len(roles) != 2 || roles[0] != "31" || roles[1] != "40" {
+		t.Errorf("unexpected new roles: %v", roles)
+	}
+}
+
+func TestRemoveMemberMuteRole(t *testing.T) {
+	m, restore := useMockSession()
+	defer restore()
+
+	mute := MuteModel{RemovedRoles: pq.Int64Array{20, 30}}
+	err := RemoveMemberMuteRole(testMuteConfig(), 100, []int64{10, 30}, mute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roles := m.memberEdits[100]
+	if len(roles) != 2 || roles[0] != "30" || roles[1] != "20" {
+		t.Errorf("unexpected new roles: %v", roles)
+	}
+}
+
+func TestResolvePunishedUser(t *testing.T) {
+	m, restore := useMockSession()
+	defer restore()
+
+	oldDelay, oldBotUser := auditLogDelay, common.BotUser
+	auditLogDelay = 0
+	common.BotUser = &discordgo.User{ID: 1}
+	defer func() { auditLogDelay, common.BotUser = oldDelay, oldBotUser }()
+
+	m.auditLog = &discordgo.GuildAuditLog{
+		Users: []*discordgo.User{{ID: 100, Username: "fromauditlog", Discriminator: "0002"}},
+	}
+
+	// the member was present when the command ran but left before the ban went through, keep what we already know
+	cached := &discordgo.User{ID: 100, Username: "cached", Discriminator: "0001"}
+	if got := resolvePunishedUser(1, PunishmentBan, cached); got != cached {
+		t.Errorf("expected the cached user, got %#v", got)
+	}
+
+	if m.auditLogCalls != 0 {
+		t.Error("looked up the audit log for a user we already knew")
+	}
+
+	// never on the server, banned by id
+	unknown := &discordgo.User{ID: 100, Username: "unknown", Discriminator: "????"}
+	if got := resolvePunishedUser(1, PunishmentBan, unknown); got.Username != "fromauditlog" {
+		t.Errorf("expected the user from the audit log, got %#v", got)
+	}
+}
+
+func TestRepliedMessageLink(t *testing.T) {
+	if got := repliedMessageLink(1, &discordgo.Message{}); got != "" {
+		t.Errorf("got a link for a message that isn't a reply: %q", got)
+	}
+
+	msg := &discordgo.Message{MessageReference: &discordgo.MessageReference{ChannelID: 2, MessageID: 3}}
+	if got := repliedMessageLink(1, msg); got != "https://discord.com/channels/1/2/3" {
+		t.Errorf("unexpected link: %q", got)
+	}
+}
+
+func TestClearDepartedMute(t *testing.T) {
+	if common.GORM == nil || common.PQ == nil || common.RedisPool == nil {
+		t.Skip("database not available, skipping.")
+		return
+	}
+
+	_, restore := useMockSession()
+	defer restore()
+
+	config := testMuteConfig()
+	target := &discordgo.User{ID: 1001, Username: "departed", Discriminator: "0001"}
+	author := &discordgo.User{ID: 1002, Username: "mod", Discriminator: "0002"}
+
+	err := common.GORM.Create(&MuteModel{GuildID: config.GuildID, UserID: target.ID}).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the unmute was left for the reconciler
+	err = common.GORM.Create(&PendingExpiryModel{GuildID: config.GuildID, UserID: target.ID, EventName: "moderation_unmute", ExpiresAt: time.Now().Add(time.Hour)}).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cleared, err := clearDepartedMute(config, config.GuildID, author, target, "left")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cleared {
+		t.Error("mute of departed user wasn't cleared")
+	}
+
+	var count int
+	common.GORM.Model(&MuteModel{}).Where("guild_id = ? AND user_id = ?", config.GuildID, target.ID).Count(&count)
+	if count != 0 {
+		t.Errorf("mute row still exists after unmuting departed user")
+	}
+
+	unmuteAt, err := scheduledActionTime(config.GuildID, target.ID, "moderation_unmute")
+	if err != nil || !unmuteAt.IsZero() {
+		t.Errorf("expected the pending unmute to be cleared, got %v, %v", unmuteAt, err)
+	}
+
+	cleared, err = clearDepartedMute(config, config.GuildID, author, target, "left")
+	if err != nil || cleared {
+		t.Errorf("cleared a mute that didn't exist: %t %v", cleared, err)
+	}
+}
+
+func TestIsDuplicateWarning(t *testing.T) {
+	now := time.Now()
+	window := time.Minute
+
+	w := &WarningModel{AuthorID: "1", Message: "spamming"}
+	w.CreatedAt = now.Add(-time.Second * 30)
+
+	if !isDuplicateWarning(w, 1, " spamming ", window, now) {
+		t.Error("identical warning within the window wasn't a duplicate")
+	}
+
+	if isDuplicateWarning(w, 2, "spamming", window, now) {
+		t.Error("warning by another author was a duplicate")
+	}
+
+	if isDuplicateWarning(w, 1, "being rude", window, now) {
+		t.Error("warning with another reason was a duplicate")
+	}
+
+	w.CreatedAt = now.Add(-time.Minute * 2)
+	if isDuplicateWarning(w, 1, "spamming", window, now) {
+		t.Error("warning outside the window was a duplicate")
+	}
+}
+
+func TestMuteUnmuteUser(t *testing.T) {
+	if common.GORM == nil || common.PQ == nil || common.RedisPool == nil {
+		t.Skip("database not available, skipping.")
+		return
+	}
+
+	if bot.State == nil {
+		t.Skip("bot state not available, skipping.")
+		return
+	}
+
+	m, restore := useMockSession()
+	defer restore()
+
+	config := testMuteConfig()
+	author := &discordgo.User{ID: 1002, Username: "mod", Discriminator: "0002"}
+	member := &dstate.MemberState{ID: 1003, Roles: []int64{20, 30}}
+	defer common.GORM.Where("guild_id = ? AND user_id = ?", config.GuildID, member.ID).Delete(MuteModel{})
+	defer cancelScheduledAction(config.GuildID, member.ID, "moderation_unmute")
+
+	err := MuteUnmuteUser(config, true, config.GuildID, nil, nil, author, "spam", member, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roles := m.memberEdits[member.ID]
+	if len(roles) != 2 || roles[0] != "10" || roles[1] != "30" {
+		t.Errorf("expected the mute role to replace the removed roles, got %v", roles)
+	}
+
+	var mute MuteModel
+	err = common.GORM.Where("guild_id = ? AND user_id = ?", config.GuildID, member.ID).First(&mute).Error
+	if err != nil {
+		t.Fatalf("expected a mute entry, got %v", err)
+	}
+	if mute.ExpiresAt.IsZero() || mute.Reason != "spam" || len(mute.RemovedRoles) != 1 || mute.RemovedRoles[0] != 20 {
+		t.Errorf("unexpected mute entry %+v", mute)
+	}
+
+	unmuteAt, err := scheduledActionTime(config.GuildID, member.ID, "moderation_unmute")
+	if err != nil || unmuteAt.IsZero() {
+		t.Errorf("expected the unmute to be scheduled, got %v, %v", unmuteAt, err)
+	}
+
+	member.Roles = []int64{30, 10}
+	err = MuteUnmuteUser(config, false, config.GuildID, nil, nil, author, "appealed", member, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roles = m.memberEdits[member.ID]
+	if len(roles) != 2 || roles[0] != "30" || roles[1] != "20" {
+		t.Errorf("expected the removed roles to be given back, got %v", roles)
+	}
+
+	var count int
+	common.GORM.Model(&MuteModel{}).Where("guild_id = ? AND user_id = ?", config.GuildID, member.ID).Count(&count)
+	if count != 0 {
+		t.Error("mute entry still exists after unmuting")
+	}
+
+	unmuteAt, err = scheduledActionTime(config.GuildID, member.ID, "moderation_unmute")
+	if err != nil || !unmuteAt.IsZero() {
+		t.Errorf("expected the scheduled unmute to be cancelled, got %v, %v", unmuteAt, err)
+	}
+}
+
+func TestBanUserWithDuration(t *testing.T) {
+	if common.GORM == nil || common.PQ == nil || common.RedisPool == nil {
+		t.Skip("database not available, skipping.")
+		return
+	}
+
+	if bot.State == nil {
+		t.Skip("bot state not available, skipping.")
+		return
+	}
+
+	m, restore := useMockSession()
+	defer restore()
+
+	oldBotUser := common.BotUser
+	common.BotUser = &discordgo.User{ID: 1}
+	defer func() { common.BotUser = oldBotUser }()
+
+	config := testMuteConfig()
+	author := &discordgo.User{ID: 1002, Username: "mod", Discriminator: "0002"}
+	user := &discordgo.User{ID: 1004, Username: "banned", Discriminator: "0003"}
+	defer cancelScheduledAction(config.GuildID, user.ID, "moderation_unban")
+	defer common.RedisPool.Do(radix.Cmd(nil, "DEL", RedisKeyBannedUser(config.GuildID, user.ID), RedisKeyBanLogged(config.GuildID, user.ID), RedisKeyLastBan(config.GuildID, author.ID)))
+
+	err := BanUserWithDuration(config, config.GuildID, nil, nil, author, "spam", user, time.Hour, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reason, ok := m.bans[user.ID]; !ok || !strings.Contains(reason, "spam") {
+		t.Errorf("expected the user to be banned with the reason, got %q", reason)
+	}
+
+	unbanAt, err := scheduledActionTime(config.GuildID, user.ID, "moderation_unban")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unbanAt.Before(time.Now().Add(time.Minute*59)) || unbanAt.After(time.Now().Add(time.Hour)) {
+		t.Errorf("expected the unban to be scheduled in an hour, got %v", unbanAt)
+	}
+
+	if !markerSet(RedisKeyBannedUser(config.GuildID, user.ID)) {
+		t.Error("expected the ban to be marked as done by the bot")
+	}
+}
@@ -0,0 +1,198 @@
+package moderation
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jonas747/discordgo"
+	"github.com/jonas747/yagpdb/bot/eventsystem"
+	"github.com/jonas747/yagpdb/common"
+	seventsmodels "github.com/jonas747/yagpdb/common/scheduledevents2/models"
+	"github.com/mediocregopher/radix/v3"
+)
+
+// Raid mode locks down the configured channels when too many members join within a short time,
+// using the same snapshots as the Lockdown command so they can be unlocked manually with Unlock as well.
+
+// How long raid mode can't be triggered again if it has no cooldown and has to be lifted manually
+const RaidModeRetriggerDelay = time.Minute * 10
+
+func RedisKeyRaidJoins(guildID int64) string {
+	return "moderation_raid_joins:" + discordgo.StrID(guildID)
+}
+
+func RedisKeyRaidModeActive(guildID int64) string {
+	return "moderation_raid_mode_active:" + discordgo.StrID(guildID)
+}
+
+type ScheduledRaidModeEndData struct {
+	// The channels that were locked down by raid mode, channels that were already locked down are left alone
+	Channels []int64 `json:"channels"`
+}
+
+func (c *Config) raidModeWindow() time.Duration {
+	return time.Duration(c.RaidModeWindow) * time.Second
+}
+
+func (c *Config) raidModeCooldown() time.Duration {
+	return time.Duration(c.RaidModeCooldown) * time.Minute
+}
+
+// recordRaidJoin records the join and returns the number of joins within the raid mode window
+func recordRaidJoin(config *Config, guildID, userID int64, t time.Time) (int, error) {
+	key := RedisKeyRaidJoins(guildID)
+	now := t.UnixNano() / int64(time.Millisecond)
+	windowStart := now - int64(config.raidModeWindow()/time.Millisecond)
+
+	var count int
+	err := common.RedisPool.Do(radix.Pipeline(
+		radix.FlatCmd(nil, "ZADD", key, now, userID),
+		radix.FlatCmd(nil, "ZREMRANGEBYSCORE", key, "-inf", "("+strconv.FormatInt(windowStart, 10)),
+		radix.Cmd(&count, "ZCARD", key),
+		radix.FlatCmd(nil, "PEXPIRE", key, int64(config.raidModeWindow()/time.Millisecond)),
+	))
+	return count, err
+}
+
+// HandleMemberJoinRaidMode counts joins and triggers raid mode if there were too many
+func HandleMemberJoinRaidMode(evt *eventsystem.EventData) (retry bool, err error) {
+	c := evt.GuildMemberAdd()
+
+	config, err := GetConfig(c.GuildID)
+	if err != nil {
+		return true, err
+	}
+
+	if !config.RaidModeEnabled || config.RaidModeJoinThreshold < 2 || config.RaidModeWindow < 1 || len(config.RaidModeChannels) < 1 {
+		return false, nil
+	}
+
+	count, err := recordRaidJoin(config, c.GuildID, c.User.ID, time.Now())
+	if err != nil {
+		return false, err
+	}
+
+	if count < config.RaidModeJoinThreshold {
+		return false, nil
+	}
+
+	return false, triggerRaidMode(config, c.GuildID, count)
+}
+
+// triggerRaidMode locks down the raid mode channels and alerts staff, unless raid mode is already active
+func triggerRaidMode(config *Config, guildID int64, joins int) error {
+	ttl := config.raidModeCooldown()
+	if ttl <= 0 {
+		ttl = RaidModeRetriggerDelay
+	}
+
+	var set string
+	err := common.RedisPool.Do(radix.FlatCmd(&set, "SET", RedisKeyRaidModeActive(guildID), 1, "PX", int64(ttl/time.Millisecond), "NX"))
+	if err != nil || set != "OK" {
+		return err
+	}
+
+	logger.WithField("guild", guildID).WithField("joins", joins).Info("raid mode triggered")
+
+	var locked []int64
+	var failed []string
+	for _, channelID := range config.RaidModeChannels {
+		channel, err := session().Channel(channelID)
+		if err == nil {
+			var newLock bool
+			newLock, err = lockdownChannel(channel, common.BotUser.ID)
+			if err == nil && newLock {
+				locked = append(locked, channelID)
+			}
+		}
+
+		if err != nil {
+			logger.WithError(err).WithField("guild", guildID).WithField("channel", channelID).Error("failed locking down channel for raid mode")
+			failed = append(failed, fmt.Sprintf("<#%d>", channelID))
+		}
+	}
+
+	msg := fmt.Sprintf("🚨 **Raid mode triggered**: %d members joined within %d seconds, locked down %d channel(s).", joins, config.RaidModeWindow, len(locked))
+	if len(failed) > 0 {
+		msg += "\nFailed locking down: " + strings.Join(failed, ", ")
+	}
+
+	if config.RaidModeCooldown > 0 && len(locked) > 0 {
+		err = scheduleEvent("moderation_raid_mode_end", guildID, time.Now().Add(config.raidModeCooldown()), &ScheduledRaidModeEndData{Channels: locked})
+		if err != nil {
+			logger.WithError(err).WithField("guild", guildID).Error("failed scheduling the end of raid mode")
+			msg += "\nCouldn't schedule lifting raid mode, use the `unlock` command on the channels to lift it."
+		} else {
+			msg += fmt.Sprintf("\nThey will be unlocked in %d minute(s), or use the `unlock` command to do it sooner.", config.RaidModeCooldown)
+		}
+	} else if len(locked) > 0 {
+		msg += "\nUse the `unlock` command on the channels to lift it."
+	}
+
+	sendRaidModeAlert(config, msg)
+	return nil
+}
+
+// sendRaidModeAlert sends the message to the report channel, pinging the report mention role
+func sendRaidModeAlert(config *Config, msg string) {
+	channelID := config.IntReportChannel()
+	if channelID == 0 {
+		channelID = config.IntActionChannel()
+	}
+	if channelID == 0 {
+		return
+	}
+
+	var allowedMentions discordgo.AllowedMentions
+	if mentionRole := config.IntReportMentionRole(); mentionRole != 0 {
+		msg = fmt.Sprintf("<@&%d> ", mentionRole) + msg
+		allowedMentions.Roles = []int64{mentionRole}
+	}
+
+	_, err := session().ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+		Content:         msg,
+		AllowedMentions: allowedMentions,
+	})
+	if err != nil {
+		logger.WithError(err).WithField("guild", config.GetGuildID()).Error("failed sending raid mode alert")
+	}
+}
+
+func handleScheduledRaidModeEnd(evt *seventsmodels.ScheduledEvent, data interface{}) (retry bool, err error) {
+	channels := data.(*ScheduledRaidModeEndData).Channels
+
+	unlocked := 0
+	for _, channelID := range channels {
+		channel, err := session().Channel(channelID)
+		if err != nil {
+			if common.IsDiscordErr(err, discordgo.ErrCodeUnknownChannel, discordgo.ErrCodeMissingAccess) {
+				continue
+			}
+			return true, err
+		}
+
+		// already unlocked manually if there's no snapshot anymore
+		restored, err := unlockChannel(channel)
+		if err != nil {
+			return true, err
+		}
+
+		if restored {
+			unlocked++
+		}
+	}
+
+	if unlocked < 1 {
+		return false, nil
+	}
+
+	config, err := GetConfig(evt.GuildID)
+	if err != nil {
+		return true, err
+	}
+
+	sendRaidModeAlert(config, fmt.Sprintf("✅ Raid mode is over, unlocked %d channel(s).", unlocked))
+	return false, nil
+}
@@ -0,0 +1,68 @@
+package moderation
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jonas747/discordgo"
+	"github.com/jonas747/yagpdb/common"
+	"github.com/lib/pq"
+	"github.com/mediocregopher/radix/v3"
+)
+
+func TestRecordRaidJoin(t *testing.T) {
+	if common.RedisPool == nil {
+		t.Skip("redis not available, skipping.")
+		return
+	}
+
+	defer common.RedisPool.Do(radix.Cmd(nil, "DEL", RedisKeyRaidJoins(1)))
+
+	config := &Config{RaidModeWindow: 10}
+	now := time.Now()
+
+	for i, offset := range []time.Duration{-time.Minute, -5 * time.Second, 0} {
+		count, err := recordRaidJoin(config, 1, int64(i+1), now.Add(offset))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the first join is outside the window by the time the later ones come in
+		if i > 0 && count != i {
+			t.Errorf("join %d: expected %d joins in the window, got %d", i, i, count)
+		}
+	}
+}
+
+func TestTriggerRaidModeFailedLock(t *testing.T) {
+	if common.RedisPool == nil {
+		t.Skip("redis not available, skipping.")
+		return
+	}
+
+	m, restore := useMockSession()
+	defer restore()
+	m.permSetErr = errors.New("missing permissions")
+
+	oldBotUser := common.BotUser
+	common.BotUser = &discordgo.User{ID: 1}
+	defer func() { common.BotUser = oldBotUser }()
+
+	defer common.RedisPool.Do(radix.Cmd(nil, "DEL", RedisKeyRaidModeActive(1), RedisKeyLockdownSnapshot(20)))
+
+	config := &Config{ActionChannel: "10", RaidModeWindow: 10, RaidModeChannels: pq.Int64Array{20}}
+	err := triggerRaidMode(config, 1, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(m.sentMessages) != 1 || !strings.Contains(m.sentMessages[0], "locked down 0 channel(s)") || !strings.Contains(m.sentMessages[0], "Failed locking down: <#20>") {
+		t.Errorf("expected the channel to only be listed as failed, got %v", m.sentMessages)
+	}
+
+	if snapshot, _ := LockdownSnapshotInfo(20); snapshot != nil {
+		t.Error("expected the snapshot of the channel that wasn't locked down to be removed")
+	}
+}
@@ -0,0 +1,124 @@
+package moderation
+
+import (
+	"context"
+	"time"
+
+	"github.com/jonas747/discordgo"
+	"github.com/jonas747/yagpdb/common"
+	"github.com/jonas747/yagpdb/common/scheduledevents2"
+	seventsmodels "github.com/jonas747/yagpdb/common/scheduledevents2/models"
+	"github.com/volatiletech/sqlboiler/queries/qm"
+)
+
+// ReportModel keeps track of report messages sent to the report channel so they can be purged after ReportRetentionDays,
+// and of reports that were snoozed or resolved
+type ReportModel struct {
+	common.SmallModel
+
+	GuildID   int64 `gorm:"index"`
+	ChannelID int64
+	MessageID int64
+
+	ReporterID int64
+	TargetID   int64
+
+	// See ReportStateOpen and friends, Content is the original report message before the state was shown on it
+	State        string `gorm:"default:'open'"`
+	Content      string
+	SnoozedUntil time.Time
+}
+
+func (r *ReportModel) TableName() string {
+	return "moderation_reports"
+}
+
+// recordReport stores the report message and makes sure a purge is scheduled, does nothing if report retention is disabled
+func recordReport(config *Config, guildID int64, m *discordgo.Message, reporterID, targetID int64) {
+	if config.ReportRetentionDays <= 0 || m == nil {
+		return
+	}
+
+	err := common.GORM.Create(&ReportModel{
+		GuildID:    guildID,
+		ChannelID:  m.ChannelID,
+		MessageID:  m.ID,
+		ReporterID: reporterID,
+		TargetID:   targetID,
+	}).Error
+	if err != nil {
+		logger.WithError(err).WithField("guild", guildID).Error("failed recording report")
+		return
+	}
+
+	err = scheduleReportPurge(guildID, time.Now().Add(reportRetention(config)))
+	if err != nil {
+		logger.WithError(err).WithField("guild", guildID).Error("failed scheduling report purge")
+	}
+}
+
+func reportRetention(config *Config) time.Duration {
+	return time.Hour * 24 * time.Duration(config.ReportRetentionDays)
+}
+
+// scheduleReportPurge schedules a purge at t, unless one is already pending
+func scheduleReportPurge(guildID int64, t time.Time) error {
+	exists, err := seventsmodels.ScheduledEvents(qm.Where("event_name='moderation_purge_reports' AND guild_id = ? AND processed = false", guildID)).Exists(context.Background(), common.PQ)
+	if err != nil || exists {
+		return err
+	}
+
+	return scheduledevents2.ScheduleEvent("moderation_purge_reports", guildID, t, nil)
+}
+
+func handleScheduledPurgeReports(evt *seventsmodels.ScheduledEvent, data interface{}) (retry bool, err error) {
+	config, err := GetConfig(evt.GuildID)
+	if err != nil {
+		return true, err
+	}
+
+	if config.ReportRetentionDays <= 0 {
+		// retention was turned off since this was scheduled, keep everything
+		return false, nil
+	}
+
+	var reports []*ReportModel
+	err = common.GORM.Where("guild_id = ? AND created_at < ?", evt.GuildID, time.Now().Add(-reportRetention(config))).Find(&reports).Error
+	if err != nil {
+		return true, err
+	}
+
+	for _, v := range reports {
+		m, err := session().ChannelMessage(v.ChannelID, v.MessageID)
+		if err != nil {
+			if common.IsDiscordErr(err, discordgo.ErrCodeUnknownMessage, discordgo.ErrCodeUnknownChannel, discordgo.ErrCodeMissingAccess) {
+				// already gone or we can't see it anymore, just drop the record
+				common.GORM.Delete(v)
+				continue
+			}
+
+			return scheduledevents2.CheckDiscordErrRetry(err), err
+		}
+
+		// only ever delete our own messages
+		if m.Author != nil && m.Author.ID == common.BotUser.ID {
+			err = session().ChannelMessageDelete(v.ChannelID, v.MessageID)
+			if err != nil && !common.IsDiscordErr(err, discordgo.ErrCodeUnknownMessage) {
+				return scheduledevents2.CheckDiscordErrRetry(err), err
+			}
+		}
+
+		common.GORM.Delete(v)
+	}
+
+	// schedule the next purge for the oldest remaining report
+	var next ReportModel
+	err = common.GORM.Where("guild_id = ?", evt.GuildID).Order("created_at asc").First(&next).Error
+	if err == nil {
+		// this event is still marked as pending, so schedule directly
+		err = scheduledevents2.ScheduleEvent("moderation_purge_reports", evt.GuildID, next.CreatedAt.Add(reportRetention(config)), nil)
+		return false, err
+	}
+
+	return false, nil
+}
@@ -0,0 +1,200 @@
+package moderation
+
+import (
+	"fmt"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/jinzhu/gorm"
+	"github.com/jonas747/dcmd"
+	"github.com/jonas747/discordgo"
+	"github.com/jonas747/yagpdb/commands"
+	"github.com/jonas747/yagpdb/common"
+	"github.com/jonas747/yagpdb/common/scheduledevents2"
+	seventsmodels "github.com/jonas747/yagpdb/common/scheduledevents2/models"
+)
+
+// Staff can snooze a report to deal with it later, it's collapsed until the snooze is over and then re-surfaced
+// if it wasn't resolved in the meantime. The state is shown at the top of the report message.
+
+const (
+	ReportStateOpen     = "open"
+	ReportStateSnoozed  = "snoozed"
+	ReportStateResolved = "resolved"
+)
+
+const (
+	DefaultReportSnooze = time.Hour
+	MaxReportSnooze     = time.Hour * 24 * 7
+)
+
+type ScheduledUnsnoozeReportData struct {
+	ReportID int64 `json:"report_id"`
+}
+
+var errNotAReport = errors.New("not a report")
+
+// findReport returns the report for the message in the report channel, creating the record if reports aren't retained
+func findReport(config *Config, guildID, messageID int64) (*ReportModel, *discordgo.Message, error) {
+	channelID := config.IntReportChannel()
+	if channelID == 0 {
+		return nil, nil, errNotAReport
+	}
+
+	m, err := session().ChannelMessage(channelID, messageID)
+	if err != nil {
+		if common.IsDiscordErr(err, discordgo.ErrCodeUnknownMessage) {
+			return nil, nil, errNotAReport
+		}
+		return nil, nil, err
+	}
+
+	if m.Author == nil || m.Author.ID != common.BotUser.ID {
+		return nil, nil, errNotAReport
+	}
+
+	var report ReportModel
+	err = common.GORM.Where("guild_id = ? AND message_id = ?", guildID, messageID).First(&report).Error
+	if err == gorm.ErrRecordNotFound {
+		report = ReportModel{
+			GuildID:   guildID,
+			ChannelID: channelID,
+			MessageID: messageID,
+		}
+		err = nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if report.Content == "" {
+		// the original report, before any state was shown on it
+		report.Content = m.Content
+	}
+
+	return &report, m, nil
+}
+
+// reportContent returns the content of the report message in the state
+func reportContent(report *ReportModel, by *discordgo.User) string {
+	switch report.State {
+	case ReportStateSnoozed:
+		return fmt.Sprintf("💤 **Report snoozed by %s#%s until %s UTC**", by.Username, by.Discriminator, report.SnoozedUntil.UTC().Format("02 Jan 15:04"))
+	case ReportStateResolved:
+		return fmt.Sprintf("✅ **Resolved by %s#%s**\n%s", by.Username, by.Discriminator, report.Content)
+	}
+
+	return report.Content
+}
+
+// setReportState updates the state of the report and edits the report message to reflect it
+func setReportState(report *ReportModel, state string, by *discordgo.User, snooze time.Duration) error {
+	report.State = state
+	report.SnoozedUntil = time.Time{}
+	if state == ReportStateSnoozed {
+		report.SnoozedUntil = time.Now().Add(snooze)
+	}
+
+	err := common.GORM.Save(report).Error
+	if err != nil {
+		return errors.WithStackIf(err)
+	}
+
+	_, err = session().ChannelMessageEdit(report.ChannelID, report.MessageID, reportContent(report, by))
+	if err != nil {
+		return err
+	}
+
+	if state == ReportStateSnoozed {
+		return scheduleEvent("moderation_unsnooze_report", report.GuildID, report.SnoozedUntil, &ScheduledUnsnoozeReportData{
+			ReportID: report.ID,
+		})
+	}
+
+	return nil
+}
+
+func handleScheduledUnsnoozeReport(evt *seventsmodels.ScheduledEvent, data interface{}) (retry bool, err error) {
+	reportID := data.(*ScheduledUnsnoozeReportData).ReportID
+
+	var report ReportModel
+	err = common.GORM.Where("guild_id = ? AND id = ?", evt.GuildID, reportID).First(&report).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			// purged since
+			return false, nil
+		}
+		return true, err
+	}
+
+	// resolved or snoozed again since this was scheduled
+	if report.State != ReportStateSnoozed || time.Now().Before(report.SnoozedUntil) {
+		return false, nil
+	}
+
+	report.State = ReportStateOpen
+	err = common.GORM.Save(&report).Error
+	if err != nil {
+		return true, err
+	}
+
+	_, err = session().ChannelMessageEdit(report.ChannelID, report.MessageID, report.Content)
+	if err != nil {
+		if common.IsDiscordErr(err, discordgo.ErrCodeUnknownMessage, discordgo.ErrCodeUnknownChannel, discordgo.ErrCodeMissingAccess) {
+			return false, nil
+		}
+		return scheduledevents2.CheckDiscordErrRetry(err), err
+	}
+
+	// the report is probably far up by now, point to it so it doesn't get missed
+	link := fmt.Sprintf("https://discord.com/channels/%d/%d/%d", report.GuildID, report.ChannelID, report.MessageID)
+	_, err = session().ChannelMessageSend(report.ChannelID, "⏰ A snoozed report is back: <"+link+">")
+	if err != nil {
+		logger.WithError(err).WithField("guild", evt.GuildID).Error("failed re-surfacing snoozed report")
+	}
+
+	return false, nil
+}
+
+func cmdSetReportState(parsed *dcmd.Data, state string) (interface{}, error) {
+	config, _, err := MBaseCmd(parsed, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = MBaseCmdSecond(parsed, "", true, discordgo.PermissionManageMessages, nil, false, config.ReportEnabled)
+	if err != nil {
+		return nil, err
+	}
+
+	report, _, err := findReport(config, parsed.GS.ID, parsed.Args[0].Int64())
+	if err != nil {
+		if err == errNotAReport {
+			return nil, commands.NewUserError("That's not a report in the report channel")
+		}
+		return nil, err
+	}
+
+	if report.State == ReportStateResolved {
+		return nil, commands.NewUserError("That report is already resolved")
+	}
+
+	snooze := DefaultReportSnooze
+	if state == ReportStateSnoozed && parsed.Args[1].Value != nil {
+		snooze = parsed.Args[1].Value.(time.Duration)
+		if snooze <= 0 || snooze > MaxReportSnooze {
+			return nil, commands.NewUserError("Reports can be snoozed for at most 7 days")
+		}
+	}
+
+	err = setReportState(report, state, parsed.Msg.Author, snooze)
+	if err != nil {
+		return nil, err
+	}
+
+	if state == ReportStateSnoozed {
+		return cmdConfirmation(config, "Snoozed the report for "+common.HumanizeDuration(common.DurationPrecisionMinutes, snooze)), nil
+	}
+
+	return cmdConfirmation(config, "Resolved the report"), nil
+}
@@ -0,0 +1,31 @@
+package moderation
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jonas747/discordgo"
+)
+
+func TestReportContent(t *testing.T) {
+	by := &discordgo.User{Username: "mod", Discriminator: "0001"}
+	report := &ReportModel{Content: "<@1> Reported <@2>", SnoozedUntil: time.Date(2020, 1, 2, 15, 4, 0, 0, time.UTC)}
+
+	report.State = ReportStateOpen
+	if got := reportContent(report, by); got != report.Content {
+		t.Errorf("open report should show the original content, got %q", got)
+	}
+
+	report.State = ReportStateSnoozed
+	got := reportContent(report, by)
+	if strings.Contains(got, report.Content) || !strings.Contains(got, "02 Jan 15:04") {
+		t.Errorf("snoozed report should be collapsed and show the snooze end, got %q", got)
+	}
+
+	report.State = ReportStateResolved
+	got = reportContent(report, by)
+	if !strings.HasPrefix(got, "✅ **Resolved by mod#0001**") || !strings.HasSuffix(got, report.Content) {
+		t.Errorf("unexpected resolved content %q", got)
+	}
+}
@@ -0,0 +1,138 @@
+package moderation
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/jonas747/yagpdb/common"
+	seventsmodels "github.com/jonas747/yagpdb/common/scheduledevents2/models"
+	"github.com/mediocregopher/radix/v3"
+	"github.com/volatiletech/sqlboiler/queries/qm"
+)
+
+// A new action always replaces the scheduled reversal of the same kind of action (a new ban cancels a pending
+// unban, a new mute or unmute cancels a pending unmute). With SupersedeScheduledActions enabled conflicting
+// actions are cleaned up as well: a ban also ends the user's mute, and bans and unbans done outside of the bot
+// cancel the pending unban. Either way the modlog entry of the new action notes what it replaced.
+
+func scheduledActionQuery(guildID, userID int64, eventName string) qm.QueryMod {
+	return qm.Where("event_name = ? AND guild_id = ? AND processed = false AND (data->>'user_id')::bigint = ?", eventName, guildID, userID)
+}
+
+// scheduledActionTime returns when the earliest scheduled unban or unmute of the user runs, including the ones
+// waiting on the reconciler, the zero time if there is none
+func scheduledActionTime(guildID, userID int64, eventName string) (time.Time, error) {
+	var runAt time.Time
+
+	events, err := seventsmodels.ScheduledEvents(scheduledActionQuery(guildID, userID, eventName)).All(context.Background(), common.PQ)
+	if err != nil {
+		return runAt, errors.WithStackIf(err)
+	}
+
+	for _, v := range events {
+		if runAt.IsZero() || v.TriggersAt.Before(runAt) {
+			runAt = v.TriggersAt
+		}
+	}
+
+	var pending []*PendingExpiryModel
+	err = common.GORM.Where("guild_id = ? AND user_id = ? AND event_name = ?", guildID, userID, eventName).Find(&pending).Error
+	if err != nil {
+		return runAt, errors.WithStackIf(err)
+	}
+
+	for _, v := range pending {
+		if runAt.IsZero() || v.ExpiresAt.Before(runAt) {
+			runAt = v.ExpiresAt
+		}
+	}
+
+	return runAt, nil
+}
+
+// cancelScheduledAction removes the scheduled unban or unmute of the user, including the ones waiting on the reconciler
+func cancelScheduledAction(guildID, userID int64, eventName string) error {
+	_, err := seventsmodels.ScheduledEvents(scheduledActionQuery(guildID, userID, eventName)).DeleteAll(context.Background(), common.PQ)
+	clearPendingExpiries(guildID, userID, eventName)
+	return errors.WithStackIf(err)
+}
+
+// supersededNote describes a scheduled action that's about to be cancelled for the footer of the modlog entry,
+// empty if there's nothing scheduled
+func supersededNote(eventName string, runAt time.Time, now time.Time) string {
+	if runAt.IsZero() {
+		return ""
+	}
+
+	action := "unmute"
+	if eventName == "moderation_unban" {
+		action = "unban"
+	}
+
+	if !runAt.After(now) {
+		return "replaces an overdue " + action
+	}
+
+	return "replaces the " + action + " scheduled in " + common.HumanizeDuration(common.DurationPrecisionMinutes, runAt.Sub(now))
+}
+
+// supersededNotes looks up the scheduled actions and describes them, errors are logged as the note is only informational
+func supersededNotes(guildID, userID int64, eventNames []string) string {
+	note := ""
+	for _, v := range eventNames {
+		runAt, err := scheduledActionTime(guildID, userID, v)
+		if err != nil {
+			logger.WithError(err).WithField("guild", guildID).WithField("user", userID).Error("failed looking up scheduled " + v)
+			continue
+		}
+
+		if n := supersededNote(v, runAt, time.Now()); n != "" {
+			if note != "" {
+				note += ", "
+			}
+			note += n
+		}
+	}
+
+	return note
+}
+
+// addFooterNote appends the note to the footer of a modlog action
+func addFooterNote(action *ModlogAction, note string) {
+	if note == "" {
+		return
+	}
+
+	if action.Footer != "" {
+		action.Footer += ", " + note
+		return
+	}
+
+	action.Footer = strings.ToUpper(note[:1]) + note[1:]
+}
+
+// banConflicts returns the scheduled actions a new ban replaces
+func banConflicts(config *Config) []string {
+	if config.SupersedeScheduledActions {
+		return []string{"moderation_unban", "moderation_unmute"}
+	}
+
+	return []string{"moderation_unban"}
+}
+
+// cancelConflicts cancels the scheduled actions after the action replacing them went through
+func cancelConflicts(guildID, userID int64, eventNames []string) {
+	for _, v := range eventNames {
+		err := cancelScheduledAction(guildID, userID, v)
+		common.LogIgnoreError(err, "[moderation] failed cancelling scheduled "+v, nil)
+
+		if v == "moderation_unmute" {
+			// the ban takes over, don't keep the mute around as active
+			err = common.GORM.Where("guild_id = ? AND user_id = ?", guildID, userID).Delete(MuteModel{}).Error
+			common.LogIgnoreError(err, "[moderation] failed removing superseded mute", nil)
+			common.RedisPool.Do(radix.Cmd(nil, "DEL", RedisKeyMutedUser(guildID, userID)))
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package moderation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jonas747/yagpdb/common"
+)
+
+func TestSupersededNote(t *testing.T) {
+	now := time.Now()
+
+	if got := supersededNote("moderation_unban", time.Time{}, now); got != "" {
+		t.Errorf("expected no note without a scheduled action, got %q", got)
+	}
+
+	if got := supersededNote("moderation_unban", now.Add(time.Hour*2), now); got != "replaces the unban scheduled in 2 hours" {
+		t.Errorf("unexpected note: %q", got)
+	}
+
+	if got := supersededNote("moderation_unmute", now.Add(-time.Minute), now); got != "replaces an overdue unmute" {
+		t.Errorf("unexpected note: %q", got)
+	}
+
+	action := MAMute
+	action.Footer = "Duration: permanent"
+	addFooterNote(&action, "replaces the unmute scheduled in 5 minutes")
+	if action.Footer != "Duration: permanent, replaces the unmute scheduled in 5 minutes" {
+		t.Errorf("unexpected footer: %q", action.Footer)
+	}
+
+	action = MABanned
+	addFooterNote(&action, "replaces an overdue unban")
+	if action.Footer != "Replaces an overdue unban" {
+		t.Errorf("unexpected footer: %q", action.Footer)
+	}
+}
+
+func TestBanConflicts(t *testing.T) {
+	if got := banConflicts(&Config{}); len(got) != 1 || got[0] != "moderation_unban" {
+		t.Errorf("a ban should only replace the pending unban by default, got %v", got)
+	}
+
+	if got := banConflicts(&Config{SupersedeScheduledActions: true}); len(got) != 2 || got[1] != "moderation_unmute" {
+		t.Errorf("a ban should also replace the pending unmute, got %v", got)
+	}
+}
+
+func TestCancelConflicts(t *testing.T) {
+	if common.GORM == nil || common.PQ == nil || common.RedisPool == nil {
+		t.Skip("db not available, skipping.")
+		return
+	}
+
+	const guildID, userID = 1, 3
+	unbanAt := time.Now().Add(time.Hour)
+	unmuteAt := time.Now().Add(time.Minute * 10)
+
+	common.GORM.Create(&PendingExpiryModel{GuildID: guildID, UserID: userID, EventName: "moderation_unban", ExpiresAt: unbanAt})
+	common.GORM.Create(&PendingExpiryModel{GuildID: guildID, UserID: userID, EventName: "moderation_unmute", ExpiresAt: unmuteAt})
+	common.GORM.Create(&MuteModel{GuildID: guildID, UserID: userID, ExpiresAt: unmuteAt})
+	defer common.GORM.Where("guild_id = ? AND user_id = ?", guildID, userID).Delete(MuteModel{})
+	defer clearPendingExpiries(guildID, userID, "moderation_unmute")
+
+	runAt, err := scheduledActionTime(guildID, userID, "moderation_unban")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := runAt.Sub(unbanAt); d > time.Second || d < -time.Second {
+		t.Errorf("expected the pending unban at %s, got %s", unbanAt, runAt)
+	}
+
+	// a permanent ban by default only replaces the unban, the mute is left alone
+	conflicts := banConflicts(&Config{})
+	cancelConflicts(guildID, userID, conflicts)
+
+	if runAt, _ := scheduledActionTime(guildID, userID, "moderation_unban"); !runAt.IsZero() {
+		t.Error("expected the pending unban to be cancelled")
+	}
+	if runAt, _ := scheduledActionTime(guildID, userID, "moderation_unmute"); runAt.IsZero() {
+		t.Error("expected the pending unmute to be kept")
+	}
+
+	// superseding also ends the mute
+	cancelConflicts(guildID, userID, banConflicts(&Config{SupersedeScheduledActions: true}))
+
+	if runAt, _ := scheduledActionTime(guildID, userID, "moderation_unmute"); !runAt.IsZero() {
+		t.Error("expected the pending unmute to be cancelled")
+	}
+
+	var count int
+	common.GORM.Model(MuteModel{}).Where("guild_id = ? AND user_id = ?", guildID, userID).Count(&count)
+	if count != 0 {
+		t.Errorf("expected the mute to be removed, %d left", count)
+	}
+}
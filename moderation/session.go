@@ -0,0 +1,53 @@
+package moderation
+
+import (
+	"io"
+
+	"github.com/jonas747/discordgo"
+	"github.com/jonas747/yagpdb/common"
+)
+
+// DiscordSession is the subset of the discord api used by the moderation plugin,
+// in production this is common.BotSession but tests can swap it out with a mock
+type DiscordSession interface {
+	GuildBanCreateWithReason(guildID, userID int64, reason string, days int) error
+	GuildBanDelete(guildID, userID int64) error
+	GuildMemberDeleteWithReason(guildID, userID int64, reason string) error
+	GuildMemberEdit(guildID, userID int64, roles []string) error
+	GuildMemberRoleAdd(guildID, userID, roleID int64) error
+	GuildAuditLog(guildID, userID, beforeID int64, actionType, limit int) (*discordgo.GuildAuditLog, error)
+
+	Channel(channelID int64) (*discordgo.Channel, error)
+	ChannelMessage(channelID, messageID int64) (*discordgo.Message, error)
+	ChannelMessages(channelID int64, limit int, beforeID, afterID, aroundID int64) ([]*discordgo.Message, error)
+	ChannelMessagesPinned(channelID int64) ([]*discordgo.Message, error)
+	ChannelMessageSend(channelID int64, content string) (*discordgo.Message, error)
+	ChannelMessageSendEmbed(channelID int64, embed *discordgo.MessageEmbed) (*discordgo.Message, error)
+	ChannelMessageSendComplex(channelID int64, data *discordgo.MessageSend) (*discordgo.Message, error)
+	ChannelFileSendWithMessage(channelID int64, content string, name string, r io.Reader) (*discordgo.Message, error)
+	ChannelMessageEdit(channelID, messageID int64, content string) (*discordgo.Message, error)
+	ChannelMessageEditEmbed(channelID, messageID int64, embed *discordgo.MessageEmbed) (*discordgo.Message, error)
+	ChannelMessageDelete(channelID, messageID int64) error
+	ChannelMessagesBulkDelete(channelID int64, messages []int64) error
+	ChannelPermissionSet(channelID, targetID int64, targetType string, allow, deny int) error
+	ChannelPermissionDelete(channelID, targetID int64) error
+
+	UserChannelCreate(recipientID int64) (*discordgo.Channel, error)
+
+	WebhookExecute(webhookID int64, token string, wait bool, data *discordgo.WebhookParams) (err error)
+
+	RequestWithBucketID(method, urlStr string, data interface{}, bucketID string) ([]byte, error)
+}
+
+var _ DiscordSession = (*discordgo.Session)(nil)
+
+// sessionOverride is used instead of common.BotSession if set, only meant for tests
+var sessionOverride DiscordSession
+
+func session() DiscordSession {
+	if sessionOverride != nil {
+		return sessionOverride
+	}
+
+	return common.BotSession
+}
@@ -0,0 +1,191 @@
+package moderation
+
+import (
+	"io"
+
+	"github.com/jonas747/discordgo"
+)
+
+// mockSession records the calls made to it, methods the tests don't care about are no-ops
+type mockSession struct {
+	memberEdits  map[int64][]string
+	roleAdds     map[int64][]int64
+	deleted      []int64
+	bulkDeleted  [][]int64
+	sentMessages []string
+	sentEmbeds   []*discordgo.MessageEmbed
+	sentFiles    []string
+	bans         map[int64]string
+	kicks        map[int64]string
+	permSets     []*discordgo.PermissionOverwrite
+	permDeletes  []int64
+	webhooks     []*discordgo.WebhookParams
+
+	// errors returned when opening a dm channel and when sending messages to it
+	dmCreateErr error
+	dmSendErr   error
+
+	// returned by ChannelPermissionSet if set
+	permSetErr error
+
+	// returned by ChannelMessage if set
+	messages map[int64]*discordgo.Message
+
+	auditLog      *discordgo.GuildAuditLog
+	auditLogCalls int
+
+	nextMessageID int64
+}
+
+func newMockSession() *mockSession {
+	return &mockSession{
+		memberEdits: make(map[int64][]string),
+		roleAdds:    make(map[int64][]int64),
+		bans:        make(map[int64]string),
+		kicks:       make(map[int64]string),
+	}
+}
+
+// useMockSession installs a fresh mock session, call the returned function to restore the previous one
+func useMockSession() (*mockSession, func()) {
+	m := newMockSession()
+	old := sessionOverride
+	sessionOverride = m
+	return m, func() { sessionOverride = old }
+}
+
+func (m *mockSession) GuildBanCreateWithReason(guildID, userID int64, reason string, days int) error {
+	m.bans[userID] = reason
+	return nil
+}
+
+func (m *mockSession) GuildBanDelete(guildID, userID int64) error {
+	delete(m.bans, userID)
+	return nil
+}
+
+func (m *mockSession) GuildMemberDeleteWithReason(guildID, userID int64, reason string) error {
+	m.kicks[userID] = reason
+	return nil
+}
+
+func (m *mockSession) GuildMemberEdit(guildID, userID int64, roles []string) error {
+	m.memberEdits[userID] = roles
+	return nil
+}
+
+func (m *mockSession) GuildMemberRoleAdd(guildID, userID, roleID int64) error {
+	m.roleAdds[userID] = append(m.roleAdds[userID], roleID)
+	return nil
+}
+
+func (m *mockSession) GuildAuditLog(guildID, userID, beforeID int64, actionType, limit int) (*discordgo.GuildAuditLog, error) {
+	m.auditLogCalls++
+	if m.auditLog != nil {
+		return m.auditLog, nil
+	}
+
+	return &discordgo.GuildAuditLog{}, nil
+}
+
+func (m *mockSession) Channel(channelID int64) (*discordgo.Channel, error) {
+	return &discordgo.Channel{ID: channelID}, nil
+}
+
+func (m *mockSession) ChannelMessage(channelID, messageID int64) (*discordgo.Message, error) {
+	if msg, ok := m.messages[messageID]; ok {
+		return msg, nil
+	}
+
+	return &discordgo.Message{ID: messageID, ChannelID: channelID}, nil
+}
+
+func (m *mockSession) ChannelMessages(channelID int64, limit int, beforeID, afterID, aroundID int64) ([]*discordgo.Message, error) {
+	return nil, nil
+}
+
+func (m *mockSession) ChannelMessagesPinned(channelID int64) ([]*discordgo.Message, error) {
+	return nil, nil
+}
+
+func (m *mockSession) ChannelMessageSend(channelID int64, content string) (*discordgo.Message, error) {
+	if channelID == dmChannelID && m.dmSendErr != nil {
+		return nil, m.dmSendErr
+	}
+
+	m.sentMessages = append(m.sentMessages, content)
+	m.nextMessageID++
+	return &discordgo.Message{ID: m.nextMessageID, ChannelID: channelID, Content: content}, nil
+}
+
+func (m *mockSession) ChannelMessageSendEmbed(channelID int64, embed *discordgo.MessageEmbed) (*discordgo.Message, error) {
+	m.sentEmbeds = append(m.sentEmbeds, embed)
+	m.nextMessageID++
+	return &discordgo.Message{ID: m.nextMessageID, ChannelID: channelID, Embeds: []*discordgo.MessageEmbed{embed}}, nil
+}
+
+func (m *mockSession) ChannelMessageSendComplex(channelID int64, data *discordgo.MessageSend) (*discordgo.Message, error) {
+	if data.Embed != nil {
+		return m.ChannelMessageSendEmbed(channelID, data.Embed)
+	}
+
+	return m.ChannelMessageSend(channelID, data.Content)
+}
+
+func (m *mockSession) ChannelFileSendWithMessage(channelID int64, content string, name string, r io.Reader) (*discordgo.Message, error) {
+	m.sentFiles = append(m.sentFiles, name)
+	m.nextMessageID++
+	return &discordgo.Message{ID: m.nextMessageID, ChannelID: channelID, Content: content}, nil
+}
+
+func (m *mockSession) ChannelMessageEdit(channelID, messageID int64, content string) (*discordgo.Message, error) {
+	return &discordgo.Message{ID: messageID, ChannelID: channelID, Content: content}, nil
+}
+
+func (m *mockSession) ChannelMessageEditEmbed(channelID, messageID int64, embed *discordgo.MessageEmbed) (*discordgo.Message, error) {
+	return &discordgo.Message{ID: messageID, ChannelID: channelID, Embeds: []*discordgo.MessageEmbed{embed}}, nil
+}
+
+func (m *mockSession) ChannelMessageDelete(channelID, messageID int64) error {
+	m.deleted = append(m.deleted, messageID)
+	return nil
+}
+
+func (m *mockSession) ChannelMessagesBulkDelete(channelID int64, messages []int64) error {
+	m.bulkDeleted = append(m.bulkDeleted, messages)
+	return nil
+}
+
+func (m *mockSession) ChannelPermissionSet(channelID, targetID int64, targetType string, allow, deny int) error {
+	if m.permSetErr != nil {
+		return m.permSetErr
+	}
+
+	m.permSets = append(m.permSets, &discordgo.PermissionOverwrite{ID: targetID, Type: targetType, Allow: allow, Deny: deny})
+	return nil
+}
+
+func (m *mockSession) ChannelPermissionDelete(channelID, targetID int64) error {
+	m.permDeletes = append(m.permDeletes, targetID)
+	return nil
+}
+
+// dmChannelID is the id of the dm channels the mock opens
+const dmChannelID = 999
+
+func (m *mockSession) UserChannelCreate(recipientID int64) (*discordgo.Channel, error) {
+	if m.dmCreateErr != nil {
+		return nil, m.dmCreateErr
+	}
+
+	return &discordgo.Channel{ID: dmChannelID, Type: discordgo.ChannelTypeDM}, nil
+}
+
+func (m *mockSession) WebhookExecute(webhookID int64, token string, wait bool, data *discordgo.WebhookParams) (err error) {
+	m.webhooks = append(m.webhooks, data)
+	return nil
+}
+
+func (m *mockSession) RequestWithBucketID(method, urlStr string, data interface{}, bucketID string) ([]byte, error) {
+	return []byte("{}"), nil
+}
@@ -0,0 +1,298 @@
+package moderation
+
+import (
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/jinzhu/gorm"
+	"github.com/jonas747/discordgo"
+	"github.com/jonas747/yagpdb/bot"
+	"github.com/jonas747/yagpdb/common"
+	"github.com/jonas747/yagpdb/common/scheduledevents2"
+	seventsmodels "github.com/jonas747/yagpdb/common/scheduledevents2/models"
+	"github.com/lib/pq"
+)
+
+// Shadow mutes deny the user from talking through member overwrites in every channel instead of giving them
+// the mute role, and they're not DM'd about it, so nothing about them visibly changes for others or themselves
+// until they try to talk. Unlike normal mutes:
+//  - channels created while they're shadow muted are not covered
+//  - changing the overwrites of a channel by hand can lift it in that channel
+//  - they can still tell they can't send messages, there's no hiding that
+// They're logged for staff only, see logShadowAction, and the moderator is confirmed through a DM.
+
+// The permissions denied by a shadow mute
+const ShadowMutePerms = discordgo.PermissionSendMessages | discordgo.PermissionAddReactions | discordgo.PermissionVoiceSpeak
+
+var (
+	MAShadowMute   = ModlogAction{Prefix: "Shadow muted", Emoji: "👻", Color: 0x57728e}
+	MAShadowUnmute = ModlogAction{Prefix: "Shadow unmuted", Emoji: "👻", Color: 0x62c65f}
+)
+
+// ShadowMuteModel is an active shadow mute, along with what was changed in each channel so unmuting
+// only reverts the bot's own changes to the user's overwrites
+type ShadowMuteModel struct {
+	common.SmallModel
+
+	GuildID int64 `gorm:"index"`
+	UserID  int64
+
+	AuthorID int64
+	Reason   string
+
+	// Zero for permanent shadow mutes
+	ExpiresAt time.Time
+
+	// Parallel arrays, the deny bits added to and allow bits removed from the user's overwrite in each channel
+	Channels      pq.Int64Array `gorm:"type:bigint[]"`
+	AddedDenies   pq.Int64Array `gorm:"type:bigint[]"`
+	RemovedAllows pq.Int64Array `gorm:"type:bigint[]"`
+}
+
+func (s *ShadowMuteModel) TableName() string {
+	return "moderation_shadow_mutes"
+}
+
+// shadowOverwrite returns the overwrite the user should have in the channel while shadow muted,
+// along with the bits that were changed to get there
+func shadowOverwrite(channel *discordgo.Channel, userID int64) (allow, deny, addedDeny, removedAllow int) {
+	for _, v := range channel.PermissionOverwrites {
+		if v.Type == "member" && v.ID == userID {
+			allow, deny = v.Allow, v.Deny
+			break
+		}
+	}
+
+	addedDeny = ShadowMutePerms &^ deny
+	removedAllow = allow & ShadowMutePerms
+	return allow &^ ShadowMutePerms, deny | ShadowMutePerms, addedDeny, removedAllow
+}
+
+// revertShadowOverwrite returns the user's overwrite with the changes of the shadow mute reverted,
+// remove is true if nothing is left of it
+func revertShadowOverwrite(channel *discordgo.Channel, userID int64, addedDeny, removedAllow int) (allow, deny int, remove bool) {
+	for _, v := range channel.PermissionOverwrites {
+		if v.Type == "member" && v.ID == userID {
+			allow, deny = v.Allow, v.Deny
+			break
+		}
+	}
+
+	allow |= removedAllow
+	deny &^= addedDeny
+	return allow, deny, allow == 0 && deny == 0
+}
+
+// shadowMuteChannels returns the channels a shadow mute applies to
+func shadowMuteChannels(config *Config, guildID int64) []*discordgo.Channel {
+	gs := bot.State.Guild(true, guildID)
+	if gs == nil {
+		return nil
+	}
+
+	gs.RLock()
+	channels := make([]*discordgo.Channel, 0, len(gs.Channels))
+	for _, v := range gs.Channels {
+		if v.Type == discordgo.ChannelTypeGuildCategory || common.ContainsInt64Slice(config.MuteIgnoreChannels, v.ID) {
+			continue
+		}
+
+		channels = append(channels, v.DGoCopy())
+	}
+	gs.RUnlock()
+
+	return channels
+}
+
+// ShadowMuteUser shadow mutes the user, or updates the duration and reason if they already are
+func ShadowMuteUser(config *Config, guildID int64, author *discordgo.User, target *discordgo.User, reason string, duration time.Duration) error {
+	LockMute(target.ID)
+	defer UnlockMute(target.ID)
+
+	mute := ShadowMuteModel{}
+	err := common.GORM.Where("guild_id = ? AND user_id = ?", guildID, target.ID).First(&mute).Error
+	alreadyMuted := err == nil
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return common.ErrWithCaller(err)
+	}
+
+	if !alreadyMuted {
+		mute = ShadowMuteModel{GuildID: guildID, UserID: target.ID}
+
+		for _, c := range shadowMuteChannels(config, guildID) {
+			if !bot.BotProbablyHasPermission(guildID, c.ID, discordgo.PermissionManageRoles) {
+				continue
+			}
+
+			allow, deny, addedDeny, removedAllow := shadowOverwrite(c, target.ID)
+			if addedDeny == 0 && removedAllow == 0 {
+				continue
+			}
+
+			err = session().ChannelPermissionSet(c.ID, target.ID, "member", allow, deny)
+			if err != nil {
+				if common.IsDiscordErr(err, discordgo.ErrCodeUnknownChannel, discordgo.ErrCodeMissingAccess, discordgo.ErrCodeMissingPermissions) {
+					continue
+				}
+
+				// don't leave them half muted
+				revertShadowMute(&mute)
+				return errors.WithMessage(err, "ChannelPermissionSet")
+			}
+
+			mute.Channels = append(mute.Channels, c.ID)
+			mute.AddedDenies = append(mute.AddedDenies, int64(addedDeny))
+			mute.RemovedAllows = append(mute.RemovedAllows, int64(removedAllow))
+		}
+	}
+
+	mute.AuthorID = author.ID
+	mute.Reason = reason
+	mute.ExpiresAt = time.Time{}
+	if duration > 0 {
+		mute.ExpiresAt = time.Now().Add(duration)
+	}
+
+	err = common.GORM.Save(&mute).Error
+	if err != nil {
+		return errors.WithMessage(err, "failed saving shadow mute")
+	}
+
+	err = cancelScheduledAction(guildID, target.ID, "moderation_shadow_unmute")
+	common.LogIgnoreError(err, "[moderation] failed clearing shadow unmute events", nil)
+
+	if duration > 0 {
+		revert, err := scheduleExpiry(config, guildID, target.ID, "moderation_shadow_unmute", mute.ExpiresAt)
+		if revert {
+			revertShadowMute(&mute)
+			common.GORM.Delete(&mute)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	action := MAShadowMute
+	action.Footer = "Duration: permanent"
+	if duration > 0 {
+		action.Footer = "Duration: " + common.HumanizeDuration(common.DurationPrecisionMinutes, duration)
+	}
+
+	return logShadowAction(config, author, action, target, reason)
+}
+
+// logShadowAction logs the shadow mute for staff only, in the private audit channel or the report channel if there's none.
+// It's kept out of the modlog as that may be public, which would give the shadow mute away.
+func logShadowAction(config *Config, author *discordgo.User, action ModlogAction, target *discordgo.User, reason string) error {
+	logModAction(config, author, action, target, reason, "")
+	publishModAction(config.GetGuildID(), author, action, target, reason)
+
+	channelID := config.IntPrivateAuditChannel()
+	if channelID == 0 {
+		channelID = config.IntReportChannel()
+	}
+	if channelID == 0 {
+		return nil
+	}
+
+	reason = ResolveReasonMentions(config.GetGuildID(), config.modlogReason(reason))
+
+	embed := &discordgo.MessageEmbed{
+		Thumbnail: &discordgo.MessageEmbedThumbnail{
+			URL: discordgo.EndpointUserAvatar(target.ID, target.Avatar),
+		},
+		Color: action.Color,
+	}
+	renderModlogEmbed(fullAuthorConfig(config), embed, author, action, target, reason, 0)
+	setModlogEmbedAuthor(fullAuthorConfig(config), author, embed)
+
+	if action.Footer != "" {
+		embed.Footer = &discordgo.MessageEmbedFooter{Text: action.Footer}
+	}
+
+	_, err := session().ChannelMessageSendEmbed(channelID, embed)
+	if err != nil {
+		return err
+	}
+
+	mirrorModlogEmbed(config, embed)
+	return nil
+}
+
+// confirmShadowAction lets the moderator know the shadow mute went through in a DM, a reply in the channel could be seen by the target
+func confirmShadowAction(guildID int64, author *discordgo.User, resp string) {
+	err := bot.SendDM(author.ID, "**"+bot.GuildName(guildID)+":** "+resp)
+	if err != nil {
+		logger.WithError(err).WithField("guild", guildID).Debug("failed confirming shadow mute to the moderator")
+	}
+}
+
+// revertShadowMute reverts the overwrites changed by the shadow mute, channels that are gone are skipped
+func revertShadowMute(mute *ShadowMuteModel) {
+	gs := bot.State.Guild(true, mute.GuildID)
+
+	for i, channelID := range mute.Channels {
+		channel := &discordgo.Channel{ID: channelID}
+		if gs != nil {
+			if cs := gs.ChannelCopy(true, channelID); cs != nil {
+				channel = cs.DGoCopy()
+			}
+		}
+
+		allow, deny, remove := revertShadowOverwrite(channel, mute.UserID, int(mute.AddedDenies[i]), int(mute.RemovedAllows[i]))
+
+		var err error
+		if remove {
+			err = session().ChannelPermissionDelete(channelID, mute.UserID)
+		} else {
+			err = session().ChannelPermissionSet(channelID, mute.UserID, "member", allow, deny)
+		}
+
+		if err != nil && !common.IsDiscordErr(err, discordgo.ErrCodeUnknownChannel, discordgo.ErrCodeMissingAccess) {
+			logger.WithError(err).WithField("guild", mute.GuildID).WithField("channel", channelID).Error("failed reverting shadow mute overwrite")
+		}
+	}
+}
+
+// ShadowUnmuteUser lifts the shadow mute of the user, returns false if they weren't shadow muted
+func ShadowUnmuteUser(config *Config, guildID int64, author *discordgo.User, target *discordgo.User, reason string) (bool, error) {
+	LockMute(target.ID)
+	defer UnlockMute(target.ID)
+
+	var mute ShadowMuteModel
+	err := common.GORM.Where("guild_id = ? AND user_id = ?", guildID, target.ID).First(&mute).Error
+	if err == gorm.ErrRecordNotFound {
+		return false, nil
+	} else if err != nil {
+		return false, common.ErrWithCaller(err)
+	}
+
+	revertShadowMute(&mute)
+
+	err = common.GORM.Delete(&mute).Error
+	if err != nil {
+		return false, errors.WithMessage(err, "failed removing shadow mute")
+	}
+
+	err = cancelScheduledAction(guildID, target.ID, "moderation_shadow_unmute")
+	common.LogIgnoreError(err, "[moderation] failed clearing shadow unmute events", nil)
+
+	return true, logShadowAction(config, author, MAShadowUnmute, target, reason)
+}
+
+func handleScheduledShadowUnmute(evt *seventsmodels.ScheduledEvent, data interface{}) (retry bool, err error) {
+	unmuteData := data.(*ScheduledUnmuteData)
+
+	config, err := GetConfig(evt.GuildID)
+	if err != nil {
+		return true, err
+	}
+
+	target := &discordgo.User{ID: unmuteData.UserID, Username: "unknown", Discriminator: "????"}
+	if member, _ := bot.GetMember(evt.GuildID, unmuteData.UserID); member != nil {
+		target = member.DGoUser()
+	}
+
+	_, err = ShadowUnmuteUser(config, evt.GuildID, common.BotUser, target, "Shadow mute duration expired")
+	return scheduledevents2.CheckDiscordErrRetry(err), err
+}
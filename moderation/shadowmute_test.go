@@ -0,0 +1,72 @@
+package moderation
+
+import (
+	"testing"
+
+	"github.com/jonas747/discordgo"
+	"github.com/jonas747/yagpdb/bot"
+)
+
+func TestShadowOverwrite(t *testing.T) {
+	const userID = 5
+	channel := &discordgo.Channel{ID: 1}
+
+	// no existing overwrite for the user
+	allow, deny, addedDeny, removedAllow := shadowOverwrite(channel, userID)
+	if allow != 0 || deny != ShadowMutePerms || addedDeny != ShadowMutePerms || removedAllow != 0 {
+		t.Errorf("unexpected overwrite: allow %d, deny %d, added %d, removed %d", allow, deny, addedDeny, removedAllow)
+	}
+
+	channel.PermissionOverwrites = []*discordgo.PermissionOverwrite{{ID: userID, Type: "member", Deny: deny}}
+	if allow, deny, remove := revertShadowOverwrite(channel, userID, addedDeny, removedAllow); !remove {
+		t.Errorf("expected the overwrite to be removed, got allow %d, deny %d", allow, deny)
+	}
+
+	// an existing overwrite allowing them to talk and denying something unrelated
+	existing := &discordgo.PermissionOverwrite{ID: userID, Type: "member", Allow: discordgo.PermissionSendMessages | discordgo.PermissionEmbedLinks, Deny: discordgo.PermissionAddReactions | discordgo.PermissionAttachFiles}
+	channel.PermissionOverwrites = []*discordgo.PermissionOverwrite{{ID: 6, Type: "member"}, existing}
+
+	allow, deny, addedDeny, removedAllow = shadowOverwrite(channel, userID)
+	if allow != discordgo.PermissionEmbedLinks || deny != ShadowMutePerms|discordgo.PermissionAttachFiles {
+		t.Errorf("unexpected overwrite: allow %d, deny %d", allow, deny)
+	}
+	if addedDeny != discordgo.PermissionSendMessages|discordgo.PermissionVoiceSpeak || removedAllow != discordgo.PermissionSendMessages {
+		t.Errorf("unexpected changes: added %d, removed %d", addedDeny, removedAllow)
+	}
+
+	channel.PermissionOverwrites = []*discordgo.PermissionOverwrite{{ID: userID, Type: "member", Allow: allow, Deny: deny}}
+	allow, deny, remove := revertShadowOverwrite(channel, userID, addedDeny, removedAllow)
+	if remove || allow != existing.Allow || deny != existing.Deny {
+		t.Errorf("expected the original overwrite back, got allow %d, deny %d, remove %t", allow, deny, remove)
+	}
+}
+
+func TestLogShadowAction(t *testing.T) {
+	m, restore := useMockSession()
+	defer restore()
+
+	author := &discordgo.User{ID: 3, Username: "mod", Discriminator: "0001"}
+	target := &discordgo.User{ID: 4, Username: "user", Discriminator: "0002"}
+
+	// never in the modlog
+	config := &Config{ActionChannel: "10"}
+	if err := logShadowAction(config, author, MAShadowMute, target, "spam"); err != nil {
+		t.Fatal(err)
+	}
+	if len(m.sentEmbeds) != 0 {
+		t.Fatalf("expected nothing in the modlog, got %d entries", len(m.sentEmbeds))
+	}
+
+	if bot.State == nil {
+		t.Skip("bot state not available, skipping.")
+		return
+	}
+
+	config.ReportChannel = "11"
+	if err := logShadowAction(config, author, MAShadowMute, target, "spam"); err != nil {
+		t.Fatal(err)
+	}
+	if len(m.sentEmbeds) != 1 || m.sentEmbeds[0].Author == nil || m.sentEmbeds[0].Author.Name != "mod#0001 (ID 3)" {
+		t.Errorf("expected a staff entry showing the moderator in full, got %+v", m.sentEmbeds)
+	}
+}
@@ -0,0 +1,79 @@
+package moderation
+
+import (
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/jinzhu/gorm"
+	"github.com/jonas747/yagpdb/common"
+	"github.com/mediocregopher/radix/v3"
+)
+
+// muteExpiry returns whether the user is muted and when it ends, the zero time for permanent mutes.
+// The stored mute is authoritative, the redis key is only checked when there's no stored mute.
+func muteExpiry(guildID, userID int64) (muted bool, expiresAt time.Time, err error) {
+	var mute MuteModel
+	err = common.GORM.Where("guild_id = ? AND user_id = ?", guildID, userID).First(&mute).Error
+	if err == nil {
+		return true, mute.ExpiresAt, nil
+	} else if err != gorm.ErrRecordNotFound {
+		return false, expiresAt, errors.WithStackIf(err)
+	}
+
+	// -2 if the key doesn't exist, -1 if it has no expiry
+	var ttl int64
+	err = common.RedisPool.Do(radix.Cmd(&ttl, "TTL", RedisKeyMutedUser(guildID, userID)))
+	if err != nil {
+		return false, expiresAt, errors.WithStackIf(err)
+	}
+
+	switch {
+	case ttl == -2:
+		return false, expiresAt, nil
+	case ttl == -1:
+		return true, expiresAt, nil
+	}
+
+	return true, time.Now().Add(time.Duration(ttl) * time.Second), nil
+}
+
+// describeTimeLeft describes the remaining time of a mute or ban ending at expiresAt, the zero time meaning permanent
+func describeTimeLeft(action string, active bool, expiresAt time.Time, now time.Time) string {
+	if !active {
+		return "Not " + action
+	}
+
+	if expiresAt.IsZero() {
+		return "Permanently " + action
+	}
+
+	if !expiresAt.After(now) {
+		return "Still " + action + ", the expiry is overdue and should be processed shortly"
+	}
+
+	return common.HumanizeDuration(common.DurationPrecisionMinutes, expiresAt.Sub(now)) + " left"
+}
+
+// timeLeftResponse builds the response of the TimeLeft command
+func timeLeftResponse(guildID, userID int64) (string, error) {
+	muted, muteExpires, err := muteExpiry(guildID, userID)
+	if err != nil {
+		return "", err
+	}
+
+	unbanAt, err := scheduledActionTime(guildID, userID, "moderation_unban")
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	resp := "**Mute:** " + describeTimeLeft("muted", muted, muteExpires, now)
+	if unbanAt.IsZero() {
+		// we only know about bans through their scheduled unban
+		resp += "\n**Ban:** No unban scheduled, if they're banned it's permanent"
+	} else {
+		resp += "\n**Ban:** " + describeTimeLeft("banned", true, unbanAt, now)
+	}
+
+	return resp, nil
+}
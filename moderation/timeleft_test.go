@@ -0,0 +1,27 @@
+package moderation
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDescribeTimeLeft(t *testing.T) {
+	now := time.Now()
+
+	cases := []struct {
+		active    bool
+		expiresAt time.Time
+		want      string
+	}{
+		{false, time.Time{}, "Not muted"},
+		{true, time.Time{}, "Permanently muted"},
+		{true, now.Add(-time.Minute), "Still muted, the expiry is overdue and should be processed shortly"},
+		{true, now.Add(90 * time.Minute), "1 hour and 30 minutes left"},
+	}
+
+	for i, c := range cases {
+		if got := describeTimeLeft("muted", c.active, c.expiresAt, now); got != c.want {
+			t.Errorf("%d: got %q, expected %q", i, got, c.want)
+		}
+	}
+}
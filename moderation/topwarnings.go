@@ -3,8 +3,6 @@ package moderation
 import (
 	"database/sql"
 	"fmt"
-	//"github.com/jonas747/discordgo"
-	//"github.com/jonas747/dstate"
 	"github.com/jonas747/yagpdb/bot"
 	"github.com/jonas747/yagpdb/common"
 )
@@ -16,16 +14,18 @@ type WarnRankEntry struct {
 	WarnCount int64  `json:"warn_count"`
 }
 
-func TopWarns(guildID int64, offset, limit int) ([]*WarnRankEntry, error) {
+// TopWarns returns the users with the most warnings, users with less than minWarns warnings are left out
+func TopWarns(guildID int64, offset, limit, minWarns int) ([]*WarnRankEntry, error) {
 	const query = `SELECT rank, warn_count, user_id FROM
 	(
 		SELECT RANK() OVER (ORDER BY count(message) DESC) AS rank, count(*) as warn_count, user_id
 		FROM moderation_warnings WHERE guild_id = $1 group by user_id
+		HAVING count(*) >= $4
 	) AS warns
 	ORDER BY warn_count desc
 	LIMIT $2 OFFSET $3`
 
-	rows, err := common.PQ.Query(query, guildID, limit, offset)
+	rows, err := common.PQ.Query(query, guildID, limit, offset, minWarns)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return []*WarnRankEntry{}, nil
@@ -36,9 +36,7 @@ func TopWarns(guildID int64, offset, limit int) ([]*WarnRankEntry, error) {
 
 	result := make([]*WarnRankEntry, 0, limit)
 	for rows.Next() {
-		//var member []*discordgo.Member
 		var rank int
-		//var tmp []*dstate.MemberState
 		var userID int64
 		var warncount int64
 		var err = rows.Scan(&rank, &warncount, &userID)
@@ -46,24 +44,13 @@ func TopWarns(guildID int64, offset, limit int) ([]*WarnRankEntry, error) {
 			return nil, err
 		}
 
-		/*tmp, err = bot.GetMembers(guildID, userID)
-		if tmp != nil {
-			for _, v := range tmp {
-				member = append(member, v.DGoCopy())
-			}
-		}
-		var username string
-		for _, m := range member {
-			username = m.User.Username + "#" + m.User.Discriminator
-			break
-		}*/
 		userSlice := bot.GetUsers(guildID, userID)
 		var username string
 		for _, u := range userSlice {
 			username = fmt.Sprintf("%s", u)
 			break
 		}
-		
+
 		result = append(result, &WarnRankEntry{
 			Rank:      rank,
 			UserID:    userID,
@@ -0,0 +1,70 @@
+package moderation
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/jonas747/yagpdb/common"
+)
+
+// Trust levels let servers mark members as more (or less) trusted than the default,
+// other features can look them up with GetTrustLevel to be more lenient towards trusted members,
+// for example by skipping automatic punishments when the level is at or above TrustLevelTrusted.
+const (
+	TrustLevelMin     = -10
+	TrustLevelDefault = 0
+	TrustLevelTrusted = 5
+	TrustLevelMax     = 10
+)
+
+type TrustLevelModel struct {
+	common.SmallModel
+
+	GuildID int64 `gorm:"unique_index:idx_trust_level_guild_user"`
+	UserID  int64 `gorm:"unique_index:idx_trust_level_guild_user"`
+
+	Level    int
+	AuthorID int64
+}
+
+func (t *TrustLevelModel) TableName() string {
+	return "moderation_trust_levels"
+}
+
+// GetTrustLevel returns the trust level of the user, or TrustLevelDefault if none is set
+func GetTrustLevel(guildID, userID int64) (int, error) {
+	var m TrustLevelModel
+	err := common.GORM.Where("guild_id = ? AND user_id = ?", guildID, userID).First(&m).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return TrustLevelDefault, nil
+		}
+
+		return TrustLevelDefault, err
+	}
+
+	return m.Level, nil
+}
+
+// IsTrusted returns true if the user has a trust level of TrustLevelTrusted or above
+func IsTrusted(guildID, userID int64) bool {
+	level, err := GetTrustLevel(guildID, userID)
+	if err != nil {
+		logger.WithError(err).WithField("guild", guildID).Error("failed retrieving trust level")
+		return false
+	}
+
+	return level >= TrustLevelTrusted
+}
+
+// SetTrustLevel sets the trust level of the user, setting it to TrustLevelDefault removes the entry
+func SetTrustLevel(guildID, userID, authorID int64, level int) error {
+	if level == TrustLevelDefault {
+		return common.GORM.Where("guild_id = ? AND user_id = ?", guildID, userID).Delete(TrustLevelModel{}).Error
+	}
+
+	m := TrustLevelModel{
+		GuildID: guildID,
+		UserID:  userID,
+	}
+
+	return common.GORM.Where(m).Assign(TrustLevelModel{Level: level, AuthorID: authorID}).FirstOrCreate(&m).Error
+}
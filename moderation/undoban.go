@@ -0,0 +1,245 @@
+package moderation
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jonas747/discordgo"
+	"github.com/jonas747/yagpdb/common"
+	"github.com/mediocregopher/radix/v3"
+)
+
+// lastBan is what's kept of a ban so it can be undone
+type lastBan struct {
+	UserID        int64
+	Username      string
+	Discriminator string
+	BannedAt      time.Time
+
+	// The message with the command that made the ban, the bans of linked accounts made with -alts share it
+	CommandMessageID int64
+
+	// zero if the entry wasn't posted
+	ModlogChannelID int64
+	ModlogMessageID int64
+	AuditChannelID  int64
+	AuditMessageID  int64
+
+	// The mute the ban ended (see SupersedeScheduledActions), restored when the ban is undone
+	SupersededMute *MuteModel
+}
+
+func newLastBan(user *discordgo.User, message *discordgo.Message, entry *modlogEntry, superseded *MuteModel) *lastBan {
+	ban := &lastBan{
+		UserID:         user.ID,
+		Username:       user.Username,
+		Discriminator:  user.Discriminator,
+		BannedAt:       time.Now(),
+		SupersededMute: superseded,
+	}
+
+	if message != nil {
+		ban.CommandMessageID = message.ID
+	}
+
+	if entry != nil {
+		if entry.Message != nil {
+			ban.ModlogChannelID, ban.ModlogMessageID = entry.Message.ChannelID, entry.Message.ID
+		}
+		if entry.AuditMessage != nil {
+			ban.AuditChannelID, ban.AuditMessageID = entry.AuditMessage.ChannelID, entry.AuditMessage.ID
+		}
+	}
+
+	return ban
+}
+
+// supersededMute returns the mute the ban is about to end, nil if it doesn't end one
+func supersededMute(guildID, userID int64, conflicts []string) *MuteModel {
+	if !common.ContainsStringSlice(conflicts, "moderation_unmute") {
+		return nil
+	}
+
+	var mute MuteModel
+	err := common.GORM.Where("guild_id = ? AND user_id = ?", guildID, userID).First(&mute).Error
+	if err != nil {
+		return nil
+	}
+
+	return &mute
+}
+
+// rememberLastBan stores the ban as the moderator's last one for the undo window. Bans made by the same command,
+// like those of linked accounts, are kept together, an earlier command's bans are replaced.
+// Bans by the bot itself have no one to undo them, synced bans are kept with rememberSyncedBan instead.
+func rememberLastBan(config *Config, author *discordgo.User, ban *lastBan) {
+	window := config.BanUndoWindowDuration()
+	if window <= 0 || author.ID == 0 || (common.BotUser != nil && author.ID == common.BotUser.ID) {
+		return
+	}
+
+	guildID := config.GetGuildID()
+	key := RedisKeyLastBan(guildID, author.ID)
+
+	var bans []*lastBan
+	if ban.CommandMessageID != 0 {
+		var serialized []byte
+		err := common.RedisPool.Do(radix.Cmd(&serialized, "GET", key))
+		if err == nil && len(serialized) > 0 && json.Unmarshal(serialized, &bans) == nil {
+			if len(bans) < 1 || bans[0].CommandMessageID != ban.CommandMessageID {
+				bans = nil
+			}
+		}
+	}
+
+	storeUndoableBans(guildID, key, append(bans, ban), window)
+}
+
+// rememberSyncedBan stores the ban synced from the source server so it can be undone along with the original ban.
+// It's kept for the longest undo window as the source server decides when it can be undone.
+func rememberSyncedBan(guildID, sourceGuildID int64, ban *lastBan) {
+	storeUndoableBans(guildID, RedisKeySyncedBan(guildID, sourceGuildID, ban.UserID), []*lastBan{ban}, MaxBanUndoWindow*time.Minute)
+}
+
+func storeUndoableBans(guildID int64, key string, bans []*lastBan, window time.Duration) {
+	serialized, err := json.Marshal(bans)
+	if err != nil {
+		logger.WithError(err).WithField("guild", guildID).Error("failed serializing undoable ban")
+		return
+	}
+
+	err = common.RedisPool.Do(radix.FlatCmd(nil, "SET", key, serialized, "PX", int64(window/time.Millisecond)))
+	if err != nil {
+		logger.WithError(err).WithField("guild", guildID).Error("failed storing undoable ban")
+	}
+}
+
+// takeLastBans returns the bans of the moderator's last ban command if it's still within the undo window, nil otherwise.
+// The first is the ban of the user the command was run on, the rest the bans of their linked accounts.
+// The reference is removed so the bans can only be undone once.
+func takeLastBans(guildID, moderatorID int64) ([]*lastBan, error) {
+	return takeUndoableBans(RedisKeyLastBan(guildID, moderatorID))
+}
+
+func takeUndoableBans(key string) ([]*lastBan, error) {
+	var serialized []byte
+	err := common.RedisPool.Do(radix.Cmd(&serialized, "GET", key))
+	if err != nil || len(serialized) == 0 {
+		return nil, err
+	}
+
+	var deleted int
+	err = common.RedisPool.Do(radix.Cmd(&deleted, "DEL", key))
+	if err != nil || deleted < 1 {
+		// another undo got to it first
+		return nil, err
+	}
+
+	var bans []*lastBan
+	err = json.Unmarshal(serialized, &bans)
+	if err != nil {
+		return nil, err
+	}
+
+	return bans, nil
+}
+
+// undoBan reverses the ban as if it never happened: the user is unbanned without an unban entry, the markers,
+// scheduled unban and ban records are cleared, the modlog entries are deleted and the mute the ban ended is restored
+func undoBan(config *Config, ban *lastBan) error {
+	guildID := config.GetGuildID()
+	setMarker(RedisKeyBanUndone(guildID, ban.UserID), UnbanMarkerTTL)
+
+	err := session().GuildBanDelete(guildID, ban.UserID)
+	if err != nil {
+		common.RedisPool.Do(radix.Cmd(nil, "DEL", RedisKeyBanUndone(guildID, ban.UserID)))
+		return err
+	}
+
+	err = common.RedisPool.Do(radix.Cmd(nil, "DEL", RedisKeyBannedUser(guildID, ban.UserID), RedisKeyBanLogged(guildID, ban.UserID)))
+	common.LogIgnoreError(err, "[moderation] failed clearing ban markers", nil)
+
+	err = cancelScheduledAction(guildID, ban.UserID, "moderation_unban")
+	common.LogIgnoreError(err, "[moderation] failed cancelling scheduled unban", nil)
+
+	removeBanRecords(guildID, ban.UserID)
+
+	if ban.ModlogMessageID != 0 {
+		err = session().ChannelMessageDelete(ban.ModlogChannelID, ban.ModlogMessageID)
+		common.LogIgnoreError(err, "[moderation] failed deleting modlog entry of undone ban", nil)
+	}
+
+	if ban.AuditMessageID != 0 {
+		err = session().ChannelMessageDelete(ban.AuditChannelID, ban.AuditMessageID)
+		common.LogIgnoreError(err, "[moderation] failed deleting private audit entry of undone ban", nil)
+	}
+
+	if ban.SupersededMute != nil {
+		restoreSupersededMute(config, ban.SupersededMute)
+	}
+
+	return nil
+}
+
+// restoreSupersededMute puts back the mute a ban ended, along with its scheduled unmute.
+// The user isn't in the server after the ban, the mute role is given back when they rejoin.
+func restoreSupersededMute(config *Config, mute *MuteModel) {
+	restored := *mute
+	restored.ID = 0
+
+	err := common.GORM.Create(&restored).Error
+	if err != nil {
+		logger.WithError(err).WithField("guild", mute.GuildID).WithField("user", mute.UserID).Error("failed restoring mute of undone ban")
+		return
+	}
+
+	if restored.ExpiresAt.IsZero() {
+		return
+	}
+
+	unmuteAt := restored.ExpiresAt
+	if unmuteAt.Before(time.Now()) {
+		unmuteAt = time.Now()
+	}
+
+	revert, err := scheduleExpiry(config, restored.GuildID, restored.UserID, "moderation_unmute", unmuteAt)
+	if revert {
+		common.GORM.Delete(&restored)
+	}
+	common.LogIgnoreError(err, "[moderation] failed scheduling unmute of restored mute", nil)
+}
+
+// undoBanResponse confirms what was undone, the first ban is the one of the user the command was run on
+func undoBanResponse(bans []*lastBan, failed int, now time.Time) string {
+	ban := bans[0]
+	resp := fmt.Sprintf("Undid your ban of **%s#%s** (ID %d)", ban.Username, ban.Discriminator, ban.UserID)
+	if len(bans) > 1 {
+		resp += fmt.Sprintf(" and %d linked account(s)", len(bans)-1)
+	}
+	resp += fmt.Sprintf(" from %s ago, they're unbanned", common.HumanizeDuration(common.DurationPrecisionSeconds, now.Sub(ban.BannedAt)))
+
+	removedEntry := false
+	restoredMutes := 0
+	for _, v := range bans {
+		removedEntry = removedEntry || v.ModlogMessageID != 0 || v.AuditMessageID != 0
+		if v.SupersededMute != nil {
+			restoredMutes++
+		}
+	}
+
+	if removedEntry {
+		resp += " and the modlog entries were removed"
+	}
+	resp += "."
+
+	if restoredMutes > 0 {
+		resp += fmt.Sprintf(" The mute the ban ended was restored for %d of them.", restoredMutes)
+	}
+
+	if failed > 0 {
+		resp += fmt.Sprintf(" %d couldn't be undone.", failed)
+	}
+
+	return resp
+}
@@ -0,0 +1,190 @@
+package moderation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jonas747/discordgo"
+	"github.com/jonas747/yagpdb/common"
+	"github.com/jonas747/yagpdb/common/configstore"
+)
+
+func TestUndoBanResponse(t *testing.T) {
+	now := time.Now()
+	ban := &lastBan{UserID: 5, Username: "user", Discriminator: "0002", BannedAt: now.Add(-time.Minute), ModlogMessageID: 10}
+
+	expected := "Undid your ban of **user#0002** (ID 5) from 1 minute ago, they're unbanned and the modlog entries were removed."
+	if got := undoBanResponse([]*lastBan{ban}, 0, now); got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+
+	ban.ModlogMessageID = 0
+	expected = "Undid your ban of **user#0002** (ID 5) from 1 minute ago, they're unbanned."
+	if got := undoBanResponse([]*lastBan{ban}, 0, now); got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+
+	alt := &lastBan{UserID: 6, SupersededMute: &MuteModel{}}
+	expected = "Undid your ban of **user#0002** (ID 5) and 1 linked account(s) from 1 minute ago, they're unbanned. " +
+		"The mute the ban ended was restored for 1 of them. 1 couldn't be undone."
+	if got := undoBanResponse([]*lastBan{ban, alt}, 1, now); got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestBanUndoWindowDuration(t *testing.T) {
+	config := &Config{}
+	if got := config.BanUndoWindowDuration(); got != DefaultBanUndoWindow {
+		t.Errorf("expected the default window for old configs, got %s", got)
+	}
+
+	config.BanUndoWindow.Valid = true
+	if got := config.BanUndoWindowDuration(); got != 0 {
+		t.Errorf("expected undoing to be disabled, got %s", got)
+	}
+
+	config.BanUndoWindow.Int64 = 15
+	if got := config.BanUndoWindowDuration(); got != time.Minute*15 {
+		t.Errorf("expected 15 minutes, got %s", got)
+	}
+}
+
+func TestUndoBan(t *testing.T) {
+	if common.RedisPool == nil || common.GORM == nil || common.PQ == nil {
+		t.Skip("redis or db not available, skipping.")
+		return
+	}
+
+	m, restore := useMockSession()
+	defer restore()
+
+	config := &Config{GuildConfigModel: configstore.GuildConfigModel{GuildID: 1}}
+	author := &discordgo.User{ID: 3, Username: "mod", Discriminator: "0001"}
+	user := &discordgo.User{ID: 7, Username: "user", Discriminator: "0002"}
+	entry := &modlogEntry{Message: &discordgo.Message{ID: 20, ChannelID: 10}}
+
+	m.bans[user.ID] = "spam"
+	setMarker(RedisKeyBanLogged(1, user.ID), BanLoggedWindow)
+	rememberLastBan(config, author, newLastBan(user, nil, entry, nil))
+
+	if bans, err := takeLastBans(1, 4); err != nil || bans != nil {
+		t.Errorf("expected no ban for another moderator, got %+v, %v", bans, err)
+	}
+
+	bans, err := takeLastBans(1, author.ID)
+	if err != nil || len(bans) != 1 {
+		t.Fatalf("expected the last ban, got %+v, %v", bans, err)
+	}
+
+	if again, _ := takeLastBans(1, author.ID); again != nil {
+		t.Error("expected the ban to only be undoable once")
+	}
+
+	err = undoBan(config, bans[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer consumeMarker(RedisKeyBanUndone(1, user.ID))
+
+	if _, ok := m.bans[user.ID]; ok {
+		t.Error("expected the user to be unbanned")
+	}
+
+	if len(m.deleted) != 1 || m.deleted[0] != 20 {
+		t.Errorf("expected the modlog entry to be deleted, got %v", m.deleted)
+	}
+
+	if markerSet(RedisKeyBanLogged(1, user.ID)) {
+		t.Error("expected the ban marker to be cleared")
+	}
+
+	if !markerSet(RedisKeyBanUndone(1, user.ID)) {
+		t.Error("expected the unban to be marked as an undo")
+	}
+}
+
+func TestUndoBanAlts(t *testing.T) {
+	if common.RedisPool == nil {
+		t.Skip("redis not available, skipping.")
+		return
+	}
+
+	config := &Config{GuildConfigModel: configstore.GuildConfigModel{GuildID: 1}}
+	author := &discordgo.User{ID: 3, Username: "mod", Discriminator: "0001"}
+	cmd := &discordgo.Message{ID: 100}
+
+	rememberLastBan(config, author, newLastBan(&discordgo.User{ID: 7}, &discordgo.Message{ID: 99}, nil, nil))
+	rememberLastBan(config, author, newLastBan(&discordgo.User{ID: 8}, cmd, nil, nil))
+	rememberLastBan(config, author, newLastBan(&discordgo.User{ID: 9}, cmd, nil, nil))
+
+	bans, err := takeLastBans(1, author.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(bans) != 2 || bans[0].UserID != 8 || bans[1].UserID != 9 {
+		t.Errorf("expected the bans of the last command only, got %+v", bans)
+	}
+
+	config.BanUndoWindow.Valid = true
+	rememberLastBan(config, author, newLastBan(&discordgo.User{ID: 7}, nil, nil, nil))
+	if bans, _ := takeLastBans(1, author.ID); bans != nil {
+		t.Errorf("expected nothing to be kept with undoing disabled, got %+v", bans)
+	}
+}
+
+func TestUndoBanRestoresMute(t *testing.T) {
+	if common.RedisPool == nil || common.GORM == nil || common.PQ == nil {
+		t.Skip("redis or db not available, skipping.")
+		return
+	}
+
+	_, restore := useMockSession()
+	defer restore()
+
+	config := &Config{GuildConfigModel: configstore.GuildConfigModel{GuildID: 1}}
+	mute := &MuteModel{GuildID: 1, UserID: 11, AuthorID: 3, Reason: "spam", RemovedRoles: []int64{5}}
+	defer common.GORM.Where("guild_id = 1 AND user_id = 11").Delete(MuteModel{})
+
+	err := undoBan(config, &lastBan{UserID: 11, SupersededMute: mute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer consumeMarker(RedisKeyBanUndone(1, 11))
+
+	var restored MuteModel
+	err = common.GORM.Where("guild_id = 1 AND user_id = 11").First(&restored).Error
+	if err != nil {
+		t.Fatalf("expected the mute to be restored, got %v", err)
+	}
+
+	if restored.Reason != "spam" || len(restored.RemovedRoles) != 1 || restored.RemovedRoles[0] != 5 {
+		t.Errorf("unexpected restored mute %+v", restored)
+	}
+}
+
+func TestUndoSyncedBan(t *testing.T) {
+	if common.RedisPool == nil || common.GORM == nil || common.PQ == nil {
+		t.Skip("redis or db not available, skipping.")
+		return
+	}
+
+	m, restore := useMockSession()
+	defer restore()
+
+	config := &Config{GuildConfigModel: configstore.GuildConfigModel{GuildID: 2}}
+	m.bans[7] = "Synced ban from source"
+	rememberSyncedBan(2, 1, newLastBan(&discordgo.User{ID: 7}, nil, nil, nil))
+
+	// a ban synced from another server isn't undone
+	undoSyncedBan(config, &BanSyncEvent{SourceGuildID: 5, UserID: 7, Undo: true}, logger)
+	if _, ok := m.bans[7]; !ok {
+		t.Fatal("expected the ban to stay")
+	}
+
+	undoSyncedBan(config, &BanSyncEvent{SourceGuildID: 1, UserID: 7, Undo: true}, logger)
+	defer consumeMarker(RedisKeyBanUndone(2, 7))
+	if _, ok := m.bans[7]; ok {
+		t.Error("expected the synced ban to be undone")
+	}
+}
@@ -0,0 +1,199 @@
+package moderation
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/jonas747/discordgo"
+	"github.com/jonas747/yagpdb/common"
+)
+
+// UserRecordEntry is a single entry in a user's moderation record
+type UserRecordEntry struct {
+	Time        time.Time  `json:"time"`
+	Type        string     `json:"type"`
+	Moderator   string     `json:"moderator,omitempty"`
+	ModeratorID int64      `json:"moderator_id,string,omitempty"`
+	Reason      string     `json:"reason"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	Source      string     `json:"source"`
+}
+
+// UserRecord is everything we know about a user's moderation history in a server
+type UserRecord struct {
+	GuildID int64              `json:"guild_id,string"`
+	UserID  int64              `json:"user_id,string"`
+	Created time.Time          `json:"created"`
+	Entries []*UserRecordEntry `json:"entries"`
+}
+
+// fetchUserRecord collects the warnings, mutes, bans and manually logged actions stored for the user,
+// kicks and other actions are only persisted as modlog entries so those are passed in from the modlog channel
+func fetchUserRecord(guildID, userID int64, modlogEntries []*ModlogExportEntry) (*UserRecord, error) {
+	record := &UserRecord{
+		GuildID: guildID,
+		UserID:  userID,
+		Created: time.Now(),
+	}
+
+	var warnings []*WarningModel
+	err := common.GORM.Where("guild_id = ? AND user_id = ?", guildID, discordgo.StrID(userID)).Find(&warnings).Error
+	if err != nil {
+		return nil, errors.WithStackIf(err)
+	}
+
+	for _, v := range warnings {
+		authorID, _ := strconv.ParseInt(v.AuthorID, 10, 64)
+		record.Entries = append(record.Entries, &UserRecordEntry{
+			Time:        v.CreatedAt,
+			Type:        "warning",
+			Moderator:   v.AuthorUsernameDiscrim,
+			ModeratorID: authorID,
+			Reason:      v.Message,
+			Source:      "warnings",
+		})
+	}
+
+	var mutes []*MuteModel
+	err = common.GORM.Where("guild_id = ? AND user_id = ?", guildID, userID).Find(&mutes).Error
+	if err != nil {
+		return nil, errors.WithStackIf(err)
+	}
+
+	for _, v := range mutes {
+		entry := &UserRecordEntry{
+			Time:        v.CreatedAt,
+			Type:        "mute",
+			ModeratorID: v.AuthorID,
+			Reason:      v.Reason,
+			Source:      "active mutes",
+		}
+		if !v.ExpiresAt.IsZero() {
+			expires := v.ExpiresAt
+			entry.ExpiresAt = &expires
+		}
+		record.Entries = append(record.Entries, entry)
+	}
+
+	var mutesHistory []*MuteHistoryModel
+	err = common.GORM.Where("guild_id = ? AND user_id = ?", guildID, userID).Find(&mutesHistory).Error
+	if err != nil {
+		return nil, errors.WithStackIf(err)
+	}
+
+	for _, v := range mutesHistory {
+		entry := &UserRecordEntry{
+			Time:        v.MutedAt,
+			Type:        "mute",
+			ModeratorID: v.AuthorID,
+			Reason:      v.Reason,
+			Source:      "mute history",
+		}
+		if !v.ExpiresAt.IsZero() {
+			expires := v.ExpiresAt
+			entry.ExpiresAt = &expires
+		}
+		record.Entries = append(record.Entries, entry)
+	}
+
+	var bans []*BanModel
+	err = common.GORM.Where("guild_id = ? AND user_id = ?", guildID, userID).Find(&bans).Error
+	if err != nil {
+		return nil, errors.WithStackIf(err)
+	}
+
+	for _, v := range bans {
+		record.Entries = append(record.Entries, &UserRecordEntry{
+			Time:   v.CreatedAt,
+			Type:   "ban",
+			Source: "bans",
+		})
+	}
+
+	var logged []*LoggedActionModel
+	err = common.GORM.Where("guild_id = ? AND user_id = ?", guildID, userID).Find(&logged).Error
+	if err != nil {
+		return nil, errors.WithStackIf(err)
+	}
+
+	for _, v := range logged {
+		record.Entries = append(record.Entries, &UserRecordEntry{
+			Time:        v.CreatedAt,
+			Type:        "logged: " + v.Action,
+			Moderator:   v.AuthorUsernameDiscrim,
+			ModeratorID: v.AuthorID,
+			Reason:      v.Reason,
+			Source:      "logged actions",
+		})
+	}
+
+	addModlogRecordEntries(record, modlogEntries)
+	return record, nil
+}
+
+// addModlogRecordEntries adds the modlog entries targeting the user and sorts the record oldest first
+func addModlogRecordEntries(record *UserRecord, entries []*ModlogExportEntry) {
+	for _, v := range entries {
+		if v.TargetID != record.UserID {
+			continue
+		}
+
+		record.Entries = append(record.Entries, &UserRecordEntry{
+			Time:        v.Time,
+			Type:        v.Action,
+			Moderator:   v.Moderator,
+			ModeratorID: v.ModeratorID,
+			Reason:      v.Reason,
+			Source:      "modlog",
+		})
+	}
+
+	sort.SliceStable(record.Entries, func(i, j int) bool {
+		return record.Entries[i].Time.Before(record.Entries[j].Time)
+	})
+}
+
+// encodeUserRecord encodes the record in the format, either text or json
+func encodeUserRecord(record *UserRecord, format string) (*bytes.Buffer, error) {
+	var buf bytes.Buffer
+	if format == "json" {
+		enc := json.NewEncoder(&buf)
+		enc.SetIndent("", "  ")
+		err := enc.Encode(record)
+		return &buf, err
+	}
+
+	fmt.Fprintf(&buf, "Moderation record of user %d in server %d\nCreated: %s\nEntries: %d\n",
+		record.UserID, record.GuildID, record.Created.UTC().Format(time.RFC3339), len(record.Entries))
+
+	for _, v := range record.Entries {
+		fmt.Fprintf(&buf, "\n[%s] %s (from %s)\n", v.Time.UTC().Format(time.RFC3339), v.Type, v.Source)
+
+		if v.Moderator != "" || v.ModeratorID != 0 {
+			moderator := v.Moderator
+			if v.ModeratorID != 0 && moderator == "" {
+				moderator = fmt.Sprintf("ID %d", v.ModeratorID)
+			} else if v.ModeratorID != 0 {
+				moderator = fmt.Sprintf("%s (ID %d)", moderator, v.ModeratorID)
+			}
+			fmt.Fprintf(&buf, "Moderator: %s\n", moderator)
+		}
+
+		if v.ExpiresAt != nil {
+			fmt.Fprintf(&buf, "Expires: %s\n", v.ExpiresAt.UTC().Format(time.RFC3339))
+		}
+
+		reason := v.Reason
+		if reason == "" {
+			reason = "(none)"
+		}
+		fmt.Fprintf(&buf, "Reason: %s\n", reason)
+	}
+
+	return &buf, nil
+}
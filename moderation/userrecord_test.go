@@ -0,0 +1,61 @@
+package moderation
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAddModlogRecordEntries(t *testing.T) {
+	now := time.Now()
+	record := &UserRecord{UserID: 10, Entries: []*UserRecordEntry{
+		{Time: now.Add(-time.Hour), Type: "warning", Reason: "spam", Source: "warnings"},
+	}}
+
+	addModlogRecordEntries(record, []*ModlogExportEntry{
+		{Time: now, Action: "👢Kicked someone#1234 (ID 10)", TargetID: 10, ModeratorID: 5, Reason: "more spam"},
+		{Time: now.Add(-time.Hour * 2), Action: "🔨Banned other#1234 (ID 11)", TargetID: 11},
+		{Time: now.Add(-time.Hour * 3), Action: "🔇Muted someone#1234 (ID 10)", TargetID: 10},
+	})
+
+	if len(record.Entries) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(record.Entries))
+	}
+
+	if record.Entries[0].Source != "modlog" || record.Entries[1].Type != "warning" || record.Entries[2].Reason != "more spam" {
+		t.Errorf("entries not sorted oldest first: %+v %+v %+v", record.Entries[0], record.Entries[1], record.Entries[2])
+	}
+}
+
+func TestEncodeUserRecord(t *testing.T) {
+	expires := time.Now().Add(time.Hour)
+	record := &UserRecord{GuildID: 1, UserID: 10, Created: time.Now(), Entries: []*UserRecordEntry{
+		{Time: time.Now(), Type: "mute", ModeratorID: 5, ExpiresAt: &expires, Source: "active mutes"},
+	}}
+
+	buf, err := encodeUserRecord(record, "text")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	text := buf.String()
+	for _, v := range []string{"user 10 in server 1", "mute (from active mutes)", "Moderator: ID 5", "Expires: ", "Reason: (none)"} {
+		if !strings.Contains(text, v) {
+			t.Errorf("text record is missing %q:\n%s", v, text)
+		}
+	}
+
+	buf, err = encodeUserRecord(record, "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded UserRecord
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.UserID != 10 || len(decoded.Entries) != 1 || decoded.Entries[0].ModeratorID != 5 {
+		t.Errorf("unexpected decoded record: %+v", decoded)
+	}
+}
@@ -0,0 +1,46 @@
+package moderation
+
+import (
+	"strings"
+)
+
+// The max number of warning categories a server can configure
+const MaxWarnCategories = 25
+
+// WarnCategoryList returns the configured warning categories
+func (c *Config) WarnCategoryList() []string {
+	var result []string
+	for _, v := range strings.Split(c.WarnCategories, ",") {
+		v = strings.TrimSpace(v)
+		if v != "" {
+			result = append(result, v)
+		}
+	}
+
+	return result
+}
+
+// matchWarnCategory returns the configured category matching the name case insensitively, false if there's none
+func (c *Config) matchWarnCategory(name string) (string, bool) {
+	name = strings.TrimSpace(name)
+	for _, v := range c.WarnCategoryList() {
+		if strings.EqualFold(v, name) {
+			return v, true
+		}
+	}
+
+	return "", false
+}
+
+// warnCategoryErr returns the response for an unknown category, listing the configured ones
+func (c *Config) warnCategoryErr(name string) string {
+	categories := c.WarnCategoryList()
+	if len(categories) < 1 {
+		return "No warning categories set up, add them in the control panel"
+	}
+
+	return "Unknown warning category `" + name + "`, available categories: `" + strings.Join(categories, "`, `") + "`"
+}
+
+// Escapes the wildcards of LIKE patterns, postgres uses \ as the escape character by default
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
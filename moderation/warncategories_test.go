@@ -0,0 +1,24 @@
+package moderation
+
+import "testing"
+
+func TestMatchWarnCategory(t *testing.T) {
+	config := &Config{WarnCategories: "Spam, toxicity ,, NSFW"}
+
+	list := config.WarnCategoryList()
+	if len(list) != 3 || list[0] != "Spam" || list[1] != "toxicity" || list[2] != "NSFW" {
+		t.Fatalf("unexpected categories: %q", list)
+	}
+
+	if got, ok := config.matchWarnCategory(" spam"); !ok || got != "Spam" {
+		t.Errorf("expected to match Spam, got %q, %t", got, ok)
+	}
+
+	if _, ok := config.matchWarnCategory("raiding"); ok {
+		t.Error("matched a category that isn't configured")
+	}
+
+	if _, ok := (&Config{}).matchWarnCategory(""); ok {
+		t.Error("matched a category without any configured")
+	}
+}
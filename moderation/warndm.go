@@ -0,0 +1,47 @@
+package moderation
+
+import (
+	"strings"
+
+	"github.com/jonas747/discordgo"
+	"github.com/jonas747/dstate"
+	"github.com/jonas747/yagpdb/bot"
+)
+
+// warnDMData is the template data warning DMs get on top of the data of every punishment DM
+func warnDMData(config *Config, count int, category string) map[string]interface{} {
+	return map[string]interface{}{
+		"WarningCount":       count,
+		"Category":           category,
+		"AppealInstructions": config.WarnAppealInstructions,
+	}
+}
+
+// warnDMStatus describes the outcome of sending the warning DM for the modlog
+func warnDMStatus(executed string, err error) string {
+	if strings.TrimSpace(executed) == "" {
+		return "no DM sent, the template is empty"
+	}
+
+	if err != nil {
+		return "couldn't DM them"
+	}
+
+	return "DM delivered"
+}
+
+// sendWarnDM sends the warning DM with the user's warning count and the appeal instructions, returning how it went.
+// Unlike the other punishment DMs it's sent right away, so the outcome can be noted in the modlog.
+func sendWarnDM(config *Config, gs *dstate.GuildState, channel *dstate.ChannelState, message *discordgo.Message, author *discordgo.User, member *dstate.MemberState, reason string, category string, count int) string {
+	executed, err := executePunishDM(config.WarnMessage, MAWarned, gs, channel, message, author, member, -1, reason, warnDMData(config, count, category))
+	if err != nil {
+		logger.WithError(err).WithField("guild", gs.ID).Warn("Failed executing pusnishment DM")
+		executed = "Failed executing template."
+	}
+
+	if strings.TrimSpace(executed) != "" {
+		err = bot.SendDM(member.ID, "**"+bot.GuildName(gs.ID)+":** "+executed)
+	}
+
+	return warnDMStatus(executed, err)
+}
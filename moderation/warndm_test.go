@@ -0,0 +1,31 @@
+package moderation
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestWarnDMStatus(t *testing.T) {
+	cases := []struct {
+		executed string
+		err      error
+		want     string
+	}{
+		{"You have been warned", nil, "DM delivered"},
+		{"You have been warned", errors.New("cannot send messages to this user"), "couldn't DM them"},
+		{" \n", nil, "no DM sent, the template is empty"},
+	}
+
+	for _, c := range cases {
+		if got := warnDMStatus(c.executed, c.err); got != c.want {
+			t.Errorf("warnDMStatus(%q, %v): got %q, expected %q", c.executed, c.err, got, c.want)
+		}
+	}
+}
+
+func TestWarnDMData(t *testing.T) {
+	data := warnDMData(&Config{WarnAppealInstructions: "Appeal at example.com"}, 3, "spam")
+	if data["WarningCount"] != 3 || data["Category"] != "spam" || data["AppealInstructions"] != "Appeal at example.com" {
+		t.Errorf("unexpected warning dm data %v", data)
+	}
+}
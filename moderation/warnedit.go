@@ -0,0 +1,41 @@
+package moderation
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/jinzhu/gorm"
+	"github.com/jonas747/discordgo"
+	"github.com/jonas747/yagpdb/common"
+)
+
+// editWarning replaces the message of the warning, returning false if it doesn't exist.
+// If enabled, the edit is posted in the modlog with the text before and after.
+func editWarning(config *Config, guildID int64, author *discordgo.User, warningID int, newMessage string) (found bool, err error) {
+	var warning WarningModel
+	err = common.GORM.Where("guild_id = ? AND id = ?", guildID, warningID).First(&warning).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return false, nil
+		}
+		return false, common.ErrWithCaller(err)
+	}
+
+	updated := fmt.Sprintf("%s (updated by %s#%s (%d))", newMessage, author.Username, author.Discriminator, author.ID)
+	err = common.GORM.Model(&warning).Update("message", updated).Error
+	if err != nil {
+		return true, common.ErrWithCaller(err)
+	}
+
+	if !config.WarnEditsToModlog {
+		return true, nil
+	}
+
+	userID, _ := strconv.ParseInt(warning.UserID, 10, 64)
+	return true, CreateModlogEmbed(config, author, MAWarningEdited, altUser(guildID, userID), warnEditReason(warningID, warning.Message, newMessage), "")
+}
+
+// warnEditReason is what the modlog entry for an edited warning shows as the reason
+func warnEditReason(warningID int, before, after string) string {
+	return fmt.Sprintf("Warning #%d: %s ➜ %s", warningID, common.CutStringShort(before, 700), common.CutStringShort(after, 700))
+}
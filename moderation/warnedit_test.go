@@ -0,0 +1,58 @@
+package moderation
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jonas747/discordgo"
+	"github.com/jonas747/yagpdb/common"
+	"github.com/jonas747/yagpdb/common/configstore"
+)
+
+func TestWarnEditReason(t *testing.T) {
+	if got := warnEditReason(5, "spam", "spamming links"); got != "Warning #5: spam ➜ spamming links" {
+		t.Errorf("unexpected reason %q", got)
+	}
+
+	got := warnEditReason(5, strings.Repeat("a", 1000), "b")
+	if len([]rune(got)) > 750 {
+		t.Errorf("expected the old text to be cut, got %d characters", len([]rune(got)))
+	}
+}
+
+func TestEditWarning(t *testing.T) {
+	if common.GORM == nil {
+		t.Skip("db not available, skipping.")
+		return
+	}
+
+	warning := &WarningModel{GuildID: 1, UserID: "6", AuthorID: "3", Message: "spam"}
+	err := common.GORM.Create(warning).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer common.GORM.Where("guild_id = 1 AND user_id = '6'").Delete(WarningModel{})
+
+	config := &Config{GuildConfigModel: configstore.GuildConfigModel{GuildID: 1}}
+	author := &discordgo.User{ID: 3, Username: "mod", Discriminator: "0001"}
+
+	found, err := editWarning(config, 1, author, int(warning.ID)+1000000, "spamming")
+	if err != nil || found {
+		t.Errorf("expected a missing warning, got %t, %v", found, err)
+	}
+
+	found, err = editWarning(config, 1, author, int(warning.ID), "spamming")
+	if err != nil || !found {
+		t.Fatalf("expected the warning to be edited, got %t, %v", found, err)
+	}
+
+	var updated WarningModel
+	err = common.GORM.Where("id = ?", warning.ID).First(&updated).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if updated.Message != "spamming (updated by mod#0001 (3))" {
+		t.Errorf("unexpected message %q", updated.Message)
+	}
+}
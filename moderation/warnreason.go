@@ -0,0 +1,72 @@
+package moderation
+
+import (
+	"bytes"
+	"strings"
+	"text/template"
+
+	"github.com/jonas747/discordgo"
+)
+
+// WarnReasonData is what's available in the warning reason template
+type WarnReasonData struct {
+	Reason string
+
+	TargetMention string
+	TargetName    string
+	TargetID      int64
+
+	AuthorMention string
+	AuthorName    string
+
+	// The number of warnings the user has including this one
+	Count int
+}
+
+func parseWarnReasonTemplate(tmpl string) (*template.Template, error) {
+	return template.New("").Parse(tmpl)
+}
+
+// validateWarnReasonTemplate parses the template and does a test run, catching references to fields that don't exist
+func validateWarnReasonTemplate(tmpl string) error {
+	parsed, err := parseWarnReasonTemplate(tmpl)
+	if err != nil {
+		return err
+	}
+
+	return parsed.Execute(&bytes.Buffer{}, &WarnReasonData{})
+}
+
+// renderWarnReason renders the warning reason template, the reason is returned as is if there's no template or it fails
+func renderWarnReason(config *Config, author, target *discordgo.User, reason string, count int) string {
+	if strings.TrimSpace(config.WarnReasonTemplate) == "" {
+		return reason
+	}
+
+	tmpl, err := parseWarnReasonTemplate(config.WarnReasonTemplate)
+	if err != nil {
+		return reason
+	}
+
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, &WarnReasonData{
+		Reason:        reason,
+		TargetMention: target.Mention(),
+		TargetName:    target.Username + "#" + target.Discriminator,
+		TargetID:      target.ID,
+		AuthorMention: author.Mention(),
+		AuthorName:    author.Username + "#" + author.Discriminator,
+		Count:         count,
+	})
+	if err != nil {
+		logger.WithError(err).WithField("guild", config.GetGuildID()).Debug("failed rendering warning reason template")
+		return reason
+	}
+
+	rendered := strings.TrimSpace(buf.String())
+	if rendered == "" {
+		return reason
+	}
+
+	return rendered
+}
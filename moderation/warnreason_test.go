@@ -0,0 +1,40 @@
+package moderation
+
+import (
+	"testing"
+
+	"github.com/jonas747/discordgo"
+)
+
+func TestRenderWarnReason(t *testing.T) {
+	author := &discordgo.User{ID: 1, Username: "mod", Discriminator: "0001"}
+	target := &discordgo.User{ID: 2, Username: "user", Discriminator: "0002"}
+
+	config := &Config{WarnReasonTemplate: "{{.Reason}} ({{.TargetName}}, warning #{{.Count}})"}
+	if got := renderWarnReason(config, author, target, "spamming", 3); got != "spamming (user#0002, warning #3)" {
+		t.Errorf("unexpected rendered reason: %q", got)
+	}
+
+	if got := renderWarnReason(&Config{}, author, target, "spamming", 3); got != "spamming" {
+		t.Errorf("reason changed without a template: %q", got)
+	}
+
+	broken := &Config{WarnReasonTemplate: "{{.Nope}}"}
+	if got := renderWarnReason(broken, author, target, "spamming", 3); got != "spamming" {
+		t.Errorf("reason changed with a failing template: %q", got)
+	}
+}
+
+func TestValidateWarnReasonTemplate(t *testing.T) {
+	if err := validateWarnReasonTemplate("{{.Reason}} #{{.Count}}"); err != nil {
+		t.Errorf("valid template failed: %v", err)
+	}
+
+	if err := validateWarnReasonTemplate("{{.Reason"); err == nil {
+		t.Error("template with a syntax error passed")
+	}
+
+	if err := validateWarnReasonTemplate("{{.Nope}}"); err == nil {
+		t.Error("template with an unknown field passed")
+	}
+}
@@ -0,0 +1,103 @@
+package moderation
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jonas747/discordgo"
+	"github.com/jonas747/yagpdb/bot"
+	"github.com/jonas747/yagpdb/common"
+	"github.com/jonas747/yagpdb/common/scheduledevents2"
+	seventsmodels "github.com/jonas747/yagpdb/common/scheduledevents2/models"
+)
+
+// Once a user reaches WarnReviewThreshold warnings, staff are reminded in the report channel to review them
+// after WarnReviewDelayMinutes, giving a chance to step in before they collect enough for harsher actions.
+
+// The number of recent warnings listed in the review reminder
+const warnReviewListed = 5
+
+type ScheduledWarnReviewData struct {
+	UserID int64 `json:"user_id"`
+}
+
+// scheduleWarnReview schedules the review reminder if the user just reached the review threshold
+func scheduleWarnReview(config *Config, guildID, userID int64, count int) {
+	if config.WarnReviewThreshold < 1 || count != config.WarnReviewThreshold || config.IntReportChannel() == 0 {
+		return
+	}
+
+	runAt := time.Now().Add(time.Duration(config.WarnReviewDelayMinutes) * time.Minute)
+	err := scheduledevents2.ScheduleEvent("moderation_warn_review", guildID, runAt, &ScheduledWarnReviewData{UserID: userID})
+	if err != nil {
+		logger.WithError(err).WithField("guild", guildID).Error("failed scheduling warning review")
+	}
+}
+
+// warnReviewMessage builds the reminder, warnings are the most recent ones first
+func warnReviewMessage(user *discordgo.User, count int, warnings []*WarningModel, now time.Time) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "📋 **%s#%s** (ID %d) has %d warnings, consider reviewing them before any further action.",
+		user.Username, user.Discriminator, user.ID, count)
+
+	if len(warnings) > 0 {
+		b.WriteString("\nMost recent:")
+	}
+
+	for _, v := range warnings {
+		fmt.Fprintf(&b, "\n`#%d` %s - by %s, %s ago", v.ID, SanitizeReason(v.Message), v.AuthorUsernameDiscrim,
+			common.HumanizeDuration(common.DurationPrecisionMinutes, now.Sub(v.CreatedAt)))
+	}
+
+	return common.CutStringShort(b.String(), 2000)
+}
+
+func handleScheduledWarnReview(evt *seventsmodels.ScheduledEvent, data interface{}) (retry bool, err error) {
+	reviewData := data.(*ScheduledWarnReviewData)
+
+	config, err := GetConfig(evt.GuildID)
+	if err != nil {
+		return true, err
+	}
+
+	channelID := config.IntReportChannel()
+	if channelID == 0 || config.WarnReviewThreshold < 1 {
+		return false, nil
+	}
+
+	var warnings []*WarningModel
+	err = common.GORM.Where("guild_id = ? AND user_id = ?", evt.GuildID, discordgo.StrID(reviewData.UserID)).Order("id desc").Find(&warnings).Error
+	if err != nil {
+		return true, err
+	}
+
+	// warnings were cleared in the meantime, nothing to review anymore
+	if len(warnings) < config.WarnReviewThreshold {
+		return false, nil
+	}
+
+	user := &discordgo.User{ID: reviewData.UserID, Username: "unknown", Discriminator: "????"}
+	if member, _ := bot.GetMember(evt.GuildID, reviewData.UserID); member != nil {
+		user = member.DGoUser()
+	}
+
+	listed := warnings
+	if len(listed) > warnReviewListed {
+		listed = listed[:warnReviewListed]
+	}
+
+	msg := warnReviewMessage(user, len(warnings), listed, time.Now())
+
+	allowedMentions := discordgo.AllowedMentions{}
+	if mentionRole := config.IntReportMentionRole(); mentionRole != 0 {
+		msg = fmt.Sprintf("<@&%d> ", mentionRole) + msg
+		allowedMentions.Roles = []int64{mentionRole}
+	}
+
+	_, err = session().ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+		Content:         msg,
+		AllowedMentions: allowedMentions,
+	})
+	return scheduledevents2.CheckDiscordErrRetry(err), err
+}
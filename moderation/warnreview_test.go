@@ -0,0 +1,33 @@
+package moderation
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jonas747/discordgo"
+	"github.com/jonas747/yagpdb/common"
+)
+
+func TestWarnReviewMessage(t *testing.T) {
+	now := time.Now()
+	user := &discordgo.User{ID: 4, Username: "user", Discriminator: "0002"}
+	warnings := []*WarningModel{
+		{SmallModel: common.SmallModel{ID: 12, CreatedAt: now.Add(-2 * time.Hour)}, Message: "spam @everyone", AuthorUsernameDiscrim: "mod#0001"},
+		{SmallModel: common.SmallModel{ID: 9, CreatedAt: now.Add(-48 * time.Hour)}, Message: "slurs", AuthorUsernameDiscrim: "mod#0001"},
+	}
+
+	msg := warnReviewMessage(user, 2, warnings, now)
+
+	if !strings.HasPrefix(msg, "📋 **user#0002** (ID 4) has 2 warnings") {
+		t.Errorf("unexpected header in %q", msg)
+	}
+
+	if !strings.Contains(msg, "`#12` spam @\u200beveryone - by mod#0001, 2 hours ago") {
+		t.Errorf("expected the sanitized latest warning in %q", msg)
+	}
+
+	if strings.Index(msg, "#12") > strings.Index(msg, "#9") {
+		t.Errorf("expected the most recent warning first in %q", msg)
+	}
+}